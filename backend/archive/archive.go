@@ -0,0 +1,235 @@
+// Package archive browses the events kept in the database after the
+// scheduler has moved on to a new one, and pins the dashboard to one of them.
+package archive
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// PinnedEventKey is the server_settings key holding the PocketBase ID of the
+// event the dashboard shows instead of the current one. The pin only changes
+// what viewers see: isCurrent and the scheduler keep following the live event.
+const PinnedEventKey = "dashboard.pinnedEvent"
+
+// PilotRef names a pilot.
+type PilotRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// FastestLap is the quickest valid racing lap of an event.
+type FastestLap struct {
+	Seconds float64  `json:"seconds"`
+	Pilot   PilotRef `json:"pilot"`
+	Race    string   `json:"race"`
+}
+
+// EventSummary describes a stored event.
+type EventSummary struct {
+	ID        string `json:"id"`
+	SourceID  string `json:"sourceId"`
+	Source    string `json:"source"`
+	Name      string `json:"name"`
+	Start     string `json:"start"`
+	IsCurrent bool   `json:"isCurrent"`
+	Pinned    bool   `json:"pinned"`
+	// Pilots in the event, not counting removed ones.
+	Pilots int `json:"pilots"`
+	// Races that were run and are valid.
+	Races int `json:"races"`
+	// FirstRaceMs and LastRaceMs bound the races that were run.
+	FirstRaceMs int64       `json:"firstRaceMs"`
+	LastRaceMs  int64       `json:"lastRaceMs"`
+	FastestLap  *FastestLap `json:"fastestLap"`
+	// Winner took first place in the last race with results, normally the final.
+	Winner *PilotRef `json:"winner"`
+}
+
+// DefaultListLimit and MaxListLimit bound a page of List.
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+// List summarizes a page of the stored events, most recently raced first;
+// events with no races yet come last. The order is decided in one query, so
+// only the events of the page are summarized.
+func List(app core.App, limit, offset int) ([]EventSummary, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	limit = min(limit, MaxListLimit)
+	offset = max(offset, 0)
+
+	var ids []string
+	if err := app.DB().NewQuery(`
+        SELECT e.id
+        FROM events e
+        LEFT JOIN (
+            SELECT event, MAX(MAX(endEpoch), MAX(startEpoch)) AS lastMs
+            FROM races
+            WHERE valid = 1 AND startEpoch > 0
+            GROUP BY event
+        ) r ON r.event = e.id
+        ORDER BY COALESCE(r.lastMs, 0) DESC, e.name ASC, e.id ASC
+        LIMIT {:limit} OFFSET {:offset}
+    `).Bind(dbx.Params{"limit": limit, "offset": offset}).Column(&ids); err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	events, err := app.FindRecordsByIds("events", ids)
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	byID := make(map[string]*core.Record, len(events))
+	for _, ev := range events {
+		byID[ev.Id] = ev
+	}
+
+	pinned := Pinned(app)
+	out := make([]EventSummary, 0, len(ids))
+	for _, id := range ids {
+		ev, ok := byID[id]
+		if !ok {
+			continue
+		}
+		s, err := summarize(app, ev, pinned)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Summary summarizes one event by its PocketBase ID.
+func Summary(app core.App, eventID string) (EventSummary, error) {
+	ev, err := app.FindRecordById("events", eventID)
+	if err != nil {
+		return EventSummary{}, err
+	}
+	return summarize(app, ev, Pinned(app))
+}
+
+func summarize(app core.App, ev *core.Record, pinned string) (EventSummary, error) {
+	s := EventSummary{
+		ID:        ev.Id,
+		SourceID:  ev.GetString("sourceId"),
+		Source:    ev.GetString("source"),
+		Name:      ev.GetString("name"),
+		Start:     ev.GetString("start"),
+		IsCurrent: ev.GetBool("isCurrent"),
+		Pinned:    ev.Id == pinned,
+	}
+	params := dbx.Params{"e": ev.Id}
+
+	if err := app.DB().NewQuery(`
+        SELECT COUNT(*) FROM event_pilots
+        WHERE event = {:e} AND (removed IS NULL OR removed = 0)
+    `).Bind(params).Row(&s.Pilots); err != nil {
+		return s, fmt.Errorf("count pilots of %s: %w", ev.Id, err)
+	}
+
+	if err := app.DB().NewQuery(`
+        SELECT COUNT(*), COALESCE(MIN(startEpoch), 0), COALESCE(MAX(MAX(endEpoch), MAX(startEpoch)), 0)
+        FROM races
+        WHERE event = {:e} AND valid = 1 AND startEpoch > 0
+    `).Bind(params).Row(&s.Races, &s.FirstRaceMs, &s.LastRaceMs); err != nil {
+		return s, fmt.Errorf("count races of %s: %w", ev.Id, err)
+	}
+
+	var lap struct {
+		Seconds   float64 `db:"seconds"`
+		PilotID   string  `db:"pilotId"`
+		PilotName string  `db:"pilotName"`
+		RaceID    string  `db:"raceId"`
+	}
+	err := app.DB().NewQuery(`
+        SELECT l.lengthSeconds AS seconds, p.id AS pilotId, p.name AS pilotName, r.id AS raceId
+        FROM laps l
+        JOIN detections d ON d.id = l.detection
+        JOIN races r ON r.id = l.race
+        JOIN pilots p ON p.id = d.pilot
+        WHERE l.event = {:e} AND l.lengthSeconds > 0
+          AND d.valid = 1 AND d.isHoleshot = 0 AND r.valid = 1
+        ORDER BY l.lengthSeconds ASC
+        LIMIT 1
+    `).Bind(params).One(&lap)
+	switch {
+	case err == nil:
+		s.FastestLap = &FastestLap{Seconds: lap.Seconds, Pilot: PilotRef{ID: lap.PilotID, Name: lap.PilotName}, Race: lap.RaceID}
+	case !errors.Is(err, sql.ErrNoRows):
+		return s, fmt.Errorf("fastest lap of %s: %w", ev.Id, err)
+	}
+
+	var winner struct {
+		ID   string `db:"id"`
+		Name string `db:"name"`
+	}
+	err = app.DB().NewQuery(`
+        SELECT p.id AS id, p.name AS name
+        FROM results res
+        JOIN races r ON r.id = res.race
+        JOIN pilots p ON p.id = res.pilot
+        WHERE res.event = {:e} AND res.position = 1 AND r.valid = 1
+        ORDER BY r.raceOrder DESC, r.endEpoch DESC
+        LIMIT 1
+    `).Bind(params).One(&winner)
+	switch {
+	case err == nil:
+		s.Winner = &PilotRef{ID: winner.ID, Name: winner.Name}
+	case !errors.Is(err, sql.ErrNoRows):
+		return s, fmt.Errorf("winner of %s: %w", ev.Id, err)
+	}
+	return s, nil
+}
+
+// Pinned returns the PocketBase ID of the pinned event, or "" when the
+// dashboard follows the current one.
+func Pinned(app core.App) string {
+	rec, err := app.FindFirstRecordByData("server_settings", "key", PinnedEventKey)
+	if err != nil || rec == nil {
+		return ""
+	}
+	return rec.GetString("value")
+}
+
+// Pin shows eventID on the dashboard until Unpin. The event must exist.
+func Pin(app core.App, eventID string) error {
+	if _, err := app.FindRecordById("events", eventID); err != nil {
+		return err
+	}
+	rec, _ := app.FindFirstRecordByData("server_settings", "key", PinnedEventKey)
+	if rec == nil {
+		col, err := app.FindCollectionByNameOrId("server_settings")
+		if err != nil {
+			return err
+		}
+		rec = core.NewRecord(col)
+		rec.Set("key", PinnedEventKey)
+	}
+	rec.Set("value", eventID)
+	if err := app.Save(rec); err != nil {
+		return err
+	}
+	slog.Info("archive.pin", "event", eventID)
+	return nil
+}
+
+// Unpin returns the dashboard to the current event.
+func Unpin(app core.App) error {
+	rec, _ := app.FindFirstRecordByData("server_settings", "key", PinnedEventKey)
+	if rec == nil {
+		return nil
+	}
+	if err := app.Delete(rec); err != nil {
+		return err
+	}
+	slog.Info("archive.unpin", "event", rec.GetString("value"))
+	return nil
+}
@@ -0,0 +1,120 @@
+package archive
+
+import (
+	"testing"
+	"time"
+
+	"drone-dashboard/ingest"
+	"drone-dashboard/ingest/ingesttest"
+	_ "drone-dashboard/migrations"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func TestListSummarizesEvents(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	ev := ingesttest.NewEvent(t, app, ingest.ManualEvent{Name: "June practice", Laps: 2}, "Zippy", "Bolt")
+	eventId := ev.ID
+	raceId := ev.Race(t, 0, 0, 1)
+	// a second race that never ran
+	ev.Race(t, 0, 0)
+	start := time.Date(2025, 6, 3, 19, 0, 0, 0, time.UTC)
+	// the holeshot is quicker than any lap but is not one
+	ev.Fly(t, raceId, start, ingesttest.Flight{Holeshot: 1.5, Laps: []float64{30, 28.25}}, ingesttest.Flight{Holeshot: 2, Laps: []float64{29, 31}})
+	ev.Finish(t, raceId, 1, 0)
+	empty := ingesttest.NewEvent(t, app, ingest.ManualEvent{Name: "Cancelled"}).ID
+
+	events, err := List(app, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].SourceID != eventId || events[1].SourceID != empty {
+		t.Fatalf("events = %+v", events)
+	}
+	s := events[0]
+	if s.Pilots != 2 || s.Races != 1 || s.FirstRaceMs != start.UnixMilli() || s.LastRaceMs != start.UnixMilli()+62000 {
+		t.Fatalf("summary = %+v", s)
+	}
+	zippy := ev.Pilot(t, 0)
+	if s.FastestLap == nil || s.FastestLap.Seconds != 28.25 || s.FastestLap.Pilot.ID != zippy.Id {
+		t.Fatalf("fastest lap = %+v", s.FastestLap)
+	}
+	if s.Winner == nil || s.Winner.Name != "Bolt" {
+		t.Fatalf("winner = %+v", s.Winner)
+	}
+	if events[1].FastestLap != nil || events[1].Winner != nil || events[1].Races != 0 {
+		t.Fatalf("empty event = %+v", events[1])
+	}
+}
+
+func TestListPagesByLastRace(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	// the weekend started first but raced last
+	day := time.Date(2025, 6, 7, 10, 0, 0, 0, time.UTC)
+	weekend := ingesttest.NewEvent(t, app, ingest.ManualEvent{Name: "Weekend"}, "Zippy")
+	weekend.Fly(t, weekend.Race(t, 0, 0), day, ingesttest.Flight{Laps: []float64{30}})
+	weekend.Fly(t, weekend.Race(t, 0, 0), day.Add(30*time.Hour), ingesttest.Flight{Laps: []float64{30}})
+	evening := ingesttest.NewEvent(t, app, ingest.ManualEvent{Name: "Evening"}, "Bolt")
+	evening.Fly(t, evening.Race(t, 0, 0), day.Add(8*time.Hour), ingesttest.Flight{Laps: []float64{30}})
+	empty := ingesttest.NewEvent(t, app, ingest.ManualEvent{Name: "Cancelled"})
+
+	var got []string
+	for offset := 0; offset < 4; offset += 2 {
+		page, err := List(app, 2, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range page {
+			got = append(got, s.SourceID)
+		}
+	}
+	if len(got) != 3 || got[0] != weekend.ID || got[1] != evening.ID || got[2] != empty.ID {
+		t.Fatalf("pages = %v", got)
+	}
+}
+
+func TestPinFollowsDeletedEvent(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+	RegisterRoutes(app)
+
+	ev := ingesttest.NewEvent(t, app, ingest.ManualEvent{Name: "Last season"}).Record
+	if err := Pin(app, "missing"); err == nil {
+		t.Fatal("pinning an unknown event should fail")
+	}
+	if err := Pin(app, ev.Id); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Summary(app, ev.Id)
+	if err != nil || !s.Pinned || s.IsCurrent {
+		t.Fatalf("pinned summary = %+v, %v", s, err)
+	}
+
+	// channels reference the event; remove them first like a purge does
+	channels, _ := app.FindRecordsByFilter("channels", "event = {:e}", "", 0, 0, dbx.Params{"e": ev.Id})
+	for _, ch := range channels {
+		if err := app.Delete(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.Delete(ev); err != nil {
+		t.Fatal(err)
+	}
+	if p := Pinned(app); p != "" {
+		t.Fatalf("pin left on deleted event: %q", p)
+	}
+}
@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterRoutes wires the archive endpoints: GET /archive/events, paged with
+// ?limit= and ?offset=, and GET /archive/events/{eventId} are public like the
+// collections they read;
+// POST /archive/pin with {"event": "<id>"} and DELETE /archive/pin are
+// admin-only. A pinned event that is deleted, e.g. by a purge, unpins itself.
+func RegisterRoutes(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/archive/events", func(c *core.RequestEvent) error {
+			q := c.Request.URL.Query()
+			limit, _ := strconv.Atoi(q.Get("limit"))
+			offset, _ := strconv.Atoi(q.Get("offset"))
+			events, err := List(c.App, limit, offset)
+			if err != nil {
+				return c.InternalServerError("list events failed", err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"events": events, "pinned": Pinned(c.App)})
+		})
+
+		se.Router.GET("/archive/events/{eventId}", func(c *core.RequestEvent) error {
+			summary, err := Summary(c.App, c.Request.PathValue("eventId"))
+			if err != nil {
+				return c.NotFoundError("event not found", err)
+			}
+			return c.JSON(http.StatusOK, summary)
+		})
+
+		se.Router.POST("/archive/pin", func(c *core.RequestEvent) error {
+			info, err := c.RequestInfo()
+			if err != nil || info.Auth == nil || !info.Auth.IsSuperuser() {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			var req struct {
+				Event string `json:"event"`
+			}
+			if err := c.BindBody(&req); err != nil || req.Event == "" {
+				return c.BadRequestError("body must be {\"event\": \"<id>\"}", err)
+			}
+			if err := Pin(c.App, req.Event); err != nil {
+				return c.NotFoundError("event not found", err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"ok": true, "pinned": req.Event})
+		})
+
+		se.Router.DELETE("/archive/pin", func(c *core.RequestEvent) error {
+			info, err := c.RequestInfo()
+			if err != nil || info.Auth == nil || !info.Auth.IsSuperuser() {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			if err := Unpin(c.App); err != nil {
+				return c.InternalServerError("unpin failed", err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"ok": true})
+		})
+
+		return se.Next()
+	})
+
+	app.OnRecordAfterDeleteSuccess("events").BindFunc(func(e *core.RecordEvent) error {
+		if Pinned(e.App) == e.Record.Id {
+			if err := Unpin(e.App); err != nil {
+				slog.Warn("archive.unpin.error", "event", e.Record.Id, "err", err)
+			}
+		}
+		return e.Next()
+	})
+}
@@ -10,6 +10,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+	// --venue-tz must resolve in containers without a zoneinfo database
+	_ "time/tzdata"
 
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
@@ -17,19 +20,33 @@ import (
 )
 
 type Flags struct {
-	FPVTrackside    string
-	FrontendDevURL  string
-	Port            int
-	LogLevel        string
-	IngestEnabled   bool
-	DirectProxy     bool
-	CloudURL        string
-	AuthToken       string
-	PitsID          string
-	DBDir           string
-	ImportSnapshot  string
-	UITitle         string
-	UITitleProvided bool
+	FPVTrackside     string
+	TimingSystem     string
+	RotorHazard      string
+	RotorHazardEvent string
+	VenueTZ          string
+	FrontendDevURL   string
+	Port             int
+	LogLevel         string
+	IngestEnabled    bool
+	DirectProxy      bool
+	CloudURL         string
+	AuthToken        string
+	RequireSigning   bool
+	PitsID           string
+	RemoteCommands   string
+	FetchConcurrency int
+	FetchCacheTTL    time.Duration
+	MetricsToken     string
+	DBDir            string
+	ImportSnapshot   string
+	Replay           string
+	ReplaySpeed      float64
+	CaptureDir       string
+	CaptureMaxMB     int
+	CaptureKeep      int
+	UITitle          string
+	UITitleProvided  bool
 }
 
 func ParseFlags() Flags {
@@ -38,6 +55,10 @@ func ParseFlags() Flags {
 	fs.SetOutput(io.Discard)
 
 	fs.StringVar(&out.FPVTrackside, "fpvtrackside", "http://localhost:8080", "FPVTrackside API endpoint")
+	fs.StringVar(&out.TimingSystem, "timing-system", "fpvtrackside", "Timing system to ingest from: fpvtrackside|rotorhazard")
+	fs.StringVar(&out.RotorHazard, "rotorhazard", "http://localhost:5000", "RotorHazard server URL (with --timing-system=rotorhazard)")
+	fs.StringVar(&out.RotorHazardEvent, "rotorhazard-event", "", "Event ID for RotorHazard data; use a new one per event (default: rotorhazard)")
+	fs.StringVar(&out.VenueTZ, "venue-tz", "", "IANA time zone the timing system writes times in, e.g. Europe/Berlin (default: this process's zone)")
 	fs.StringVar(&out.FrontendDevURL, "frontend-dev-url", "", "Proxy frontend requests to this dev server URL (e.g. http://localhost:5173)")
 	fs.IntVar(&out.Port, "port", 3000, "Server port")
 	fs.StringVar(&out.LogLevel, "log-level", "info", "Log level: error|warn|info|debug|trace")
@@ -46,9 +67,19 @@ func ParseFlags() Flags {
 
 	fs.StringVar(&out.CloudURL, "cloud-url", "", "Cloud WS URL (pits mode)")
 	fs.StringVar(&out.AuthToken, "auth-token", "", "Auth token for control link")
-	fs.StringVar(&out.PitsID, "pits-id", "default", "Identifier for this pits instance")
+	fs.BoolVar(&out.RequireSigning, "require-signing", false, "Refuse control link peers that do not sign their frames")
+	fs.StringVar(&out.PitsID, "pits-id", "default", "Identifier for this pits instance (cloud mode: comma-separated list of accepted pits)")
+	fs.StringVar(&out.RemoteCommands, "remote-commands", "ingest.fullAuto,scheduler.setEnabled,scheduler.targets,control.fetchQueue", "Comma-separated cloud commands this pits accepts (pits mode; add ingest.purge to allow purges)")
+	fs.IntVar(&out.FetchConcurrency, "fetch-concurrency", 2, "Concurrent FPVTrackside fetches for cloud requests (pits mode)")
+	fs.DurationVar(&out.FetchCacheTTL, "fetch-cache-ttl", 500*time.Millisecond, "Reuse FPVTrackside responses this fresh for cloud requests (pits mode; 0 = only share concurrent fetches)")
+	fs.StringVar(&out.MetricsToken, "metrics-token", "", "Bearer token required on /metrics (empty = open)")
 	fs.StringVar(&out.DBDir, "db-dir", "", "Directory for SQLite database files (empty = in-memory)")
 	fs.StringVar(&out.ImportSnapshot, "import-snapshot", "", "Path to PB snapshot JSON to import at startup")
+	fs.StringVar(&out.Replay, "replay", "", "Ingest from a directory of captured FPVTrackside responses instead of FPVTrackside")
+	fs.Float64Var(&out.ReplaySpeed, "replay-speed", 1, "Replay clock speed relative to real time")
+	fs.StringVar(&out.CaptureDir, "capture-dir", "", "Record every distinct FPVTrackside response under this directory (empty = off)")
+	fs.IntVar(&out.CaptureMaxMB, "capture-max-mb", 256, "Start a new capture archive after this many MB (0 = never)")
+	fs.IntVar(&out.CaptureKeep, "capture-keep", 10, "Capture archives to keep (0 = all)")
 	uiTitle := fs.String("ui-title", "", "UI title shown in the browser tab (default: Drone Dashboard)")
 
 	showHelp := fs.Bool("help", false, "Show help message")
@@ -61,6 +92,9 @@ func ParseFlags() Flags {
 	if out.AuthToken == "" {
 		out.AuthToken = os.Getenv("AUTH_TOKEN")
 	}
+	if out.MetricsToken == "" {
+		out.MetricsToken = os.Getenv("METRICS_TOKEN")
+	}
 	fs.Visit(func(f *flag.Flag) {
 		if f.Name == "ui-title" {
 			out.UITitleProvided = true
@@ -75,6 +109,32 @@ func ParseFlags() Flags {
 	return out
 }
 
+// VenueLocation resolves --venue-tz; empty means this process's zone.
+func (f Flags) VenueLocation() (*time.Location, error) {
+	if f.VenueTZ == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(f.VenueTZ)
+}
+
+// PitsIDs splits the --pits-id flag into the distinct pits a cloud serves.
+func (f Flags) PitsIDs() []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, id := range strings.Split(f.PitsID, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		ids = []string{"default"}
+	}
+	return ids
+}
+
 func PreparePocketBaseArgs(flags Flags) []string {
 	return []string{"serve", "--http", fmt.Sprintf("0.0.0.0:%d", flags.Port)}
 }
@@ -125,6 +185,12 @@ Usage: %s [OPTIONS]
 
 Options:
   --fpvtrackside string    Set the FPVTrackside API endpoint (default: http://localhost:8080)
+  --timing-system string   Timing system to ingest from: fpvtrackside|rotorhazard (default: fpvtrackside)
+  --rotorhazard string     RotorHazard server URL (default: http://localhost:5000)
+  --rotorhazard-event str  Event ID for RotorHazard data; use a new one per event (default: rotorhazard)
+  --venue-tz string        IANA time zone the timing system writes times in, e.g. Europe/Berlin;
+                           a pits reports it to the cloud (default: this process's zone; set it
+                           when the server runs in UTC)
   --frontend-dev-url str   Proxy frontend requests to dev server (default: disabled)
   --port int               Set the server port (default: 3000)
   --log-level string       Log level: error|warn|info|debug|trace
@@ -134,12 +200,30 @@ Options:
 
   --cloud-url string       Cloud WebSocket URL (required for pits mode)
   --auth-token string      Authentication token (enables cloud or pits mode)
+  --require-signing        Refuse control link peers that do not sign their frames;
+                           set it once every pits and the cloud sign (default: false)
   --pits-id string         Identifier for this pits instance
+                           (cloud mode: comma-separated list, one event per pits)
+  --remote-commands str    Cloud commands this pits accepts (pits mode)
+                           (default: ingest.fullAuto,scheduler.setEnabled,scheduler.targets,
+                           control.fetchQueue; add ingest.purge to allow remote purges)
+  --fetch-concurrency int  Concurrent FPVTrackside fetches for cloud requests (pits mode, default: 2)
+  --fetch-cache-ttl dur    Reuse FPVTrackside responses this fresh for cloud requests
+                           (pits mode, default: 500ms; 0 = only share concurrent fetches)
+  --metrics-token string   Bearer token required on /metrics (empty = open)
   --db-dir string          Directory for SQLite database files (empty = in-memory)
+  --replay string          Ingest from a directory of captured FPVTrackside responses
+                           instead of FPVTrackside (standalone only)
+  --replay-speed float     Replay clock speed relative to real time (default: 1)
+  --capture-dir string     Record every distinct FPVTrackside response under this
+                           directory, in the layout --replay reads (default: off)
+  --capture-max-mb int     Start a new capture archive after this many MB (default: 256)
+  --capture-keep int       Capture archives to keep (default: 10; 0 = all)
   --help                   Show this help message
 
 Environment Variables:
   AUTH_TOKEN               Authentication token (alternative to --auth-token flag)
+  METRICS_TOKEN            Metrics token (alternative to --metrics-token flag)
 
 Behavior Modes:
   Standalone (default): No auth-token provided
@@ -172,6 +256,9 @@ Examples:
   # Cloud mode - acts as server for pits
   drone-dashboard -auth-token="your-token-here"
 
+  # Cloud mode serving two venues, each with its own current event
+  drone-dashboard -auth-token="your-token-here" -pits-id="north,south"
+
   # Pits mode - connects to cloud server
   drone-dashboard -auth-token="your-token-here" -cloud-url="ws://cloud.example.com/ws"
 
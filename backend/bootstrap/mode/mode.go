@@ -2,13 +2,22 @@ package mode
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"log/slog"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"drone-dashboard/bootstrap/config"
+	"drone-dashboard/buildinfo"
 	"drone-dashboard/control"
+	"drone-dashboard/fpvhttp"
 	"drone-dashboard/ingest"
+	"drone-dashboard/metrics"
 	"drone-dashboard/scheduler"
+	"drone-dashboard/tracing"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
@@ -17,30 +26,295 @@ import (
 func Build(app *pocketbase.PocketBase, flags config.Flags) (*ingest.Service, *scheduler.Manager) {
 	hub := control.NewHub()
 	hub.SetFetchStatsStore(control.NewPocketBaseFetchStatsStore(app))
+	hub.SetPitsStatusStore(control.NewPocketBasePitsStatusStore(app))
 	hub.SetCurrentRaceProvider(control.NewClientKVCurrentRaceProvider(app, time.Second))
 
-	ingestService := selectIngestService(app, flags, hub)
+	role := "standalone"
+	switch {
+	case flags.Replay != "":
+		role = "replay"
+	case flags.AuthToken != "" && flags.CloudURL == "":
+		role = "cloud"
+	case flags.AuthToken != "":
+		role = "pits"
+	}
+	resource := []tracing.Attr{
+		tracing.String("service.name", "drone-dashboard"),
+		tracing.String("service.version", buildinfo.String()),
+		tracing.String("deployment.role", role),
+	}
+	if role == "pits" {
+		resource = append(resource, tracing.String("pits.id", flags.PitsID))
+	}
+	tracing.Default.SetResource(resource...)
+
+	if flags.Replay != "" {
+		svc, mgr := buildReplay(app, flags)
+		registerManual(app, svc, nil)
+		return svc, mgr
+	}
+	if flags.TimingSystem != "fpvtrackside" && flags.AuthToken != "" {
+		log.Fatal("--timing-system=" + flags.TimingSystem + " is standalone only; pits and cloud relay FPVTrackside")
+	}
+	if flags.AuthToken != "" && flags.CloudURL == "" {
+		return buildCloud(app, flags, hub)
+	}
+
+	ingestService, pc := selectIngestService(app, flags)
+	if flags.VenueTZ != "" {
+		ingestService.Clock = ingest.ZoneClock{Loc: mustVenueLocation(flags)}
+	}
+	if rec := newRecorder(app, flags, ""); rec != nil {
+		if ingestService.Clock != nil {
+			rec.Location = ingestService.Clock.Location
+		}
+		ingestService.Source = ingest.RecordSource(ingestService.Source, rec.Record)
+		if pc != nil {
+			pc.OnOriginBody = rec.Record
+		}
+	}
 	manager := scheduler.NewManager(app, ingestService, scheduler.Config{})
+	metrics.Default.Register(manager.Collect)
+	metrics.Default.Register(fpvhttp.Collect)
+	if pc != nil {
+		metrics.Default.Register(pc.Collect)
+		pc.SetFetchConcurrency(flags.FetchConcurrency)
+		pc.SetFetchCacheTTL(flags.FetchCacheTTL)
+		control.RegisterPitsRoutes(app, pc)
+		registerPitsCommands(pc, ingestService, manager)
+		pc.AllowCommands(strings.Split(flags.RemoteCommands, ","))
+		go pc.Start(context.Background())
+	}
+	registerManual(app, ingestService, nil)
 	return ingestService, manager
 }
 
-func selectIngestService(app *pocketbase.PocketBase, flags config.Flags, hub *control.Hub) *ingest.Service {
-	if flags.AuthToken != "" {
-		if flags.CloudURL != "" {
-			ingestService := mustNewIngestService(app, flags.FPVTrackside)
-			pc, err := control.NewPitsClient(flags.CloudURL, flags.AuthToken, flags.PitsID, flags.FPVTrackside)
-			if err != nil {
-				log.Fatal("control client init:", err)
-			}
-			go pc.Start(context.Background())
-			return ingestService
+// buildCloud wires one ingest pipeline per configured pits. A single pits
+// keeps the unscoped behaviour; several pits each get their own current event
+// and ingest_targets queue, tagged with pitsId. The first pipeline is returned
+// as primary; the rest register their hooks and loops here.
+func buildCloud(app *pocketbase.PocketBase, flags config.Flags, hub *control.Hub) (*ingest.Service, *scheduler.Manager) {
+	control.RegisterServer(app, hub, flags.AuthToken)
+	hub.SetRequireSigning(flags.RequireSigning)
+	metrics.Default.Register(hub.Collect)
+
+	ids := flags.PitsIDs()
+	hub.SetPitsIDs(ids)
+	scoped := len(ids) > 1
+	router := pushRouter{}
+	changelogs := changelogRouter{}
+	var primarySvc *ingest.Service
+	var primaryMgr *scheduler.Manager
+	var extras []*scheduler.Manager
+	for _, id := range ids {
+		svc := ingest.NewServiceWithSource(app, ingest.NewRemoteSource(hub, id))
+		svc.Clock = hub.VenueClock(id)
+		if rec := newRecorder(app, flags, captureSubdir(scoped, id)); rec != nil {
+			rec.Location = svc.Clock.Location
+			svc.Source = ingest.RecordSource(svc.Source, rec.Record)
+		}
+		mgr := scheduler.NewManager(app, svc, scheduler.Config{})
+		if scoped {
+			svc.PitsID = id
+			mgr.PitsID = id
+		}
+		svc.Breaker = ingest.NewSettingsBreaker(app, ingest.SourceHealthKey(svc.PitsID))
+		router[id] = svc
+		changelogs[id] = mgr
+		metrics.Default.Register(mgr.Collect)
+		if primarySvc == nil {
+			primarySvc, primaryMgr = svc, mgr
+			continue
 		}
+		extras = append(extras, mgr)
+	}
+	hub.SetPushHandler(router)
+	hub.SetChangelogHandler(changelogs)
+	var manualPits pushRouter
+	if scoped {
+		manualPits = router
+	}
+	registerManual(app, primarySvc, manualPits)
+
+	for _, mgr := range extras {
+		mgr.RegisterHooks()
+	}
+	if len(extras) > 0 {
+		app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+			for _, mgr := range extras {
+				mgr.StartLoops(context.Background())
+			}
+			return se.Next()
+		})
+	}
+	return primarySvc, primaryMgr
+}
+
+// buildReplay ingests from a recording on its virtual clock, as standalone
+// would from FPVTrackside.
+func buildReplay(app *pocketbase.PocketBase, flags config.Flags) (*ingest.Service, *scheduler.Manager) {
+	if flags.AuthToken != "" {
+		log.Fatal("--replay cannot be combined with --auth-token")
+	}
+	replay, err := ingest.NewReplaySource(flags.Replay)
+	if err != nil {
+		log.Fatal("replay init:", err)
+	}
+	if err := replay.SetSpeed(flags.ReplaySpeed); err != nil {
+		log.Fatal("replay init:", err)
+	}
+	svc := ingest.NewServiceWithSource(app, replay)
+	svc.Clock = replay
+	ingest.RegisterReplayRoutes(app, replay)
+	manager := scheduler.NewManager(app, svc, scheduler.Config{})
+	metrics.Default.Register(manager.Collect)
+	st := replay.Status()
+	slog.Info("ingest.replay.start", "dir", st.Dir, "captures", st.Captures, "firstMs", st.FirstMs, "lastMs", st.LastMs, "speed", st.Speed)
+	return svc, manager
+}
+
+func mustNewRotorHazardService(app core.App, flags config.Flags) *ingest.Service {
+	src, err := ingest.NewRotorHazardSource(flags.RotorHazard, flags.RotorHazardEvent)
+	if err != nil {
+		log.Fatal("RotorHazard source init:", err)
+	}
+	src.Location = mustVenueLocation(flags)
+	svc := ingest.NewServiceWithSource(app, src)
+	svc.Clock = ingest.ZoneClock{Loc: src.Location}
+	svc.Breaker = ingest.NewSettingsBreaker(app, ingest.SourceHealthKey(""))
+	slog.Info("ingest.rotorhazard.start", "url", flags.RotorHazard, "eventId", src.EventID)
+	return svc
+}
+
+func mustVenueLocation(flags config.Flags) *time.Location {
+	loc, err := flags.VenueLocation()
+	if err != nil {
+		log.Fatal("--venue-tz: ", err)
+	}
+	return loc
+}
+
+// newRecorder returns the --capture-dir recorder, nil when capture is off,
+// writing under subdir of the capture directory.
+func newRecorder(app core.App, flags config.Flags, subdir string) *ingest.Recorder {
+	if flags.CaptureDir == "" {
+		return nil
+	}
+	dir := filepath.Join(flags.CaptureDir, subdir)
+	rec := ingest.NewRecorder(dir, int64(flags.CaptureMaxMB)<<20, flags.CaptureKeep)
+	metrics.Default.Register(rec.Collect)
+	app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		rec.Close()
+		return e.Next()
+	})
+	slog.Info("ingest.capture.start", "dir", dir, "maxMb", flags.CaptureMaxMB, "keep", flags.CaptureKeep)
+	return rec
+}
 
-		control.RegisterServer(app, hub, flags.AuthToken)
-		return ingest.NewServiceWithSource(app, ingest.NewRemoteSource(hub, flags.PitsID))
+// registerManual serves the manual API in the venue clock of svc, or of each
+// pits in scoped when several pits feed this server.
+func registerManual(app core.App, svc *ingest.Service, scoped map[string]*ingest.Service) {
+	manual := ingest.NewManual(app)
+	manual.SetClock(svc.Clock)
+	for id, pitsSvc := range scoped {
+		manual.AddPits(id, pitsSvc.Clock)
 	}
+	ingest.RegisterManualRoutes(app, manual)
+}
+
+// captureSubdir keeps the captures of several pits apart.
+func captureSubdir(scoped bool, pitsID string) string {
+	if !scoped {
+		return ""
+	}
+	return pitsID
+}
+
+// pushRouter hands each pits' pushes to the ingest service that owns it.
+type pushRouter map[string]*ingest.Service
+
+func (r pushRouter) HandlePush(pitsID string, push control.Push) error {
+	svc, ok := r[pitsID]
+	if !ok {
+		return fmt.Errorf("unknown pits %s", pitsID)
+	}
+	return svc.HandlePush(pitsID, push)
+}
+
+// changelogRouter hands post-outage changelogs to the pits' scheduler.
+type changelogRouter map[string]*scheduler.Manager
 
-	return mustNewIngestService(app, flags.FPVTrackside)
+func (r changelogRouter) HandleChangelog(pitsID string, cl control.Changelog) error {
+	mgr, ok := r[pitsID]
+	if !ok {
+		return fmt.Errorf("unknown pits %s", pitsID)
+	}
+	return mgr.HandleChangelog(pitsID, cl)
+}
+
+// selectIngestService returns the local ingest service and, in pits mode, the
+// not yet started control client.
+func selectIngestService(app *pocketbase.PocketBase, flags config.Flags) (*ingest.Service, *control.PitsClient) {
+	if flags.TimingSystem == "rotorhazard" {
+		return mustNewRotorHazardService(app, flags), nil
+	}
+	if flags.TimingSystem != "fpvtrackside" {
+		log.Fatal("unknown --timing-system: ", flags.TimingSystem)
+	}
+	ingestService := mustNewIngestService(app, flags.FPVTrackside)
+	if flags.AuthToken == "" {
+		return ingestService, nil
+	}
+
+	pc, err := control.NewPitsClient(flags.CloudURL, flags.AuthToken, flags.PitsID, flags.FPVTrackside)
+	if err != nil {
+		log.Fatal("control client init:", err)
+	}
+	pc.RequireSigning = flags.RequireSigning
+	// Publish whatever the local scheduler fetches so the cloud sees changes without polling.
+	if ds, ok := ingestService.Source.(ingest.DirectSource); ok {
+		ds.C.OnResponse = pc.Publish
+	}
+	if flags.VenueTZ != "" {
+		pc.Location = mustVenueLocation(flags)
+	}
+	// The local copy doubles as the outage buffer replayed on reconnect.
+	pc.Changelog = ingestService
+	pc.Outages = control.NewPocketBaseOutageStore(app)
+	return ingestService, pc
+}
+
+// registerPitsCommands exposes local actions to the cloud; --remote-commands
+// decides which of them may actually run.
+func registerPitsCommands(pc *control.PitsClient, svc *ingest.Service, mgr *scheduler.Manager) {
+	pc.RegisterCommand(control.CommandFullAuto, func(ctx context.Context, _ json.RawMessage) (any, error) {
+		return svc.FullAuto(ctx)
+	})
+	pc.RegisterCommand(control.CommandPurge, func(ctx context.Context, _ json.RawMessage) (any, error) {
+		return svc.Purge(ctx)
+	})
+	pc.RegisterCommand(control.CommandSchedulerEnabled, func(ctx context.Context, args json.RawMessage) (any, error) {
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil || req.Enabled == nil {
+			return nil, fmt.Errorf(`args must be {"enabled": true|false}`)
+		}
+		if err := mgr.SetEnabled(*req.Enabled); err != nil {
+			return nil, err
+		}
+		return map[string]bool{"enabled": mgr.IsEnabled()}, nil
+	})
+	pc.RegisterQuery(control.CommandIngestTargets, func(ctx context.Context, _ json.RawMessage) (any, error) {
+		targets, err := mgr.TargetsStatus()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"enabled": mgr.IsEnabled(), "targets": targets}, nil
+	})
+	pc.RegisterQuery(control.CommandFetchQueue, func(ctx context.Context, _ json.RawMessage) (any, error) {
+		return pc.FetchQueueStats(), nil
+	})
 }
 
 func mustNewIngestService(app core.App, baseURL string) *ingest.Service {
@@ -48,5 +322,6 @@ func mustNewIngestService(app core.App, baseURL string) *ingest.Service {
 	if err != nil {
 		log.Fatal("Failed to create proxy service:", err)
 	}
+	svc.Breaker = ingest.NewSettingsBreaker(app, ingest.SourceHealthKey(""))
 	return svc
 }
@@ -18,6 +18,7 @@ import (
 	"drone-dashboard/bootstrap/config"
 	"drone-dashboard/importer"
 	"drone-dashboard/ingest"
+	"drone-dashboard/metrics"
 	"drone-dashboard/realtime"
 	"drone-dashboard/scheduler"
 
@@ -91,6 +92,13 @@ func RegisterServe(app *pocketbase.PocketBase, static fs.FS, ingestService *inge
 			return nil
 		})
 
+		metrics.Default.Register(realtime.Collector(app))
+		metricsHandler := metrics.Handler(metrics.Default, flags.MetricsToken)
+		se.Router.GET("/metrics", func(c *core.RequestEvent) error {
+			metricsHandler.ServeHTTP(c.Response, c.Request)
+			return nil
+		})
+
 		se.Router.GET("/health", func(c *core.RequestEvent) error {
 			return c.JSON(http.StatusOK, map[string]interface{}{
 				"status":    "ok",
@@ -140,6 +148,9 @@ func shouldProxyFrontendPath(path string) bool {
 	if strings.HasPrefix(path, "/control") {
 		return false
 	}
+	if path == "/metrics" || path == "/traces" {
+		return false
+	}
 	return true
 }
 
@@ -4,6 +4,16 @@ echo Building Drone Dashboard for all platforms...
 REM Create build directory if it doesn't exist
 if not exist "build" mkdir build
 
+REM Stamp version details into the binary (see buildinfo package)
+if not defined VERSION (
+    for /f %%V in ('git describe --tags --always --dirty 2^>nul') do set "VERSION=%%V"
+)
+if not defined VERSION set "VERSION=dev"
+set "COMMIT="
+for /f %%C in ('git rev-parse --short HEAD 2^>nul') do set "COMMIT=%%C"
+set "LDFLAGS=-s -w -X drone-dashboard/buildinfo.Version=%VERSION% -X drone-dashboard/buildinfo.Commit=%COMMIT%"
+echo Version: %VERSION%
+
 REM Create a temporary file to track completion
 set "LOCKFILE=%TEMP%\build-lock-%RANDOM%.txt"
 type nul > "%LOCKFILE%"
@@ -22,15 +32,15 @@ REM Start all builds in parallel
 echo Starting parallel builds...
 
 REM Windows build
-start /b cmd /c "set GOOS=windows&& set GOARCH=amd64&& go build -ldflags="-s -w" -trimpath -o build/drone-dashboard-windows-amd64.exe && echo Windows build complete. && if defined COMPRESS_CMD (%COMPRESS_CMD% build\drone-dashboard-windows-amd64.exe >nul && echo Windows binary compressed.) && echo done >> %LOCKFILE%"
+start /b cmd /c "set GOOS=windows&& set GOARCH=amd64&& go build -ldflags="%LDFLAGS%" -trimpath -o build/drone-dashboard-windows-amd64.exe && echo Windows build complete. && if defined COMPRESS_CMD (%COMPRESS_CMD% build\drone-dashboard-windows-amd64.exe >nul && echo Windows binary compressed.) && echo done >> %LOCKFILE%"
 
 REM Linux builds
-start /b cmd /c "set GOOS=linux&& set GOARCH=amd64&& go build -ldflags="-s -w" -trimpath -o build/drone-dashboard-linux-amd64 && echo Linux amd64 build complete. && if defined COMPRESS_CMD (%COMPRESS_CMD% build\drone-dashboard-linux-amd64 >nul && echo Linux amd64 binary compressed.) && echo done >> %LOCKFILE%"
-start /b cmd /c "set GOOS=linux&& set GOARCH=arm64&& go build -ldflags="-s -w" -trimpath -o build/drone-dashboard-linux-arm64 && echo Linux arm64 build complete. && if defined COMPRESS_CMD (%COMPRESS_CMD% build\drone-dashboard-linux-arm64 >nul && echo Linux arm64 binary compressed.) && echo done >> %LOCKFILE%"
+start /b cmd /c "set GOOS=linux&& set GOARCH=amd64&& go build -ldflags="%LDFLAGS%" -trimpath -o build/drone-dashboard-linux-amd64 && echo Linux amd64 build complete. && if defined COMPRESS_CMD (%COMPRESS_CMD% build\drone-dashboard-linux-amd64 >nul && echo Linux amd64 binary compressed.) && echo done >> %LOCKFILE%"
+start /b cmd /c "set GOOS=linux&& set GOARCH=arm64&& go build -ldflags="%LDFLAGS%" -trimpath -o build/drone-dashboard-linux-arm64 && echo Linux arm64 build complete. && if defined COMPRESS_CMD (%COMPRESS_CMD% build\drone-dashboard-linux-arm64 >nul && echo Linux arm64 binary compressed.) && echo done >> %LOCKFILE%"
 
 REM macOS builds (no compression)
-start /b cmd /c "set GOOS=darwin&& set GOARCH=amd64&& go build -ldflags="-s -w" -trimpath -o build/drone-dashboard-macos-amd64 && echo macOS amd64 build complete. && echo done >> %LOCKFILE%"
-start /b cmd /c "set GOOS=darwin&& set GOARCH=arm64&& go build -ldflags="-s -w" -trimpath -o build/drone-dashboard-macos-arm64 && echo macOS arm64 build complete. && echo done >> %LOCKFILE%"
+start /b cmd /c "set GOOS=darwin&& set GOARCH=amd64&& go build -ldflags="%LDFLAGS%" -trimpath -o build/drone-dashboard-macos-amd64 && echo macOS amd64 build complete. && echo done >> %LOCKFILE%"
+start /b cmd /c "set GOOS=darwin&& set GOARCH=arm64&& go build -ldflags="%LDFLAGS%" -trimpath -o build/drone-dashboard-macos-arm64 && echo macOS arm64 build complete. && echo done >> %LOCKFILE%"
 
 REM Wait for all builds to complete by counting completion markers
 :WAIT_LOOP
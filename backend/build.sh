@@ -4,6 +4,13 @@ echo "Building Drone Dashboard for all platforms..."
 # Create build directory if it doesn't exist
 mkdir -p build
 
+# Stamp version details into the binary (see buildinfo package)
+VERSION=${VERSION:-$(git describe --tags --always --dirty 2>/dev/null || echo dev)}
+COMMIT=$(git rev-parse --short HEAD 2>/dev/null)
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+LDFLAGS="-s -w -X drone-dashboard/buildinfo.Version=${VERSION} -X drone-dashboard/buildinfo.Commit=${COMMIT} -X drone-dashboard/buildinfo.Date=${BUILD_DATE}"
+echo "Version: ${VERSION}"
+
 # Function to compress binary if UPX is available
 compress_if_available() {
     local binary=$1
@@ -19,24 +26,24 @@ compress_if_available() {
 echo "Starting parallel builds..."
 
 # Windows build
-(GOOS=windows GOARCH=amd64 go build -ldflags="-s -w" -trimpath -o build/drone-dashboard.exe && 
+(GOOS=windows GOARCH=amd64 go build -ldflags="$LDFLAGS" -trimpath -o build/drone-dashboard.exe && 
  echo "Windows build complete." && 
  compress_if_available "build/drone-dashboard.exe" "windows") &
 
 # Linux builds
-(GOOS=linux GOARCH=amd64 go build -ldflags="-s -w" -trimpath -o build/drone-dashboard_linux_x86 && 
+(GOOS=linux GOARCH=amd64 go build -ldflags="$LDFLAGS" -trimpath -o build/drone-dashboard_linux_x86 && 
  echo "Linux amd64 build complete." && 
  compress_if_available "build/drone-dashboard_linux_x86" "linux") &
 
-(GOOS=linux GOARCH=arm64 go build -ldflags="-s -w" -trimpath -o build/drone-dashboard_linux_arm && 
+(GOOS=linux GOARCH=arm64 go build -ldflags="$LDFLAGS" -trimpath -o build/drone-dashboard_linux_arm && 
  echo "Linux arm64 build complete." && 
  compress_if_available "build/drone-dashboard_linux_arm" "linux") &
 
 # macOS builds (no compression)
-(GOOS=darwin GOARCH=amd64 go build -ldflags="-s -w" -trimpath -o build/drone-dashboard_mac_x86 && 
+(GOOS=darwin GOARCH=amd64 go build -ldflags="$LDFLAGS" -trimpath -o build/drone-dashboard_mac_x86 && 
  echo "macOS amd64 build complete.") &
 
-(GOOS=darwin GOARCH=arm64 go build -ldflags="-s -w" -trimpath -o build/drone-dashboard_mac_arm && 
+(GOOS=darwin GOARCH=arm64 go build -ldflags="$LDFLAGS" -trimpath -o build/drone-dashboard_mac_arm && 
  echo "macOS arm64 build complete.") &
 
 # Wait for all background jobs to complete
@@ -0,0 +1,36 @@
+// Package buildinfo holds version details stamped in at link time:
+//
+//	go build -ldflags "-X drone-dashboard/buildinfo.Version=v1.4.0 -X drone-dashboard/buildinfo.Commit=$(git rev-parse --short HEAD)"
+package buildinfo
+
+import "runtime/debug"
+
+var (
+	// Version is the release tag; "dev" for local builds.
+	Version = "dev"
+	// Commit is the short VCS revision; filled from the Go build info when not stamped.
+	Commit = ""
+	// Date is the build time in RFC 3339, when stamped.
+	Date = ""
+)
+
+func init() {
+	if Commit != "" {
+		return
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && len(s.Value) >= 7 {
+				Commit = s.Value[:7]
+			}
+		}
+	}
+}
+
+// String returns Version, with the commit appended when known.
+func String() string {
+	if Commit == "" {
+		return Version
+	}
+	return Version + "+" + Commit
+}
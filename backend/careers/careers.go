@@ -0,0 +1,335 @@
+// Package careers keeps per-pilot statistics across every stored event in the
+// pilot_careers collection, so viewers and overlays can read them without
+// redoing the lap maths the dashboard does per event.
+package careers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"drone-dashboard/derived"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ConsecutiveLaps is the window of the best-consecutive stat.
+const ConsecutiveLaps = 3
+
+const collectionName = "pilot_careers"
+
+// EventPoint is one event in a pilot's trend.
+type EventPoint struct {
+	Event   string `json:"event"`
+	Name    string `json:"name"`
+	StartMs int64  `json:"startMs"`
+	Races   int    `json:"races"`
+	// BestLap and BestConsecutive are in seconds; 0 when the pilot set none.
+	BestLap         float64 `json:"bestLap"`
+	BestConsecutive float64 `json:"bestConsecutive"`
+	// AveragePosition is over the event's race results; 0 when there were none.
+	AveragePosition float64 `json:"averagePosition"`
+}
+
+// Career is a pilot's record over all stored events. Lap times count valid,
+// non-holeshot laps of valid races, as the dashboard's leaderboard does.
+type Career struct {
+	Pilot                  string       `json:"pilot"`
+	Name                   string       `json:"name"`
+	BestLapSeconds         float64      `json:"bestLapSeconds"`
+	BestLapRace            string       `json:"bestLapRace"`
+	BestConsecutiveSeconds float64      `json:"bestConsecutiveSeconds"`
+	ConsecutiveLaps        int          `json:"consecutiveLaps"`
+	RacesFlown             int          `json:"racesFlown"`
+	EventsFlown            int          `json:"eventsFlown"`
+	Laps                   int          `json:"laps"`
+	Wins                   int          `json:"wins"`
+	Podiums                int          `json:"podiums"`
+	AveragePosition        float64      `json:"averagePosition"`
+	LastEvent              string       `json:"lastEvent"`
+	Trend                  []EventPoint `json:"trend"`
+}
+
+type lapRow struct {
+	Race      string  `db:"race"`
+	Event     string  `db:"event"`
+	LapNumber int     `db:"lapNumber"`
+	Seconds   float64 `db:"seconds"`
+}
+
+type raceRow struct {
+	Race    string `db:"race"`
+	Event   string `db:"event"`
+	StartMs int64  `db:"startMs"`
+}
+
+type resultRow struct {
+	Event    string `db:"event"`
+	Position int    `db:"position"`
+}
+
+// Compute builds the career of a pilot from the stored races.
+func Compute(app core.App, pilotID string) (Career, error) {
+	pilot, err := app.FindRecordById("pilots", pilotID)
+	if err != nil {
+		return Career{}, err
+	}
+	c := Career{Pilot: pilotID, Name: pilot.GetString("name"), ConsecutiveLaps: ConsecutiveLaps}
+	params := dbx.Params{"p": pilotID}
+
+	var races []raceRow
+	if err := app.DB().NewQuery(`
+        SELECT DISTINCT r.id AS race, r.event AS event, r.startEpoch AS startMs
+        FROM pilotChannels pc
+        JOIN races r ON r.id = pc.race
+        WHERE pc.pilot = {:p} AND r.valid = 1 AND r.startEpoch > 0
+    `).Bind(params).All(&races); err != nil {
+		return c, fmt.Errorf("races of %s: %w", pilotID, err)
+	}
+
+	var laps []lapRow
+	if err := app.DB().NewQuery(`
+        SELECT l.race AS race, r.event AS event, l.lapNumber AS lapNumber, l.lengthSeconds AS seconds
+        FROM laps l
+        JOIN detections d ON d.id = l.detection
+        JOIN races r ON r.id = l.race
+        WHERE d.pilot = {:p} AND d.valid = 1 AND d.isHoleshot = 0 AND r.valid = 1 AND l.lengthSeconds > 0
+        ORDER BY l.race, l.lapNumber
+    `).Bind(params).All(&laps); err != nil {
+		return c, fmt.Errorf("laps of %s: %w", pilotID, err)
+	}
+
+	var results []resultRow
+	if err := app.DB().NewQuery(`
+        SELECT r.event AS event, res.position AS position
+        FROM results res
+        JOIN races r ON r.id = res.race
+        WHERE res.pilot = {:p} AND r.valid = 1 AND res.position > 0
+    `).Bind(params).All(&results); err != nil {
+		return c, fmt.Errorf("results of %s: %w", pilotID, err)
+	}
+
+	points := map[string]*EventPoint{}
+	point := func(event string) *EventPoint {
+		p, ok := points[event]
+		if !ok {
+			p = &EventPoint{Event: event}
+			points[event] = p
+		}
+		return p
+	}
+
+	for _, r := range races {
+		p := point(r.Event)
+		p.Races++
+		if p.StartMs == 0 || r.StartMs < p.StartMs {
+			p.StartMs = r.StartMs
+		}
+	}
+	c.RacesFlown = len(races)
+
+	byRace := map[string][]float64{}
+	eventOfRace := map[string]string{}
+	var raceOrder []string
+	for _, l := range laps {
+		if _, ok := byRace[l.Race]; !ok {
+			raceOrder = append(raceOrder, l.Race)
+			eventOfRace[l.Race] = l.Event
+		}
+		byRace[l.Race] = append(byRace[l.Race], l.Seconds)
+		p := point(l.Event)
+		if p.BestLap == 0 || l.Seconds < p.BestLap {
+			p.BestLap = l.Seconds
+		}
+		if c.BestLapSeconds == 0 || l.Seconds < c.BestLapSeconds {
+			c.BestLapSeconds, c.BestLapRace = l.Seconds, l.Race
+		}
+	}
+	c.Laps = len(laps)
+	for _, race := range raceOrder {
+		best := derived.BestConsecutive(byRace[race], ConsecutiveLaps)
+		if best == 0 {
+			continue
+		}
+		p := point(eventOfRace[race])
+		if p.BestConsecutive == 0 || best < p.BestConsecutive {
+			p.BestConsecutive = best
+		}
+		if c.BestConsecutiveSeconds == 0 || best < c.BestConsecutiveSeconds {
+			c.BestConsecutiveSeconds = best
+		}
+	}
+
+	positionSum := map[string]int{}
+	positionCount := map[string]int{}
+	total := 0
+	for _, r := range results {
+		positionSum[r.Event] += r.Position
+		positionCount[r.Event]++
+		total += r.Position
+		if r.Position == 1 {
+			c.Wins++
+		}
+		if r.Position <= 3 {
+			c.Podiums++
+		}
+	}
+	if len(results) > 0 {
+		c.AveragePosition = derived.Round3(float64(total) / float64(len(results)))
+	}
+
+	for id, p := range points {
+		if n := positionCount[id]; n > 0 {
+			p.AveragePosition = derived.Round3(float64(positionSum[id]) / float64(n))
+		}
+		if ev, err := app.FindRecordById("events", id); err == nil {
+			p.Name = ev.GetString("name")
+		}
+		p.BestLap, p.BestConsecutive = derived.Round3(p.BestLap), derived.Round3(p.BestConsecutive)
+		c.Trend = append(c.Trend, *p)
+	}
+	sort.Slice(c.Trend, func(i, j int) bool {
+		if c.Trend[i].StartMs != c.Trend[j].StartMs {
+			return c.Trend[i].StartMs < c.Trend[j].StartMs
+		}
+		return c.Trend[i].Event < c.Trend[j].Event
+	})
+	c.EventsFlown = len(c.Trend)
+	if len(c.Trend) > 0 {
+		c.LastEvent = c.Trend[len(c.Trend)-1].Event
+	}
+	c.BestLapSeconds, c.BestConsecutiveSeconds = derived.Round3(c.BestLapSeconds), derived.Round3(c.BestConsecutiveSeconds)
+	return c, nil
+}
+
+// refreshMu serializes refreshes from the Refresher, the backfill and the
+// refresh route, so two of them cannot both create a pilot's record.
+var refreshMu sync.Mutex
+
+// Refresh recomputes a pilot's career and stores it, touching the record only
+// when something changed so subscribers are not woken for nothing. A pilot
+// who no longer flew any stored race loses their record.
+func Refresh(app core.App, pilotID string) error {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	existing, _ := app.FindFirstRecordByData(collectionName, "pilot", pilotID)
+	if _, err := app.FindRecordById("pilots", pilotID); err != nil {
+		// deleted pilot: the cascade takes care of the record
+		return nil
+	}
+	c, err := Compute(app, pilotID)
+	if err != nil {
+		return err
+	}
+	if len(c.Trend) == 0 {
+		if existing != nil {
+			return app.Delete(existing)
+		}
+		return nil
+	}
+
+	trend, err := json.Marshal(c.Trend)
+	if err != nil {
+		return err
+	}
+	fields := map[string]any{
+		"name":                   c.Name,
+		"bestLapSeconds":         c.BestLapSeconds,
+		"bestLapRace":            c.BestLapRace,
+		"bestConsecutiveSeconds": c.BestConsecutiveSeconds,
+		"consecutiveLaps":        c.ConsecutiveLaps,
+		"racesFlown":             c.RacesFlown,
+		"eventsFlown":            c.EventsFlown,
+		"laps":                   c.Laps,
+		"wins":                   c.Wins,
+		"podiums":                c.Podiums,
+		"averagePosition":        c.AveragePosition,
+		"lastEvent":              c.LastEvent,
+	}
+	rec := existing
+	if rec == nil {
+		col, err := app.FindCollectionByNameOrId(collectionName)
+		if err != nil {
+			return err
+		}
+		rec = core.NewRecord(col)
+		rec.Set("pilot", pilotID)
+	} else if derived.Unchanged(rec, fields, "trend", trend) {
+		return nil
+	}
+	for k, v := range fields {
+		rec.Set(k, v)
+	}
+	rec.Set("trend", string(trend))
+	return app.Save(rec)
+}
+
+// RefreshAll rebuilds the career of every pilot.
+func RefreshAll(app core.App) (int, error) {
+	pilots, err := app.FindAllRecords("pilots")
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range pilots {
+		if err := Refresh(app, p.Id); err != nil {
+			return 0, fmt.Errorf("refresh %s: %w", p.Id, err)
+		}
+	}
+	return len(pilots), nil
+}
+
+// fromRecord reads a stored career.
+func fromRecord(rec *core.Record) Career {
+	c := Career{
+		Pilot:                  rec.GetString("pilot"),
+		Name:                   rec.GetString("name"),
+		BestLapSeconds:         rec.GetFloat("bestLapSeconds"),
+		BestLapRace:            rec.GetString("bestLapRace"),
+		BestConsecutiveSeconds: rec.GetFloat("bestConsecutiveSeconds"),
+		ConsecutiveLaps:        rec.GetInt("consecutiveLaps"),
+		RacesFlown:             rec.GetInt("racesFlown"),
+		EventsFlown:            rec.GetInt("eventsFlown"),
+		Laps:                   rec.GetInt("laps"),
+		Wins:                   rec.GetInt("wins"),
+		Podiums:                rec.GetInt("podiums"),
+		AveragePosition:        rec.GetFloat("averagePosition"),
+		LastEvent:              rec.GetString("lastEvent"),
+	}
+	_ = rec.UnmarshalJSONField("trend", &c.Trend)
+	return c
+}
+
+// List returns the stored careers, fastest best lap first; pilots without a
+// timed lap come last by races flown.
+func List(app core.App) ([]Career, error) {
+	recs, err := app.FindAllRecords(collectionName)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Career, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, fromRecord(rec))
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if (a.BestLapSeconds == 0) != (b.BestLapSeconds == 0) {
+			return b.BestLapSeconds == 0
+		}
+		if a.BestLapSeconds != b.BestLapSeconds {
+			return a.BestLapSeconds < b.BestLapSeconds
+		}
+		return a.RacesFlown > b.RacesFlown
+	})
+	return out, nil
+}
+
+// Get returns the stored career of a pilot.
+func Get(app core.App, pilotID string) (Career, error) {
+	rec, err := app.FindFirstRecordByData(collectionName, "pilot", pilotID)
+	if err != nil {
+		return Career{}, err
+	}
+	return fromRecord(rec), nil
+}
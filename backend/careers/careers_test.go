@@ -0,0 +1,87 @@
+package careers
+
+import (
+	"testing"
+	"time"
+
+	"drone-dashboard/ingest"
+	"drone-dashboard/ingest/ingesttest"
+	_ "drone-dashboard/migrations"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+// practiceNight authors an event with one race between pilots, finishing in
+// the order given, and returns the event and the race's sourceId.
+func practiceNight(t *testing.T, app core.App, name string, start time.Time, pilots []string, laps [][]float64) (*ingesttest.Event, string) {
+	t.Helper()
+	ev := ingesttest.NewEvent(t, app, ingest.ManualEvent{Name: name}, pilots...)
+	order := make([]int, len(pilots))
+	flights := make([]ingesttest.Flight, len(pilots))
+	for i := range pilots {
+		order[i] = i
+		flights[i] = ingesttest.Flight{Holeshot: 1, Laps: laps[i]}
+	}
+	raceId := ev.Race(t, 0, order...)
+	ev.Fly(t, raceId, start, flights...)
+	ev.Finish(t, raceId, order...)
+	return ev, raceId
+}
+
+func TestCareersAcrossEvents(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+	r := Register(app)
+	r.Delay = time.Hour // flushed by hand below
+
+	june := time.Date(2025, 6, 3, 19, 0, 0, 0, time.UTC)
+	junePractice, _ := practiceNight(t, app, "June", june, []string{"Zippy", "Bolt"}, [][]float64{{30, 29, 31, 28}, {32, 33}})
+	r.Flush()
+
+	zippyPBID := junePractice.Pilot(t, 0).Id
+	c, err := Get(app, zippyPBID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.BestLapSeconds != 28 || c.BestConsecutiveSeconds != 88 || c.RacesFlown != 1 || c.Wins != 1 || c.Laps != 4 {
+		t.Fatalf("after June: %+v", c)
+	}
+
+	// a month later Zippy is back, finishes second and improves
+	_, julyRace := practiceNight(t, app, "July", june.AddDate(0, 1, 0), []string{"Dash", "Zippy"}, [][]float64{{25, 25, 25}, {27.5, 29, 30}})
+	r.Flush()
+	c, err = Get(app, zippyPBID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.BestLapSeconds != 27.5 || c.BestConsecutiveSeconds != 86.5 || c.RacesFlown != 2 || c.EventsFlown != 2 ||
+		c.Podiums != 2 || c.Wins != 1 || c.AveragePosition != 1.5 {
+		t.Fatalf("after July: %+v", c)
+	}
+	if len(c.Trend) != 2 || c.Trend[0].Name != "June" || c.Trend[1].Name != "July" || c.Trend[1].BestLap != 27.5 || c.Trend[0].AveragePosition != 1 {
+		t.Fatalf("trend = %+v", c.Trend)
+	}
+	julyRaceRec := ingesttest.Find(t, app, "races", julyRace)
+	if c.BestLapRace != julyRaceRec.Id || c.LastEvent != julyRaceRec.GetString("event") {
+		t.Fatalf("best lap race %q, last event %q", c.BestLapRace, c.LastEvent)
+	}
+
+	// an unchanged refresh does not rewrite the record
+	before, _ := app.FindFirstRecordByData(collectionName, "pilot", zippyPBID)
+	if err := Refresh(app, zippyPBID); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := app.FindFirstRecordByData(collectionName, "pilot", zippyPBID)
+	if before.GetString("lastUpdated") != after.GetString("lastUpdated") {
+		t.Fatal("unchanged career was saved again")
+	}
+
+	all, err := List(app)
+	if err != nil || len(all) != 3 || all[0].Name != "Dash" {
+		t.Fatalf("list = %+v, %v", all, err)
+	}
+}
@@ -0,0 +1,46 @@
+package careers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterRoutes wires GET /careers and GET /careers/{pilotId}, public like the
+// pilot_careers collection, which clients can also subscribe to for live
+// updates, and the admin-only POST /careers/refresh that rebuilds every career.
+func RegisterRoutes(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/careers", func(c *core.RequestEvent) error {
+			careers, err := List(c.App)
+			if err != nil {
+				return c.InternalServerError("list careers failed", err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"careers": careers})
+		})
+
+		se.Router.GET("/careers/{pilotId}", func(c *core.RequestEvent) error {
+			career, err := Get(c.App, c.Request.PathValue("pilotId"))
+			if err != nil {
+				return c.NotFoundError("no career for pilot", err)
+			}
+			return c.JSON(http.StatusOK, career)
+		})
+
+		se.Router.POST("/careers/refresh", func(c *core.RequestEvent) error {
+			info, err := c.RequestInfo()
+			if err != nil || info.Auth == nil || !info.Auth.IsSuperuser() {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			n, err := RefreshAll(c.App)
+			if err != nil {
+				return c.InternalServerError("refresh failed", err)
+			}
+			slog.Info("careers.refreshAll", "pilots", n)
+			return c.JSON(http.StatusOK, map[string]any{"ok": true, "pilots": n})
+		})
+
+		return se.Next()
+	})
+}
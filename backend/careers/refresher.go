@@ -0,0 +1,49 @@
+package careers
+
+import (
+	"log/slog"
+	"time"
+
+	"drone-dashboard/derived"
+	"drone-dashboard/ingest"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DefaultDelay batches the commits of a live race, which the scheduler
+// re-ingests every few hundred milliseconds.
+const DefaultDelay = 2 * time.Second
+
+// Refresher rebuilds careers after races are ingested or typed in. Each race
+// commit queues the pilots of that race; Delay after the first their careers
+// are refreshed in one pass. Pilots removed by a purge lose their career
+// through the cascade.
+type Refresher struct {
+	*derived.Queue
+	App core.App
+}
+
+// Register binds a Refresher to ingest's race commits. A database with pilots
+// but no careers yet is backfilled at startup.
+func Register(app core.App) *Refresher {
+	r := &Refresher{App: app}
+	r.Queue = derived.NewQueue(app, DefaultDelay, r.refresh)
+	r.Backfill(app, "careers", collectionName, func() (int, error) { return RefreshAll(app) })
+	ingest.OnRaceCommitted(app).BindFunc(func(e *ingest.RaceCommitEvent) error {
+		r.Mark(e.Pilots...)
+		return e.Next()
+	})
+	return r
+}
+
+func (r *Refresher) refresh(pilots map[string]struct{}) {
+	started := time.Now()
+	failed := 0
+	for p := range pilots {
+		if err := Refresh(r.App, p); err != nil {
+			failed++
+			slog.Warn("careers.refresh.error", "pilot", p, "err", err)
+		}
+	}
+	slog.Debug("careers.refresh.done", "pilots", len(pilots), "failed", failed, "ms", time.Since(started).Milliseconds())
+}
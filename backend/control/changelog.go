@@ -0,0 +1,290 @@
+package control
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// changelogMargin widens the outage window to cover commits racing the disconnect.
+	changelogMargin    = 5 * time.Second
+	changelogFetchTime = 5 * time.Second
+)
+
+// ChangelogSource lists local changes on the pits since a point in time.
+type ChangelogSource interface {
+	ChangedSince(since time.Time) ([]ChangelogEntry, error)
+}
+
+// ChangelogHandler reacts to a pits changelog on the cloud.
+type ChangelogHandler interface {
+	HandleChangelog(pitsID string, cl Changelog) error
+}
+
+// SetChangelogHandler configures the sink for post-outage changelogs.
+func (h *Hub) SetChangelogHandler(handler ChangelogHandler) {
+	h.changelogHandler = handler
+}
+
+// applyChangelog hands a changelog to the changelog handler, without the
+// entries whose path is outside the fetch allowlist.
+func (h *Hub) applyChangelog(pitsID string, env Envelope) {
+	b, _ := json.Marshal(env.Payload)
+	var cl Changelog
+	if err := json.Unmarshal(b, &cl); err != nil {
+		slog.Warn("control.hub.changelog.decode.error", "pitsId", pitsID, "id", env.ID, "err", err)
+		return
+	}
+	entries := cl.Entries[:0]
+	for _, e := range cl.Entries {
+		if !IsAllowedFetchPath(e.Path) {
+			slog.Warn("control.hub.changelog.path.rejected", "pitsId", pitsID, "id", env.ID, "path", e.Path)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	cl.Entries = entries
+	if h.changelogHandler == nil {
+		slog.Debug("control.hub.changelog.unhandled", "pitsId", pitsID, "entries", len(cl.Entries))
+		return
+	}
+	if err := h.changelogHandler.HandleChangelog(pitsID, cl); err != nil {
+		slog.Warn("control.hub.changelog.error", "pitsId", pitsID, "entries", len(cl.Entries), "err", err)
+		return
+	}
+	slog.Info("control.hub.changelog", "pitsId", pitsID, "entries", len(cl.Entries), "sinceMs", cl.SinceMs)
+}
+
+// OutageStore keeps the start of an outage that has not been replayed yet, so
+// a pits that restarts mid-outage still replays it on reconnect.
+type OutageStore interface {
+	LoadOutage() (time.Time, error)
+	// SaveOutage records start; the zero time clears it.
+	SaveOutage(start time.Time) error
+}
+
+// outageSettingKey is the server_settings key holding the outage start in unix ms.
+const outageSettingKey = "control.pits.outageStart"
+
+// NewPocketBaseOutageStore returns an OutageStore backed by server_settings.
+func NewPocketBaseOutageStore(app core.App) OutageStore {
+	return &pocketBaseOutageStore{app: app}
+}
+
+type pocketBaseOutageStore struct {
+	app core.App
+}
+
+func (s *pocketBaseOutageStore) LoadOutage() (time.Time, error) {
+	rec, err := s.app.FindFirstRecordByData("server_settings", "key", outageSettingKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	ms, err := strconv.ParseInt(rec.GetString("value"), 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(ms), nil
+}
+
+func (s *pocketBaseOutageStore) SaveOutage(start time.Time) error {
+	rec, err := s.app.FindFirstRecordByData("server_settings", "key", outageSettingKey)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if start.IsZero() {
+			return nil
+		}
+		col, err := s.app.FindCollectionByNameOrId("server_settings")
+		if err != nil {
+			return err
+		}
+		rec = core.NewRecord(col)
+		rec.Set("key", outageSettingKey)
+	}
+	if start.IsZero() {
+		return s.app.Delete(rec)
+	}
+	rec.Set("value", strconv.FormatInt(start.UnixMilli(), 10))
+	return s.app.Save(rec)
+}
+
+// loadOutage picks up an outage a previous process did not get to replay.
+func (p *PitsClient) loadOutage() {
+	if p.Outages == nil {
+		return
+	}
+	start, err := p.Outages.LoadOutage()
+	if err != nil {
+		slog.Warn("control.pits.outage.load.error", "err", err)
+		return
+	}
+	if start.IsZero() {
+		return
+	}
+	p.outageMu.Lock()
+	if p.linkDownAt.IsZero() || start.Before(p.linkDownAt) {
+		p.linkDownAt = start
+	}
+	p.outageMu.Unlock()
+	slog.Info("control.pits.outage.resume", "since", start)
+}
+
+// markLinkDown remembers when the link was lost, or could not be made; the
+// earliest loss wins until a replay completes.
+func (p *PitsClient) markLinkDown() {
+	p.outageMu.Lock()
+	p.linkDrops++
+	if !p.linkDownAt.IsZero() {
+		p.outageMu.Unlock()
+		return
+	}
+	p.linkDownAt = time.Now()
+	start := p.linkDownAt
+	p.outageMu.Unlock()
+	p.saveOutage(start)
+}
+
+// pendingOutage returns the outage start still to replay and the drop count
+// that a successful replay must still match to clear it.
+func (p *PitsClient) pendingOutage() (time.Time, int64) {
+	p.outageMu.Lock()
+	defer p.outageMu.Unlock()
+	return p.linkDownAt, p.linkDrops
+}
+
+// clearOutage forgets the replayed outage unless the link dropped again meanwhile.
+func (p *PitsClient) clearOutage(drops int64) {
+	p.outageMu.Lock()
+	if p.linkDrops != drops {
+		p.outageMu.Unlock()
+		return
+	}
+	p.linkDownAt = time.Time{}
+	p.outageMu.Unlock()
+	p.saveOutage(time.Time{})
+}
+
+func (p *PitsClient) saveOutage(start time.Time) {
+	if p.Outages == nil {
+		return
+	}
+	if err := p.Outages.SaveOutage(start); err != nil {
+		slog.Warn("control.pits.outage.save.error", "err", err)
+	}
+}
+
+// heldBody is a payload published while the link was down.
+type heldBody struct {
+	contentType string
+	body        []byte
+}
+
+// holdBody keeps the newest body fetched for path while the link is down;
+// p.pushMu must be held.
+func (p *PitsClient) holdBody(path string, b heldBody) {
+	if p.held == nil {
+		p.held = make(map[string]heldBody)
+	}
+	p.held[path] = b
+}
+
+func (p *PitsClient) takeHeld() map[string]heldBody {
+	p.pushMu.Lock()
+	defer p.pushMu.Unlock()
+	held := p.held
+	p.held = nil
+	return held
+}
+
+// replayOutage sends the changelog for an outage and re-publishes each changed
+// path so the cloud holds current bodies. Bodies the local scheduler fetched
+// during the outage are sent as held; only paths without one are read from
+// FPVTrackside again. The outage is forgotten once every path was handed over.
+func (p *PitsClient) replayOutage(ctx context.Context, ws *websocket.Conn, writeMu *sync.Mutex, downAt time.Time, drops int64) {
+	since := downAt.Add(-changelogMargin)
+	entries, err := p.Changelog.ChangedSince(since)
+	if err != nil {
+		slog.Warn("control.pits.changelog.error", "err", err)
+		return
+	}
+	held := p.takeHeld()
+	slog.Info("control.pits.changelog", "since", since, "entries", len(entries), "held", len(held), "outage", time.Since(downAt).Round(time.Second))
+	if len(entries) == 0 {
+		p.clearOutage(drops)
+		return
+	}
+	// clouds without changelog support still get the re-published bodies
+	if p.changelogs.Load() {
+		env := NewEnvelope(TypeChangelog, "changelog-"+newTraceID(), Changelog{SinceMs: since.UnixMilli(), Entries: entries})
+		if err := p.writeEnvelope(writeMu, ws, env); err != nil {
+			slog.Warn("control.pits.changelog.send.error", "err", err)
+			return
+		}
+	}
+	if !p.pushes.Load() {
+		// a cloud that takes no pushes picks the changes up with its next fetches
+		slog.Debug("control.pits.changelog.republish.skipped", "entries", len(entries))
+		p.clearOutage(drops)
+		return
+	}
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		b, ok := held[e.Path]
+		if !ok {
+			b, err = p.fetchLocal(ctx, e.Path)
+			if err != nil {
+				slog.Debug("control.pits.changelog.fetch.error", "path", e.Path, "err", err)
+				continue
+			}
+		}
+		p.Publish(e.Path, b.contentType, b.body)
+	}
+	p.clearOutage(drops)
+}
+
+// fetchLocal reads an allowed path from FPVTrackside.
+func (p *PitsClient) fetchLocal(ctx context.Context, path string) (heldBody, error) {
+	if !IsAllowedFetchPath(path) {
+		return heldBody{}, fmt.Errorf("path not allowed: %s", path)
+	}
+	u := *p.FPVBase
+	u.Path = path
+	ctx, cancel := context.WithTimeout(ctx, changelogFetchTime)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return heldBody{}, err
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return heldBody{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return heldBody{}, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	body, err := ioReadAllCap(resp.Body, 8*1024*1024)
+	if err != nil {
+		return heldBody{}, err
+	}
+	return heldBody{contentType: resp.Header.Get("Content-Type"), body: body}, nil
+}
@@ -0,0 +1,97 @@
+package control
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tests"
+
+	_ "drone-dashboard/migrations"
+)
+
+type staticChangelog []ChangelogEntry
+
+func (s staticChangelog) ChangedSince(time.Time) ([]ChangelogEntry, error) { return s, nil }
+
+func TestOutageSurvivesRestartAndStartingDisconnected(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	// the first run never reaches the cloud
+	first, err := NewPitsClient("ws://127.0.0.1:1/control", "", "north", "http://127.0.0.1:1")
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	first.Outages = NewPocketBaseOutageStore(app)
+	first.loadOutage()
+	if err := first.runOnce(context.Background()); err == nil {
+		t.Fatalf("expected connect to fail")
+	}
+	downAt, _ := first.pendingOutage()
+	if downAt.IsZero() {
+		t.Fatalf("failed first connect did not start an outage")
+	}
+
+	// a restarted pits picks the outage up from the store
+	second, _ := NewPitsClient("ws://127.0.0.1:1/control", "", "north", "http://127.0.0.1:1")
+	second.Outages = NewPocketBaseOutageStore(app)
+	second.loadOutage()
+	resumed, drops := second.pendingOutage()
+	if resumed.UnixMilli() != downAt.UnixMilli() {
+		t.Fatalf("resumed outage = %v, want %v", resumed, downAt)
+	}
+
+	second.clearOutage(drops)
+	if start, err := NewPocketBaseOutageStore(app).LoadOutage(); err != nil || !start.IsZero() {
+		t.Fatalf("outage after replay = %v, %v; want cleared", start, err)
+	}
+}
+
+func TestReplayPublishesHeldBodiesWithoutRefetching(t *testing.T) {
+	// FPVBase points nowhere: any refetch would fail and skip the path
+	p, _ := NewPitsClient("ws://127.0.0.1:1/control", "", "north", "http://127.0.0.1:1")
+	p.Changelog = staticChangelog{{Path: "/events/e1/Event.json"}}
+	p.markLinkDown()
+	p.Publish("/events/e1/Event.json", "application/json", []byte(`{"b":1, "a":2}`))
+
+	stop := p.startPushLoop(context.Background(), nil, nil)
+	defer stop()
+	// swap in a queue nobody drains so the replayed push can be inspected
+	queue := make(chan Push, 1)
+	p.pushMu.Lock()
+	p.pushQueue = queue
+	p.pushMu.Unlock()
+	p.pushes.Store(true)
+
+	downAt, drops := p.pendingOutage()
+	p.replayOutage(context.Background(), nil, nil, downAt, drops)
+	select {
+	case push := <-queue:
+		if push.Path != "/events/e1/Event.json" || string(push.Body) != `{"a":2,"b":1}` {
+			t.Fatalf("replayed push = %s %s", push.Path, push.Body)
+		}
+	default:
+		t.Fatalf("held body was not replayed")
+	}
+	if at, _ := p.pendingOutage(); !at.IsZero() {
+		t.Fatalf("outage still pending after replay")
+	}
+}
+
+func TestReplaySendsNothingTheCloudDidNotOffer(t *testing.T) {
+	p, _ := NewPitsClient("ws://127.0.0.1:1/control", "", "north", "http://127.0.0.1:1")
+	p.Changelog = staticChangelog{{Path: "/events/e1/Event.json"}}
+	p.markLinkDown()
+	p.Publish("/events/e1/Event.json", "application/json", []byte(`{}`))
+
+	// neither feature was offered: a nil link would panic on any write
+	downAt, drops := p.pendingOutage()
+	p.replayOutage(context.Background(), nil, nil, downAt, drops)
+	if at, _ := p.pendingOutage(); !at.IsZero() {
+		t.Fatalf("outage still pending after replay")
+	}
+}
@@ -4,15 +4,21 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"drone-dashboard/buildinfo"
 	"drone-dashboard/fpvhttp"
+	"drone-dashboard/tracing"
 
 	"github.com/gorilla/websocket"
 )
@@ -29,6 +35,64 @@ type PitsClient struct {
 	PitsID    string
 	FPVBase   *url.URL
 	HTTP      *http.Client
+	// RequireSigning refuses a cloud that does not offer FeatureHMAC instead
+	// of running the link unsigned.
+	RequireSigning bool
+
+	// pushQueue is non-nil only while a link is up; pushed tracks the last
+	// ETag the cloud holds per path for that link.
+	pushMu    sync.Mutex
+	pushQueue chan Push
+	pushed    map[string]string
+	// held keeps the newest body per path published while the link is down.
+	held map[string]heldBody
+
+	// binaryFrames and patchDeltas are set from the cloud's Hello features.
+	binaryFrames atomic.Bool
+	patchDeltas  atomic.Bool
+	// pushes and changelogs are set when the cloud accepts TypePush and
+	// TypeChangelog.
+	pushes     atomic.Bool
+	changelogs atomic.Bool
+	// bodies keeps recent canonical JSON bodies by ETag for delta responses.
+	bodies *bodyCache
+	// verifier checks cloud frames once the cloud's Hello offers FeatureHMAC.
+	verifier atomic.Pointer[frameVerifier]
+
+	// OnOriginBody, when set, observes every successful FPVTrackside response
+	// fetched for the cloud, before canonicalization.
+	OnOriginBody func(path string, body []byte)
+	// Location is the venue zone FPVTrackside writes times in, reported to the
+	// cloud in Hello; nil means this process's zone (--venue-tz).
+	Location *time.Location
+
+	// Changelog, when set, lists local changes to replay after an outage;
+	// Outages, when set, keeps the outage start across restarts. linkDrops
+	// counts lost links so a replay only clears the outage it covered.
+	Changelog  ChangelogSource
+	Outages    OutageStore
+	outageMu   sync.Mutex
+	linkDownAt time.Time
+	linkDrops  int64
+
+	commandsMu      sync.RWMutex
+	commands        map[string]registeredCommand
+	allowedCommands map[string]bool
+	commandRun      sync.Mutex
+
+	// fetches limits concurrent FPVTrackside requests and origin reuses and
+	// coalesces their responses; inflight maps cloud request IDs to their
+	// cancel funcs for TypeCancel.
+	fetches    *fetchQueue
+	origin     *originCache
+	inflightMu sync.Mutex
+	inflight   map[string]context.CancelFunc
+
+	// clock estimates the cloud clock from the Hello and our own pings.
+	clock      clockEstimator
+	pingSeq    atomic.Int64
+	pingID     atomic.Value // string
+	pingSentAt atomic.Int64 // unix ns
 }
 
 func NewPitsClient(cloudURL, authToken, pitsID string, fpvBase string) (*PitsClient, error) {
@@ -42,10 +106,14 @@ func NewPitsClient(cloudURL, authToken, pitsID string, fpvBase string) (*PitsCli
 		PitsID:    pitsID,
 		FPVBase:   u,
 		HTTP:      fpvhttp.Shared(),
+		bodies:    newBodyCache(),
+		fetches:   newFetchQueue(defaultFetchConcurrency),
+		origin:    newOriginCache(defaultFetchCacheTTL),
 	}, nil
 }
 
 func (p *PitsClient) Start(ctx context.Context) {
+	p.loadOutage()
 	backoff := time.Second
 	for {
 		if ctx.Err() != nil {
@@ -63,14 +131,22 @@ func (p *PitsClient) Start(ctx context.Context) {
 }
 
 func (p *PitsClient) runOnce(ctx context.Context) error {
+	// a pits that starts without a link has to replay from its first attempt
+	defer p.markLinkDown()
 	ws, writeMu, err := p.connect(ctx)
 	if err != nil {
 		return err
 	}
 	defer ws.Close()
+	defer p.cancelAll()
 
 	stopPing := p.startPingLoop(ctx, ws, writeMu)
 	defer stopPing()
+	stopPush := p.startPushLoop(ctx, ws, writeMu)
+	defer stopPush()
+	if downAt, drops := p.pendingOutage(); !downAt.IsZero() && p.Changelog != nil {
+		go p.replayOutage(ctx, ws, writeMu, downAt, drops)
+	}
 
 	return p.consumeFrames(ctx, ws, writeMu)
 }
@@ -83,13 +159,26 @@ func safeWriteJSON(mu *sync.Mutex, ws *websocket.Conn, v any) error {
 	return ws.WriteJSON(v)
 }
 
-func (p *PitsClient) handleFetch(mu *sync.Mutex, ws *websocket.Conn, env Envelope) {
+// writeEnvelope signs env when the pits holds a token and writes it as JSON.
+// Clouds that predate signing ignore the extra field.
+func (p *PitsClient) writeEnvelope(mu *sync.Mutex, ws *websocket.Conn, env Envelope) error {
+	if p.AuthToken != "" {
+		if err := signEnvelope(p.AuthToken, &env, nil); err != nil {
+			return err
+		}
+	}
+	return safeWriteJSON(mu, ws, env)
+}
+
+// handleFetch serves one cloud fetch from FPVTrackside. ctx is cancelled when the
+// cloud sends TypeCancel for the request or the link drops; no reply is sent then.
+func (p *PitsClient) handleFetch(ctx context.Context, mu *sync.Mutex, ws *websocket.Conn, env Envelope) {
 	b, _ := json.Marshal(env.Payload)
 	var f Fetch
 	if err := json.Unmarshal(b, &f); err != nil {
 		errEnv := NewEnvelope(TypeError, env.ID, Error{Code: "BAD_REQUEST", Message: "invalid fetch"})
 		errEnv.TraceID = env.TraceID
-		_ = safeWriteJSON(mu, ws, errEnv)
+		_ = p.writeEnvelope(mu, ws, errEnv)
 		return
 	}
 	traceID := f.TraceID
@@ -97,63 +186,73 @@ func (p *PitsClient) handleFetch(mu *sync.Mutex, ws *websocket.Conn, env Envelop
 		traceID = env.TraceID
 	}
 	slog.Debug("control.pits.fetch", "path", f.Path, "timeoutMs", f.TimeoutMs, "traceId", traceID)
+	ctx, span := tracing.Start(tracing.WithRemoteParent(ctx, traceID, f.SpanID), "pits.fetch", tracing.KindServer,
+		tracing.String("path", f.Path), tracing.String("requestId", env.ID))
+	var spanErr error
+	defer func() { span.End(spanErr) }()
 	if strings.ToUpper(f.Method) != "GET" && strings.ToUpper(f.Method) != "HEAD" {
+		spanErr = errors.New("method not allowed")
 		errEnv := NewEnvelope(TypeError, env.ID, Error{Code: "DENIED", Message: "method not allowed"})
 		errEnv.TraceID = traceID
-		_ = safeWriteJSON(mu, ws, errEnv)
+		_ = p.writeEnvelope(mu, ws, errEnv)
 		return
 	}
-	// basic allowlist: only /events, /httpfiles, root
-	if !(f.Path == "/" || strings.HasPrefix(f.Path, "/events/") || strings.HasPrefix(f.Path, "/httpfiles/")) {
+	if !IsAllowedFetchPath(f.Path) {
+		spanErr = errors.New("path not allowed")
 		errEnv := NewEnvelope(TypeError, env.ID, Error{Code: "DENIED", Message: "path not allowed"})
 		errEnv.TraceID = traceID
-		_ = safeWriteJSON(mu, ws, errEnv)
+		_ = p.writeEnvelope(mu, ws, errEnv)
 		return
 	}
 	// Build URL
 	u := *p.FPVBase
 	u.Path = f.Path
-	timeout := time.Second
-	if f.TimeoutMs > 0 {
-		timeout = time.Duration(f.TimeoutMs) * time.Millisecond
+	// the cloud states what it can wait for; a slow venue laptop may be given
+	// longer locally through the fpvhttp settings
+	timeout := fpvhttp.Timeout(f.Path)
+	if d := time.Duration(f.TimeoutMs) * time.Millisecond; d > timeout {
+		timeout = d
 	}
-	ctxFetch, cancelFetch := context.WithTimeout(context.Background(), timeout)
-	defer cancelFetch()
-	req, _ := http.NewRequestWithContext(ctxFetch, http.MethodGet, u.String(), nil)
-	// Prefer uncompressed to simplify hashing
-	req.Header.Set("Accept-Encoding", "identity")
 	start := time.Now()
-	resp, err := p.HTTP.Do(req)
+	res, from, err := p.origin.get(ctx, f.Path, func(ctx context.Context) (*originResponse, error) {
+		return p.fetchOrigin(ctx, u.String(), f.Path, timeout)
+	})
+	span.SetAttrs(tracing.String("origin", from))
+	spanErr = err
+	if err != nil && ctx.Err() != nil {
+		slog.Debug("control.pits.fetch.cancelled", "path", f.Path, "requestId", env.ID, "traceId", traceID, "origin", from, "latencyMs", time.Since(start).Milliseconds())
+		return
+	}
+	if errors.Is(err, errFetchQueueFull) {
+		slog.Warn("control.pits.fetch.busy", "path", f.Path, "requestId", env.ID, "traceId", traceID, "queued", p.fetches.queued.Load())
+		errEnv := NewEnvelope(TypeError, env.ID, Error{Code: "BUSY", Message: err.Error()})
+		errEnv.TraceID = traceID
+		_ = p.writeEnvelope(mu, ws, errEnv)
+		return
+	}
 	if err != nil {
 		slog.Warn("control.pits.fetch.http_error", "path", f.Path, "requestId", env.ID, "traceId", traceID, "err", err)
 		errEnv := NewEnvelope(TypeError, env.ID, Error{Code: "INTERNAL", Message: err.Error()})
 		errEnv.TraceID = traceID
-		if sendErr := safeWriteJSON(mu, ws, errEnv); sendErr != nil {
+		if sendErr := p.writeEnvelope(mu, ws, errEnv); sendErr != nil {
 			slog.Warn("control.pits.fetch.send.error", "path", f.Path, "requestId", env.ID, "traceId", traceID, "err", sendErr)
 		} else {
 			slog.Debug("control.pits.fetch.sent", "path", f.Path, "requestId", env.ID, "traceId", traceID, "status", "error", "bytes", 0)
 		}
 		return
 	}
-	defer resp.Body.Close()
-	body, _ := ioReadAllCap(resp.Body, 8*1024*1024)
-
-	// Compute ETag
-	etag := ""
-	ct := resp.Header.Get("Content-Type")
-	if strings.Contains(ct, "application/json") {
-		if can, err := CanonicalizeJSON(body); err == nil {
-			body = can
-		}
+	body, etag, ct, canonical := res.body, res.etag, res.contentType, res.canonical
+	if res.ok() {
+		p.markDelivered(f.Path, etag)
 	}
-	etag = ComputeETag(body)
 	latency := time.Since(start).Milliseconds()
 	commonFields := []any{
 		"path", f.Path,
 		"requestId", env.ID,
 		"traceId", traceID,
 		"latencyMs", latency,
-		"originStatus", resp.StatusCode,
+		"originStatus", res.status,
+		"origin", from,
 		"bytes", len(body),
 		"etag", etag,
 	}
@@ -164,9 +263,10 @@ func (p *PitsClient) handleFetch(mu *sync.Mutex, ws *websocket.Conn, env Envelop
 		resultFields := append([]any{}, commonFields...)
 		resultFields = append(resultFields, "status", http.StatusNotModified, "fromCache", true)
 		slog.Debug("control.pits.fetch.result", resultFields...)
+		span.SetAttrs(tracing.Int("status", http.StatusNotModified))
 		respEnv := NewEnvelope(TypeResponse, env.ID, Response{Status: http.StatusNotModified, Headers: map[string]string{"ETag": etag}})
 		respEnv.TraceID = traceID
-		if err := safeWriteJSON(mu, ws, respEnv); err != nil {
+		if err := p.writeEnvelope(mu, ws, respEnv); err != nil {
 			slog.Warn("control.pits.fetch.send.error", "path", f.Path, "requestId", env.ID, "traceId", traceID, "err", err)
 			return
 		}
@@ -178,17 +278,115 @@ func (p *PitsClient) handleFetch(mu *sync.Mutex, ws *websocket.Conn, env Envelop
 	if ct != "" {
 		hdrs["Content-Type"] = ct
 	}
-	payload := Response{Status: resp.StatusCode, Headers: hdrs, BodyB64: base64.StdEncoding.EncodeToString(body)}
+	payload := Response{Status: res.status, Headers: hdrs}
+	fullBytes := len(body)
+	if canonical && f.IfNoneMatch != "" && p.patchDeltas.Load() && p.bodies != nil {
+		if patch, ok := p.bodies.buildDelta(f.IfNoneMatch, body); ok {
+			payload.Encoding = EncodingJSONPatch
+			payload.BaseETag = f.IfNoneMatch
+			body = patch
+		}
+	}
 	resultFields := append([]any{}, commonFields...)
-	resultFields = append(resultFields, "status", resp.StatusCode, "fromCache", false)
+	resultFields = append(resultFields, "status", res.status, "fromCache", false, "encoding", payload.Encoding, "fullBytes", fullBytes)
 	slog.Debug("control.pits.fetch.result", resultFields...)
+	span.SetAttrs(tracing.Int("status", res.status), tracing.Int("bytes", len(body)), tracing.String("encoding", payload.Encoding))
 	respEnv := NewEnvelope(TypeResponse, env.ID, payload)
 	respEnv.TraceID = traceID
-	if err := safeWriteJSON(mu, ws, respEnv); err != nil {
+	wireBytes, err := p.writeWithBody(mu, ws, respEnv, body)
+	if err != nil {
 		slog.Warn("control.pits.fetch.send.error", "path", f.Path, "requestId", env.ID, "traceId", traceID, "err", err)
 		return
 	}
-	slog.Debug("control.pits.fetch.sent", "path", f.Path, "requestId", env.ID, "traceId", traceID, "status", resp.StatusCode, "bytes", len(body))
+	slog.Debug("control.pits.fetch.sent", "path", f.Path, "requestId", env.ID, "traceId", traceID, "status", res.status, "bytes", len(body), "wireBytes", wireBytes)
+}
+
+// fetchOrigin performs one FPVTrackside GET for the origin cache once a fetch
+// queue slot is free, canonicalizing JSON bodies so ETags are stable.
+func (p *PitsClient) fetchOrigin(ctx context.Context, rawURL, path string, timeout time.Duration) (*originResponse, error) {
+	queuedAt := time.Now()
+	release, err := p.fetches.acquire(ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "fpvtrackside.get", tracing.KindClient,
+		tracing.String("path", path), tracing.Int64("queueWaitMs", time.Since(queuedAt).Milliseconds()))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	// Prefer uncompressed to simplify hashing
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := ioReadAllCap(resp.Body, 8*1024*1024)
+	span.SetAttrs(tracing.Int("status", resp.StatusCode), tracing.Int("bytes", len(body)))
+	span.End(nil)
+
+	res := &originResponse{status: resp.StatusCode, contentType: resp.Header.Get("Content-Type"), body: body, fetchedAt: time.Now()}
+	if p.OnOriginBody != nil && res.ok() {
+		p.OnOriginBody(path, body)
+	}
+	if strings.Contains(res.contentType, "application/json") {
+		if can, err := CanonicalizeJSON(body); err == nil {
+			res.body, res.canonical = can, true
+		}
+	}
+	res.etag = ComputeETag(res.body)
+	if res.canonical && p.bodies != nil {
+		p.bodies.put(res.etag, res.body)
+	}
+	return res, nil
+}
+
+// writeWithBody sends env (a Response or Push without body) together with body,
+// using a binary frame when negotiated and base64 JSON otherwise. It returns the
+// number of body-carrying bytes written.
+func (p *PitsClient) writeWithBody(mu *sync.Mutex, ws *websocket.Conn, env Envelope, body []byte) (int, error) {
+	if p.binaryFrames.Load() {
+		if p.AuthToken != "" {
+			if err := signEnvelope(p.AuthToken, &env, body); err != nil {
+				return 0, err
+			}
+		}
+		frame, err := encodeBinaryFrame(env, body)
+		if err != nil {
+			return 0, err
+		}
+		return len(frame), safeWriteBinary(mu, ws, frame)
+	}
+	encoded := base64.StdEncoding.EncodeToString(body)
+	switch payload := env.Payload.(type) {
+	case Response:
+		payload.BodyB64 = encoded
+		env.Payload = payload
+	case Push:
+		payload.BodyB64 = encoded
+		env.Payload = payload
+	}
+	return len(encoded), p.writeEnvelope(mu, ws, env)
+}
+
+// IsAllowedFetchPath is the basic allowlist of paths the control link carries:
+// only /events, /httpfiles and root, without ".." segments or backslashes, so
+// a path is also safe to map onto a directory.
+func IsAllowedFetchPath(path string) bool {
+	if !(path == "/" || strings.HasPrefix(path, "/events/") || strings.HasPrefix(path, "/httpfiles/")) {
+		return false
+	}
+	if strings.ContainsRune(path, '\\') {
+		return false
+	}
+	for _, seg := range strings.Split(path, "/") {
+		if seg == ".." {
+			return false
+		}
+	}
+	return true
 }
 
 func (p *PitsClient) connect(ctx context.Context) (*websocket.Conn, *sync.Mutex, error) {
@@ -203,22 +401,67 @@ func (p *PitsClient) connect(ctx context.Context) (*websocket.Conn, *sync.Mutex,
 	}
 	q := u.Query()
 	q.Set("role", "pits")
-	q.Set("version", "1")
+	q.Set("version", strconv.Itoa(ProtocolMajor))
 	u.RawQuery = q.Encode()
 	slog.Debug("control.pits.dial", "url", u.String(), "pitsId", p.PitsID)
+	dialedAt := time.Now()
 	ws, _, err := dialer.DialContext(ctx, u.String(), hdr)
 	if err != nil {
 		return nil, nil, err
 	}
 	writeMu := &sync.Mutex{}
-	if err := safeWriteJSON(writeMu, ws, NewEnvelope(TypeHello, "", Hello{ProtocolVersion: 1, PitsID: p.PitsID, SWVersion: "dev", Features: []string{"etag"}})); err != nil {
+	// plain JSON bodies until the cloud's Hello says otherwise
+	p.binaryFrames.Store(false)
+	p.patchDeltas.Store(false)
+	p.pushes.Store(false)
+	p.changelogs.Store(false)
+	p.verifier.Store(nil)
+	features := []string{FeatureETag, FeaturePush, FeatureBinary, FeatureChangelog, FeatureCommands, FeatureCancel}
+	if p.AuthToken != "" {
+		features = append(features, FeatureHMAC)
+	}
+	hello := Hello{ProtocolVersion: ProtocolMajor, ProtocolMinor: ProtocolMinor, PitsID: p.PitsID, SWVersion: buildinfo.String(), Features: features, UTCOffsetSec: venueUTCOffset(p.Location)}
+	if err := p.writeEnvelope(writeMu, ws, NewEnvelope(TypeHello, "", hello)); err != nil {
 		_ = ws.Close()
 		return nil, nil, err
 	}
 	slog.Debug("control.pits.hello_sent", "pitsId", p.PitsID)
+	if err := p.awaitHello(ws, dialedAt); err != nil {
+		_ = ws.Close()
+		return nil, nil, err
+	}
 	return ws, writeMu, nil
 }
 
+// awaitHello reads the cloud's Hello, which it sends first on every link, so
+// the negotiated behaviors are settled before any loop starts. Its server time
+// seeds the clock estimate; the dial handshake makes that a coarse sample
+// that the first pings replace.
+func (p *PitsClient) awaitHello(ws *websocket.Conn, dialedAt time.Time) error {
+	ws.SetReadDeadline(time.Now().Add(clientReadTimeout))
+	messageType, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	env, err := decodeFrame(messageType, data)
+	if err != nil {
+		return err
+	}
+	if env.Type != TypeHello {
+		return fmt.Errorf("expected hello from cloud, got %s", env.Type)
+	}
+	if err := p.handleHello(env); err != nil {
+		return err
+	}
+	b, _ := json.Marshal(env.Payload)
+	var h Hello
+	_ = json.Unmarshal(b, &h)
+	if h.ServerTimeMs != 0 {
+		p.observeCloudClock(dialedAt, time.UnixMilli(h.ServerTimeMs), time.Now())
+	}
+	return nil
+}
+
 func (p *PitsClient) startPingLoop(ctx context.Context, ws *websocket.Conn, writeMu *sync.Mutex) func() {
 	stop := make(chan struct{})
 	var once sync.Once
@@ -234,7 +477,10 @@ func (p *PitsClient) startPingLoop(ctx context.Context, ws *websocket.Conn, writ
 				slog.Debug("control.pits.ping.stop", "reason", "loop_exit")
 				return
 			case <-ticker.C:
-				if err := safeWriteJSON(writeMu, ws, NewEnvelope(TypePing, "", nil)); err != nil {
+				id := fmt.Sprintf("ping-%d", p.pingSeq.Add(1))
+				p.pingID.Store(id)
+				p.pingSentAt.Store(time.Now().UnixNano())
+				if err := p.writeEnvelope(writeMu, ws, NewEnvelope(TypePing, id, nil)); err != nil {
 					slog.Warn("control.pits.ping.error", "err", err)
 					_ = ws.Close()
 					return
@@ -250,12 +496,23 @@ func (p *PitsClient) startPingLoop(ctx context.Context, ws *websocket.Conn, writ
 
 func (p *PitsClient) consumeFrames(ctx context.Context, ws *websocket.Conn, writeMu *sync.Mutex) error {
 	for {
-		var env Envelope
 		ws.SetReadDeadline(time.Now().Add(clientReadTimeout))
-		if err := ws.ReadJSON(&env); err != nil {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
 			slog.Warn("control.pits.read.error", "err", err)
 			return err
 		}
+		env, err := decodeFrame(messageType, data)
+		if err != nil {
+			slog.Warn("control.pits.decode.error", "err", err, "bytes", len(data))
+			continue
+		}
+		if v := p.verifier.Load(); v != nil && env.Type != TypeHello {
+			if err := v.verify(env); err != nil {
+				slog.Warn("control.pits.frame.rejected", "type", env.Type, "id", env.ID, "err", err)
+				continue
+			}
+		}
 		slog.Debug("control.pits.frame", "type", env.Type, "id", env.ID, "traceId", env.TraceID)
 		p.handleEnvelope(writeMu, ws, env)
 	}
@@ -264,20 +521,106 @@ func (p *PitsClient) consumeFrames(ctx context.Context, ws *websocket.Conn, writ
 func (p *PitsClient) handleEnvelope(writeMu *sync.Mutex, ws *websocket.Conn, env Envelope) {
 	switch env.Type {
 	case TypeHello:
-		// ignore
+		if err := p.handleHello(env); err != nil {
+			slog.Warn("control.pits.hello.error", "err", err)
+			_ = ws.Close()
+		}
 	case TypeFetch:
-		go p.handleFetch(writeMu, ws, env)
+		ctx, done := p.trackRequest(env.ID)
+		go func() {
+			defer done()
+			p.handleFetch(ctx, writeMu, ws, env)
+		}()
+	case TypeCommand:
+		ctx, done := p.trackRequest(env.ID)
+		go func() {
+			defer done()
+			p.handleCommand(ctx, writeMu, ws, env)
+		}()
+	case TypeCancel:
+		p.cancelRequest(env)
 	case TypePing:
 		pong := NewEnvelope(TypePong, env.ID, nil)
 		pong.TraceID = env.TraceID
-		_ = safeWriteJSON(writeMu, ws, pong)
+		_ = p.writeEnvelope(writeMu, ws, pong)
 	case TypePong:
-		// ignore
+		p.handlePong(env)
+	case TypeError:
+		b, _ := json.Marshal(env.Payload)
+		var e Error
+		_ = json.Unmarshal(b, &e)
+		if e.Code == "INCOMPATIBLE_VERSION" {
+			slog.Error("control.pits.incompatible", "message", e.Message, "swVersion", buildinfo.String(), "pitsId", p.PitsID)
+			return
+		}
+		slog.Warn("control.pits.server_error", "code", e.Code, "message", e.Message, "pitsId", p.PitsID)
 	default:
 		// ignore
 	}
 }
 
+// handlePong feeds the cloud clock estimate from the answer to our last ping.
+func (p *PitsClient) handlePong(env Envelope) {
+	id, _ := p.pingID.Load().(string)
+	if env.ID == "" || env.ID != id || env.TS == 0 {
+		return
+	}
+	p.observeCloudClock(time.Unix(0, p.pingSentAt.Load()), time.UnixMilli(env.TS), time.Now())
+}
+
+func (p *PitsClient) observeCloudClock(sent, cloudTS, received time.Time) {
+	if p.clock.observe(sent, cloudTS, received) {
+		s := p.clock.snapshot()
+		slog.Info("control.pits.clock.offset", "offsetMs", s.OffsetMs, "delayMs", s.DelayMs, "pitsId", p.PitsID)
+	}
+}
+
+// cloudOffset is the current estimate of how far the cloud clock runs ahead.
+func (p *PitsClient) cloudOffset() time.Duration {
+	d, _ := p.clock.estimate()
+	return d
+}
+
+// CloudClock returns how far the cloud clock runs ahead of ours.
+func (p *PitsClient) CloudClock() ClockOffset { return p.clock.snapshot() }
+
+// handleHello applies the cloud's Hello. A different major version is an error;
+// the link is dropped and retried with backoff until one side is upgraded.
+func (p *PitsClient) handleHello(env Envelope) error {
+	b, _ := json.Marshal(env.Payload)
+	var h Hello
+	_ = json.Unmarshal(b, &h)
+	if h.ProtocolVersion != ProtocolMajor {
+		slog.Error("control.pits.incompatible", "cloudProtocol", h.ProtocolVersion, "cloudVersion", h.SWVersion, "protocol", ProtocolMajor, "swVersion", buildinfo.String())
+		return fmt.Errorf("cloud protocol %d incompatible with %d", h.ProtocolVersion, ProtocolMajor)
+	}
+	binary := hasFeature(h.Features, FeatureBinary)
+	patch := hasFeature(h.Features, FeaturePatch)
+	pushes := hasFeature(h.Features, FeaturePush)
+	changelogs := hasFeature(h.Features, FeatureChangelog)
+	signed := p.AuthToken != "" && (hasFeature(h.Features, FeatureHMAC) || env.Sig != "")
+	if p.AuthToken != "" && !signed {
+		if p.RequireSigning {
+			return fmt.Errorf("cloud did not offer frame signing")
+		}
+		slog.Warn("control.pits.hello.unsigned", "cloudVersion", h.SWVersion, "features", h.Features)
+	}
+	if signed {
+		if err := verifyEnvelope(p.AuthToken, env); err != nil {
+			return fmt.Errorf("cloud hello: %w", err)
+		}
+	}
+	p.binaryFrames.Store(binary)
+	p.patchDeltas.Store(patch)
+	p.pushes.Store(pushes)
+	p.changelogs.Store(changelogs)
+	if signed && p.verifier.Load() == nil {
+		p.verifier.Store(newFrameVerifier(p.AuthToken, p.cloudOffset))
+	}
+	slog.Debug("control.pits.hello", "protocol", fmt.Sprintf("%d.%d", h.ProtocolVersion, h.ProtocolMinor), "cloudVersion", h.SWVersion, "features", h.Features, "binaryFrames", binary, "patchDeltas", patch, "pushes", pushes, "changelogs", changelogs, "signed", signed)
+	return nil
+}
+
 func ioReadAllCap(r io.Reader, max int64) ([]byte, error) {
 	lr := &io.LimitedReader{R: r, N: max}
 	var buf []byte
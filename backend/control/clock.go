@@ -0,0 +1,158 @@
+package control
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock offset estimation follows NTP's clock filter: every ping/pong gives an
+// offset and round-trip delay from the envelope TS values, and the sample with
+// the lowest delay among the recent ones is the best estimate because the
+// least time was spent in queues. The peer is assumed to stamp its pong right
+// as it receives the ping, which holds for the single read loop on both sides.
+
+const (
+	clockSampleWindow = 8
+	// clockStepThreshold keeps the published offset still while estimates
+	// jitter, so timestamps normalized with it do not change on every ping.
+	clockStepThreshold = 100 * time.Millisecond
+)
+
+// ClockOffset is the current estimate of how far a peer's clock runs ahead
+// of ours. Negative means it is behind.
+type ClockOffset struct {
+	OffsetMs  float64 `json:"offsetMs"`
+	DelayMs   float64 `json:"delayMs"`
+	Samples   int     `json:"samples"`
+	UpdatedAt int64   `json:"updatedAt"`
+}
+
+type clockSample struct {
+	offset time.Duration
+	delay  time.Duration
+}
+
+// clockEstimator keeps the recent samples for one peer.
+type clockEstimator struct {
+	mu        sync.Mutex
+	samples   []clockSample
+	total     int
+	offset    time.Duration
+	delay     time.Duration
+	valid     bool
+	updatedAt time.Time
+}
+
+// observe records an exchange sent at t0 and answered at t3 (our clock) by a
+// peer that stamped its reply at peerTS (its clock). It reports whether the
+// published offset moved.
+func (e *clockEstimator) observe(t0, peerTS, t3 time.Time) bool {
+	delay := t3.Sub(t0)
+	if delay < 0 {
+		return false
+	}
+	offset := peerTS.Sub(t0.Add(delay / 2))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.samples = append(e.samples, clockSample{offset: offset, delay: delay})
+	if len(e.samples) > clockSampleWindow {
+		e.samples = e.samples[len(e.samples)-clockSampleWindow:]
+	}
+	e.total++
+	best := e.samples[0]
+	for _, s := range e.samples[1:] {
+		if s.delay < best.delay {
+			best = s
+		}
+	}
+	step := best.offset - e.offset
+	if e.valid && step < clockStepThreshold && step > -clockStepThreshold {
+		return false
+	}
+	e.offset, e.delay, e.valid, e.updatedAt = best.offset, best.delay, true, t3
+	return true
+}
+
+// estimate returns the published offset; ok is false before the first sample.
+func (e *clockEstimator) estimate() (time.Duration, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.offset, e.valid
+}
+
+func (e *clockEstimator) snapshot() ClockOffset {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.valid {
+		return ClockOffset{Samples: e.total}
+	}
+	return ClockOffset{
+		OffsetMs:  float64(e.offset.Microseconds()) / 1000,
+		DelayMs:   float64(e.delay.Microseconds()) / 1000,
+		Samples:   e.total,
+		UpdatedAt: e.updatedAt.UnixMilli(),
+	}
+}
+
+// PitsClock is the cloud's view of one venue's clock: its time zone from the
+// pits Hello and its offset from ping/pong. It outlives connections so data
+// ingested during a reconnect is still placed correctly.
+type PitsClock struct {
+	est clockEstimator
+	mu  sync.RWMutex
+	loc *time.Location
+}
+
+// Location is the venue time zone FPVTrackside writes timestamps in. Before a
+// pits reports one, the server's own zone is assumed, as in standalone mode.
+func (c *PitsClock) Location() *time.Location {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.loc == nil {
+		return time.Local
+	}
+	return c.loc
+}
+
+// Offset is how far the venue clock runs ahead of the cloud's.
+func (c *PitsClock) Offset() time.Duration {
+	d, _ := c.est.estimate()
+	return d
+}
+
+// Snapshot returns the current estimate.
+func (c *PitsClock) Snapshot() ClockOffset { return c.est.snapshot() }
+
+func (c *PitsClock) setUTCOffset(sec *int) {
+	if sec == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loc = time.FixedZone("venue", *sec)
+}
+
+// VenueClock returns the clock tracked for pitsID, creating it if needed.
+func (h *Hub) VenueClock(pitsID string) *PitsClock {
+	h.clockMu.Lock()
+	defer h.clockMu.Unlock()
+	if h.clocks == nil {
+		h.clocks = make(map[string]*PitsClock)
+	}
+	c, ok := h.clocks[pitsID]
+	if !ok {
+		c = &PitsClock{}
+		h.clocks[pitsID] = c
+	}
+	return c
+}
+
+// venueUTCOffset is what a pits reports as its venue zone in Hello: the
+// current offset of loc, or of this process's zone when loc is nil.
+func venueUTCOffset(loc *time.Location) *int {
+	if loc == nil {
+		loc = time.Local
+	}
+	_, sec := time.Now().In(loc).Zone()
+	return &sec
+}
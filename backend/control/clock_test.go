@@ -0,0 +1,56 @@
+package control
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockEstimatorPrefersLowestDelay(t *testing.T) {
+	var e clockEstimator
+	base := time.Unix(1_700_000_000, 0)
+	// peer runs 2s ahead; the slow exchange spent its extra delay on the way back
+	exchange := func(at time.Time, out, back time.Duration) bool {
+		return e.observe(at, at.Add(out+2*time.Second), at.Add(out+back))
+	}
+	if !exchange(base, 300*time.Millisecond, 900*time.Millisecond) {
+		t.Fatalf("first sample must publish an offset")
+	}
+	if got, _ := e.estimate(); got != 1700*time.Millisecond {
+		t.Fatalf("asymmetric sample offset = %v", got)
+	}
+	if !exchange(base.Add(30*time.Second), 10*time.Millisecond, 10*time.Millisecond) {
+		t.Fatalf("lower delay sample should move the offset")
+	}
+	if got, _ := e.estimate(); got != 2*time.Second {
+		t.Fatalf("offset = %v, want 2s", got)
+	}
+	// a slower sample that disagrees does not replace the better one
+	if exchange(base.Add(60*time.Second), 400*time.Millisecond, 20*time.Millisecond) {
+		t.Fatalf("worse sample moved the offset")
+	}
+	if s := e.snapshot(); s.Samples != 3 || s.OffsetMs != 2000 || s.DelayMs != 20 {
+		t.Fatalf("unexpected snapshot: %+v", s)
+	}
+}
+
+func TestClockEstimatorHoldsSmallSteps(t *testing.T) {
+	var e clockEstimator
+	at := time.Unix(1_700_000_000, 0)
+	e.observe(at, at.Add(505*time.Millisecond), at.Add(10*time.Millisecond))
+	if e.observe(at, at.Add(540*time.Millisecond), at.Add(8*time.Millisecond)) {
+		t.Fatalf("jitter below the step threshold must not move the offset")
+	}
+	if got, _ := e.estimate(); got != 500*time.Millisecond {
+		t.Fatalf("offset = %v", got)
+	}
+}
+
+func TestHelloReportsConfiguredVenueZone(t *testing.T) {
+	if got := *venueUTCOffset(time.FixedZone("venue", 7200)); got != 7200 {
+		t.Fatalf("venue offset = %d, want 7200", got)
+	}
+	_, local := time.Now().Zone()
+	if got := *venueUTCOffset(nil); got != local {
+		t.Fatalf("default offset = %d, want the process zone's %d", got, local)
+	}
+}
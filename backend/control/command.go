@@ -0,0 +1,274 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// commandTimeout bounds a command end to end; full ingests can take a while.
+	commandTimeout     = 2 * time.Minute
+	commandHistorySize = 50
+)
+
+// ErrCommandsUnsupported is returned for pits builds that predate commands.
+var ErrCommandsUnsupported = errors.New("pits does not support commands")
+
+// RemoteError is an Error envelope returned by the peer.
+type RemoteError struct {
+	Code    string
+	Message string
+}
+
+func (e *RemoteError) Error() string { return e.Code + ": " + e.Message }
+
+// ErrPitsNotConnected is returned for requests to a pits without a live link.
+var ErrPitsNotConnected = errors.New("pits not connected")
+
+// ErrPitsTimeout is returned when a pits did not answer a request in time.
+var ErrPitsTimeout = errors.New("pits did not answer in time")
+
+// IsLinkError reports whether err says the control link failed rather than
+// the timing system behind it: no pits connected, a pits that did not answer
+// in time, or a pits too busy to fetch.
+func IsLinkError(err error) bool {
+	if errors.Is(err, ErrPitsNotConnected) || errors.Is(err, ErrPitsTimeout) {
+		return true
+	}
+	var remote *RemoteError
+	return errors.As(err, &remote) && remote.Code == "BUSY"
+}
+
+// CommandRecord is one entry in the hub's recent command history.
+type CommandRecord struct {
+	ID         string          `json:"id"`
+	PitsID     string          `json:"pitsId"`
+	Name       string          `json:"name"`
+	Args       json.RawMessage `json:"args,omitempty"`
+	StartedAt  int64           `json:"startedAt"`
+	DurationMs int64           `json:"durationMs"`
+	OK         bool            `json:"ok"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// DoCommand runs cmd on the pits and waits for its result.
+func (h *Hub) DoCommand(ctx context.Context, pitsID string, cmd Command) (CommandResult, error) {
+	start := time.Now()
+	ctx, traceID := EnsureTraceID(ctx)
+	id := "cmd-" + newTraceID()
+	env := NewEnvelope(TypeCommand, id, cmd)
+	env.TraceID = traceID
+
+	var result CommandResult
+	var reply Envelope
+	var err error
+	if h.peerSupports(pitsID, FeatureCommands) {
+		reply, err = h.roundTrip(ctx, pitsID, env, commandTimeout)
+	} else {
+		err = ErrCommandsUnsupported
+	}
+	if err == nil {
+		switch reply.Type {
+		case TypeCommandResult:
+			b, _ := json.Marshal(reply.Payload)
+			err = json.Unmarshal(b, &result)
+		case TypeError:
+			b, _ := json.Marshal(reply.Payload)
+			var er Error
+			_ = json.Unmarshal(b, &er)
+			err = &RemoteError{Code: er.Code, Message: er.Message}
+		default:
+			err = fmt.Errorf("unexpected reply type: %s", reply.Type)
+		}
+	}
+
+	rec := CommandRecord{
+		ID:         id,
+		PitsID:     pitsID,
+		Name:       cmd.Name,
+		Args:       cmd.Args,
+		StartedAt:  start.UnixMilli(),
+		DurationMs: time.Since(start).Milliseconds(),
+		OK:         err == nil,
+		Result:     result.Result,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	h.recordCommand(rec)
+	slog.Info("control.hub.command", "pitsId", pitsID, "name", cmd.Name, "ok", rec.OK, "durationMs", rec.DurationMs, "traceId", traceID, "error", rec.Error)
+	return result, err
+}
+
+// CommandHistory returns recent commands, newest first, optionally for one pits.
+func (h *Hub) CommandHistory(pitsID string) []CommandRecord {
+	h.cmdMu.Lock()
+	defer h.cmdMu.Unlock()
+	out := make([]CommandRecord, 0, len(h.commandLog))
+	for i := len(h.commandLog) - 1; i >= 0; i-- {
+		if pitsID == "" || h.commandLog[i].PitsID == pitsID {
+			out = append(out, h.commandLog[i])
+		}
+	}
+	return out
+}
+
+func (h *Hub) recordCommand(rec CommandRecord) {
+	h.cmdMu.Lock()
+	defer h.cmdMu.Unlock()
+	h.commandLog = append(h.commandLog, rec)
+	if len(h.commandLog) > commandHistorySize {
+		h.commandLog = h.commandLog[len(h.commandLog)-commandHistorySize:]
+	}
+}
+
+// roundTrip sends env to the pits and waits for the envelope answering its ID.
+func (h *Hub) roundTrip(ctx context.Context, pitsID string, env Envelope, timeout time.Duration) (Envelope, error) {
+	h.mu.RLock()
+	conn, ok := h.conns[pitsID]
+	h.mu.RUnlock()
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %s", ErrPitsNotConnected, pitsID)
+	}
+	ch := make(chan Envelope, 1)
+	h.mu.Lock()
+	h.pending[env.ID] = pendingReply{conn: conn, ch: ch}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.pending, env.ID)
+		h.mu.Unlock()
+	}()
+	if err := conn.SendJSON(env); err != nil {
+		return Envelope{}, err
+	}
+	select {
+	case <-ctx.Done():
+		h.sendCancel(conn, pitsID, env.ID, env.TraceID, ctx.Err().Error())
+		return Envelope{}, ctx.Err()
+	case reply := <-ch:
+		return reply, nil
+	case <-time.After(timeout):
+		h.sendCancel(conn, pitsID, env.ID, env.TraceID, "timeout")
+		return Envelope{}, fmt.Errorf("%w: timeout waiting for %s", ErrPitsTimeout, env.Type)
+	}
+}
+
+// CommandHandler runs one named command on the pits. ctx is cancelled when
+// the cloud cancels the command, it times out or the link drops.
+type CommandHandler func(ctx context.Context, args json.RawMessage) (any, error)
+
+// registeredCommand is a handler and whether it changes pits state.
+type registeredCommand struct {
+	handler  CommandHandler
+	readOnly bool
+}
+
+// RegisterCommand installs a handler that changes pits state; such commands
+// run one at a time. It only runs if the name is also allowed.
+func (p *PitsClient) RegisterCommand(name string, handler CommandHandler) {
+	p.registerCommand(name, registeredCommand{handler: handler})
+}
+
+// RegisterQuery installs a read-only handler, which runs alongside any other
+// command. It only runs if the name is also allowed.
+func (p *PitsClient) RegisterQuery(name string, handler CommandHandler) {
+	p.registerCommand(name, registeredCommand{handler: handler, readOnly: true})
+}
+
+func (p *PitsClient) registerCommand(name string, cmd registeredCommand) {
+	p.commandsMu.Lock()
+	defer p.commandsMu.Unlock()
+	if p.commands == nil {
+		p.commands = make(map[string]registeredCommand)
+	}
+	p.commands[name] = cmd
+}
+
+// AllowCommands replaces the set of commands the cloud may run on this pits.
+func (p *PitsClient) AllowCommands(names []string) {
+	allowed := make(map[string]bool, len(names))
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			allowed[n] = true
+		}
+	}
+	p.commandsMu.Lock()
+	p.allowedCommands = allowed
+	p.commandsMu.Unlock()
+}
+
+func (p *PitsClient) lookupCommand(name string) (registeredCommand, string) {
+	p.commandsMu.RLock()
+	defer p.commandsMu.RUnlock()
+	cmd, ok := p.commands[name]
+	if !ok {
+		return registeredCommand{}, "UNKNOWN"
+	}
+	if !p.allowedCommands[name] {
+		return registeredCommand{}, "DENIED"
+	}
+	return cmd, ""
+}
+
+// handleCommand dispatches a cloud command. Commands that change state run one
+// at a time so a purge can never interleave with a full ingest; queries do not
+// wait behind them.
+func (p *PitsClient) handleCommand(ctx context.Context, mu *sync.Mutex, ws *websocket.Conn, env Envelope) {
+	reply := func(out Envelope) {
+		out.TraceID = env.TraceID
+		if err := p.writeEnvelope(mu, ws, out); err != nil {
+			slog.Warn("control.pits.command.send.error", "id", env.ID, "err", err)
+		}
+	}
+	b, _ := json.Marshal(env.Payload)
+	var cmd Command
+	if err := json.Unmarshal(b, &cmd); err != nil || cmd.Name == "" {
+		reply(NewEnvelope(TypeError, env.ID, Error{Code: "BAD_REQUEST", Message: "invalid command"}))
+		return
+	}
+	registered, code := p.lookupCommand(cmd.Name)
+	if registered.handler == nil {
+		slog.Warn("control.pits.command.rejected", "name", cmd.Name, "code", code)
+		reply(NewEnvelope(TypeError, env.ID, Error{Code: code, Message: "command not available: " + cmd.Name}))
+		return
+	}
+
+	if !registered.readOnly {
+		p.commandRun.Lock()
+		defer p.commandRun.Unlock()
+	}
+	if ctx.Err() != nil {
+		slog.Info("control.pits.command.cancelled", "name", cmd.Name, "id", env.ID)
+		return
+	}
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(WithTraceID(ctx, env.TraceID), commandTimeout)
+	defer cancel()
+	out, err := registered.handler(ctx, cmd.Args)
+	if err != nil && ctx.Err() == context.Canceled {
+		slog.Info("control.pits.command.cancelled", "name", cmd.Name, "id", env.ID, "durationMs", time.Since(start).Milliseconds())
+		return
+	}
+	if err != nil {
+		slog.Warn("control.pits.command.error", "name", cmd.Name, "err", err)
+		reply(NewEnvelope(TypeError, env.ID, Error{Code: "FAILED", Message: err.Error()}))
+		return
+	}
+	raw, err := json.Marshal(out)
+	if err != nil {
+		reply(NewEnvelope(TypeError, env.ID, Error{Code: "INTERNAL", Message: err.Error()}))
+		return
+	}
+	slog.Info("control.pits.command", "name", cmd.Name, "durationMs", time.Since(start).Milliseconds())
+	reply(NewEnvelope(TypeCommandResult, env.ID, CommandResult{Name: cmd.Name, Result: raw, DurationMs: time.Since(start).Milliseconds()}))
+}
@@ -0,0 +1,146 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// replyConn answers every envelope sent to it through the hub.
+type replyConn struct {
+	hub   *Hub
+	reply func(Envelope) Envelope
+}
+
+func (c *replyConn) SendJSON(v any) error {
+	env := v.(Envelope)
+	go c.hub.deliver(c, c.reply(env))
+	return nil
+}
+
+func (c *replyConn) Close() error { return nil }
+
+func TestDoCommandResultAndHistory(t *testing.T) {
+	t.Helper()
+	hub := NewHub()
+	hub.conns["north"] = &replyConn{hub: hub, reply: func(env Envelope) Envelope {
+		cmd := env.Payload.(Command)
+		if cmd.Name == CommandPurge {
+			return NewEnvelope(TypeError, env.ID, Error{Code: "DENIED", Message: "command not available"})
+		}
+		return NewEnvelope(TypeCommandResult, env.ID, CommandResult{Name: cmd.Name, Result: json.RawMessage(`{"enabled":false}`)})
+	}}
+
+	res, err := hub.DoCommand(context.Background(), "north", Command{Name: CommandSchedulerEnabled, Args: json.RawMessage(`{"enabled":false}`)})
+	if err != nil {
+		t.Fatalf("command: %v", err)
+	}
+	if string(res.Result) != `{"enabled":false}` {
+		t.Fatalf("unexpected result %s", res.Result)
+	}
+
+	_, err = hub.DoCommand(context.Background(), "north", Command{Name: CommandPurge})
+	var remote *RemoteError
+	if !errors.As(err, &remote) || remote.Code != "DENIED" {
+		t.Fatalf("expected DENIED remote error, got %v", err)
+	}
+
+	if _, err := hub.DoCommand(context.Background(), "south", Command{Name: CommandFullAuto}); err == nil {
+		t.Fatalf("expected error for unconnected pits")
+	}
+
+	history := hub.CommandHistory("north")
+	if len(history) != 2 || history[0].Name != CommandPurge || history[0].OK || !history[1].OK {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+}
+
+func TestPitsCommandPermissions(t *testing.T) {
+	t.Helper()
+	p := &PitsClient{}
+	noop := func(context.Context, json.RawMessage) (any, error) { return nil, nil }
+	p.RegisterCommand(CommandFullAuto, noop)
+	p.RegisterCommand(CommandPurge, noop)
+	p.RegisterQuery(CommandFetchQueue, noop)
+	p.AllowCommands([]string{CommandFullAuto, CommandFetchQueue, " "})
+
+	if cmd, code := p.lookupCommand(CommandFullAuto); cmd.handler == nil || cmd.readOnly || code != "" {
+		t.Fatalf("allowed command rejected: %s", code)
+	}
+	if cmd, code := p.lookupCommand(CommandFetchQueue); cmd.handler == nil || !cmd.readOnly || code != "" {
+		t.Fatalf("query should run outside the command lock: %+v %s", cmd, code)
+	}
+	if _, code := p.lookupCommand(CommandPurge); code != "DENIED" {
+		t.Fatalf("purge should be denied, got %q", code)
+	}
+	if _, code := p.lookupCommand("shell"); code != "UNKNOWN" {
+		t.Fatalf("unknown command, got %q", code)
+	}
+}
+
+// sentConn hands every envelope sent to it to the test.
+type sentConn struct {
+	sent chan Envelope
+}
+
+func (c *sentConn) SendJSON(v any) error {
+	c.sent <- v.(Envelope)
+	return nil
+}
+
+func (c *sentConn) Close() error { return nil }
+
+func TestDeliverOnlyAcceptsTheFirstAnswerFromTheTargetConn(t *testing.T) {
+	hub := NewHub()
+	north := &sentConn{sent: make(chan Envelope, 1)}
+	south := &sentConn{sent: make(chan Envelope, 1)}
+	hub.conns["north"] = north
+	hub.conns["south"] = south
+
+	type outcome struct {
+		res CommandResult
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		res, err := hub.DoCommand(context.Background(), "north", Command{Name: CommandSchedulerEnabled})
+		done <- outcome{res, err}
+	}()
+	env := <-north.sent
+
+	result := func(body string) Envelope {
+		return NewEnvelope(TypeCommandResult, env.ID, CommandResult{Name: CommandSchedulerEnabled, Result: json.RawMessage(body)})
+	}
+	hub.deliver(south, result(`"forged"`))
+	hub.deliver(north, result(`"real"`))
+	// returns rather than blocking on the full reply channel
+	hub.deliver(north, result(`"duplicate"`))
+
+	got := <-done
+	if got.err != nil {
+		t.Fatalf("command: %v", got.err)
+	}
+	if string(got.res.Result) != `"real"` {
+		t.Fatalf("result = %s, want the first answer from north", got.res.Result)
+	}
+}
+
+func TestDoFetchTimeoutIsALinkError(t *testing.T) {
+	hub := NewHub()
+	// holds the fetches and their cancels, never answering
+	hub.conns["north"] = &sentConn{sent: make(chan Envelope, 4)}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := hub.DoFetch(ctx, "north", Fetch{Method: "GET", Path: "/events/e1/Event.json"})
+	if !errors.Is(err, context.DeadlineExceeded) || !IsLinkError(err) {
+		t.Fatalf("fetch past its deadline = %v, want a link error", err)
+	}
+
+	hub.SetTimeout(20 * time.Millisecond)
+	_, err = hub.DoFetch(context.Background(), "north", Fetch{Method: "GET", Path: "/events/e1/Event.json"})
+	if !IsLinkError(err) {
+		t.Fatalf("fetch past the hub timeout = %v, want a link error", err)
+	}
+}
@@ -0,0 +1,206 @@
+package control
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	credentialsCollection = "pits_credentials"
+	tokenPrefix           = "pits_"
+)
+
+var errInvalidToken = errors.New("invalid token")
+
+// IssuedToken is returned once when a credential is created; only its hash is stored.
+type IssuedToken struct {
+	ID        string `json:"id"`
+	PitsID    string `json:"pitsId"`
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
+
+// credential is the pits credential a connection authenticated with. ID is
+// empty for the shared secret.
+type credential struct {
+	ID     string
+	PitsID string
+}
+
+// credentialStore resolves bearer tokens to the pits they are bound to.
+// The shared secret passed on the command line authenticates any pits that
+// has no live credential of its own, so pits move to issued tokens one by one.
+type credentialStore struct {
+	app          core.App
+	sharedSecret string
+}
+
+var errSharedSecretDisabled = errors.New("shared secret disabled for a pits with its own credentials")
+
+// authenticate returns the credential token belongs to. The shared secret
+// yields a credential without pits ID: the pits may name itself, and check
+// then decides whether the shared secret is still valid for that pits.
+func (s *credentialStore) authenticate(token string) (credential, error) {
+	if token == "" {
+		return credential{}, errInvalidToken
+	}
+	if s.sharedSecret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.sharedSecret)) == 1 {
+		return credential{}, nil
+	}
+	if s.app == nil {
+		return credential{}, errInvalidToken
+	}
+	rec, err := s.app.FindFirstRecordByFilter(credentialsCollection, "tokenHash = {:h}", dbx.Params{"h": hashToken(token)})
+	if err != nil || rec == nil {
+		return credential{}, errInvalidToken
+	}
+	if err := live(rec); err != nil {
+		return credential{}, err
+	}
+	rec.Set("lastUsedAt", types.NowDateTime())
+	if err := s.app.Save(rec); err != nil {
+		slog.Debug("control.credentials.touch.error", "id", rec.Id, "err", err)
+	}
+	return credential{ID: rec.Id, PitsID: rec.GetString("pitsId")}, nil
+}
+
+// live reports why a stored credential may no longer be used, if it may not.
+func live(rec *core.Record) error {
+	if rec.GetBool("revoked") {
+		return fmt.Errorf("token revoked")
+	}
+	if exp := rec.GetDateTime("expiresAt"); !exp.IsZero() && time.Now().After(exp.Time()) {
+		return fmt.Errorf("token expired")
+	}
+	return nil
+}
+
+// check re-validates a credential a connection of pitsID authenticated with,
+// so revoked and expired tokens do not outlive the handshake. The shared
+// secret is checked too: it stops being valid for pitsID once that pits has a
+// live credential of its own.
+func (s *credentialStore) check(c credential, pitsID string) error {
+	if s.app == nil {
+		return nil
+	}
+	if c.ID == "" {
+		if s.sharedSecret == "" {
+			return nil
+		}
+		if n, err := s.liveCount(pitsID); err != nil || n > 0 {
+			return errSharedSecretDisabled
+		}
+		return nil
+	}
+	rec, err := s.app.FindRecordById(credentialsCollection, c.ID)
+	if err != nil {
+		return errInvalidToken
+	}
+	return live(rec)
+}
+
+// liveCount counts the credentials of pitsID that are neither revoked nor expired.
+func (s *credentialStore) liveCount(pitsID string) (int64, error) {
+	return s.app.CountRecords(credentialsCollection,
+		dbx.HashExp{"pitsId": pitsID, "revoked": false},
+		dbx.Or(
+			dbx.HashExp{"expiresAt": ""},
+			dbx.NewExp("expiresAt > {:now}", dbx.Params{"now": types.NowDateTime().String()}),
+		),
+	)
+}
+
+// revoke marks the credential id of pitsID revoked.
+func (s *credentialStore) revoke(pitsID, id string) error {
+	rec, err := s.app.FindRecordById(credentialsCollection, id)
+	if err != nil || rec.GetString("pitsId") != pitsID {
+		return errInvalidToken
+	}
+	if rec.GetBool("revoked") {
+		return nil
+	}
+	rec.Set("revoked", true)
+	return s.app.Save(rec)
+}
+
+// issue creates a new credential for pitsID. A zero ttl never expires.
+func (s *credentialStore) issue(pitsID, label string, ttl time.Duration) (IssuedToken, error) {
+	col, err := s.app.FindCollectionByNameOrId(credentialsCollection)
+	if err != nil {
+		return IssuedToken{}, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return IssuedToken{}, err
+	}
+	rec := core.NewRecord(col)
+	rec.Set("pitsId", pitsID)
+	rec.Set("tokenHash", hashToken(token))
+	rec.Set("tokenPrefix", token[:len(tokenPrefix)+6])
+	rec.Set("label", label)
+	out := IssuedToken{PitsID: pitsID, Token: token}
+	if ttl > 0 {
+		exp := time.Now().Add(ttl).UTC()
+		rec.Set("expiresAt", exp)
+		out.ExpiresAt = exp.Format(time.RFC3339)
+	}
+	if err := s.app.Save(rec); err != nil {
+		return IssuedToken{}, err
+	}
+	out.ID = rec.Id
+	return out, nil
+}
+
+// rotate issues a fresh credential and revokes every other live one for pitsID.
+func (s *credentialStore) rotate(pitsID, label string, ttl time.Duration) (IssuedToken, int, error) {
+	var issued IssuedToken
+	revoked := 0
+	err := s.app.RunInTransaction(func(tx core.App) error {
+		txStore := &credentialStore{app: tx}
+		var err error
+		if issued, err = txStore.issue(pitsID, label, ttl); err != nil {
+			return err
+		}
+		recs, err := tx.FindAllRecords(credentialsCollection,
+			dbx.HashExp{"pitsId": pitsID, "revoked": false},
+			dbx.Not(dbx.HashExp{"id": issued.ID}),
+		)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		for _, rec := range recs {
+			rec.Set("revoked", true)
+			if err := tx.Save(rec); err != nil {
+				return err
+			}
+			revoked++
+		}
+		return nil
+	})
+	return issued, revoked, err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
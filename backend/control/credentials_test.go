@@ -0,0 +1,139 @@
+package control
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tests"
+
+	_ "drone-dashboard/migrations"
+)
+
+func TestCredentialStoreIssueRotate(t *testing.T) {
+	t.Helper()
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	store := &credentialStore{app: app, sharedSecret: "shared"}
+	if cred, err := store.authenticate("shared"); err != nil || cred.PitsID != "" {
+		t.Fatalf("shared secret: pits=%q err=%v", cred.PitsID, err)
+	}
+
+	first, err := store.issue("north", "laptop", 0)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if cred, err := store.authenticate(first.Token); err != nil || cred.PitsID != "north" {
+		t.Fatalf("issued token: pits=%q err=%v", cred.PitsID, err)
+	}
+	// once a pits has its own credential the shared secret no longer admits it
+	if err := store.check(credential{}, "north"); err == nil {
+		t.Fatalf("shared-secret connection survives credential issue")
+	}
+	firstCred := credential{ID: first.ID, PitsID: "north"}
+	if err := store.check(firstCred, "north"); err != nil {
+		t.Fatalf("live credential rejected: %v", err)
+	}
+
+	second, revoked, err := store.rotate("north", "", time.Hour)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if revoked != 1 || second.ExpiresAt == "" {
+		t.Fatalf("rotate result: revoked=%d expiresAt=%q", revoked, second.ExpiresAt)
+	}
+	if _, err := store.authenticate(first.Token); err == nil {
+		t.Fatalf("rotated-out token still accepted")
+	}
+	if err := store.check(firstCred, "north"); err == nil {
+		t.Fatalf("connection on rotated-out token still valid")
+	}
+	if cred, err := store.authenticate(second.Token); err != nil || cred.PitsID != "north" {
+		t.Fatalf("rotated token: pits=%q err=%v", cred.PitsID, err)
+	}
+
+	rec, err := app.FindRecordById(credentialsCollection, second.ID)
+	if err != nil {
+		t.Fatalf("find credential: %v", err)
+	}
+	rec.Set("expiresAt", time.Now().Add(-time.Minute))
+	if err := app.Save(rec); err != nil {
+		t.Fatalf("expire credential: %v", err)
+	}
+	if _, err := store.authenticate(second.Token); err == nil {
+		t.Fatalf("expired token accepted")
+	}
+	if _, err := store.authenticate("pits_unknown"); err == nil {
+		t.Fatalf("unknown token accepted")
+	}
+
+	third, err := store.issue("south", "", 0)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if err := store.revoke("north", third.ID); err == nil {
+		t.Fatalf("revoked another pits' token")
+	}
+	if err := store.revoke("south", third.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := store.check(credential{ID: third.ID, PitsID: "south"}, "south"); err == nil {
+		t.Fatalf("revoked token still valid")
+	}
+}
+
+func TestSharedSecretStaysValidForPitsWithoutCredentials(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	store := &credentialStore{app: app, sharedSecret: "shared"}
+	north, err := store.issue("north", "", 0)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	shared, err := store.authenticate("shared")
+	if err != nil {
+		t.Fatalf("shared secret refused once another pits has a credential: %v", err)
+	}
+	if err := store.check(shared, "south"); err != nil {
+		t.Fatalf("shared secret refused for a pits without credentials: %v", err)
+	}
+	if err := store.check(shared, "north"); err == nil {
+		t.Fatalf("shared secret accepted for a pits with its own credential")
+	}
+	if cred, err := store.authenticate(north.Token); err != nil || store.check(cred, "north") != nil {
+		t.Fatalf("issued token refused: %v", err)
+	}
+
+	// revoked and expired credentials leave the shared secret valid
+	if err := store.revoke("north", north.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := store.check(shared, "north"); err != nil {
+		t.Fatalf("shared secret refused after the only credential was revoked: %v", err)
+	}
+	expiring, err := store.issue("north", "", time.Hour)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if err := store.check(shared, "north"); err == nil {
+		t.Fatalf("shared secret accepted for a pits with a live credential")
+	}
+	rec, err := app.FindRecordById(credentialsCollection, expiring.ID)
+	if err != nil {
+		t.Fatalf("find credential: %v", err)
+	}
+	rec.Set("expiresAt", time.Now().Add(-time.Minute))
+	if err := app.Save(rec); err != nil {
+		t.Fatalf("expire credential: %v", err)
+	}
+	if err := store.check(shared, "north"); err != nil {
+		t.Fatalf("shared secret refused after the only credential expired: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package control
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	// EncodingJSONPatch marks a Response whose body is an RFC 6902 patch against BaseETag.
+	EncodingJSONPatch = "json-patch"
+
+	bodyCacheMaxEntries = 64
+	bodyCacheMaxBytes   = 32 * 1024 * 1024
+	// deltaMaxRatio is the largest patch/body size ratio worth sending as a delta.
+	deltaMaxRatio = 0.5
+)
+
+// bodyCache remembers recent canonical bodies by ETag so the pits can diff
+// against whatever version the cloud names in IfNoneMatch.
+type bodyCache struct {
+	mu    sync.Mutex
+	order []string
+	items map[string][]byte
+	bytes int
+}
+
+func newBodyCache() *bodyCache {
+	return &bodyCache{items: make(map[string][]byte)}
+}
+
+func (c *bodyCache) put(etag string, body []byte) {
+	if etag == "" || len(body) > bodyCacheMaxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[etag]; ok {
+		return
+	}
+	c.items[etag] = body
+	c.order = append(c.order, etag)
+	c.bytes += len(body)
+	for len(c.order) > bodyCacheMaxEntries || c.bytes > bodyCacheMaxBytes {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.bytes -= len(c.items[oldest])
+		delete(c.items, oldest)
+	}
+}
+
+func (c *bodyCache) get(etag string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.items[etag]
+	return b, ok
+}
+
+// buildDelta returns a patch from the cached base to body when it is meaningfully smaller.
+func (c *bodyCache) buildDelta(baseETag string, body []byte) ([]byte, bool) {
+	base, ok := c.get(baseETag)
+	if !ok {
+		return nil, false
+	}
+	patch, err := DiffJSON(base, body)
+	if err != nil {
+		return nil, false
+	}
+	if float64(len(patch)) > float64(len(body))*deltaMaxRatio {
+		return nil, false
+	}
+	return patch, true
+}
+
+// ApplyDelta applies a JSON Patch to base and verifies the result hashes to wantETag.
+func ApplyDelta(base, patch []byte, wantETag string) ([]byte, error) {
+	out, err := ApplyJSONPatch(base, patch)
+	if err != nil {
+		return nil, err
+	}
+	if got := ComputeETag(out); got != wantETag {
+		return nil, fmt.Errorf("delta etag mismatch: got %s want %s", got, wantETag)
+	}
+	return out, nil
+}
@@ -0,0 +1,198 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// defaultFetchConcurrency keeps FPVTrackside requests from piling up in the
+	// single-connection fpvhttp transport, where each would burn its timeout.
+	defaultFetchConcurrency = 2
+	maxQueuedFetches        = 64
+)
+
+var errFetchQueueFull = errors.New("fetch queue full")
+
+// FetchQueueStats reports the pits fetch limiter.
+type FetchQueueStats struct {
+	Concurrency int   `json:"concurrency"`
+	Running     int64 `json:"running"`
+	Queued      int64 `json:"queued"`
+	MaxQueued   int64 `json:"maxQueued"`
+	Admitted    int64 `json:"admitted"`
+	Rejected    int64 `json:"rejected"`
+	Cancelled   int64 `json:"cancelled"`
+	WaitMsTotal int64 `json:"waitMsTotal"`
+	WaitMsMax   int64 `json:"waitMsMax"`
+	// Cache reports the response cache in front of the limiter.
+	Cache FetchCacheStats `json:"cache"`
+}
+
+// fetchQueue bounds concurrent FPVTrackside fetches; waiters queue in arrival order
+// as far as the Go scheduler allows.
+type fetchQueue struct {
+	slots chan struct{}
+
+	running   atomic.Int64
+	queued    atomic.Int64
+	maxQueued atomic.Int64
+	admitted  atomic.Int64
+	rejected  atomic.Int64
+	cancelled atomic.Int64
+	waitTotal atomic.Int64
+	waitMax   atomic.Int64
+}
+
+func newFetchQueue(concurrency int) *fetchQueue {
+	if concurrency <= 0 {
+		concurrency = defaultFetchConcurrency
+	}
+	return &fetchQueue{slots: make(chan struct{}, concurrency)}
+}
+
+// acquire waits for a slot until ctx ends or maxWait passes. The returned
+// release func must be called once the fetch is done.
+func (q *fetchQueue) acquire(ctx context.Context, maxWait time.Duration) (func(), error) {
+	depth := q.queued.Add(1)
+	defer q.queued.Add(-1)
+	if depth > maxQueuedFetches {
+		q.rejected.Add(1)
+		return nil, errFetchQueueFull
+	}
+	storeMax(&q.maxQueued, depth)
+	start := time.Now()
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	select {
+	case q.slots <- struct{}{}:
+	case <-ctx.Done():
+		q.cancelled.Add(1)
+		return nil, ctx.Err()
+	case <-timer.C:
+		q.rejected.Add(1)
+		return nil, errFetchQueueFull
+	}
+	waited := time.Since(start).Milliseconds()
+	q.waitTotal.Add(waited)
+	storeMax(&q.waitMax, waited)
+	q.admitted.Add(1)
+	q.running.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.running.Add(-1)
+			<-q.slots
+		})
+	}, nil
+}
+
+func (q *fetchQueue) snapshot() FetchQueueStats {
+	return FetchQueueStats{
+		Concurrency: cap(q.slots),
+		Running:     q.running.Load(),
+		Queued:      q.queued.Load(),
+		MaxQueued:   q.maxQueued.Load(),
+		Admitted:    q.admitted.Load(),
+		Rejected:    q.rejected.Load(),
+		Cancelled:   q.cancelled.Load(),
+		WaitMsTotal: q.waitTotal.Load(),
+		WaitMsMax:   q.waitMax.Load(),
+	}
+}
+
+func storeMax(v *atomic.Int64, n int64) {
+	for {
+		cur := v.Load()
+		if n <= cur || v.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
+// SetFetchConcurrency changes how many FPVTrackside fetches run at once. Call it
+// before Start.
+func (p *PitsClient) SetFetchConcurrency(n int) {
+	p.fetches = newFetchQueue(n)
+}
+
+// FetchQueueStats returns the current limiter and cache counters.
+func (p *PitsClient) FetchQueueStats() FetchQueueStats {
+	s := p.fetches.snapshot()
+	s.Cache = p.origin.snapshot()
+	return s
+}
+
+// RegisterPitsRoutes exposes the pits-local control endpoints.
+func RegisterPitsRoutes(app core.App, p *PitsClient) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/control/fetch-queue", func(c *core.RequestEvent) error {
+			if !isSuperuser(c) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			return c.JSON(http.StatusOK, p.FetchQueueStats())
+		})
+		return se.Next()
+	})
+}
+
+// trackRequest registers a cancellable context for a cloud request ID. The
+// returned func unregisters it and must always be called.
+func (p *PitsClient) trackRequest(id string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.inflightMu.Lock()
+	if p.inflight == nil {
+		p.inflight = make(map[string]context.CancelFunc)
+	}
+	p.inflight[id] = cancel
+	p.inflightMu.Unlock()
+	return ctx, func() {
+		p.inflightMu.Lock()
+		delete(p.inflight, id)
+		p.inflightMu.Unlock()
+		cancel()
+	}
+}
+
+// cancelRequest aborts the in-flight request the cloud gave up on.
+func (p *PitsClient) cancelRequest(env Envelope) {
+	p.inflightMu.Lock()
+	cancel, ok := p.inflight[env.ID]
+	p.inflightMu.Unlock()
+	if !ok {
+		slog.Debug("control.pits.cancel.unknown", "id", env.ID, "traceId", env.TraceID)
+		return
+	}
+	cancel()
+	slog.Debug("control.pits.cancel", "id", env.ID, "traceId", env.TraceID)
+}
+
+// cancelAll aborts every in-flight request when the link drops; nobody is
+// waiting for the answers anymore.
+func (p *PitsClient) cancelAll() {
+	p.inflightMu.Lock()
+	defer p.inflightMu.Unlock()
+	for _, cancel := range p.inflight {
+		cancel()
+	}
+}
+
+// sendCancel tells the pits to stop working on a request the hub gave up on.
+// Pits builds without FeatureCancel just finish the work as before.
+func (h *Hub) sendCancel(conn WSConn, pitsID, id, traceID, reason string) {
+	if !h.peerSupports(pitsID, FeatureCancel) {
+		return
+	}
+	env := NewEnvelope(TypeCancel, id, Cancel{Reason: reason})
+	env.TraceID = traceID
+	if err := conn.SendJSON(env); err != nil {
+		slog.Debug("control.hub.cancel.error", "pitsId", pitsID, "id", id, "err", err)
+	}
+}
@@ -0,0 +1,76 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchQueueLimitsAndCounts(t *testing.T) {
+	q := newFetchQueue(1)
+	release, err := q.acquire(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	if _, err := q.acquire(context.Background(), 20*time.Millisecond); !errors.Is(err, errFetchQueueFull) {
+		t.Fatalf("expected queue wait to expire, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := q.acquire(ctx, time.Second); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation while queued, got %v", err)
+	}
+
+	release()
+	release() // idempotent
+	release2, err := q.acquire(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	release2()
+
+	s := q.snapshot()
+	if s.Concurrency != 1 || s.Running != 0 || s.Queued != 0 || s.Admitted != 2 || s.Rejected != 1 || s.Cancelled != 1 || s.MaxQueued != 1 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestCancelAbortsInFlightFetch(t *testing.T) {
+	started := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer origin.Close()
+
+	p, err := NewPitsClient("", "", "north", origin.URL)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	p.HTTP = origin.Client()
+
+	ctx, done := p.trackRequest("f1")
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		defer done()
+		// ws is nil: a cancelled fetch must not try to reply
+		p.handleFetch(ctx, &sync.Mutex{}, nil, NewEnvelope(TypeFetch, "f1", Fetch{Method: "GET", Path: "/events/e1/Event.json", TimeoutMs: 10000}))
+	}()
+
+	<-started
+	p.cancelRequest(Envelope{ID: "f1"})
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("fetch kept running after cancel")
+	}
+	if s := p.FetchQueueStats(); s.Running != 0 || s.Admitted != 1 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
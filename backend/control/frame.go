@@ -0,0 +1,171 @@
+package control
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Binary frames carry a Response or Push body without base64 or JSON escaping:
+//
+//	[1 byte version][1 byte encoding][4 byte big-endian header length][header JSON][body]
+//
+// The header is the usual envelope with the body fields left empty. Bodies above
+// frameGzipThreshold are gzip-compressed.
+const (
+	frameVersion       byte = 1
+	frameEncodingNone  byte = 0
+	frameEncodingGzip  byte = 1
+	frameHeaderPrefix       = 6
+	frameGzipThreshold      = 1024
+	frameMaxBodyBytes       = 16 * 1024 * 1024
+)
+
+var (
+	errFrameTooShort = errors.New("control: binary frame too short")
+	errFrameTooLarge = fmt.Errorf("control: binary frame body over %d bytes", frameMaxBodyBytes)
+)
+
+type frameHeader struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	TS      int64           `json:"ts"`
+	TraceID string          `json:"traceId,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+	Sig     string          `json:"sig,omitempty"`
+}
+
+// encodeBinaryFrame serializes env (whose payload must not carry a body) followed by body.
+func encodeBinaryFrame(env Envelope, body []byte) ([]byte, error) {
+	header, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	encoding := frameEncodingNone
+	if len(body) > frameGzipThreshold {
+		var zbuf bytes.Buffer
+		zw := gzip.NewWriter(&zbuf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		body = zbuf.Bytes()
+		encoding = frameEncodingGzip
+	}
+	out := make([]byte, frameHeaderPrefix, frameHeaderPrefix+len(header)+len(body))
+	out[0] = frameVersion
+	out[1] = encoding
+	binary.BigEndian.PutUint32(out[2:frameHeaderPrefix], uint32(len(header)))
+	out = append(out, header...)
+	out = append(out, body...)
+	return out, nil
+}
+
+// decodeBinaryFrame parses a binary frame into an envelope whose payload is a
+// Response or Push with Body populated.
+func decodeBinaryFrame(data []byte) (Envelope, error) {
+	if len(data) < frameHeaderPrefix {
+		return Envelope{}, errFrameTooShort
+	}
+	if data[0] != frameVersion {
+		return Envelope{}, fmt.Errorf("control: unsupported frame version %d", data[0])
+	}
+	headerLen := int(binary.BigEndian.Uint32(data[2:frameHeaderPrefix]))
+	if headerLen > len(data)-frameHeaderPrefix {
+		return Envelope{}, errFrameTooShort
+	}
+	var hdr frameHeader
+	if err := json.Unmarshal(data[frameHeaderPrefix:frameHeaderPrefix+headerLen], &hdr); err != nil {
+		return Envelope{}, err
+	}
+	body := data[frameHeaderPrefix+headerLen:]
+	switch data[1] {
+	case frameEncodingNone:
+	case frameEncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return Envelope{}, err
+		}
+		// read one byte past the limit so an oversized body is refused, not cut
+		body, err = io.ReadAll(io.LimitReader(zr, frameMaxBodyBytes+1))
+		if err != nil {
+			return Envelope{}, err
+		}
+	default:
+		return Envelope{}, fmt.Errorf("control: unsupported frame encoding %d", data[1])
+	}
+	if len(body) > frameMaxBodyBytes {
+		// the header still names the request the body was for
+		return Envelope{ID: hdr.ID, Type: hdr.Type, TraceID: hdr.TraceID}, errFrameTooLarge
+	}
+
+	env := Envelope{ID: hdr.ID, Type: hdr.Type, TS: hdr.TS, TraceID: hdr.TraceID, Sig: hdr.Sig, rawPayload: hdr.Payload}
+	switch hdr.Type {
+	case TypeResponse:
+		var r Response
+		if err := json.Unmarshal(hdr.Payload, &r); err != nil {
+			return Envelope{}, err
+		}
+		r.Body = body
+		env.Payload = r
+	case TypePush:
+		var p Push
+		if err := json.Unmarshal(hdr.Payload, &p); err != nil {
+			return Envelope{}, err
+		}
+		p.Body = body
+		env.Payload = p
+	default:
+		return Envelope{}, fmt.Errorf("control: unexpected binary frame type %q", hdr.Type)
+	}
+	return env, nil
+}
+
+// decodeFrame turns a websocket message of either kind into an envelope.
+func decodeFrame(messageType int, data []byte) (Envelope, error) {
+	if messageType == websocket.BinaryMessage {
+		return decodeBinaryFrame(data)
+	}
+	var hdr frameHeader
+	if err := json.Unmarshal(data, &hdr); err != nil {
+		return Envelope{}, err
+	}
+	env := Envelope{ID: hdr.ID, Type: hdr.Type, TS: hdr.TS, TraceID: hdr.TraceID, Sig: hdr.Sig, rawPayload: hdr.Payload}
+	if len(hdr.Payload) > 0 {
+		if err := json.Unmarshal(hdr.Payload, &env.Payload); err != nil {
+			return Envelope{}, err
+		}
+	}
+	return env, nil
+}
+
+// safeWriteBinary writes a binary frame. Per-message deflate is skipped since
+// large bodies are already gzip-compressed.
+func safeWriteBinary(mu *sync.Mutex, ws *websocket.Conn, frame []byte) error {
+	mu.Lock()
+	defer mu.Unlock()
+	ws.SetWriteDeadline(time.Now().Add(15 * time.Second))
+	ws.EnableWriteCompression(false)
+	defer ws.EnableWriteCompression(true)
+	return ws.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// hasFeature reports whether a peer advertised the named feature in its Hello.
+func hasFeature(features []string, name string) bool {
+	for _, f := range features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
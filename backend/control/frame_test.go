@@ -0,0 +1,71 @@
+package control
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBinaryFrameRoundTrip(t *testing.T) {
+	t.Helper()
+	for _, size := range []int{0, 16, frameGzipThreshold * 4} {
+		body := bytes.Repeat([]byte("a"), size)
+		env := NewEnvelope(TypeResponse, "req-1", Response{Status: http.StatusOK, Headers: map[string]string{"ETag": ComputeETag(body)}})
+		env.TraceID = "trace-1"
+
+		frame, err := encodeBinaryFrame(env, body)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if size > frameGzipThreshold && len(frame) >= size {
+			t.Fatalf("expected compressed frame smaller than body: frame=%d body=%d", len(frame), size)
+		}
+
+		got, err := decodeFrame(websocket.BinaryMessage, frame)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if got.ID != env.ID || got.Type != TypeResponse || got.TraceID != env.TraceID {
+			t.Fatalf("header mismatch: %+v", got)
+		}
+		resp, ok := got.Payload.(Response)
+		if !ok {
+			t.Fatalf("expected Response payload, got %T", got.Payload)
+		}
+		status, headers, decoded := DecodeResponse(resp)
+		if status != http.StatusOK || headers["ETag"] != ComputeETag(body) || !bytes.Equal(decoded, body) {
+			t.Fatalf("payload mismatch: status=%d etag=%s bytes=%d", status, headers["ETag"], len(decoded))
+		}
+	}
+}
+
+func TestDecodeBinaryFrameRejectsGarbage(t *testing.T) {
+	t.Helper()
+	if _, err := decodeBinaryFrame([]byte{frameVersion}); err == nil {
+		t.Fatalf("expected short frame error")
+	}
+	if _, err := decodeBinaryFrame([]byte{9, 0, 0, 0, 0, 0}); err == nil {
+		t.Fatalf("expected unsupported version error")
+	}
+}
+
+func TestDecodeBinaryFrameRefusesOversizedBodies(t *testing.T) {
+	env := NewEnvelope(TypePush, "push-1", Push{Path: "/events/e1/Event.json"})
+	// gzip squeezes the repeated bytes far below the limit on the wire
+	for _, size := range []int{frameMaxBodyBytes, frameMaxBodyBytes + 1} {
+		frame, err := encodeBinaryFrame(env, bytes.Repeat([]byte("a"), size))
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		got, err := decodeBinaryFrame(frame)
+		if size > frameMaxBodyBytes && (!errors.Is(err, errFrameTooLarge) || got.ID != "push-1") {
+			t.Fatalf("%d byte body: %+v, err = %v, want errFrameTooLarge", size, got, err)
+		}
+		if size == frameMaxBodyBytes && err != nil {
+			t.Fatalf("body at the limit: %v", err)
+		}
+	}
+}
@@ -2,36 +2,51 @@ package control
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"drone-dashboard/tracing"
 )
 
 // Hub manages active pits connections and request/response correlation.
 type Hub struct {
 	mu                  sync.RWMutex
 	conns               map[string]WSConn // pitsId -> connection
-	pending             map[string]chan Envelope
+	pending             map[string]pendingReply
 	timeout             time.Duration
 	inflight            atomic.Int64
 	statsMu             sync.RWMutex
 	stats               map[string]*fetchMetrics
 	statsStore          FetchStatsStore
+	statusStore         PitsStatusStore
+	pitsIDs             map[string]bool
 	currentRaceProvider CurrentRaceProvider
+	pushHandler         PushHandler
+	changelogHandler    ChangelogHandler
+	telemetry           *fetchTelemetry
+	cmdMu               sync.Mutex
+	commandLog          []CommandRecord
+	clockMu             sync.Mutex
+	clocks              map[string]*PitsClock
+	requireSigning      bool
 }
 
 func NewHub() *Hub {
 	return &Hub{
-		conns:   make(map[string]WSConn),
-		pending: make(map[string]chan Envelope),
-		stats:   make(map[string]*fetchMetrics),
-		timeout: 10 * time.Second,
+		conns:     make(map[string]WSConn),
+		pending:   make(map[string]pendingReply),
+		stats:     make(map[string]*fetchMetrics),
+		telemetry: newFetchTelemetry(),
+		timeout:   10 * time.Second,
 	}
 }
 
@@ -52,9 +67,16 @@ type FetchStatsSnapshot struct {
 
 const overallStatsKey = "overall"
 
-// FetchStatsStore persists fetch metrics snapshots for external consumers.
+// FetchStatsStore persists fetch metrics snapshots and windowed series for
+// external consumers.
 type FetchStatsStore interface {
 	UpsertFetchStats(ctx context.Context, bucket string, stats FetchStatsSnapshot) error
+	UpsertFetchSeries(ctx context.Context, points []FetchSeriesPoint) error
+	// ListFetchSeries returns stored points of window (every window when
+	// empty) that start at or after since.
+	ListFetchSeries(ctx context.Context, window string, since time.Time) ([]FetchSeriesPoint, error)
+	// PruneFetchSeries deletes points older than each window's retention.
+	PruneFetchSeries(ctx context.Context, now time.Time) error
 }
 
 // SetFetchStatsStore configures an optional persistence sink for fetch stats.
@@ -82,39 +104,124 @@ type WSConn interface {
 
 func (h *Hub) Register(pitsID string, c *Conn) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
 	slog.Debug("control.hub.register", "pitsId", pitsID)
 	if prev, ok := h.conns[pitsID]; ok {
 		_ = prev.Close()
 	}
 	h.conns[pitsID] = c
+	h.mu.Unlock()
+	h.reportConnected(c)
 }
 
+// Unregister drops c if it is still the current connection for pitsID. A
+// connection replaced by a reconnect leaves the new one alone.
 func (h *Hub) Unregister(pitsID string, c *Conn) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
 	slog.Debug("control.hub.unregister", "pitsId", pitsID)
+	removed := false
 	if cur, ok := h.conns[pitsID]; ok {
 		if curc, ok2 := cur.(*Conn); ok2 && curc == c {
 			delete(h.conns, pitsID)
-			return
+			removed = true
+		}
+	}
+	h.mu.Unlock()
+	if removed {
+		slog.Info("control.hub.pits_down", "pitsId", pitsID)
+		h.reportDisconnected(pitsID)
+	}
+}
+
+// dropRevoked closes the connections whose credential is no longer valid and
+// returns how many it closed. The periodic check would catch them within a
+// ping interval; this makes revocation take effect right away.
+func (h *Hub) dropRevoked() int {
+	h.mu.RLock()
+	conns := make(map[string]*Conn, len(h.conns))
+	for pitsID, conn := range h.conns {
+		if c, ok := conn.(*Conn); ok {
+			conns[pitsID] = c
+		}
+	}
+	h.mu.RUnlock()
+	dropped := 0
+	for pitsID, c := range conns {
+		if !c.credentialLive(pitsID) {
+			dropped++
 		}
 	}
+	return dropped
+}
+
+// PitsInfo describes a registered pits connection.
+type PitsInfo struct {
+	PitsID      string       `json:"pitsId"`
+	RemoteAddr  string       `json:"remoteAddr,omitempty"`
+	ConnectedAt int64        `json:"connectedAt,omitempty"`
+	SWVersion   string       `json:"swVersion,omitempty"`
+	Protocol    string       `json:"protocol,omitempty"`
+	Features    []string     `json:"features,omitempty"`
+	LastFrameAt int64        `json:"lastFrameAt,omitempty"`
+	RTTMs       float64      `json:"rttMs,omitempty"`
+	Clock       *ClockOffset `json:"clock,omitempty"`
+}
+
+// ConnectedPits lists currently registered pits sorted by ID.
+func (h *Hub) ConnectedPits() []PitsInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]PitsInfo, 0, len(h.conns))
+	for id, conn := range h.conns {
+		info := PitsInfo{PitsID: id}
+		if c, ok := conn.(*Conn); ok {
+			info = c.info()
+			clock := h.VenueClock(id).Snapshot()
+			info.Clock = &clock
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PitsID < out[j].PitsID })
+	return out
+}
+
+// peerSupports reports whether the pits connection advertised feature. Unknown
+// pits and non-websocket connections are assumed capable; the send reports them.
+func (h *Hub) peerSupports(pitsID, feature string) bool {
+	h.mu.RLock()
+	conn, ok := h.conns[pitsID]
+	h.mu.RUnlock()
+	if c, isConn := conn.(*Conn); ok && isConn {
+		return c.Supports(feature)
+	}
+	return true
 }
 
 func (h *Hub) SetTimeout(d time.Duration) { h.timeout = d }
 
+// SetRequireSigning refuses pits that connect with a token but do not sign
+// their frames, rather than serving them unsigned.
+func (h *Hub) SetRequireSigning(require bool) { h.requireSigning = require }
+
 func (h *Hub) DoFetch(ctx context.Context, pitsID string, f Fetch) (resp Response, err error) {
 	start := time.Now()
 	started := h.inflight.Add(1)
 	ctx, traceID := EnsureTraceID(ctx)
+	ctx, span := tracing.Start(ctx, "control.fetch", tracing.KindClient,
+		tracing.String("pitsId", pitsID), tracing.String("path", f.Path))
 	var requestID string
 	sentToPits := false
+	defer func() {
+		if err == nil {
+			span.SetAttrs(tracing.Int("status", resp.Status), tracing.Int("bytes", len(resp.Body)))
+		}
+		span.SetAttrs(tracing.String("requestId", requestID))
+		span.End(err)
+	}()
 	defer func() {
 		if !sentToPits {
 			return
 		}
-		h.recordFetchResult(f.Path, resp.Status, err)
+		h.recordFetchResult(f.Path, resp, err, time.Since(start))
 	}()
 	defer func() {
 		remaining := h.inflight.Add(-1)
@@ -144,13 +251,14 @@ func (h *Hub) DoFetch(ctx context.Context, pitsID string, f Fetch) (resp Respons
 	connRaw, ok := h.conns[pitsID]
 	h.mu.RUnlock()
 	if !ok {
-		err = NewTraceError(traceID, fmt.Errorf("no pits connection for %s", pitsID))
+		err = NewTraceError(traceID, fmt.Errorf("%w: %s", ErrPitsNotConnected, pitsID))
 		return
 	}
 	id := fmt.Sprintf("%d-%d", time.Now().UnixNano(), time.Now().Unix())
 	requestID = id
 	fetch := f
 	fetch.TraceID = traceID
+	fetch.SpanID = span.SpanID
 	env := NewEnvelope(TypeFetch, id, fetch)
 	env.TraceID = traceID
 
@@ -168,7 +276,7 @@ func (h *Hub) DoFetch(ctx context.Context, pitsID string, f Fetch) (resp Respons
 
 	ch := make(chan Envelope, 1)
 	h.mu.Lock()
-	h.pending[id] = ch
+	h.pending[id] = pendingReply{conn: connRaw, ch: ch}
 	h.mu.Unlock()
 
 	defer func() {
@@ -178,14 +286,21 @@ func (h *Hub) DoFetch(ctx context.Context, pitsID string, f Fetch) (resp Respons
 	}()
 
 	if err = connRaw.SendJSON(env); err != nil {
-		err = NewTraceError(traceID, err)
+		err = NewTraceError(traceID, fmt.Errorf("%w: %s: %v", ErrPitsNotConnected, pitsID, err))
 		return
 	}
 	sentToPits = true
 
 	select {
 	case <-ctx.Done():
-		err = NewTraceError(traceID, ctx.Err())
+		h.sendCancel(connRaw, pitsID, id, traceID, ctx.Err().Error())
+		err = ctx.Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			// the pits bounds its own fetch well inside our deadline, so
+			// running out of time here means the link, not the timing system
+			err = fmt.Errorf("%w: %w", ErrPitsTimeout, err)
+		}
+		err = NewTraceError(traceID, err)
 		return
 	case envResp := <-ch:
 		respTraceID := envResp.TraceID
@@ -194,6 +309,11 @@ func (h *Hub) DoFetch(ctx context.Context, pitsID string, f Fetch) (resp Respons
 		}
 		switch envResp.Type {
 		case TypeResponse:
+			if r, ok := envResp.Payload.(Response); ok {
+				// decoded from a binary frame with the body already attached
+				resp = r
+				return
+			}
 			var r Response
 			// envResp.Payload is raw json; re-marshal to bytes then unmarshal
 			b, _ := json.Marshal(envResp.Payload)
@@ -210,14 +330,15 @@ func (h *Hub) DoFetch(ctx context.Context, pitsID string, f Fetch) (resp Respons
 			if er.Message == "" {
 				er.Message = "remote error"
 			}
-			err = NewTraceError(respTraceID, errors.New(er.Message))
+			err = NewTraceError(respTraceID, &RemoteError{Code: er.Code, Message: er.Message})
 			return
 		default:
 			err = NewTraceError(respTraceID, fmt.Errorf("unexpected response type: %s", envResp.Type))
 			return
 		}
 	case <-time.After(h.timeout):
-		err = NewTraceError(traceID, fmt.Errorf("timeout waiting for response"))
+		h.sendCancel(connRaw, pitsID, id, traceID, "timeout")
+		err = NewTraceError(traceID, fmt.Errorf("%w: timeout waiting for response", ErrPitsTimeout))
 		return
 	}
 }
@@ -226,19 +347,36 @@ func (h *Hub) InFlight() int64 {
 	return h.inflight.Load()
 }
 
-// deliver is called by the server when a message with matching id arrives.
-func (h *Hub) deliver(env Envelope) {
+// pendingReply is a request waiting for its answer from the conn it was sent on.
+type pendingReply struct {
+	conn WSConn
+	ch   chan Envelope
+}
+
+// deliver is called by the server when a message with matching id arrives on
+// from. Answers from another conn are dropped, and so is anything after the
+// first answer, so a duplicate never blocks the read loop.
+func (h *Hub) deliver(from WSConn, env Envelope) {
 	h.mu.RLock()
-	ch, ok := h.pending[env.ID]
+	p, ok := h.pending[env.ID]
 	h.mu.RUnlock()
 	if !ok {
 		slog.Warn("control.hub.deliver.no_pending", "id", env.ID, "traceId", env.TraceID)
 		return
 	}
-	ch <- env
+	if p.conn != from {
+		slog.Warn("control.hub.deliver.wrong_conn", "id", env.ID, "traceId", env.TraceID)
+		return
+	}
+	select {
+	case p.ch <- env:
+	default:
+		slog.Debug("control.hub.deliver.duplicate", "id", env.ID, "traceId", env.TraceID)
+	}
 }
 
-func (h *Hub) recordFetchResult(path string, status int, err error) {
+func (h *Hub) recordFetchResult(path string, resp Response, err error, latency time.Duration) {
+	status := resp.Status
 	keys := []string{overallStatsKey}
 
 	var currentRace CurrentRaceInfo
@@ -261,7 +399,6 @@ func (h *Hub) recordFetchResult(path string, status int, err error) {
 		bucket.total.Add(1)
 		if err != nil {
 			bucket.errors.Add(1)
-			h.persistFetchStats(key, bucket)
 			continue
 		}
 		if status == http.StatusNotModified {
@@ -269,8 +406,23 @@ func (h *Hub) recordFetchResult(path string, status int, err error) {
 		} else {
 			bucket.fullResponses.Add(1)
 		}
-		h.persistFetchStats(key, bucket)
 	}
+	h.telemetry.observe(keys, fetchObservation{
+		at:        time.Now(),
+		latencyMs: float64(latency.Microseconds()) / 1000,
+		bytes:     responseBytes(resp),
+		status:    status,
+		err:       err != nil,
+	})
+}
+
+// responseBytes is the body size as carried on the link: the delta for patch
+// responses, the raw body for binary frames.
+func responseBytes(r Response) int {
+	if r.Body != nil {
+		return len(r.Body)
+	}
+	return base64.StdEncoding.DecodedLen(len(r.BodyB64))
 }
 
 func (h *Hub) ensureMetricsBucket(key string) *fetchMetrics {
@@ -290,16 +442,6 @@ func (h *Hub) ensureMetricsBucket(key string) *fetchMetrics {
 	return bucket
 }
 
-func (h *Hub) persistFetchStats(key string, bucket *fetchMetrics) {
-	if h.statsStore == nil {
-		return
-	}
-	snapshot := snapshotFetchMetrics(bucket)
-	if err := h.statsStore.UpsertFetchStats(context.Background(), key, snapshot); err != nil {
-		slog.Warn("control.hub.fetch_stats.persist.error", "bucket", key, "err", err)
-	}
-}
-
 func (h *Hub) FetchStatsSnapshot() map[string]FetchStatsSnapshot {
 	h.statsMu.RLock()
 	defer h.statsMu.RUnlock()
@@ -0,0 +1,262 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Minimal RFC 6902 JSON Patch support: DiffJSON emits add/remove/replace
+// operations and ApplyJSONPatch understands add, remove, replace and test.
+
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// DiffJSON returns a JSON Patch that transforms document a into document b.
+func DiffJSON(a, b []byte) ([]byte, error) {
+	av, err := decodeJSONValue(a)
+	if err != nil {
+		return nil, err
+	}
+	bv, err := decodeJSONValue(b)
+	if err != nil {
+		return nil, err
+	}
+	ops := []map[string]any{}
+	diffValues("", av, bv, &ops)
+	return json.Marshal(ops)
+}
+
+func diffValues(path string, a, b interface{}, ops *[]map[string]any) {
+	switch at := a.(type) {
+	case map[string]interface{}:
+		bt, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(at)+len(bt))
+		for k := range at {
+			keys = append(keys, k)
+		}
+		for k := range bt {
+			if _, ok := at[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := path + "/" + escapePointerToken(k)
+			av, inA := at[k]
+			bv, inB := bt[k]
+			switch {
+			case inA && !inB:
+				*ops = append(*ops, map[string]any{"op": "remove", "path": child})
+			case !inA && inB:
+				*ops = append(*ops, map[string]any{"op": "add", "path": child, "value": bv})
+			default:
+				diffValues(child, av, bv, ops)
+			}
+		}
+		return
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		common := len(at)
+		if len(bt) < common {
+			common = len(bt)
+		}
+		for i := 0; i < common; i++ {
+			diffValues(path+"/"+strconv.Itoa(i), at[i], bt[i], ops)
+		}
+		// trim from the end so earlier indices stay valid
+		for i := len(at) - 1; i >= len(bt); i-- {
+			*ops = append(*ops, map[string]any{"op": "remove", "path": path + "/" + strconv.Itoa(i)})
+		}
+		for i := len(at); i < len(bt); i++ {
+			*ops = append(*ops, map[string]any{"op": "add", "path": path + "/" + strconv.Itoa(i), "value": bt[i]})
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*ops = append(*ops, map[string]any{"op": "replace", "path": path, "value": b})
+	}
+}
+
+// ApplyJSONPatch applies patch to doc and returns the canonical encoding of the result.
+func ApplyJSONPatch(doc, patch []byte) ([]byte, error) {
+	root, err := decodeJSONValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	var ops []patchOp
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("decode patch: %w", err)
+	}
+	for i, op := range ops {
+		var value interface{}
+		if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+			if len(op.Value) == 0 {
+				return nil, fmt.Errorf("patch op %d (%s %s): missing value", i, op.Op, op.Path)
+			}
+			if value, err = decodeJSONValue(op.Value); err != nil {
+				return nil, fmt.Errorf("patch op %d: %w", i, err)
+			}
+		}
+		if root, err = applyPatchOp(root, op.Op, op.Path, value); err != nil {
+			return nil, fmt.Errorf("patch op %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := writeCanonicalJSON(buf, root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func applyPatchOp(root interface{}, op, path string, value interface{}) (interface{}, error) {
+	if path == "" {
+		switch op {
+		case "add", "replace":
+			return value, nil
+		case "test":
+			if !reflect.DeepEqual(root, value) {
+				return nil, fmt.Errorf("test failed")
+			}
+			return root, nil
+		default:
+			return nil, fmt.Errorf("unsupported op on document root")
+		}
+	}
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	parentTokens, last := tokens[:len(tokens)-1], tokens[len(tokens)-1]
+	return root, mutateAt(&root, parentTokens, func(parent *interface{}) error {
+		switch container := (*parent).(type) {
+		case map[string]interface{}:
+			current, exists := container[last]
+			switch op {
+			case "add":
+				container[last] = value
+			case "replace":
+				if !exists {
+					return fmt.Errorf("path not found")
+				}
+				container[last] = value
+			case "remove":
+				if !exists {
+					return fmt.Errorf("path not found")
+				}
+				delete(container, last)
+			case "test":
+				if !exists || !reflect.DeepEqual(current, value) {
+					return fmt.Errorf("test failed")
+				}
+			default:
+				return fmt.Errorf("unsupported op %q", op)
+			}
+			return nil
+		case []interface{}:
+			if op == "add" && last == "-" {
+				*parent = append(container, value)
+				return nil
+			}
+			idx, err := strconv.Atoi(last)
+			if err != nil || idx < 0 {
+				return fmt.Errorf("invalid array index %q", last)
+			}
+			switch op {
+			case "add":
+				if idx > len(container) {
+					return fmt.Errorf("index out of range")
+				}
+				container = append(container, nil)
+				copy(container[idx+1:], container[idx:])
+				container[idx] = value
+				*parent = container
+			case "replace":
+				if idx >= len(container) {
+					return fmt.Errorf("index out of range")
+				}
+				container[idx] = value
+			case "remove":
+				if idx >= len(container) {
+					return fmt.Errorf("index out of range")
+				}
+				*parent = append(container[:idx], container[idx+1:]...)
+			case "test":
+				if idx >= len(container) || !reflect.DeepEqual(container[idx], value) {
+					return fmt.Errorf("test failed")
+				}
+			default:
+				return fmt.Errorf("unsupported op %q", op)
+			}
+			return nil
+		default:
+			return fmt.Errorf("parent is not a container")
+		}
+	})
+}
+
+// mutateAt walks tokens from node and calls fn with a pointer to the value found,
+// writing back any replacement so array growth propagates to the parent.
+func mutateAt(node *interface{}, tokens []string, fn func(*interface{}) error) error {
+	if len(tokens) == 0 {
+		return fn(node)
+	}
+	switch container := (*node).(type) {
+	case map[string]interface{}:
+		child, ok := container[tokens[0]]
+		if !ok {
+			return fmt.Errorf("path not found")
+		}
+		if err := mutateAt(&child, tokens[1:], fn); err != nil {
+			return err
+		}
+		container[tokens[0]] = child
+		return nil
+	case []interface{}:
+		idx, err := strconv.Atoi(tokens[0])
+		if err != nil || idx < 0 || idx >= len(container) {
+			return fmt.Errorf("invalid array index %q", tokens[0])
+		}
+		return mutateAt(&container[idx], tokens[1:], fn)
+	default:
+		return fmt.Errorf("path not found")
+	}
+}
+
+func parsePointer(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", path)
+	}
+	parts := strings.Split(path[1:], "/")
+	for i, p := range parts {
+		parts[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(p)
+	}
+	return parts, nil
+}
+
+func escapePointerToken(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func decodeJSONValue(in []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(in))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
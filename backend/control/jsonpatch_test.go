@@ -0,0 +1,62 @@
+package control
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffJSONRoundTrip(t *testing.T) {
+	t.Helper()
+	cases := []struct {
+		name string
+		a, b string
+	}{
+		{"append detections", `[{"ID":"r1","Detections":[{"ID":"d1"}],"End":""}]`, `[{"ID":"r1","Detections":[{"ID":"d1"},{"ID":"d2","Peak":12}],"End":"x"}]`},
+		{"remove key and trim", `{"a":1,"b":[1,2,3],"c/d":"~"}`, `{"b":[1],"c/d":null}`},
+		{"type change", `{"a":{"b":1}}`, `{"a":[1,2]}`},
+		{"root replace", `[1]`, `"x"`},
+		{"unchanged", `{"a":1}`, `{"a":1}`},
+	}
+	for _, tc := range cases {
+		patch, err := DiffJSON([]byte(tc.a), []byte(tc.b))
+		if err != nil {
+			t.Fatalf("%s: diff: %v", tc.name, err)
+		}
+		want, err := CanonicalizeJSON([]byte(tc.b))
+		if err != nil {
+			t.Fatalf("%s: canonicalize: %v", tc.name, err)
+		}
+		got, err := ApplyDelta([]byte(tc.a), patch, ComputeETag(want))
+		if err != nil {
+			t.Fatalf("%s: apply %s: %v", tc.name, patch, err)
+		}
+		if string(got) != string(want) {
+			t.Fatalf("%s: got %s want %s", tc.name, got, want)
+		}
+	}
+}
+
+func TestApplyDeltaRejectsWrongETag(t *testing.T) {
+	t.Helper()
+	patch, err := DiffJSON([]byte(`{"a":1}`), []byte(`{"a":2}`))
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if _, err := ApplyDelta([]byte(`{"a":1}`), patch, ComputeETag([]byte(`{"a":3}`))); err == nil {
+		t.Fatalf("expected etag mismatch")
+	}
+}
+
+func TestBodyCacheBuildDelta(t *testing.T) {
+	t.Helper()
+	c := newBodyCache()
+	base := []byte(`{"items":[` + strings.Repeat(`{"Pilot":"pilot-guid","Peak":100},`, 20) + `{"Pilot":"last","Peak":1}]}`)
+	next := []byte(`{"items":[` + strings.Repeat(`{"Pilot":"pilot-guid","Peak":100},`, 20) + `{"Pilot":"last","Peak":2}]}`)
+	c.put(ComputeETag(base), base)
+	if _, ok := c.buildDelta(ComputeETag(base), next); !ok {
+		t.Fatalf("expected delta for small change")
+	}
+	if _, ok := c.buildDelta(ComputeETag(next), base); ok {
+		t.Fatalf("expected no delta for unknown base")
+	}
+}
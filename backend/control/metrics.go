@@ -0,0 +1,55 @@
+package control
+
+import (
+	"sort"
+
+	"drone-dashboard/metrics"
+)
+
+// Collect reports control link metrics for /metrics on the cloud.
+func (h *Hub) Collect(w *metrics.Writer) {
+	w.Gauge("control_fetch_inflight", "Fetches waiting on a pits response.", float64(h.InFlight()))
+	pits := h.ConnectedPits()
+	w.Gauge("control_pits_connected", "Pits connected over the control link.", float64(len(pits)))
+	for _, p := range pits {
+		w.Gauge("control_pits_info", "Connected pits with build and protocol version.", 1,
+			metrics.L("pits", p.PitsID), metrics.L("version", p.SWVersion), metrics.L("protocol", p.Protocol))
+		w.Gauge("control_pits_rtt_seconds", "Round trip of the last answered server ping.", p.RTTMs/1000, metrics.L("pits", p.PitsID))
+		if p.Clock != nil && p.Clock.Samples > 0 {
+			w.Gauge("control_pits_clock_offset_seconds", "Estimated pits clock offset; positive means the pits runs ahead.", p.Clock.OffsetMs/1000, metrics.L("pits", p.PitsID))
+		}
+	}
+	stats := h.FetchStatsSnapshot()
+	buckets := make([]string, 0, len(stats))
+	for bucket := range stats {
+		buckets = append(buckets, bucket)
+	}
+	sort.Strings(buckets)
+	const fetchHelp = "Control fetches by bucket and result."
+	for _, bucket := range buckets {
+		s := stats[bucket]
+		w.Counter("control_fetch_total", fetchHelp, float64(s.FullResponses), metrics.L("bucket", bucket), metrics.L("result", "full"))
+		w.Counter("control_fetch_total", fetchHelp, float64(s.ETagHits), metrics.L("bucket", bucket), metrics.L("result", "not_modified"))
+		w.Counter("control_fetch_total", fetchHelp, float64(s.Errors), metrics.L("bucket", bucket), metrics.L("result", "error"))
+	}
+}
+
+// Collect reports the pits fetch limiter for /metrics on the pits.
+func (p *PitsClient) Collect(w *metrics.Writer) {
+	s := p.FetchQueueStats()
+	w.Gauge("pits_fetch_concurrency", "Concurrent FPVTrackside fetches allowed for cloud requests.", float64(s.Concurrency))
+	w.Gauge("pits_fetch_running", "Cloud fetches currently talking to FPVTrackside.", float64(s.Running))
+	w.Gauge("pits_fetch_queued", "Cloud fetches waiting for a slot.", float64(s.Queued))
+	w.Counter("pits_fetch_admitted_total", "Cloud fetches that got a slot.", float64(s.Admitted))
+	w.Counter("pits_fetch_rejected_total", "Cloud fetches refused because the queue was full or the wait expired.", float64(s.Rejected))
+	w.Counter("pits_fetch_cancelled_total", "Cloud fetches cancelled while queued.", float64(s.Cancelled))
+	w.Counter("pits_fetch_queue_wait_seconds_total", "Total time cloud fetches spent queued.", float64(s.WaitMsTotal)/1000)
+	const cacheHelp = "Cloud fetches by where the FPVTrackside response came from."
+	w.Counter("pits_fetch_origin_total", cacheHelp, float64(s.Cache.Hits), metrics.L("source", originFromCache))
+	w.Counter("pits_fetch_origin_total", cacheHelp, float64(s.Cache.Coalesced), metrics.L("source", originFromShared))
+	w.Counter("pits_fetch_origin_total", cacheHelp, float64(s.Cache.Origin), metrics.L("source", originFromFetch))
+	w.Gauge("pits_fetch_cache_entries", "FPVTrackside responses held for reuse.", float64(s.Cache.Entries))
+	if c := p.CloudClock(); c.Samples > 0 {
+		w.Gauge("pits_cloud_clock_offset_seconds", "Estimated cloud clock offset; positive means the cloud runs ahead.", c.OffsetMs/1000)
+	}
+}
@@ -0,0 +1,173 @@
+package control
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultFetchCacheTTL is short enough that the cloud never sees a noticeably
+// stale race, and long enough to absorb the bursts of fetches for the same
+// path that several cloud loops issue around a heat.
+const defaultFetchCacheTTL = 500 * time.Millisecond
+
+// Where an origin response came from, for logs and spans.
+const (
+	originFromCache  = "cache"
+	originFromShared = "shared"
+	originFromFetch  = "origin"
+)
+
+// originResponse is one FPVTrackside reply as handleFetch serves it; JSON
+// bodies are already canonical.
+type originResponse struct {
+	status      int
+	contentType string
+	body        []byte
+	etag        string
+	canonical   bool
+	fetchedAt   time.Time
+}
+
+func (r *originResponse) ok() bool { return r.status >= 200 && r.status < 300 }
+
+// originCall is one FPVTrackside request shared by every fetch of its path that
+// arrives while it runs. It is cancelled once all of them have given up.
+type originCall struct {
+	done    chan struct{}
+	res     *originResponse
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// FetchCacheStats reports the pits response cache.
+type FetchCacheStats struct {
+	TTLMs     int64 `json:"ttlMs"`
+	Entries   int   `json:"entries"`
+	Hits      int64 `json:"hits"`
+	Coalesced int64 `json:"coalesced"`
+	Origin    int64 `json:"origin"`
+}
+
+// originCache sits in front of the fetch queue: fresh 2xx responses are served
+// from memory and concurrent fetches of one path share a single request.
+type originCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*originResponse
+	calls   map[string]*originCall
+
+	hits      atomic.Int64
+	coalesced atomic.Int64
+	origin    atomic.Int64
+}
+
+// newOriginCache returns a cache keeping responses for ttl. A ttl of 0 still
+// coalesces concurrent fetches but never reuses a finished one.
+func newOriginCache(ttl time.Duration) *originCache {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return &originCache{
+		ttl:     ttl,
+		entries: make(map[string]*originResponse),
+		calls:   make(map[string]*originCall),
+	}
+}
+
+// get returns the response for path, calling fetch only when no fresh entry
+// exists and no request for path is already running. fetch runs with a context
+// that keeps ctx's values (trace span) but is only cancelled when every waiter
+// has left. The second result says where the response came from.
+func (c *originCache) get(ctx context.Context, path string, fetch func(context.Context) (*originResponse, error)) (*originResponse, string, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[path]; ok && time.Since(e.fetchedAt) < c.ttl {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return e, originFromCache, nil
+	}
+	from := originFromShared
+	call, ok := c.calls[path]
+	if ok {
+		call.waiters++
+		c.coalesced.Add(1)
+	} else {
+		from = originFromFetch
+		c.origin.Add(1)
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &originCall{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		c.calls[path] = call
+		go c.run(callCtx, path, call, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.res, from, call.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		call.waiters--
+		abandoned := call.waiters == 0
+		if abandoned {
+			// nobody wants it any more: abort and let the next fetch start afresh
+			call.cancel()
+			if c.calls[path] == call {
+				delete(c.calls, path)
+			}
+		}
+		c.mu.Unlock()
+		if abandoned {
+			// the aborted request returns promptly; wait so its queue slot is
+			// free by the time the cancelled fetch is done
+			<-call.done
+		}
+		return nil, from, ctx.Err()
+	}
+}
+
+func (c *originCache) run(ctx context.Context, path string, call *originCall, fetch func(context.Context) (*originResponse, error)) {
+	defer call.cancel()
+	res, err := fetch(ctx)
+	c.mu.Lock()
+	if c.calls[path] == call {
+		delete(c.calls, path)
+	}
+	if err == nil && res.ok() && c.ttl > 0 {
+		c.store(path, res)
+	}
+	call.res, call.err = res, err
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// store adds res and drops expired entries; c.mu must be held.
+func (c *originCache) store(path string, res *originResponse) {
+	for p, e := range c.entries {
+		if time.Since(e.fetchedAt) >= c.ttl {
+			delete(c.entries, p)
+		}
+	}
+	c.entries[path] = res
+}
+
+func (c *originCache) snapshot() FetchCacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return FetchCacheStats{
+		TTLMs:     c.ttl.Milliseconds(),
+		Entries:   entries,
+		Hits:      c.hits.Load(),
+		Coalesced: c.coalesced.Load(),
+		Origin:    c.origin.Load(),
+	}
+}
+
+// SetFetchCacheTTL changes how long FPVTrackside responses are reused for
+// cloud fetches; 0 disables reuse but keeps concurrent fetches coalesced. Call
+// it before Start.
+func (p *PitsClient) SetFetchCacheTTL(ttl time.Duration) {
+	p.origin = newOriginCache(ttl)
+}
@@ -0,0 +1,132 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCountingOrigin(t *testing.T, gate chan struct{}) (*PitsClient, *atomic.Int64) {
+	t.Helper()
+	var hits atomic.Int64
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if gate != nil {
+			<-gate
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"b":1, "a":2}`))
+	}))
+	t.Cleanup(origin.Close)
+	p, err := NewPitsClient("", "", "north", origin.URL)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	p.HTTP = origin.Client()
+	return p, &hits
+}
+
+func (p *PitsClient) getOrigin(ctx context.Context, path string) (*originResponse, string, error) {
+	return p.origin.get(ctx, path, func(ctx context.Context) (*originResponse, error) {
+		return p.fetchOrigin(ctx, p.FPVBase.String()+path, path, time.Second)
+	})
+}
+
+func TestOriginCacheCoalescesAndReuses(t *testing.T) {
+	gate := make(chan struct{})
+	p, hits := newCountingOrigin(t, gate)
+	p.SetFetchCacheTTL(time.Hour)
+
+	const n = 4
+	var wg sync.WaitGroup
+	froms := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, from, err := p.getOrigin(context.Background(), "/events/e1/Race.json")
+			if err != nil {
+				t.Errorf("fetch: %v", err)
+			} else if string(res.body) != `{"a":2,"b":1}` {
+				t.Errorf("expected canonical body, got %s", res.body)
+			}
+			froms <- from
+		}()
+	}
+	// let every fetch join before the origin answers
+	deadline := time.Now().Add(2 * time.Second)
+	for p.origin.coalesced.Load() < n-1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(gate)
+	wg.Wait()
+	close(froms)
+	counts := map[string]int{}
+	for f := range froms {
+		counts[f]++
+	}
+	if hits.Load() != 1 || counts[originFromFetch] != 1 || counts[originFromShared] != n-1 {
+		t.Fatalf("expected one origin request, got %d hits, %v", hits.Load(), counts)
+	}
+
+	if _, from, err := p.getOrigin(context.Background(), "/events/e1/Race.json"); err != nil || from != originFromCache {
+		t.Fatalf("expected cached response, got %q %v", from, err)
+	}
+	if s := p.FetchQueueStats().Cache; s.Hits != 1 || s.Origin != 1 || s.Coalesced != n-1 || s.Entries != 1 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestOriginCacheZeroTTLOnlyCoalesces(t *testing.T) {
+	p, hits := newCountingOrigin(t, nil)
+	p.SetFetchCacheTTL(0)
+	for i := 0; i < 2; i++ {
+		if _, from, err := p.getOrigin(context.Background(), "/events/e1/Event.json"); err != nil || from != originFromFetch {
+			t.Fatalf("fetch %d: %q %v", i, from, err)
+		}
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("expected no reuse, got %d origin hits", hits.Load())
+	}
+}
+
+func TestOriginCacheSurvivesOneWaiterLeaving(t *testing.T) {
+	gate := make(chan struct{})
+	p, hits := newCountingOrigin(t, gate)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() {
+		_, _, err := p.getOrigin(ctx, "/events/e1/Race.json")
+		leaderDone <- err
+	}()
+	for hits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	followerDone := make(chan error, 1)
+	go func() {
+		_, _, err := p.getOrigin(context.Background(), "/events/e1/Race.json")
+		followerDone <- err
+	}()
+	for p.origin.coalesced.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the fetch that started the request gives up; the follower still needs it
+	cancel()
+	if err := <-leaderDone; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected leader cancellation, got %v", err)
+	}
+	close(gate)
+	if err := <-followerDone; err != nil {
+		t.Fatalf("follower lost the shared response: %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("expected one origin request, got %d", hits.Load())
+	}
+}
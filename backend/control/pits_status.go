@@ -0,0 +1,185 @@
+package control
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const pitsStatusCollection = "pits_status"
+
+// PitsStatusStore records control link presence so viewers can tell when the
+// venue stopped sending.
+type PitsStatusStore interface {
+	PitsConnected(info PitsInfo) error
+	PitsHeartbeat(pitsID string, health LinkHealth) error
+	PitsDisconnected(pitsID string, at time.Time) error
+	// ResetPitsStatus marks every pits disconnected, as links do not survive a
+	// restart, and drops the rows of pits not in keep unless keep is empty.
+	ResetPitsStatus(at time.Time, keep []string) error
+}
+
+// LinkHealth is what a ping/pong round trip tells about a pits link.
+type LinkHealth struct {
+	LastFrameAt time.Time
+	RTT         time.Duration
+	// ClockOffset is how far the pits clock runs ahead of the cloud's.
+	ClockOffset time.Duration
+}
+
+// SetPitsStatusStore configures the sink for pits presence.
+func (h *Hub) SetPitsStatusStore(store PitsStatusStore) {
+	h.statusStore = store
+}
+
+// SetPitsIDs limits presence to the pits this cloud serves; others neither
+// get a row nor keep one from an earlier configuration.
+func (h *Hub) SetPitsIDs(ids []string) {
+	h.pitsIDs = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		h.pitsIDs[id] = true
+	}
+}
+
+// tracksStatus says whether pitsID's presence is recorded.
+func (h *Hub) tracksStatus(pitsID string) bool {
+	return h.statusStore != nil && pitsID != "" && (len(h.pitsIDs) == 0 || h.pitsIDs[pitsID])
+}
+
+// resetPitsStatus clears presence left over from a previous process; every
+// pits has to reconnect to this one.
+func (h *Hub) resetPitsStatus() {
+	if h.statusStore == nil {
+		return
+	}
+	keep := make([]string, 0, len(h.pitsIDs))
+	for id := range h.pitsIDs {
+		keep = append(keep, id)
+	}
+	if err := h.statusStore.ResetPitsStatus(time.Now(), keep); err != nil {
+		slog.Warn("control.hub.pits_status.reset.error", "err", err)
+	}
+}
+
+func (h *Hub) reportConnected(c *Conn) {
+	if !h.tracksStatus(c.PitsID) {
+		return
+	}
+	if err := h.statusStore.PitsConnected(c.info()); err != nil {
+		slog.Warn("control.hub.pits_status.error", "pitsId", c.PitsID, "event", "connected", "err", err)
+	}
+}
+
+func (h *Hub) reportDisconnected(pitsID string) {
+	if !h.tracksStatus(pitsID) {
+		return
+	}
+	if err := h.statusStore.PitsDisconnected(pitsID, time.Now()); err != nil {
+		slog.Warn("control.hub.pits_status.error", "pitsId", pitsID, "event", "disconnected", "err", err)
+	}
+}
+
+func (h *Hub) reportHeartbeat(c *Conn, rtt time.Duration) {
+	if !h.tracksStatus(c.PitsID) {
+		return
+	}
+	health := LinkHealth{LastFrameAt: c.LastFrameAt(), RTT: rtt, ClockOffset: h.VenueClock(c.PitsID).Offset()}
+	if err := h.statusStore.PitsHeartbeat(c.PitsID, health); err != nil {
+		slog.Warn("control.hub.pits_status.error", "pitsId", c.PitsID, "event", "heartbeat", "err", err)
+	}
+}
+
+// NewPocketBasePitsStatusStore returns a PitsStatusStore backed by PocketBase.
+func NewPocketBasePitsStatusStore(app core.App) PitsStatusStore {
+	return &pocketBasePitsStatusStore{app: app}
+}
+
+type pocketBasePitsStatusStore struct {
+	app core.App
+}
+
+func (s *pocketBasePitsStatusStore) find(pitsID string) (*core.Record, error) {
+	rec, err := s.app.FindFirstRecordByFilter(pitsStatusCollection, "pitsId = {:pits}", dbx.Params{"pits": pitsID})
+	if err == nil {
+		return rec, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	col, err := s.app.FindCollectionByNameOrId(pitsStatusCollection)
+	if err != nil {
+		return nil, err
+	}
+	rec = core.NewRecord(col)
+	rec.Set("pitsId", pitsID)
+	return rec, nil
+}
+
+func (s *pocketBasePitsStatusStore) PitsConnected(info PitsInfo) error {
+	rec, err := s.find(info.PitsID)
+	if err != nil {
+		return err
+	}
+	connectedAt := time.UnixMilli(info.ConnectedAt)
+	rec.Set("connected", true)
+	rec.Set("connectedAt", connectedAt)
+	rec.Set("lastFrameAt", connectedAt)
+	rec.Set("swVersion", info.SWVersion)
+	rec.Set("protocol", info.Protocol)
+	rec.Set("remoteAddr", info.RemoteAddr)
+	return s.app.Save(rec)
+}
+
+func (s *pocketBasePitsStatusStore) PitsHeartbeat(pitsID string, health LinkHealth) error {
+	rec, err := s.find(pitsID)
+	if err != nil {
+		return err
+	}
+	rec.Set("connected", true)
+	rec.Set("lastFrameAt", health.LastFrameAt)
+	rec.Set("rttMs", float64(health.RTT.Microseconds())/1000)
+	rec.Set("clockOffsetMs", float64(health.ClockOffset.Microseconds())/1000)
+	return s.app.Save(rec)
+}
+
+func (s *pocketBasePitsStatusStore) PitsDisconnected(pitsID string, at time.Time) error {
+	rec, err := s.find(pitsID)
+	if err != nil {
+		return err
+	}
+	rec.Set("connected", false)
+	rec.Set("disconnectedAt", at)
+	return s.app.Save(rec)
+}
+
+func (s *pocketBasePitsStatusStore) ResetPitsStatus(at time.Time, keep []string) error {
+	kept := make(map[string]bool, len(keep))
+	for _, id := range keep {
+		kept[id] = true
+	}
+	recs, err := s.app.FindAllRecords(pitsStatusCollection)
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		if len(kept) > 0 && !kept[rec.GetString("pitsId")] {
+			if err := s.app.Delete(rec); err != nil {
+				return err
+			}
+			continue
+		}
+		if !rec.GetBool("connected") {
+			continue
+		}
+		rec.Set("connected", false)
+		rec.Set("disconnectedAt", at)
+		if err := s.app.Save(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package control
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+
+	_ "drone-dashboard/migrations"
+)
+
+func waitForStatus(t *testing.T, app core.App, pitsID string, cond func(*core.Record) bool) *core.Record {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec, err := app.FindFirstRecordByData(pitsStatusCollection, "pitsId", pitsID)
+		if err == nil && cond(rec) {
+			return rec
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pits_status for %s never reached expected state (last err %v)", pitsID, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPitsStatusFollowsConnection(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+	hub := NewHub()
+	hub.SetPitsStatusStore(NewPocketBasePitsStatusStore(app))
+
+	ws := dialTestServer(t, hub, "")
+	if err := ws.WriteJSON(NewEnvelope(TypeHello, "h1", Hello{ProtocolVersion: ProtocolMajor, ProtocolMinor: ProtocolMinor, PitsID: "north", SWVersion: "v1.2.3"})); err != nil {
+		t.Fatalf("write hello: %v", err)
+	}
+	rec := waitForStatus(t, app, "north", func(r *core.Record) bool { return r.GetBool("connected") })
+	if rec.GetString("swVersion") != "v1.2.3" || rec.GetString("protocol") == "" || rec.GetDateTime("connectedAt").IsZero() {
+		t.Fatalf("incomplete connected status: %v", rec.FieldsData())
+	}
+	if _, ok := rec.PublicExport()["remoteAddr"]; ok {
+		t.Fatalf("remoteAddr must not be public")
+	}
+
+	_ = ws.Close()
+	rec = waitForStatus(t, app, "north", func(r *core.Record) bool { return !r.GetBool("connected") })
+	if rec.GetDateTime("disconnectedAt").IsZero() {
+		t.Fatalf("disconnectedAt not set: %v", rec.FieldsData())
+	}
+}
+
+func TestPongRecordsRoundTrip(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+	hub := NewHub()
+	hub.SetPitsStatusStore(NewPocketBasePitsStatusStore(app))
+
+	c := &Conn{PitsID: "north", ConnectedAt: time.Now()}
+	c.pingID.Store("ping-2")
+	c.pingSentAt.Store(time.Now().Add(-40 * time.Millisecond).UnixNano())
+
+	c.handlePong(hub, Envelope{Type: TypePong, ID: "ping-1"})
+	if c.RTT() != 0 {
+		t.Fatalf("stale pong measured: %v", c.RTT())
+	}
+	// the pits clock runs 5s ahead
+	c.handlePong(hub, Envelope{Type: TypePong, ID: "ping-2", TS: time.Now().Add(5 * time.Second).UnixMilli()})
+	if c.RTT() < 40*time.Millisecond {
+		t.Fatalf("unexpected rtt %v", c.RTT())
+	}
+	if off := hub.VenueClock("north").Offset(); off < 4900*time.Millisecond || off > 5100*time.Millisecond {
+		t.Fatalf("clock offset = %v, want about 5s", off)
+	}
+	rec := waitForStatus(t, app, "north", func(r *core.Record) bool { return r.GetFloat("rttMs") >= 40 })
+	if !rec.GetBool("connected") || rec.GetDateTime("lastFrameAt").IsZero() || rec.GetFloat("clockOffsetMs") < 4900 {
+		t.Fatalf("heartbeat not recorded: %v", rec.FieldsData())
+	}
+}
+
+func TestPitsStatusKeepsOnlyConfiguredPits(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+	store := NewPocketBasePitsStatusStore(app)
+	for _, id := range []string{"north", "retired"} {
+		if err := store.PitsConnected(PitsInfo{PitsID: id, ConnectedAt: time.Now().UnixMilli()}); err != nil {
+			t.Fatalf("seed %s: %v", id, err)
+		}
+	}
+	hub := NewHub()
+	hub.SetPitsStatusStore(store)
+	hub.SetPitsIDs([]string{"north"})
+
+	hub.resetPitsStatus()
+	if _, err := app.FindFirstRecordByData(pitsStatusCollection, "pitsId", "retired"); err == nil {
+		t.Fatalf("row of a pits no longer configured survived the reset")
+	}
+	north, err := app.FindFirstRecordByData(pitsStatusCollection, "pitsId", "north")
+	if err != nil || north.GetBool("connected") {
+		t.Fatalf("north after reset: %v, %v", north, err)
+	}
+
+	hub.reportConnected(&Conn{PitsID: "stranger", ConnectedAt: time.Now()})
+	if _, err := app.FindFirstRecordByData(pitsStatusCollection, "pitsId", "stranger"); err == nil {
+		t.Fatalf("unconfigured pits got a status row")
+	}
+}
@@ -1,15 +1,54 @@
 package control
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Message types
 const (
-	TypeHello    = "hello"
-	TypeFetch    = "fetch"
-	TypeResponse = "response"
-	TypeError    = "error"
-	TypePing     = "ping"
-	TypePong     = "pong"
+	TypeHello     = "hello"
+	TypeFetch     = "fetch"
+	TypeResponse  = "response"
+	TypeError     = "error"
+	TypePing      = "ping"
+	TypePong      = "pong"
+	TypePush      = "push"
+	TypeChangelog = "changelog"
+	// TypeCommand asks the pits to run a named action; it answers with
+	// TypeCommandResult or TypeError under the same ID.
+	TypeCommand       = "command"
+	TypeCommandResult = "command_result"
+	// TypeCancel withdraws the fetch or command with the same ID.
+	TypeCancel = "cancel"
+)
+
+// Commands the cloud may send to a pits.
+const (
+	CommandFullAuto         = "ingest.fullAuto"
+	CommandPurge            = "ingest.purge"
+	CommandSchedulerEnabled = "scheduler.setEnabled"
+	CommandIngestTargets    = "scheduler.targets"
+	CommandFetchQueue       = "control.fetchQueue"
+)
+
+// Protocol version carried in Hello. Peers must share the major version; the
+// minor version and Features decide which newer behaviors a link may use.
+const (
+	ProtocolMajor = 1
+	ProtocolMinor = 2
+)
+
+// Features advertised in Hello.Features
+const (
+	FeatureETag      = "etag"
+	FeaturePush      = "push"
+	FeatureBinary    = "binary-gzip"
+	FeaturePatch     = "json-patch"
+	FeatureHMAC      = "hmac-sha256"
+	FeatureChangelog = "changelog"
+	FeatureCommands  = "commands"
+	FeatureCancel    = "cancel"
 )
 
 type Envelope struct {
@@ -18,6 +57,11 @@ type Envelope struct {
 	TS      int64       `json:"ts"`
 	TraceID string      `json:"traceId,omitempty"`
 	Payload interface{} `json:"payload"`
+	// Sig is the HMAC over the frame when FeatureHMAC was negotiated.
+	Sig string `json:"sig,omitempty"`
+
+	// rawPayload keeps the payload bytes as received for signature checks.
+	rawPayload json.RawMessage
 }
 
 func NewEnvelope(t string, id string, payload interface{}) Envelope {
@@ -25,11 +69,16 @@ func NewEnvelope(t string, id string, payload interface{}) Envelope {
 }
 
 type Hello struct {
+	// ProtocolVersion is the major version; ProtocolMinor is absent on 1.0 peers.
 	ProtocolVersion int      `json:"protocolVersion"`
+	ProtocolMinor   int      `json:"protocolMinor,omitempty"`
 	PitsID          string   `json:"pitsId,omitempty"`
 	SWVersion       string   `json:"swVersion,omitempty"`
 	Features        []string `json:"features,omitempty"`
 	ServerTimeMs    int64    `json:"serverTimeMs,omitempty"`
+	// UTCOffsetSec is the pits' time zone offset, the zone FPVTrackside
+	// timestamps are written in. Absent on older pits.
+	UTCOffsetSec *int `json:"utcOffsetSec,omitempty"`
 }
 
 type Fetch struct {
@@ -39,12 +88,58 @@ type Fetch struct {
 	Headers     map[string]string `json:"headers,omitempty"`
 	TimeoutMs   int               `json:"timeoutMs"`
 	TraceID     string            `json:"traceId,omitempty"`
+	// SpanID is the cloud's fetch span; the pits records its spans as children.
+	SpanID string `json:"spanId,omitempty"`
 }
 
 type Response struct {
 	Status  int               `json:"status"`
 	Headers map[string]string `json:"headers"`
 	BodyB64 string            `json:"bodyB64,omitempty"`
+	// Encoding is empty for full bodies or EncodingJSONPatch for a delta against BaseETag.
+	Encoding string `json:"encoding,omitempty"`
+	BaseETag string `json:"baseEtag,omitempty"`
+	// Body holds raw bytes for binary frames; JSON frames use BodyB64.
+	Body []byte `json:"-"`
+}
+
+// Push carries a payload the pits published on its own, without a matching fetch.
+// The ETag header is computed with ComputeETag over the canonical body.
+type Push struct {
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	BodyB64 string            `json:"bodyB64,omitempty"`
+	// Body holds raw bytes for binary frames; JSON frames use BodyB64.
+	Body []byte `json:"-"`
+}
+
+type Command struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type Cancel struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+type CommandResult struct {
+	Name       string          `json:"name"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	DurationMs int64           `json:"durationMs"`
+}
+
+// Changelog lists FPVTrackside paths whose local records changed while the
+// link was down, so the cloud can refresh them in one pass after reconnecting.
+type Changelog struct {
+	SinceMs int64            `json:"sinceMs"`
+	Entries []ChangelogEntry `json:"entries"`
+}
+
+type ChangelogEntry struct {
+	Path string `json:"path"`
+	// Records counts local rows behind the path that changed since SinceMs.
+	Records     int   `json:"records"`
+	LastUpdated int64 `json:"lastUpdated,omitempty"`
 }
 
 type Error struct {
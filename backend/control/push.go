@@ -0,0 +1,208 @@
+package control
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// pushQueueSize bounds buffered pushes on both ends of the link. Dropped pushes
+// are harmless: the cloud scheduler keeps polling and will catch up.
+const pushQueueSize = 64
+
+// PushHandler applies payloads that a pits published without being asked.
+type PushHandler interface {
+	HandlePush(pitsID string, push Push) error
+}
+
+// SetPushHandler configures the sink for pits-initiated pushes.
+func (h *Hub) SetPushHandler(handler PushHandler) {
+	h.pushHandler = handler
+}
+
+// applyPush verifies a pushed payload against the fetch allowlist and its ETag
+// and hands it to the push handler.
+func (h *Hub) applyPush(pitsID string, env Envelope) {
+	push, ok := env.Payload.(Push)
+	if !ok {
+		b, _ := json.Marshal(env.Payload)
+		if err := json.Unmarshal(b, &push); err != nil {
+			slog.Warn("control.hub.push.decode.error", "pitsId", pitsID, "id", env.ID, "err", err)
+			return
+		}
+	}
+	if !IsAllowedFetchPath(push.Path) {
+		slog.Warn("control.hub.push.path.rejected", "pitsId", pitsID, "id", env.ID, "path", push.Path)
+		return
+	}
+	headers, body := DecodePush(push)
+	if etag := headers["ETag"]; etag != "" && etag != ComputeETag(body) {
+		slog.Warn("control.hub.push.etag_mismatch", "pitsId", pitsID, "path", push.Path, "etag", etag)
+		return
+	}
+	if h.pushHandler == nil {
+		slog.Debug("control.hub.push.unhandled", "pitsId", pitsID, "path", push.Path)
+		return
+	}
+	start := time.Now()
+	err := h.pushHandler.HandlePush(pitsID, push)
+	fields := []any{
+		"pitsId", pitsID,
+		"path", push.Path,
+		"bytes", len(body),
+		"latencyMs", time.Since(start).Milliseconds(),
+		"traceId", env.TraceID,
+	}
+	if err != nil {
+		fields = append(fields, "error", err.Error())
+		slog.Warn("control.hub.push.error", fields...)
+		return
+	}
+	slog.Debug("control.hub.push", fields...)
+}
+
+// DecodePush decodes a control Push into headers and body bytes.
+func DecodePush(p Push) (map[string]string, []byte) {
+	if p.Body != nil {
+		return p.Headers, p.Body
+	}
+	var body []byte
+	if p.BodyB64 != "" {
+		b, _ := base64.StdEncoding.DecodeString(p.BodyB64)
+		body = b
+	}
+	return p.Headers, body
+}
+
+func (c *Conn) enqueuePush(env Envelope) {
+	select {
+	case c.pushQueue <- env:
+	default:
+		slog.Warn("control.server.push.dropped", "pitsId", c.PitsID, "id", env.ID)
+	}
+}
+
+// runPushWorker applies queued pushes and changelogs one at a time so a newer
+// payload for a path can never be overwritten by an older one. It starts once
+// the Hello registered pitsID, as the read loop owns c.PitsID.
+func (c *Conn) runPushWorker(ctx context.Context, hub *Hub, pitsID string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case env := <-c.pushQueue:
+			if env.Type == TypeChangelog {
+				hub.applyChangelog(pitsID, env)
+				continue
+			}
+			hub.applyPush(pitsID, env)
+		}
+	}
+}
+
+// Publish queues a changed FPVTrackside payload for delivery to the cloud.
+// JSON is canonicalized, as for a fetch, so a path has one ETag however it
+// reaches the cloud. Payloads whose ETag matches what the cloud last received
+// are skipped, as is everything when the cloud did not offer FeaturePush; while
+// the link is down the newest payload per path is held for the outage replay.
+func (p *PitsClient) Publish(path, contentType string, body []byte) {
+	if !IsAllowedFetchPath(path) {
+		return
+	}
+	canonical := false
+	if strings.Contains(contentType, "application/json") {
+		if can, err := CanonicalizeJSON(body); err == nil {
+			body, canonical = can, true
+		}
+	}
+	etag := ComputeETag(body)
+	if canonical && p.bodies != nil {
+		p.bodies.put(etag, body)
+	}
+
+	p.pushMu.Lock()
+	defer p.pushMu.Unlock()
+	if p.pushQueue == nil {
+		if p.Changelog != nil {
+			p.holdBody(path, heldBody{contentType: contentType, body: body})
+		}
+		return
+	}
+	if !p.pushes.Load() || p.pushed[path] == etag {
+		return
+	}
+	headers := map[string]string{"ETag": etag}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	push := Push{
+		Path:    path,
+		Headers: headers,
+		Body:    body,
+	}
+	select {
+	case p.pushQueue <- push:
+		p.pushed[path] = etag
+	default:
+		slog.Debug("control.pits.push.dropped", "path", path, "etag", etag)
+	}
+}
+
+// markDelivered records that the cloud already holds etag for path.
+func (p *PitsClient) markDelivered(path, etag string) {
+	p.pushMu.Lock()
+	defer p.pushMu.Unlock()
+	if p.pushed != nil {
+		p.pushed[path] = etag
+	}
+}
+
+func (p *PitsClient) startPushLoop(ctx context.Context, ws *websocket.Conn, writeMu *sync.Mutex) func() {
+	queue := make(chan Push, pushQueueSize)
+	p.pushMu.Lock()
+	p.pushQueue = queue
+	// a fresh link starts with no knowledge of what the cloud holds
+	p.pushed = make(map[string]string)
+	p.pushMu.Unlock()
+
+	stop := make(chan struct{})
+	var once sync.Once
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case push := <-queue:
+				body := push.Body
+				push.Body = nil
+				env := NewEnvelope(TypePush, newPushID(), push)
+				wireBytes, err := p.writeWithBody(writeMu, ws, env, body)
+				if err != nil {
+					slog.Warn("control.pits.push.send.error", "path", push.Path, "err", err)
+					continue
+				}
+				slog.Debug("control.pits.push.sent", "path", push.Path, "etag", push.Headers["ETag"], "bytes", len(body), "wireBytes", wireBytes)
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() {
+			p.pushMu.Lock()
+			p.pushQueue = nil
+			p.pushMu.Unlock()
+			close(stop)
+		})
+	}
+}
+
+func newPushID() string {
+	return "push-" + newTraceID()
+}
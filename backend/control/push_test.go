@@ -0,0 +1,84 @@
+package control
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPublishHashesBodiesLikeFetch(t *testing.T) {
+	p, _ := NewPitsClient("ws://127.0.0.1:1/control", "", "north", "http://127.0.0.1:1")
+	stop := p.startPushLoop(context.Background(), nil, nil)
+	defer stop()
+	queue := make(chan Push, 2)
+	p.pushMu.Lock()
+	p.pushQueue = queue
+	p.pushMu.Unlock()
+	p.pushes.Store(true)
+
+	json := []byte(`{"b":1, "a":2}`)
+	p.Publish("/events/e1/Event.json", "application/json; charset=utf-8", json)
+	p.Publish("/events/e1/Pilots.json", "text/plain", json)
+
+	can := <-queue
+	if string(can.Body) != `{"a":2,"b":1}` || can.Headers["ETag"] != ComputeETag(can.Body) {
+		t.Fatalf("json push = %s %v", can.Body, can.Headers)
+	}
+	raw := <-queue
+	if string(raw.Body) != string(json) || raw.Headers["ETag"] != ComputeETag(json) || raw.Headers["Content-Type"] != "text/plain" {
+		t.Fatalf("non-json push = %s %v", raw.Body, raw.Headers)
+	}
+}
+
+func TestPublishWaitsForFeaturePush(t *testing.T) {
+	p, _ := NewPitsClient("ws://127.0.0.1:1/control", "", "north", "http://127.0.0.1:1")
+	queue := make(chan Push, 1)
+	p.pushMu.Lock()
+	p.pushQueue = queue
+	p.pushed = make(map[string]string)
+	p.pushMu.Unlock()
+
+	p.Publish("/events/e1/Event.json", "application/json", []byte(`{}`))
+	if len(queue) != 0 {
+		t.Fatalf("pushed to a cloud without %s", FeaturePush)
+	}
+}
+
+type recordingPushSink struct {
+	pushes     []string
+	changelogs []Changelog
+}
+
+func (s *recordingPushSink) HandlePush(pitsID string, push Push) error {
+	s.pushes = append(s.pushes, push.Path)
+	return nil
+}
+
+func (s *recordingPushSink) HandleChangelog(pitsID string, cl Changelog) error {
+	s.changelogs = append(s.changelogs, cl)
+	return nil
+}
+
+func TestHubDropsPushesOutsideTheFetchAllowlist(t *testing.T) {
+	hub := NewHub()
+	sink := &recordingPushSink{}
+	hub.SetPushHandler(sink)
+	hub.SetChangelogHandler(sink)
+
+	for _, path := range []string{"/events/../../etc/passwd", "/events/e1/..\\..\\x.json", "/api/collections", "/events/e1/Event.json"} {
+		hub.applyPush("north", NewEnvelope(TypePush, "push-1", Push{Path: path, Body: []byte(`{}`)}))
+	}
+	if len(sink.pushes) != 1 || sink.pushes[0] != "/events/e1/Event.json" {
+		t.Fatalf("pushes = %v, want only the allowed path", sink.pushes)
+	}
+
+	hub.applyChangelog("north", NewEnvelope(TypeChangelog, "cl-1", Changelog{Entries: []ChangelogEntry{
+		{Path: "/events/e1/../../../x"},
+		{Path: "/events/e1/Laps.json", Records: 3},
+	}}))
+	if len(sink.changelogs) != 1 {
+		t.Fatalf("changelogs = %d, want 1", len(sink.changelogs))
+	}
+	if got := sink.changelogs[0].Entries; len(got) != 1 || got[0].Path != "/events/e1/Laps.json" {
+		t.Fatalf("changelog entries = %v, want only the allowed path", got)
+	}
+}
@@ -4,12 +4,19 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"drone-dashboard/buildinfo"
+
 	"github.com/gorilla/websocket"
 	"github.com/pocketbase/pocketbase/core"
 )
@@ -25,11 +32,43 @@ type Conn struct {
 	// optional identity
 	PitsID string
 	hub    *Hub
+	// cred is what the bearer token authenticated as; its PitsID is empty for
+	// the shared secret. creds re-checks it while the connection lives.
+	cred  credential
+	creds *credentialStore
+	// signKey is the bearer token the pits connected with; the cloud Hello is
+	// signed with it, and later frames once the pits Hello offers FeatureHMAC.
+	signKey  string
+	signing  atomic.Bool
+	verifier *frameVerifier
+	// connection details reported by the admin pits listing
+	RemoteAddr  string
+	ConnectedAt time.Time
+	SWVersion   string
+	Features    []string
+	// negotiated from the pits Hello
+	ProtocolVersion int
+	ProtocolMinor   int
+	// link health: last frame read (unix ms), the outstanding server ping and
+	// the round trip of the last answered one
+	lastFrame  atomic.Int64
+	pingSeq    atomic.Int64
+	pingID     atomic.Value // string
+	pingSentAt atomic.Int64 // unix ns
+	rtt        atomic.Int64 // ns
 	// serialize writes to avoid concurrent write panics
 	writeMu sync.Mutex
+	// pushes are applied in arrival order by a single worker
+	pushQueue chan Envelope
 }
 
 func (c *Conn) SendJSON(v any) error {
+	if env, ok := v.(Envelope); ok && c.signing.Load() {
+		if err := signEnvelope(c.signKey, &env, nil); err != nil {
+			return err
+		}
+		v = env
+	}
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
 	c.ws.SetWriteDeadline(time.Now().Add(15 * time.Second))
@@ -38,6 +77,33 @@ func (c *Conn) SendJSON(v any) error {
 
 func (c *Conn) Close() error { return c.ws.Close() }
 
+// Supports reports whether the pits advertised feature in its Hello.
+func (c *Conn) Supports(feature string) bool { return hasFeature(c.Features, feature) }
+
+// LastFrameAt is when the last frame arrived from the pits.
+func (c *Conn) LastFrameAt() time.Time {
+	if ms := c.lastFrame.Load(); ms != 0 {
+		return time.UnixMilli(ms)
+	}
+	return c.ConnectedAt
+}
+
+// RTT is the round trip of the last server ping the pits answered.
+func (c *Conn) RTT() time.Duration { return time.Duration(c.rtt.Load()) }
+
+func (c *Conn) info() PitsInfo {
+	info := PitsInfo{PitsID: c.PitsID, RemoteAddr: c.RemoteAddr, SWVersion: c.SWVersion, Features: c.Features}
+	if c.ProtocolVersion != 0 {
+		info.Protocol = fmt.Sprintf("%d.%d", c.ProtocolVersion, c.ProtocolMinor)
+	}
+	if !c.ConnectedAt.IsZero() {
+		info.ConnectedAt = c.ConnectedAt.UnixMilli()
+	}
+	info.LastFrameAt = c.LastFrameAt().UnixMilli()
+	info.RTTMs = float64(c.RTT().Microseconds()) / 1000
+	return info
+}
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:    4096,
 	WriteBufferSize:   4096,
@@ -47,17 +113,132 @@ var upgrader = websocket.Upgrader{
 
 // RegisterServer registers the /control route on the PocketBase router for cloud mode.
 func RegisterServer(app core.App, hub *Hub, authSecret string) {
+	creds := &credentialStore{app: app, sharedSecret: authSecret}
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		flushCtx, stopFlush := context.WithCancel(context.Background())
+		if se.Server != nil {
+			se.Server.RegisterOnShutdown(stopFlush)
+		} else {
+			defer stopFlush()
+		}
+		go hub.RunStatsFlush(flushCtx, defaultStatsFlushInterval)
+		hub.resetPitsStatus()
+
 		se.Router.GET("/control/etag-stats", func(c *core.RequestEvent) error {
 			return c.JSON(http.StatusOK, hub.FetchStatsSnapshot())
 		})
+		se.Router.GET("/control/fetch-series", func(c *core.RequestEvent) error {
+			if !isSuperuser(c) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			q := c.Request.URL.Query()
+			window := q.Get("window")
+			since := time.Now().Add(-fetchSeriesRetention(window))
+			if ms, err := strconv.ParseInt(q.Get("sinceMs"), 10, 64); err == nil && ms > 0 {
+				since = time.UnixMilli(ms)
+			}
+			points, err := hub.FetchSeries(c.Request.Context(), window, since)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			return c.JSON(http.StatusOK, points)
+		})
+		se.Router.GET("/control/pits", func(c *core.RequestEvent) error {
+			if !isSuperuser(c) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			return c.JSON(http.StatusOK, hub.ConnectedPits())
+		})
+		se.Router.POST("/control/pits/{pitsId}/tokens", func(c *core.RequestEvent) error {
+			if !isSuperuser(c) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			req, err := readTokenRequest(c)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			issued, err := creds.issue(c.Request.PathValue("pitsId"), req.Label, req.ttl())
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			slog.Info("control.credentials.issued", "pitsId", issued.PitsID, "id", issued.ID)
+			// the pits may still be on the shared secret, which no longer admits it
+			hub.dropRevoked()
+			return c.JSON(http.StatusCreated, issued)
+		})
+		se.Router.POST("/control/pits/{pitsId}/tokens/rotate", func(c *core.RequestEvent) error {
+			if !isSuperuser(c) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			req, err := readTokenRequest(c)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			issued, revoked, err := creds.rotate(c.Request.PathValue("pitsId"), req.Label, req.ttl())
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			slog.Info("control.credentials.rotated", "pitsId", issued.PitsID, "id", issued.ID, "revoked", revoked)
+			hub.dropRevoked()
+			return c.JSON(http.StatusOK, map[string]any{"token": issued, "revoked": revoked})
+		})
+		se.Router.DELETE("/control/pits/{pitsId}/tokens/{tokenId}", func(c *core.RequestEvent) error {
+			if !isSuperuser(c) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			pitsID, id := c.Request.PathValue("pitsId"), c.Request.PathValue("tokenId")
+			if err := creds.revoke(pitsID, id); err != nil {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "token not found"})
+			}
+			slog.Info("control.credentials.revoked", "pitsId", pitsID, "id", id)
+			dropped := hub.dropRevoked()
+			return c.JSON(http.StatusOK, map[string]any{"ok": true, "dropped": dropped})
+		})
+		se.Router.POST("/control/pits/{pitsId}/commands/{name}", func(c *core.RequestEvent) error {
+			if !isSuperuser(c) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			cmd := Command{Name: c.Request.PathValue("name")}
+			if c.Request.ContentLength != 0 {
+				var args json.RawMessage
+				if err := json.NewDecoder(c.Request.Body).Decode(&args); err != nil && !errors.Is(err, io.EOF) {
+					return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body: " + err.Error()})
+				}
+				cmd.Args = args
+			}
+			result, err := hub.DoCommand(c.Request.Context(), c.Request.PathValue("pitsId"), cmd)
+			if err != nil {
+				status := http.StatusBadGateway
+				var remote *RemoteError
+				if errors.As(err, &remote) && (remote.Code == "DENIED" || remote.Code == "UNKNOWN") {
+					status = http.StatusForbidden
+				} else if errors.Is(err, ErrCommandsUnsupported) {
+					status = http.StatusNotImplemented
+				}
+				return c.JSON(status, map[string]any{"ok": false, "error": err.Error()})
+			}
+			return c.JSON(http.StatusOK, map[string]any{"ok": true, "name": result.Name, "durationMs": result.DurationMs, "result": result.Result})
+		})
+		se.Router.GET("/control/pits/{pitsId}/commands", func(c *core.RequestEvent) error {
+			if !isSuperuser(c) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			return c.JSON(http.StatusOK, hub.CommandHistory(c.Request.PathValue("pitsId")))
+		})
 		se.Router.Any("/control", func(c *core.RequestEvent) error {
-			// Simple bearer check; production can be JWT or mTLS (handled at proxy)
+			// Bearer check against the shared secret or a per-pits credential;
+			// production can add JWT or mTLS at the proxy.
+			var cred credential
+			var token string
 			if authSecret != "" {
 				auth := c.Request.Header.Get("Authorization")
-				if !strings.HasPrefix(auth, "Bearer ") || strings.TrimPrefix(auth, "Bearer ") != authSecret {
+				token = strings.TrimPrefix(auth, "Bearer ")
+				found, err := creds.authenticate(token)
+				if !strings.HasPrefix(auth, "Bearer ") || err != nil {
+					slog.Warn("control.server.auth.denied", "remote", c.Request.RemoteAddr, "err", err)
 					return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 				}
+				cred = found
 			}
 
 			w := c.Response
@@ -67,7 +248,7 @@ func RegisterServer(app core.App, hub *Hub, authSecret string) {
 				return c.InternalServerError("upgrade", err)
 			}
 			slog.Debug("control.server.connection", "remote", r.RemoteAddr)
-			conn := &Conn{ws: ws, hub: hub}
+			conn := &Conn{ws: ws, hub: hub, cred: cred, creds: creds, signKey: token, RemoteAddr: r.RemoteAddr, ConnectedAt: time.Now(), pushQueue: make(chan Envelope, pushQueueSize)}
 
 			go serveConn(conn, hub)
 			return nil
@@ -79,95 +260,241 @@ func RegisterServer(app core.App, hub *Hub, authSecret string) {
 func serveConn(c *Conn, hub *Hub) {
 	defer c.ws.Close()
 	// On connect, send hello
-	_ = c.SendJSON(NewEnvelope(TypeHello, "", Hello{ProtocolVersion: 1, ServerTimeMs: time.Now().UnixMilli()}))
+	features := []string{FeatureETag, FeaturePush, FeatureBinary, FeaturePatch, FeatureChangelog, FeatureCommands}
+	if c.signKey != "" {
+		features = append(features, FeatureHMAC)
+		c.signing.Store(true)
+	}
+	_ = c.SendJSON(NewEnvelope(TypeHello, "", Hello{
+		ProtocolVersion: ProtocolMajor,
+		ProtocolMinor:   ProtocolMinor,
+		SWVersion:       buildinfo.String(),
+		ServerTimeMs:    time.Now().UnixMilli(),
+		Features:        features,
+	}))
 	slog.Debug("control.server.hello_sent", "pitsId", c.PitsID)
 
+	// the ping loop and push worker start once the Hello registered the pits
+	// and stop with the read loop
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	stopPing := c.startPingLoop(ctx)
-	defer stopPing()
-
-	_ = c.consumeFrames(hub)
+	_ = c.consumeFrames(ctx, hub)
 }
 
-func (c *Conn) startPingLoop(ctx context.Context) func() {
-	stop := make(chan struct{})
-	var once sync.Once
+// startPingLoop pings the registered pits until ctx ends. pitsID is passed in
+// because the read loop owns c.PitsID.
+func (c *Conn) startPingLoop(ctx context.Context, pitsID string) {
 	ticker := time.NewTicker(serverPingInterval)
 	go func() {
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
-				slog.Debug("control.server.ping.stop", "pitsId", c.PitsID, "reason", "ctx")
-				return
-			case <-stop:
-				slog.Debug("control.server.ping.stop", "pitsId", c.PitsID, "reason", "loop_exit")
+				slog.Debug("control.server.ping.stop", "pitsId", pitsID)
 				return
 			case <-ticker.C:
-				if err := c.SendJSON(NewEnvelope(TypePing, "", nil)); err != nil {
-					slog.Warn("control.server.ping.error", "err", err, "pitsId", c.PitsID)
+				if !c.credentialLive(pitsID) {
+					return
+				}
+				if err := c.sendPing(); err != nil {
+					slog.Warn("control.server.ping.error", "err", err, "pitsId", pitsID)
 					_ = c.ws.Close()
 					return
 				}
-				slog.Debug("control.server.ping.sent", "pitsId", c.PitsID)
+				slog.Debug("control.server.ping.sent", "pitsId", pitsID)
 			}
 		}
 	}()
-	return func() {
-		once.Do(func() { close(stop) })
+}
+
+// credentialLive re-checks the token the pits connected with and drops the
+// connection once it was revoked or expired.
+func (c *Conn) credentialLive(pitsID string) bool {
+	if c.creds == nil {
+		return true
+	}
+	if err := c.creds.check(c.cred, pitsID); err != nil {
+		slog.Warn("control.server.credential.dropped", "pitsId", pitsID, "credential", c.cred.ID, "err", err)
+		_ = c.SendJSON(NewEnvelope(TypeError, "", Error{Code: "FORBIDDEN", Message: err.Error()}))
+		_ = c.ws.Close()
+		return false
 	}
+	return true
 }
 
-func (c *Conn) consumeFrames(hub *Hub) error {
+// sendPing sends a ping whose pong measures RTT and clock offset.
+func (c *Conn) sendPing() error {
+	id := fmt.Sprintf("ping-%d", c.pingSeq.Add(1))
+	c.pingID.Store(id)
+	c.pingSentAt.Store(time.Now().UnixNano())
+	return c.SendJSON(NewEnvelope(TypePing, id, nil))
+}
+
+func (c *Conn) consumeFrames(ctx context.Context, hub *Hub) error {
 	for {
-		var env Envelope
 		c.ws.SetReadDeadline(time.Now().Add(serverReadTimeout))
-		if err := c.ws.ReadJSON(&env); err != nil {
+		messageType, data, err := c.ws.ReadMessage()
+		if err != nil {
 			slog.Warn("control.server.read.error", "err", err, "pitsId", c.PitsID)
 			if c.PitsID != "" {
 				hub.Unregister(c.PitsID, c)
 			}
 			return err
 		}
+		c.lastFrame.Store(time.Now().UnixMilli())
+		env, err := decodeFrame(messageType, data)
+		if c.PitsID == "" && env.Type != TypeHello {
+			// nothing but the Hello is trusted before it registered the pits
+			// and set up frame verification
+			slog.Warn("control.server.frame.unregistered", "type", env.Type, "id", env.ID, "remote", c.RemoteAddr)
+			continue
+		}
+		if err != nil {
+			slog.Warn("control.server.decode.error", "err", err, "pitsId", c.PitsID, "bytes", len(data))
+			if errors.Is(err, errFrameTooLarge) && env.Type == TypeResponse {
+				// fail the waiting fetch now rather than at its timeout
+				hub.deliver(c, NewEnvelope(TypeError, env.ID, Error{Code: "TOO_LARGE", Message: err.Error()}))
+			}
+			continue
+		}
+		if c.verifier != nil && env.Type != TypeHello {
+			if err := c.verifier.verify(env); err != nil {
+				slog.Warn("control.server.frame.rejected", "type", env.Type, "id", env.ID, "pitsId", c.PitsID, "err", err)
+				continue
+			}
+		}
 		slog.Debug("control.server.frame", "type", env.Type, "id", env.ID, "traceId", env.TraceID, "pitsId", c.PitsID)
-		c.handleEnvelope(hub, env)
+		c.handleEnvelope(ctx, hub, env)
 	}
 }
 
-func (c *Conn) handleEnvelope(hub *Hub, env Envelope) {
+func (c *Conn) handleEnvelope(ctx context.Context, hub *Hub, env Envelope) {
 	switch env.Type {
 	case TypeHello:
-		c.registerPits(hub, env)
-	case TypeResponse, TypeError:
+		c.registerPits(ctx, hub, env)
+	case TypeResponse, TypeError, TypeCommandResult:
 		slog.Debug("control.server.deliver", "id", env.ID, "type", env.Type, "traceId", env.TraceID, "pitsId", c.PitsID)
-		hub.deliver(env)
+		hub.deliver(c, env)
+	case TypePush, TypeChangelog:
+		c.enqueuePush(env)
 	case TypePing:
 		slog.Debug("control.server.pong", "id", env.ID, "traceId", env.TraceID, "pitsId", c.PitsID)
 		pong := NewEnvelope(TypePong, env.ID, nil)
 		pong.TraceID = env.TraceID
 		_ = c.SendJSON(pong)
 	case TypePong:
-		// ignore
+		c.handlePong(hub, env)
 	default:
 		// ignore
 	}
 }
 
-func (c *Conn) registerPits(hub *Hub, env Envelope) {
+// handlePong measures the round trip of the outstanding server ping and feeds
+// the pits clock estimate. Pongs for older or unknown pings only count as a
+// sign of life.
+func (c *Conn) handlePong(hub *Hub, env Envelope) {
+	id, _ := c.pingID.Load().(string)
+	if env.ID == "" || env.ID != id {
+		return
+	}
+	now := time.Now()
+	sent := time.Unix(0, c.pingSentAt.Load())
+	rtt := now.Sub(sent)
+	c.rtt.Store(int64(rtt))
+	slog.Debug("control.server.pong.rtt", "pitsId", c.PitsID, "id", env.ID, "rttMs", rtt.Milliseconds())
+	if env.TS != 0 && c.PitsID != "" {
+		clock := hub.VenueClock(c.PitsID)
+		if clock.est.observe(sent, time.UnixMilli(env.TS), now) {
+			s := clock.Snapshot()
+			slog.Info("control.server.clock.offset", "pitsId", c.PitsID, "offsetMs", s.OffsetMs, "delayMs", s.DelayMs)
+		}
+	}
+	hub.reportHeartbeat(c, rtt)
+}
+
+func (c *Conn) registerPits(ctx context.Context, hub *Hub, env Envelope) {
+	if c.PitsID != "" {
+		slog.Debug("control.server.register.repeated", "pitsId", c.PitsID)
+		return
+	}
 	b, _ := json.Marshal(env.Payload)
 	var h Hello
 	_ = json.Unmarshal(b, &h)
-	c.PitsID = h.PitsID
-	if c.PitsID == "" {
-		c.PitsID = "default"
+	pitsID := h.PitsID
+	if pitsID == "" {
+		pitsID = "default"
 	}
-	slog.Debug("control.server.register", "pitsId", c.PitsID)
+	if h.ProtocolVersion != ProtocolMajor {
+		slog.Warn("control.server.register.incompatible", "pitsId", pitsID, "protocol", h.ProtocolVersion, "swVersion", h.SWVersion)
+		_ = c.SendJSON(NewEnvelope(TypeError, env.ID, Error{
+			Code:    "INCOMPATIBLE_VERSION",
+			Message: fmt.Sprintf("protocol %d not supported, cloud speaks %d.%d", h.ProtocolVersion, ProtocolMajor, ProtocolMinor),
+			Details: map[string]interface{}{"protocolVersion": ProtocolMajor, "protocolMinor": ProtocolMinor, "swVersion": buildinfo.String()},
+		}))
+		_ = c.ws.Close()
+		return
+	}
+	signed := c.signKey != "" && (hasFeature(h.Features, FeatureHMAC) || env.Sig != "")
+	if c.signKey != "" && !signed {
+		if hub.requireSigning {
+			slog.Warn("control.server.register.unsigned", "pitsId", pitsID, "features", h.Features)
+			_ = c.SendJSON(NewEnvelope(TypeError, env.ID, Error{Code: "FORBIDDEN", Message: "frame signing required"}))
+			_ = c.ws.Close()
+			return
+		}
+		// a pits that predates signing ignores Sig; stop signing for it
+		slog.Warn("control.server.register.unsigned", "pitsId", pitsID, "features", h.Features, "swVersion", h.SWVersion)
+		c.signing.Store(false)
+	}
+	if signed {
+		// the Hello's timestamp seeds the venue clock, so it is not skew-checked
+		if err := verifyEnvelope(c.signKey, env); err != nil {
+			slog.Warn("control.server.register.bad_signature", "pitsId", pitsID, "err", err)
+			_ = c.SendJSON(NewEnvelope(TypeError, env.ID, Error{Code: "FORBIDDEN", Message: "invalid hello signature"}))
+			_ = c.ws.Close()
+			return
+		}
+		clock := hub.VenueClock(pitsID)
+		if env.TS != 0 {
+			clock.est.observe(c.ConnectedAt, time.UnixMilli(env.TS), time.Now())
+		}
+		c.verifier = newFrameVerifier(c.signKey, clock.Offset)
+	}
+	if c.cred.PitsID != "" && pitsID != c.cred.PitsID {
+		slog.Warn("control.server.register.denied", "pitsId", pitsID, "tokenPitsId", c.cred.PitsID)
+		_ = c.SendJSON(NewEnvelope(TypeError, env.ID, Error{Code: "FORBIDDEN", Message: "token not valid for pits " + pitsID}))
+		_ = c.ws.Close()
+		return
+	}
+	if c.creds != nil {
+		if err := c.creds.check(c.cred, pitsID); err != nil {
+			slog.Warn("control.server.register.denied", "pitsId", pitsID, "credential", c.cred.ID, "err", err)
+			_ = c.SendJSON(NewEnvelope(TypeError, env.ID, Error{Code: "FORBIDDEN", Message: err.Error()}))
+			_ = c.ws.Close()
+			return
+		}
+	}
+	c.PitsID = pitsID
+	c.SWVersion = h.SWVersion
+	c.Features = h.Features
+	c.ProtocolVersion = h.ProtocolVersion
+	c.ProtocolMinor = h.ProtocolMinor
+	slog.Info("control.server.register", "pitsId", c.PitsID, "protocol", fmt.Sprintf("%d.%d", h.ProtocolVersion, h.ProtocolMinor), "swVersion", h.SWVersion, "features", h.Features)
+	hub.VenueClock(c.PitsID).setUTCOffset(h.UTCOffsetSec)
 	hub.Register(c.PitsID, c)
+	c.startPingLoop(ctx, pitsID)
+	go c.runPushWorker(ctx, hub, pitsID)
+	// ping right away so the clock estimate does not wait a full interval
+	if err := c.sendPing(); err != nil {
+		slog.Debug("control.server.ping.error", "err", err, "pitsId", c.PitsID)
+	}
 }
 
 // DecodeResponse decodes a control Response into status, headers, and body bytes.
 func DecodeResponse(r Response) (int, map[string]string, []byte) {
+	if r.Body != nil {
+		return r.Status, r.Headers, r.Body
+	}
 	var body []byte
 	if r.BodyB64 != "" {
 		b, _ := base64.StdEncoding.DecodeString(r.BodyB64)
@@ -175,3 +502,31 @@ func DecodeResponse(r Response) (int, map[string]string, []byte) {
 	}
 	return r.Status, r.Headers, body
 }
+
+func isSuperuser(c *core.RequestEvent) bool {
+	info, err := c.RequestInfo()
+	return err == nil && info.Auth != nil && info.Auth.IsSuperuser()
+}
+
+type tokenRequest struct {
+	Label    string  `json:"label"`
+	TTLHours float64 `json:"ttlHours"`
+}
+
+func (r tokenRequest) ttl() time.Duration {
+	return time.Duration(r.TTLHours * float64(time.Hour))
+}
+
+// readTokenRequest reads the optional JSON body of the token admin routes.
+func readTokenRequest(c *core.RequestEvent) (tokenRequest, error) {
+	var req tokenRequest
+	if c.Request.ContentLength != 0 {
+		if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			return req, fmt.Errorf("invalid body: %w", err)
+		}
+	}
+	if req.TTLHours < 0 {
+		return req, fmt.Errorf("ttlHours must not be negative")
+	}
+	return req, nil
+}
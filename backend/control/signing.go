@@ -0,0 +1,169 @@
+package control
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Envelope signing: peers with an auth token sign their Hello and offer
+// FeatureHMAC; when both do, every later frame carries Sig = HMAC-SHA256(auth
+// token, signing input) where the input covers type, id, ts, traceId, the
+// canonical payload and any binary body. A peer that predates signing ignores
+// Sig and the link stays unsigned, so old pits and clouds still interoperate.
+// A Hello that carries a Sig is always verified, so the feature cannot be
+// stripped from a signed Hello; stripping both is only caught with
+// --require-signing, which refuses peers that do not sign. Receivers also
+// reject repeated IDs and frames outside signatureMaxSkew of the peer's clock,
+// as estimated from ping/pong and seeded from the Hello, so a venue clock that
+// drifts does not lock it out.
+const (
+	signatureVersion = "v1"
+	signatureMaxSkew = 2 * time.Minute
+	// replayPruneSize triggers pruning of remembered frame keys.
+	replayPruneSize = 1024
+)
+
+var (
+	errMissingSignature = errors.New("control: missing signature")
+	errBadSignature     = errors.New("control: bad signature")
+)
+
+// signEnvelope sets env.Sig. body is the out-of-band body of a binary frame, or nil.
+func signEnvelope(key string, env *Envelope, body []byte) error {
+	raw, err := json.Marshal(env.Payload)
+	if err != nil {
+		return err
+	}
+	sig, err := computeSignature(key, *env, raw, body)
+	if err != nil {
+		return err
+	}
+	env.Sig = sig
+	return nil
+}
+
+// verifyEnvelope checks env.Sig against the payload bytes received on the wire.
+func verifyEnvelope(key string, env Envelope) error {
+	if env.Sig == "" {
+		return errMissingSignature
+	}
+	raw := env.rawPayload
+	if raw == nil {
+		b, err := json.Marshal(env.Payload)
+		if err != nil {
+			return err
+		}
+		raw = b
+	}
+	want, err := computeSignature(key, env, raw, envelopeBody(env))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(want), []byte(env.Sig)) {
+		return errBadSignature
+	}
+	return nil
+}
+
+func computeSignature(key string, env Envelope, payload, body []byte) (string, error) {
+	can, err := CanonicalizeJSON(payload)
+	if err != nil {
+		return "", fmt.Errorf("canonicalize payload: %w", err)
+	}
+	payloadSum := sha256.Sum256(can)
+	bodySum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(key))
+	for _, part := range []string{
+		signatureVersion,
+		env.Type,
+		env.ID,
+		strconv.FormatInt(env.TS, 10),
+		env.TraceID,
+		hex.EncodeToString(payloadSum[:]),
+		hex.EncodeToString(bodySum[:]),
+	} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// envelopeBody returns the binary-frame body carried next to the payload, if any.
+func envelopeBody(env Envelope) []byte {
+	switch p := env.Payload.(type) {
+	case Response:
+		return p.Body
+	case Push:
+		return p.Body
+	}
+	return nil
+}
+
+// replayGuard rejects stale and repeated frames on one connection.
+type replayGuard struct {
+	mu   sync.Mutex
+	seen map[string]int64 // frame key -> ts
+}
+
+func newReplayGuard() *replayGuard {
+	return &replayGuard{seen: make(map[string]int64)}
+}
+
+func (g *replayGuard) check(env Envelope, now time.Time) error {
+	skew := now.Sub(time.UnixMilli(env.TS))
+	if skew > signatureMaxSkew || skew < -signatureMaxSkew {
+		return fmt.Errorf("control: frame ts outside skew window (%s)", skew.Round(time.Millisecond))
+	}
+	// responses reuse the fetch ID, so keys are per type; ID-less frames fall back to the signature
+	key := env.Type + ":" + env.ID
+	if env.ID == "" {
+		key = env.Type + ":" + env.Sig
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, dup := g.seen[key]; dup {
+		return fmt.Errorf("control: duplicate frame %s", key)
+	}
+	g.seen[key] = env.TS
+	if len(g.seen) > replayPruneSize {
+		cutoff := now.Add(-2 * signatureMaxSkew).UnixMilli()
+		for k, ts := range g.seen {
+			if ts < cutoff {
+				delete(g.seen, k)
+			}
+		}
+	}
+	return nil
+}
+
+// frameVerifier bundles signature and replay checks for one side of a link.
+type frameVerifier struct {
+	key        string
+	replay     *replayGuard
+	peerOffset func() time.Duration
+}
+
+// newFrameVerifier checks frames signed with key. peerOffset, if not nil,
+// returns how far the peer's clock runs ahead of ours; frame timestamps are
+// compared with our clock shifted by it.
+func newFrameVerifier(key string, peerOffset func() time.Duration) *frameVerifier {
+	return &frameVerifier{key: key, replay: newReplayGuard(), peerOffset: peerOffset}
+}
+
+func (v *frameVerifier) verify(env Envelope) error {
+	if err := verifyEnvelope(v.key, env); err != nil {
+		return err
+	}
+	now := time.Now()
+	if v.peerOffset != nil {
+		now = now.Add(v.peerOffset())
+	}
+	return v.replay.check(env, now)
+}
@@ -0,0 +1,191 @@
+package control
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSignedEnvelopeRoundTrip(t *testing.T) {
+	t.Helper()
+	const key = "secret"
+
+	env := NewEnvelope(TypeFetch, "req-1", Fetch{Method: http.MethodGet, Path: "/events/e1/Event.json", TimeoutMs: 1000})
+	if err := signEnvelope(key, &env, nil); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	got, err := decodeFrame(websocket.TextMessage, data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	v := newFrameVerifier(key, nil)
+	if err := v.verify(got); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := v.verify(got); err == nil {
+		t.Fatalf("replayed frame accepted")
+	}
+
+	tampered, _ := decodeFrame(websocket.TextMessage, []byte(strings.Replace(string(data), "Event.json", "Pilots.json", 1)))
+	tampered.ID = "req-2"
+	if err := verifyEnvelope(key, tampered); err == nil {
+		t.Fatalf("tampered frame accepted")
+	}
+	if err := verifyEnvelope("other", got); err == nil {
+		t.Fatalf("wrong key accepted")
+	}
+}
+
+func TestSignedBinaryFrameCoversBody(t *testing.T) {
+	t.Helper()
+	const key = "secret"
+	body := []byte(strings.Repeat(`{"a":1}`, 400))
+
+	env := NewEnvelope(TypeResponse, "req-1", Response{Status: http.StatusOK, Headers: map[string]string{"ETag": ComputeETag(body)}})
+	if err := signEnvelope(key, &env, body); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	frame, err := encodeBinaryFrame(env, body)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got, err := decodeFrame(websocket.BinaryMessage, frame)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if err := verifyEnvelope(key, got); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	resp := got.Payload.(Response)
+	resp.Body = append([]byte{}, resp.Body[1:]...)
+	got.Payload = resp
+	if err := verifyEnvelope(key, got); err == nil {
+		t.Fatalf("altered body accepted")
+	}
+}
+
+func TestReplayGuardRejectsStaleFrames(t *testing.T) {
+	t.Helper()
+	g := newReplayGuard()
+	env := NewEnvelope(TypePing, "", nil)
+	env.TS = time.Now().Add(-2 * signatureMaxSkew).UnixMilli()
+	env.Sig = "x"
+	if err := g.check(env, time.Now()); err == nil {
+		t.Fatalf("stale frame accepted")
+	}
+}
+
+// expectForbidden reads the next frame and fails unless it is a FORBIDDEN error.
+func expectForbidden(t *testing.T, ws *websocket.Conn) {
+	t.Helper()
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var reply Envelope
+	if err := ws.ReadJSON(&reply); err != nil {
+		t.Fatalf("read reply: %v", err)
+	}
+	b, _ := json.Marshal(reply.Payload)
+	var e Error
+	_ = json.Unmarshal(b, &e)
+	if reply.Type != TypeError || e.Code != "FORBIDDEN" {
+		t.Fatalf("expected FORBIDDEN, got %s %+v", reply.Type, e)
+	}
+}
+
+func TestServerServesUnsignedPitsUnlessSigningRequired(t *testing.T) {
+	// a pits build that predates signing
+	hello := NewEnvelope(TypeHello, "h1", Hello{ProtocolVersion: ProtocolMajor, PitsID: "north", Features: []string{FeatureETag}})
+
+	hub := NewHub()
+	ws := dialTestServer(t, hub, "secret")
+	if err := ws.WriteJSON(hello); err != nil {
+		t.Fatalf("write hello: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(hub.ConnectedPits()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("unsigned pits never registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	strict := NewHub()
+	strict.SetRequireSigning(true)
+	ws = dialTestServer(t, strict, "secret")
+	if err := ws.WriteJSON(hello); err != nil {
+		t.Fatalf("write hello: %v", err)
+	}
+	expectForbidden(t, ws)
+	if got := strict.ConnectedPits(); len(got) != 0 {
+		t.Fatalf("unsigned pits was registered with signing required: %+v", got)
+	}
+}
+
+func TestServerVerifiesSignedHelloWithoutFeature(t *testing.T) {
+	hub := NewHub()
+	ws := dialTestServer(t, hub, "secret")
+	hello := NewEnvelope(TypeHello, "h1", Hello{ProtocolVersion: ProtocolMajor, PitsID: "north", Features: []string{FeatureETag, FeatureHMAC}})
+	if err := signEnvelope("secret", &hello, nil); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	// stripping FeatureHMAC from a signed Hello must not turn signing off
+	hello.Payload = Hello{ProtocolVersion: ProtocolMajor, PitsID: "north", Features: []string{FeatureETag}}
+	if err := ws.WriteJSON(hello); err != nil {
+		t.Fatalf("write hello: %v", err)
+	}
+	expectForbidden(t, ws)
+	if got := hub.ConnectedPits(); len(got) != 0 {
+		t.Fatalf("tampered pits was registered: %+v", got)
+	}
+}
+
+func TestPitsNegotiatesSigningWithTheCloud(t *testing.T) {
+	unsigned := NewEnvelope(TypeHello, "", Hello{ProtocolVersion: ProtocolMajor, Features: []string{FeatureETag}})
+
+	p, _ := NewPitsClient("ws://127.0.0.1:1/control", "secret", "north", "http://127.0.0.1:1")
+	if err := p.handleHello(unsigned); err != nil {
+		t.Fatalf("cloud that predates signing refused: %v", err)
+	}
+	if p.verifier.Load() != nil {
+		t.Fatalf("verifier set for an unsigned cloud")
+	}
+
+	p.RequireSigning = true
+	if err := p.handleHello(unsigned); err == nil {
+		t.Fatalf("unsigned cloud accepted with signing required")
+	}
+
+	signed := NewEnvelope(TypeHello, "", Hello{ProtocolVersion: ProtocolMajor, Features: []string{FeatureETag, FeatureHMAC}})
+	if err := signEnvelope("secret", &signed, nil); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	signed.Payload = Hello{ProtocolVersion: ProtocolMajor, Features: []string{FeatureETag}}
+	p.RequireSigning = false
+	if err := p.handleHello(signed); err == nil {
+		t.Fatalf("signed cloud hello stripped of %s accepted", FeatureHMAC)
+	}
+}
+
+func TestVerifierAllowsForMeasuredClockOffset(t *testing.T) {
+	const key = "secret"
+	env := NewEnvelope(TypePong, "ping-1", nil)
+	// the peer's clock runs ten minutes fast
+	env.TS = time.Now().Add(10 * time.Minute).UnixMilli()
+	if err := signEnvelope(key, &env, nil); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if err := newFrameVerifier(key, nil).verify(env); err == nil {
+		t.Fatalf("frame outside the raw skew window accepted")
+	}
+	offset := func() time.Duration { return 10 * time.Minute }
+	if err := newFrameVerifier(key, offset).verify(env); err != nil {
+		t.Fatalf("frame within the offset-corrected window rejected: %v", err)
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 )
 
-const fetchStatsCollection = "control_stats"
+const (
+	fetchStatsCollection  = "control_stats"
+	fetchSeriesCollection = "control_stats_series"
+)
 
 // NewPocketBaseFetchStatsStore returns a FetchStatsStore backed by PocketBase.
 func NewPocketBaseFetchStatsStore(app core.App) FetchStatsStore {
@@ -45,3 +49,107 @@ func (s *pocketBaseFetchStatsStore) UpsertFetchStats(ctx context.Context, bucket
 	rec.Set("errors", stats.Errors)
 	return s.app.Save(rec)
 }
+
+// UpsertFetchSeries writes a batch of window points in one transaction. The
+// rows already holding points of the batch are read with a single query.
+func (s *pocketBaseFetchStatsStore) UpsertFetchSeries(ctx context.Context, points []FetchSeriesPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	return s.app.RunInTransaction(func(txApp core.App) error {
+		col, err := txApp.FindCollectionByNameOrId(fetchSeriesCollection)
+		if err != nil {
+			return err
+		}
+		oldest := points[0].WindowStart
+		for _, p := range points {
+			oldest = min(oldest, p.WindowStart)
+		}
+		existing, err := txApp.FindRecordsByFilter(fetchSeriesCollection, "windowStart >= {:start}", "", 0, 0, dbx.Params{"start": oldest})
+		if err != nil {
+			return err
+		}
+		byKey := make(map[seriesKey]*core.Record, len(existing))
+		for _, rec := range existing {
+			byKey[seriesKey{bucket: rec.GetString("bucket"), window: rec.GetString("window"), start: int64(rec.GetInt("windowStart"))}] = rec
+		}
+		for _, p := range points {
+			rec, ok := byKey[seriesKey{bucket: p.Bucket, window: p.Window, start: p.WindowStart}]
+			if !ok {
+				rec = core.NewRecord(col)
+				rec.Set("bucket", p.Bucket)
+				rec.Set("window", p.Window)
+				rec.Set("windowStart", p.WindowStart)
+			}
+			rec.Set("count", p.Count)
+			rec.Set("fullResponses", p.FullResponses)
+			rec.Set("etagHits", p.ETagHits)
+			rec.Set("errors", p.Errors)
+			rec.Set("latencyP50", p.LatencyP50)
+			rec.Set("latencyP95", p.LatencyP95)
+			rec.Set("latencyP99", p.LatencyP99)
+			rec.Set("latencyMax", p.LatencyMax)
+			rec.Set("bytesTotal", p.BytesTotal)
+			rec.Set("bytesP50", p.BytesP50)
+			rec.Set("bytesP95", p.BytesP95)
+			rec.Set("bytesP99", p.BytesP99)
+			rec.Set("latencyHist", p.LatencyHist)
+			rec.Set("bytesHist", p.BytesHist)
+			if err := txApp.Save(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *pocketBaseFetchStatsStore) ListFetchSeries(ctx context.Context, window string, since time.Time) ([]FetchSeriesPoint, error) {
+	filter := "windowStart >= {:since}"
+	params := dbx.Params{"since": since.UnixMilli()}
+	if window != "" {
+		filter += " && window = {:window}"
+		params["window"] = window
+	}
+	recs, err := s.app.FindRecordsByFilter(fetchSeriesCollection, filter, "windowStart", 0, 0, params)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FetchSeriesPoint, 0, len(recs))
+	for _, rec := range recs {
+		p := FetchSeriesPoint{
+			Bucket:        rec.GetString("bucket"),
+			Window:        rec.GetString("window"),
+			WindowStart:   int64(rec.GetInt("windowStart")),
+			Count:         int64(rec.GetInt("count")),
+			FullResponses: int64(rec.GetInt("fullResponses")),
+			ETagHits:      int64(rec.GetInt("etagHits")),
+			Errors:        int64(rec.GetInt("errors")),
+			LatencyP50:    rec.GetFloat("latencyP50"),
+			LatencyP95:    rec.GetFloat("latencyP95"),
+			LatencyP99:    rec.GetFloat("latencyP99"),
+			LatencyMax:    rec.GetFloat("latencyMax"),
+			BytesTotal:    int64(rec.GetInt("bytesTotal")),
+			BytesP50:      rec.GetFloat("bytesP50"),
+			BytesP95:      rec.GetFloat("bytesP95"),
+			BytesP99:      rec.GetFloat("bytesP99"),
+		}
+		_ = rec.UnmarshalJSONField("latencyHist", &p.LatencyHist)
+		_ = rec.UnmarshalJSONField("bytesHist", &p.BytesHist)
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// PruneFetchSeries drops points past their window's retention.
+func (s *pocketBaseFetchStatsStore) PruneFetchSeries(ctx context.Context, now time.Time) error {
+	for _, w := range fetchWindows {
+		cutoff := now.Add(-w.Retention).UnixMilli()
+		if _, err := s.app.DB().Delete(fetchSeriesCollection, dbx.And(
+			dbx.HashExp{"window": w.Name},
+			dbx.NewExp("windowStart < {:cutoff}", dbx.Params{"cutoff": cutoff}),
+		)).Execute(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package control
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fetch telemetry aggregates each classifyFetchPath bucket into aligned 1m, 5m
+// and 1h windows with latency and payload size histograms. Windows live in
+// memory and are upserted to control_stats_series on an interval, so a burst
+// of fetches costs one write per window instead of one per request.
+
+const (
+	defaultStatsFlushInterval = 10 * time.Second
+	// closedWindowGrace keeps a closed window around for fetches that finish
+	// just after it ends, so a late observation never restarts it from zero.
+	closedWindowGrace = time.Minute
+)
+
+// fetchWindow is one rolling window size and how long its points are kept.
+type fetchWindow struct {
+	Name      string
+	Size      time.Duration
+	Retention time.Duration
+}
+
+var fetchWindows = []fetchWindow{
+	{Name: "1m", Size: time.Minute, Retention: 3 * time.Hour},
+	{Name: "5m", Size: 5 * time.Minute, Retention: 48 * time.Hour},
+	{Name: "1h", Size: time.Hour, Retention: 14 * 24 * time.Hour},
+}
+
+// Histogram upper bounds; a final overflow bucket catches the rest.
+var (
+	latencyBoundsMs = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+	bytesBounds     = []float64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+)
+
+// histogram counts observations against fixed upper bounds.
+type histogram struct {
+	bounds []float64
+	counts []int64
+	max    float64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]int64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i]++
+	h.sum += v
+	if v > h.max {
+		h.max = v
+	}
+}
+
+func (h *histogram) total() int64 {
+	var n int64
+	for _, c := range h.counts {
+		n += c
+	}
+	return n
+}
+
+// quantile estimates q by interpolating inside the bucket holding the rank.
+// The overflow bucket interpolates up to the largest value seen.
+func (h *histogram) quantile(q float64) float64 {
+	n := h.total()
+	if n == 0 {
+		return 0
+	}
+	rank := q * float64(n)
+	var cum float64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		if cum+float64(c) >= rank {
+			lower := 0.0
+			if i > 0 {
+				lower = h.bounds[i-1]
+			}
+			upper := h.max
+			if i < len(h.bounds) && h.bounds[i] < upper {
+				upper = h.bounds[i]
+			}
+			if upper < lower {
+				upper = lower
+			}
+			return lower + (upper-lower)*(rank-cum)/float64(c)
+		}
+		cum += float64(c)
+	}
+	return h.max
+}
+
+// FetchSeriesPoint is one bucket's aggregate over one window.
+type FetchSeriesPoint struct {
+	Bucket        string  `json:"bucket"`
+	Window        string  `json:"window"`
+	WindowStart   int64   `json:"windowStart"`
+	Count         int64   `json:"count"`
+	FullResponses int64   `json:"fullResponses"`
+	ETagHits      int64   `json:"etagHits"`
+	Errors        int64   `json:"errors"`
+	LatencyP50    float64 `json:"latencyP50"`
+	LatencyP95    float64 `json:"latencyP95"`
+	LatencyP99    float64 `json:"latencyP99"`
+	LatencyMax    float64 `json:"latencyMax"`
+	BytesTotal    int64   `json:"bytesTotal"`
+	BytesP50      float64 `json:"bytesP50"`
+	BytesP95      float64 `json:"bytesP95"`
+	BytesP99      float64 `json:"bytesP99"`
+	LatencyHist   []int64 `json:"latencyHist"`
+	BytesHist     []int64 `json:"bytesHist"`
+}
+
+type seriesKey struct {
+	bucket string
+	window string
+	start  int64
+}
+
+type seriesAgg struct {
+	end           time.Time
+	count         int64
+	fullResponses int64
+	etagHits      int64
+	errors        int64
+	latency       *histogram
+	bytes         *histogram
+	dirty         bool
+}
+
+func (a *seriesAgg) point(k seriesKey) FetchSeriesPoint {
+	return FetchSeriesPoint{
+		Bucket:        k.bucket,
+		Window:        k.window,
+		WindowStart:   k.start,
+		Count:         a.count,
+		FullResponses: a.fullResponses,
+		ETagHits:      a.etagHits,
+		Errors:        a.errors,
+		LatencyP50:    a.latency.quantile(0.50),
+		LatencyP95:    a.latency.quantile(0.95),
+		LatencyP99:    a.latency.quantile(0.99),
+		LatencyMax:    a.latency.max,
+		BytesTotal:    int64(a.bytes.sum),
+		BytesP50:      a.bytes.quantile(0.50),
+		BytesP95:      a.bytes.quantile(0.95),
+		BytesP99:      a.bytes.quantile(0.99),
+		LatencyHist:   append([]int64(nil), a.latency.counts...),
+		BytesHist:     append([]int64(nil), a.bytes.counts...),
+	}
+}
+
+// fetchTelemetry holds the open windows of every bucket.
+type fetchTelemetry struct {
+	mu     sync.Mutex
+	series map[seriesKey]*seriesAgg
+	// countersDirty lists control_stats buckets changed since the last flush.
+	countersDirty map[string]bool
+}
+
+func newFetchTelemetry() *fetchTelemetry {
+	return &fetchTelemetry{series: make(map[seriesKey]*seriesAgg), countersDirty: make(map[string]bool)}
+}
+
+// fetchObservation is one finished fetch as seen by the hub.
+type fetchObservation struct {
+	at        time.Time
+	latencyMs float64
+	bytes     int
+	status    int
+	err       bool
+}
+
+func (t *fetchTelemetry) observe(buckets []string, o fetchObservation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, bucket := range buckets {
+		t.countersDirty[bucket] = true
+		for _, w := range fetchWindows {
+			start := o.at.Truncate(w.Size)
+			k := seriesKey{bucket: bucket, window: w.Name, start: start.UnixMilli()}
+			agg, ok := t.series[k]
+			if !ok {
+				agg = &seriesAgg{end: start.Add(w.Size), latency: newHistogram(latencyBoundsMs), bytes: newHistogram(bytesBounds)}
+				t.series[k] = agg
+			}
+			agg.dirty = true
+			agg.count++
+			agg.latency.observe(o.latencyMs)
+			switch {
+			case o.err:
+				agg.errors++
+				continue
+			case o.status == 304:
+				agg.etagHits++
+			default:
+				agg.fullResponses++
+			}
+			agg.bytes.observe(float64(o.bytes))
+		}
+	}
+}
+
+// drain returns dirty points and counter buckets and clears their dirty flags.
+func (t *fetchTelemetry) drain() ([]FetchSeriesPoint, []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var points []FetchSeriesPoint
+	for k, agg := range t.series {
+		if agg.dirty {
+			points = append(points, agg.point(k))
+			agg.dirty = false
+		}
+	}
+	counters := make([]string, 0, len(t.countersDirty))
+	for bucket := range t.countersDirty {
+		counters = append(counters, bucket)
+	}
+	t.countersDirty = make(map[string]bool)
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].WindowStart != points[j].WindowStart {
+			return points[i].WindowStart < points[j].WindowStart
+		}
+		if points[i].Bucket != points[j].Bucket {
+			return points[i].Bucket < points[j].Bucket
+		}
+		return points[i].Window < points[j].Window
+	})
+	sort.Strings(counters)
+	return points, counters
+}
+
+// markDirty puts undelivered points back so the next flush retries them.
+func (t *fetchTelemetry) markDirty(points []FetchSeriesPoint, counters []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range points {
+		if agg, ok := t.series[seriesKey{bucket: p.Bucket, window: p.Window, start: p.WindowStart}]; ok {
+			agg.dirty = true
+		}
+	}
+	for _, bucket := range counters {
+		t.countersDirty[bucket] = true
+	}
+}
+
+// forgetClosed drops windows that ended before now and hold nothing unflushed.
+func (t *fetchTelemetry) forgetClosed(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, agg := range t.series {
+		if !agg.dirty && !agg.end.Add(closedWindowGrace).After(now) {
+			delete(t.series, k)
+		}
+	}
+}
+
+// FetchSeries returns the points of one window size (every size when empty)
+// that start at or after since, oldest first. Stored history is overlaid with
+// the windows still in memory, which may hold fetches not yet flushed.
+func (h *Hub) FetchSeries(ctx context.Context, window string, since time.Time) ([]FetchSeriesPoint, error) {
+	byKey := map[seriesKey]FetchSeriesPoint{}
+	if h.statsStore != nil {
+		stored, err := h.statsStore.ListFetchSeries(ctx, window, since)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range stored {
+			byKey[seriesKey{bucket: p.Bucket, window: p.Window, start: p.WindowStart}] = p
+		}
+	}
+	h.telemetry.mu.Lock()
+	for k, agg := range h.telemetry.series {
+		if (window == "" || k.window == window) && k.start >= since.UnixMilli() {
+			byKey[k] = agg.point(k)
+		}
+	}
+	h.telemetry.mu.Unlock()
+
+	out := make([]FetchSeriesPoint, 0, len(byKey))
+	for _, p := range byKey {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].WindowStart != out[j].WindowStart {
+			return out[i].WindowStart < out[j].WindowStart
+		}
+		if out[i].Bucket != out[j].Bucket {
+			return out[i].Bucket < out[j].Bucket
+		}
+		return out[i].Window < out[j].Window
+	})
+	return out, nil
+}
+
+// fetchSeriesRetention is how far back window keeps points; the longest
+// retention when window is empty or unknown.
+func fetchSeriesRetention(window string) time.Duration {
+	var longest time.Duration
+	for _, w := range fetchWindows {
+		if w.Name == window {
+			return w.Retention
+		}
+		longest = max(longest, w.Retention)
+	}
+	return longest
+}
+
+// FlushFetchStats writes changed counters and windows to the stats store.
+func (h *Hub) FlushFetchStats(ctx context.Context) {
+	if h.statsStore == nil {
+		return
+	}
+	now := time.Now()
+	points, counters := h.telemetry.drain()
+	var failedCounters []string
+	for _, bucket := range counters {
+		if err := h.statsStore.UpsertFetchStats(ctx, bucket, snapshotFetchMetrics(h.ensureMetricsBucket(bucket))); err != nil {
+			slog.Warn("control.hub.fetch_stats.persist.error", "bucket", bucket, "err", err)
+			failedCounters = append(failedCounters, bucket)
+		}
+	}
+	var failedPoints []FetchSeriesPoint
+	if len(points) > 0 {
+		if err := h.statsStore.UpsertFetchSeries(ctx, points); err != nil {
+			slog.Warn("control.hub.fetch_series.persist.error", "points", len(points), "err", err)
+			failedPoints = points
+		}
+	}
+	h.telemetry.markDirty(failedPoints, failedCounters)
+	h.telemetry.forgetClosed(now)
+	if err := h.statsStore.PruneFetchSeries(ctx, now); err != nil {
+		slog.Warn("control.hub.fetch_series.prune.error", "err", err)
+	}
+	slog.Debug("control.hub.fetch_stats.flush", "counters", len(counters), "points", len(points))
+}
+
+// RunStatsFlush flushes fetch telemetry every interval until ctx ends, then
+// once more so a clean shutdown loses nothing.
+func (h *Hub) RunStatsFlush(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultStatsFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			h.FlushFetchStats(context.Background())
+			return
+		case <-ticker.C:
+			h.FlushFetchStats(ctx)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tests"
+
+	_ "drone-dashboard/migrations"
+)
+
+func TestHistogramQuantiles(t *testing.T) {
+	h := newHistogram(latencyBoundsMs)
+	for i := 1; i <= 100; i++ {
+		h.observe(float64(i))
+	}
+	if p50 := h.quantile(0.5); p50 < 25 || p50 > 50 {
+		t.Fatalf("p50 out of range: %v", p50)
+	}
+	if p99 := h.quantile(0.99); p99 < 50 || p99 > 100 {
+		t.Fatalf("p99 out of range: %v", p99)
+	}
+	if h.max != 100 || h.total() != 100 {
+		t.Fatalf("unexpected max/total: %v/%d", h.max, h.total())
+	}
+
+	over := newHistogram(latencyBoundsMs)
+	over.observe(30000)
+	if q := over.quantile(0.99); q > 30000 || q < 10000 {
+		t.Fatalf("overflow bucket should interpolate up to max, got %v", q)
+	}
+}
+
+// memoryStatsStore records what the hub flushes.
+type memoryStatsStore struct {
+	counters map[string]FetchStatsSnapshot
+	points   []FetchSeriesPoint
+	fail     bool
+}
+
+func (s *memoryStatsStore) UpsertFetchStats(_ context.Context, bucket string, stats FetchStatsSnapshot) error {
+	if s.fail {
+		return errors.New("down")
+	}
+	s.counters[bucket] = stats
+	return nil
+}
+
+func (s *memoryStatsStore) UpsertFetchSeries(_ context.Context, points []FetchSeriesPoint) error {
+	if s.fail {
+		return errors.New("down")
+	}
+	s.points = append(s.points, points...)
+	return nil
+}
+
+func (s *memoryStatsStore) ListFetchSeries(context.Context, string, time.Time) ([]FetchSeriesPoint, error) {
+	return nil, nil
+}
+
+func (s *memoryStatsStore) PruneFetchSeries(context.Context, time.Time) error { return nil }
+
+func TestFetchStatsBufferedUntilFlush(t *testing.T) {
+	hub := NewHub()
+	store := &memoryStatsStore{counters: map[string]FetchStatsSnapshot{}}
+	hub.SetFetchStatsStore(store)
+
+	hub.recordFetchResult("/events/e1/Results.json", Response{Status: http.StatusOK, Body: make([]byte, 2048)}, nil, 40*time.Millisecond)
+	hub.recordFetchResult("/events/e1/Results.json", Response{Status: http.StatusNotModified}, nil, 4*time.Millisecond)
+	hub.recordFetchResult("/events/e1/Results.json", Response{}, errors.New("timeout"), 900*time.Millisecond)
+	if len(store.counters) != 0 || len(store.points) != 0 {
+		t.Fatalf("stats written before flush")
+	}
+
+	store.fail = true
+	hub.FlushFetchStats(context.Background())
+	store.fail = false
+	hub.FlushFetchStats(context.Background())
+
+	if got := store.counters["results"]; got.Total != 3 || got.ETagHits != 1 || got.Errors != 1 {
+		t.Fatalf("unexpected counters after retry: %+v", got)
+	}
+	var oneMinute *FetchSeriesPoint
+	for i := range store.points {
+		p := &store.points[i]
+		if p.Bucket == "results" && p.Window == "1m" {
+			oneMinute = p
+		}
+	}
+	if oneMinute == nil {
+		t.Fatalf("missing results/1m point in %+v", store.points)
+	}
+	if oneMinute.Count != 3 || oneMinute.BytesTotal != 2048 || oneMinute.LatencyMax != 900 {
+		t.Fatalf("unexpected point: %+v", *oneMinute)
+	}
+	if len(store.points) != 2*len(fetchWindows) {
+		t.Fatalf("expected overall and results points per window, got %d", len(store.points))
+	}
+
+	// nothing changed since, so nothing is rewritten
+	store.points = nil
+	hub.FlushFetchStats(context.Background())
+	if len(store.points) != 0 {
+		t.Fatalf("clean windows rewritten: %+v", store.points)
+	}
+}
+
+func TestPocketBaseFetchSeriesUpsertAndPrune(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+	store := NewPocketBaseFetchStatsStore(app)
+	ctx := context.Background()
+
+	now := time.Now()
+	start := now.Truncate(time.Minute).UnixMilli()
+	old := now.Add(-24 * time.Hour).Truncate(time.Minute).UnixMilli()
+	point := FetchSeriesPoint{Bucket: "overall", Window: "1m", WindowStart: start, Count: 1, LatencyHist: []int64{1}}
+	if err := store.UpsertFetchSeries(ctx, []FetchSeriesPoint{point, {Bucket: "overall", Window: "1m", WindowStart: old, Count: 7}}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	point.Count = 5
+	point.LatencyP95 = 12.5
+	if err := store.UpsertFetchSeries(ctx, []FetchSeriesPoint{point}); err != nil {
+		t.Fatalf("second upsert: %v", err)
+	}
+	if err := store.PruneFetchSeries(ctx, now); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+
+	recs, err := app.FindAllRecords(fetchSeriesCollection)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(recs) != 1 {
+		t.Fatalf("expected one point after prune, got %d", len(recs))
+	}
+	if recs[0].GetInt("count") != 5 || math.Abs(recs[0].GetFloat("latencyP95")-12.5) > 1e-9 {
+		t.Fatalf("point not updated in place: %v", recs[0].PublicExport())
+	}
+}
+
+func TestFetchSeriesServesHistoryAfterWindowsLeaveMemory(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+	hub := NewHub()
+	hub.SetFetchStatsStore(NewPocketBaseFetchStatsStore(app))
+	ctx := context.Background()
+
+	hub.recordFetchResult("/events/e1/Results.json", Response{Status: http.StatusOK, Body: make([]byte, 512)}, nil, 20*time.Millisecond)
+	hub.FlushFetchStats(ctx)
+	// an hour later every window the fetch landed in has closed and been forgotten
+	hub.telemetry.forgetClosed(time.Now().Add(2 * time.Hour))
+	if len(hub.telemetry.series) != 0 {
+		t.Fatalf("windows still in memory: %d", len(hub.telemetry.series))
+	}
+
+	points, err := hub.FetchSeries(ctx, "1m", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("fetch series: %v", err)
+	}
+	var results *FetchSeriesPoint
+	for i := range points {
+		if points[i].Bucket == "results" {
+			results = &points[i]
+		}
+	}
+	if results == nil || results.Count != 1 || results.Window != "1m" || len(results.LatencyHist) == 0 {
+		t.Fatalf("stored point not served: %+v", points)
+	}
+
+}
@@ -2,32 +2,19 @@ package control
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
 	"fmt"
-)
-
-type traceKeyType struct{}
 
-var traceKey traceKeyType
+	"drone-dashboard/tracing"
+)
 
 // WithTraceID returns a context derived from ctx carrying the provided trace ID.
 func WithTraceID(ctx context.Context, traceID string) context.Context {
-	if ctx == nil {
-		ctx = context.Background()
-	}
-	return context.WithValue(ctx, traceKey, traceID)
+	return tracing.WithTraceID(ctx, traceID)
 }
 
 // TraceIDFromContext extracts a trace ID from ctx if present.
 func TraceIDFromContext(ctx context.Context) string {
-	if ctx == nil {
-		return ""
-	}
-	if v, ok := ctx.Value(traceKey).(string); ok {
-		return v
-	}
-	return ""
+	return tracing.TraceIDFromContext(ctx)
 }
 
 // EnsureTraceID guarantees a trace ID on the returned context and provides it.
@@ -42,14 +29,7 @@ func EnsureTraceID(ctx context.Context) (context.Context, string) {
 	return WithTraceID(ctx, id), id
 }
 
-func newTraceID() string {
-	var b [12]byte
-	if _, err := rand.Read(b[:]); err != nil {
-		// Fallback to timestamp-like hex when entropy fails.
-		return hex.EncodeToString([]byte("tracefallback"))
-	}
-	return hex.EncodeToString(b[:])
-}
+func newTraceID() string { return tracing.NewTraceID() }
 
 // TraceCarrier represents errors that expose an associated trace ID.
 type TraceCarrier interface {
@@ -0,0 +1,38 @@
+package control
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"drone-dashboard/tracing"
+)
+
+func TestDoFetchPropagatesSpanToPits(t *testing.T) {
+	hub := NewHub()
+	var sent Fetch
+	hub.conns["north"] = &replyConn{hub: hub, reply: func(env Envelope) Envelope {
+		sent = env.Payload.(Fetch)
+		return NewEnvelope(TypeResponse, env.ID, Response{Status: http.StatusNotModified})
+	}}
+
+	ctx, parent := tracing.Start(context.Background(), "scheduler.ingest", tracing.KindInternal)
+	if _, err := hub.DoFetch(ctx, "north", Fetch{Method: http.MethodGet, Path: "/events/e1/Race.json"}); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	parent.End(nil)
+
+	if sent.TraceID != parent.TraceID || sent.SpanID == "" {
+		t.Fatalf("trace not propagated: %+v", sent)
+	}
+	var fetchSpan *tracing.SpanData
+	for _, s := range tracing.Default.Recent(parent.TraceID, 0) {
+		if s.Name == "control.fetch" {
+			s := s
+			fetchSpan = &s
+		}
+	}
+	if fetchSpan == nil || fetchSpan.SpanID != sent.SpanID || fetchSpan.ParentID != parent.SpanID {
+		t.Fatalf("fetch span not linked: %+v (sent %+v)", fetchSpan, sent)
+	}
+}
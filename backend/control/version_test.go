@@ -0,0 +1,124 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialTestServer runs serveConn behind an httptest server and returns a client
+// socket that has already read the cloud's Hello. A signKey makes the link
+// one authenticated with that token.
+func dialTestServer(t *testing.T, hub *Hub, signKey string) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn := &Conn{ws: ws, hub: hub, signKey: signKey, ConnectedAt: time.Now(), pushQueue: make(chan Envelope, pushQueueSize)}
+		go serveConn(conn, hub)
+	}))
+	t.Cleanup(srv.Close)
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	var hello Envelope
+	if err := ws.ReadJSON(&hello); err != nil || hello.Type != TypeHello {
+		t.Fatalf("expected cloud hello, got %+v (%v)", hello, err)
+	}
+	return ws
+}
+
+func TestServerRejectsIncompatibleMajor(t *testing.T) {
+	hub := NewHub()
+	ws := dialTestServer(t, hub, "")
+	if err := ws.WriteJSON(NewEnvelope(TypeHello, "h1", Hello{ProtocolVersion: ProtocolMajor + 1, PitsID: "north"})); err != nil {
+		t.Fatalf("write hello: %v", err)
+	}
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var reply Envelope
+	if err := ws.ReadJSON(&reply); err != nil {
+		t.Fatalf("read reply: %v", err)
+	}
+	b, _ := json.Marshal(reply.Payload)
+	var e Error
+	_ = json.Unmarshal(b, &e)
+	if reply.Type != TypeError || e.Code != "INCOMPATIBLE_VERSION" {
+		t.Fatalf("expected INCOMPATIBLE_VERSION, got %s %+v", reply.Type, e)
+	}
+	if got := hub.ConnectedPits(); len(got) != 0 {
+		t.Fatalf("incompatible pits was registered: %+v", got)
+	}
+}
+
+func TestCommandsGatedOnPitsFeatures(t *testing.T) {
+	hub := NewHub()
+	ws := dialTestServer(t, hub, "")
+	// a 1.0 pits build: no minor version and no commands feature
+	if err := ws.WriteJSON(NewEnvelope(TypeHello, "h1", Hello{ProtocolVersion: ProtocolMajor, PitsID: "north", SWVersion: "v0.9.0", Features: []string{FeatureETag}})); err != nil {
+		t.Fatalf("write hello: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(hub.ConnectedPits()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("pits never registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	info := hub.ConnectedPits()[0]
+	if info.Protocol != "1.0" || info.SWVersion != "v0.9.0" {
+		t.Fatalf("unexpected pits info: %+v", info)
+	}
+	_, err := hub.DoCommand(context.Background(), "north", Command{Name: CommandFullAuto})
+	if !errors.Is(err, ErrCommandsUnsupported) {
+		t.Fatalf("expected ErrCommandsUnsupported, got %v", err)
+	}
+}
+
+// chanPushSink hands pushed paths to the test.
+type chanPushSink chan string
+
+func (s chanPushSink) HandlePush(pitsID string, push Push) error {
+	s <- push.Path
+	return nil
+}
+
+func TestServerIgnoresFramesBeforeHello(t *testing.T) {
+	hub := NewHub()
+	sink := make(chanPushSink, 2)
+	hub.SetPushHandler(sink)
+	ws := dialTestServer(t, hub, "")
+	send := func(env Envelope) {
+		t.Helper()
+		if err := ws.WriteJSON(env); err != nil {
+			t.Fatalf("write %s: %v", env.Type, err)
+		}
+	}
+	send(NewEnvelope(TypePush, "p1", Push{Path: "/events/e1/Early.json", BodyB64: "e30="}))
+	send(NewEnvelope(TypeHello, "h1", Hello{ProtocolVersion: ProtocolMajor, PitsID: "north", Features: []string{FeaturePush}}))
+	send(NewEnvelope(TypePush, "p2", Push{Path: "/events/e1/Event.json", BodyB64: "e30="}))
+
+	select {
+	case path := <-sink:
+		if path != "/events/e1/Event.json" {
+			t.Fatalf("applied %s, want only the push after the hello", path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("push after the hello never applied")
+	}
+	select {
+	case path := <-sink:
+		t.Fatalf("applied a second push %s", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
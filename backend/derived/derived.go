@@ -0,0 +1,132 @@
+// Package derived holds what the packages that keep collections derived from
+// race data (careers, leaderboard) share: a debounced rebuild queue driven by
+// ingest's race commits, the lap maths and a write-if-changed check.
+package derived
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Queue batches keys marked dirty and hands them to Run, Delay after the
+// first mark. Runs never overlap, so a timer firing during a slow run waits
+// for it instead of racing it.
+type Queue struct {
+	Delay time.Duration
+	// Run rebuilds the given keys.
+	Run func(keys map[string]struct{})
+
+	mu    sync.Mutex
+	keys  map[string]struct{}
+	timer *time.Timer
+	runMu sync.Mutex
+}
+
+// NewQueue returns a queue calling run; its timer is stopped when app terminates.
+func NewQueue(app core.App, delay time.Duration, run func(keys map[string]struct{})) *Queue {
+	q := &Queue{Delay: delay, Run: run}
+	app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		q.mu.Lock()
+		if q.timer != nil {
+			q.timer.Stop()
+		}
+		q.mu.Unlock()
+		return e.Next()
+	})
+	return q
+}
+
+// Mark queues keys; empty ones are ignored.
+func (q *Queue) Mark(keys ...string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, k := range keys {
+		if k == "" {
+			continue
+		}
+		if q.keys == nil {
+			q.keys = map[string]struct{}{}
+		}
+		q.keys[k] = struct{}{}
+	}
+	if q.keys != nil && q.timer == nil {
+		q.timer = time.AfterFunc(q.Delay, q.Flush)
+	}
+}
+
+// Flush runs everything marked so far.
+func (q *Queue) Flush() {
+	q.Exclusive(func() {
+		q.mu.Lock()
+		keys := q.keys
+		q.keys = nil
+		if q.timer != nil {
+			q.timer.Stop()
+			q.timer = nil
+		}
+		q.mu.Unlock()
+		if len(keys) > 0 {
+			q.Run(keys)
+		}
+	})
+}
+
+// Exclusive runs fn while no flush is running, e.g. a full rebuild.
+func (q *Queue) Exclusive(fn func()) {
+	q.runMu.Lock()
+	defer q.runMu.Unlock()
+	fn()
+}
+
+// Backfill runs rebuild at startup when collection is still empty, e.g.
+// right after upgrading. name prefixes the log events.
+func (q *Queue) Backfill(app core.App, name, collection string, rebuild func() (int, error)) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		if n, err := app.CountRecords(collection); err == nil && n == 0 {
+			go q.Exclusive(func() {
+				if n, err := rebuild(); err != nil {
+					slog.Warn(name+".backfill.error", "err", err)
+				} else {
+					slog.Info(name+".backfill.done", "count", n)
+				}
+			})
+		}
+		return se.Next()
+	})
+}
+
+// BestConsecutive is the quickest run of n laps in order, or 0.
+func BestConsecutive(laps []float64, n int) float64 {
+	best := 0.0
+	for i := 0; n > 0 && i+n <= len(laps); i++ {
+		sum := 0.0
+		for _, s := range laps[i : i+n] {
+			sum += s
+		}
+		if best == 0 || sum < best {
+			best = sum
+		}
+	}
+	return best
+}
+
+// Round3 rounds seconds to the millisecond.
+func Round3(v float64) float64 { return math.Round(v*1000) / 1000 }
+
+// Unchanged reports whether rec already holds fields and, under jsonField,
+// the marshalled jsonValue.
+func Unchanged(rec *core.Record, fields map[string]any, jsonField string, jsonValue []byte) bool {
+	for k, v := range fields {
+		if fmt.Sprint(rec.Get(k)) != fmt.Sprint(v) {
+			return false
+		}
+	}
+	stored, err := json.Marshal(rec.Get(jsonField))
+	return err == nil && string(stored) == string(jsonValue)
+}
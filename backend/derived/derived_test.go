@@ -0,0 +1,36 @@
+package derived
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func TestBestConsecutive(t *testing.T) {
+	laps := []float64{30, 29, 31, 28, 27}
+	if got := BestConsecutive(laps, 3); got != 86 {
+		t.Fatalf("best 3 = %v", got)
+	}
+	if got := BestConsecutive(laps, 6); got != 0 {
+		t.Fatalf("window longer than the laps = %v", got)
+	}
+}
+
+func TestQueueCoalescesMarks(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	var runs []map[string]struct{}
+	q := NewQueue(app, time.Hour, func(keys map[string]struct{}) { runs = append(runs, keys) })
+	q.Mark("a", "b", "")
+	q.Mark("a")
+	q.Flush()
+	q.Flush()
+	if len(runs) != 1 || len(runs[0]) != 2 {
+		t.Fatalf("runs = %v", runs)
+	}
+}
@@ -2,111 +2,172 @@ package fpvhttp
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
+
+	"drone-dashboard/metrics"
 )
 
 var (
 	clientOnce sync.Once
 	client     *http.Client
+	transport  *throttledTransport
+
+	// throttleWait is how long requests queued for their turn on the connection.
+	throttleWait = metrics.NewHistogram(metrics.DurationBounds)
 )
 
 const (
-	requestTimeout       = 1 * time.Second
-	requestThrottleDelay = 5 * time.Millisecond
-	sleepStep            = 5 * time.Millisecond
+	sleepStep = 5 * time.Millisecond
+	// latencyWeight is the EWMA weight of each new response time.
+	latencyWeight = 0.3
 )
 
-// Shared returns the shared HTTP client used for FPVTrackside calls.
-// It enforces MaxConnsPerHost=1 and introduces a minimum delay between
-// sequential requests to avoid overloading FPVTrackside.
+// Shared returns the shared HTTP client used for FPVTrackside calls. It limits
+// requests in flight to Config.MaxConns and spaces them by an adaptive gap to
+// avoid overloading FPVTrackside. The client has no overall timeout, since that
+// would be fixed at creation; callers bound each request with Timeout(path).
 func Shared() *http.Client {
 	clientOnce.Do(func() {
-		transport := &http.Transport{
-			DisableKeepAlives: true,
-			MaxConnsPerHost:   1,
-		}
-
-		client = &http.Client{
-			Timeout:   requestTimeout,
-			Transport: newThrottledTransport(transport, requestThrottleDelay),
-		}
+		transport = newThrottledTransport(CurrentConfig())
+		client = &http.Client{Transport: transport}
 	})
 	return client
 }
 
-type throttledTransport struct {
-	inner  http.RoundTripper
-	minGap time.Duration
+func sharedTransport() *throttledTransport {
+	Shared()
+	return transport
+}
+
+func newHTTPTransport(cfg Config) *http.Transport {
+	return &http.Transport{
+		DisableKeepAlives:   !cfg.KeepAlive,
+		MaxConnsPerHost:     cfg.MaxConns,
+		MaxIdleConnsPerHost: cfg.MaxConns,
+	}
+}
 
+type throttledTransport struct {
 	mu       sync.Mutex
+	inner    http.RoundTripper
+	cfg      Config
+	gap      time.Duration
+	latency  time.Duration
 	lastStop time.Time
-	inflight bool
+	inflight int
 }
 
-func newThrottledTransport(inner http.RoundTripper, minGap time.Duration) http.RoundTripper {
-	if inner == nil || minGap <= 0 {
-		return inner
-	}
+func newThrottledTransport(cfg Config) *throttledTransport {
+	return &throttledTransport{inner: newHTTPTransport(cfg), cfg: cfg, gap: cfg.MinGap}
+}
 
-	t := &throttledTransport{
-		inner:  inner,
-		minGap: minGap,
+// apply switches to cfg, replacing the connection pool only when its
+// settings changed.
+func (t *throttledTransport) apply(cfg Config) {
+	t.mu.Lock()
+	prev := t.cfg
+	t.cfg = cfg
+	t.gap = min(max(t.gap, cfg.MinGap), cfg.MaxGap)
+	var old http.RoundTripper
+	if prev.MaxConns != cfg.MaxConns || prev.KeepAlive != cfg.KeepAlive {
+		old, t.inner = t.inner, newHTTPTransport(cfg)
+	}
+	t.mu.Unlock()
+	if closer, ok := old.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
 	}
-	return t
 }
 
 func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if t == nil || t.inner == nil {
-		return http.DefaultTransport.RoundTrip(req)
-	}
-
-	if err := t.waitTurn(req.Context()); err != nil {
+	start := time.Now()
+	inner, err := t.waitTurn(req.Context())
+	throttleWait.Observe(time.Since(start).Seconds())
+	if err != nil {
 		return nil, err
 	}
-	defer t.finish()
-
-	return t.inner.RoundTrip(req)
+	sent := time.Now()
+	resp, err := inner.RoundTrip(req)
+	t.finish(req.Context(), time.Since(sent), err)
+	return resp, err
 }
 
-func (t *throttledTransport) waitTurn(ctx context.Context) error {
+func (t *throttledTransport) waitTurn(ctx context.Context) (http.RoundTripper, error) {
 	for {
 		t.mu.Lock()
 
-		if t.inflight {
+		if t.inflight >= t.cfg.MaxConns {
 			t.mu.Unlock()
 			if err := sleepWithContext(ctx, sleepStep); err != nil {
-				return err
+				return nil, err
 			}
 			continue
 		}
 
-		wait := time.Until(t.lastStop.Add(t.minGap))
+		wait := time.Until(t.lastStop.Add(t.gap))
 		if wait > 0 {
 			t.mu.Unlock()
 			if err := sleepWithContext(ctx, wait); err != nil {
-				return err
+				return nil, err
 			}
 			continue
 		}
 
-		t.inflight = true
+		t.inflight++
+		inner := t.inner
 		t.mu.Unlock()
-		return nil
+		return inner, nil
 	}
 }
 
-func (t *throttledTransport) finish() {
+// finish releases the turn and adapts the gap to how FPVTrackside is coping.
+// Requests the caller abandoned say nothing about FPVTrackside and are skipped.
+func (t *throttledTransport) finish(ctx context.Context, took time.Duration, err error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	t.inflight = false
+	t.inflight--
 	t.lastStop = time.Now()
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	if t.latency == 0 {
+		t.latency = took
+	} else {
+		t.latency += time.Duration(latencyWeight * float64(took-t.latency))
+	}
+	slow := t.cfg.SlowLatency
+	if slow <= 0 {
+		t.gap = t.cfg.MinGap
+		return
+	}
+	prev := t.gap
+	switch {
+	case err != nil || t.latency > slow:
+		t.gap = min(max(2*t.gap, sleepStep), t.cfg.MaxGap)
+	case t.latency < slow/2:
+		t.gap = max(t.gap/2, t.cfg.MinGap)
+	}
+	if t.gap != prev {
+		slog.Debug("fpvhttp.backoff", "gapMs", t.gap.Milliseconds(), "prevGapMs", prev.Milliseconds(), "latencyMs", t.latency.Milliseconds(), "err", err)
+	}
+}
+
+// state returns the current gap and latency estimate.
+func (t *throttledTransport) state() (gap, latency time.Duration, inflight int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.gap, t.latency, t.inflight
 }
 
 func (t *throttledTransport) CloseIdleConnections() {
-	if closer, ok := t.inner.(interface{ CloseIdleConnections() }); ok {
+	t.mu.Lock()
+	inner := t.inner
+	t.mu.Unlock()
+	if closer, ok := inner.(interface{ CloseIdleConnections() }); ok {
 		closer.CloseIdleConnections()
 	}
 }
@@ -126,3 +187,12 @@ func sleepWithContext(ctx context.Context, d time.Duration) error {
 		return nil
 	}
 }
+
+// Collect reports throttle wait times and the adaptive gap for /metrics.
+func Collect(w *metrics.Writer) {
+	w.Histogram("fpvhttp_throttle_wait_seconds", "Time FPVTrackside requests waited for the shared connection.", throttleWait.Snapshot())
+	gap, latency, inflight := sharedTransport().state()
+	w.Gauge("fpvhttp_gap_seconds", "Current pause between FPVTrackside requests.", gap.Seconds())
+	w.Gauge("fpvhttp_latency_seconds", "Moving average of FPVTrackside response times.", latency.Seconds())
+	w.Gauge("fpvhttp_inflight", "FPVTrackside requests in flight.", float64(inflight))
+}
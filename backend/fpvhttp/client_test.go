@@ -0,0 +1,131 @@
+package fpvhttp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+
+	_ "drone-dashboard/migrations"
+)
+
+func TestSettingsReloadOnChange(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+	t.Cleanup(func() { Configure(DefaultConfig()) })
+	RegisterSettings(app)
+	ensureDefaultSettings(app)
+
+	if got := LoadConfig(app); got.Timeout != time.Second || got.MaxConns != 1 || got.KeepAlive {
+		t.Fatalf("unexpected seeded config: %+v", got)
+	}
+
+	col, err := app.FindCollectionByNameOrId("server_settings")
+	if err != nil {
+		t.Fatalf("collection: %v", err)
+	}
+	set := func(key, value string) {
+		t.Helper()
+		rec, _ := app.FindFirstRecordByData("server_settings", "key", key)
+		if rec == nil {
+			rec = core.NewRecord(col)
+			rec.Set("key", key)
+		}
+		rec.Set("value", value)
+		if err := app.Save(rec); err != nil {
+			t.Fatalf("save %s: %v", key, err)
+		}
+	}
+	set("fpvhttp.timeoutMs.race", "4000")
+	set("fpvhttp.timeoutMs", "1500")
+	set("fpvhttp.maxConns", "2")
+	set("fpvhttp.keepAlive", "true")
+	set("fpvhttp.minGapMs", "nonsense")
+
+	if got := Timeout("/events/e1/r1/Race.json"); got != 4*time.Second {
+		t.Fatalf("race override not applied: %v", got)
+	}
+	if got := Timeout("/events/e1/Results.json?x=1"); got != 1500*time.Millisecond {
+		t.Fatalf("base timeout not applied: %v", got)
+	}
+	cfg := CurrentConfig()
+	if cfg.MaxConns != 2 || !cfg.KeepAlive || cfg.MinGap != DefaultConfig().MinGap {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if tr := sharedTransport(); tr.cfg.MaxConns != 2 {
+		t.Fatalf("shared transport not reconfigured: %+v", tr.cfg)
+	}
+}
+
+func TestThrottleAdaptsGapToLatency(t *testing.T) {
+	cfg := Config{Timeout: time.Second, MaxConns: 1, MinGap: 5 * time.Millisecond, MaxGap: 40 * time.Millisecond, SlowLatency: 100 * time.Millisecond}
+	tr := newThrottledTransport(cfg)
+	ctx := context.Background()
+	take := func(took time.Duration, err error) {
+		tr.inflight++
+		tr.finish(ctx, took, err)
+	}
+
+	for i := 0; i < 5; i++ {
+		take(300*time.Millisecond, nil)
+	}
+	if gap, _, _ := tr.state(); gap != cfg.MaxGap {
+		t.Fatalf("gap should widen to the cap while slow, got %v", gap)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	tr.inflight++
+	tr.finish(cancelled, time.Millisecond, context.Canceled)
+	if _, latency, _ := tr.state(); latency < cfg.SlowLatency {
+		t.Fatalf("abandoned request should not count, latency %v", latency)
+	}
+
+	for i := 0; i < 20; i++ {
+		take(10*time.Millisecond, nil)
+	}
+	if gap, _, inflight := tr.state(); gap != cfg.MinGap || inflight != 0 {
+		t.Fatalf("gap should recover to the minimum, got %v (inflight %d)", gap, inflight)
+	}
+
+	take(10*time.Millisecond, errors.New("connection refused"))
+	if gap, _, _ := tr.state(); gap != 2*cfg.MinGap {
+		t.Fatalf("errors should widen the gap, got %v", gap)
+	}
+}
+
+func TestThrottleLimitsInflight(t *testing.T) {
+	tr := newThrottledTransport(Config{Timeout: time.Second, MaxConns: 2})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if _, err := tr.waitTurn(ctx); err != nil {
+			t.Fatalf("turn %d: %v", i, err)
+		}
+	}
+	if _, err := tr.waitTurn(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("third request should wait for a free slot, got %v", err)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	cases := map[string]string{
+		"/":                           "eventSource",
+		"/events/e1/Event.json":       "event",
+		"/events/e1/r1/Race.json":     "race",
+		"/httpfiles/Channels.json":    "channels",
+		"/events/e1/Results.json?t=1": "results",
+		"/events/e1/Unknown.json":     "other",
+	}
+	for path, want := range cases {
+		if got := Endpoint(path); got != want {
+			t.Errorf("Endpoint(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package fpvhttp
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Config tunes the shared FPVTrackside client. Configure swaps it at runtime,
+// so slow venue laptops can be given room without a restart.
+type Config struct {
+	// Timeout bounds a whole request, body included. EndpointTimeouts
+	// overrides it per Endpoint name, e.g. "race" for large Race.json files.
+	Timeout          time.Duration
+	EndpointTimeouts map[string]time.Duration
+	// MaxConns is how many requests may be in flight at once.
+	MaxConns  int
+	KeepAlive bool
+	// MinGap is the pause between one request finishing and the next
+	// starting. While FPVTrackside answers slower than SlowLatency the gap
+	// doubles up to MaxGap, and it halves again once latency recovers. A zero
+	// SlowLatency keeps the gap at MinGap.
+	MinGap      time.Duration
+	MaxGap      time.Duration
+	SlowLatency time.Duration
+}
+
+// DefaultConfig matches what FPVTrackside's built-in web server handles on a
+// typical timing laptop: one connection, no keep-alive, 1s per request.
+func DefaultConfig() Config {
+	return Config{
+		Timeout:     time.Second,
+		MaxConns:    1,
+		MinGap:      5 * time.Millisecond,
+		MaxGap:      250 * time.Millisecond,
+		SlowLatency: 500 * time.Millisecond,
+	}
+}
+
+func (c Config) normalize() Config {
+	def := DefaultConfig()
+	if c.Timeout <= 0 {
+		c.Timeout = def.Timeout
+	}
+	if c.MaxConns <= 0 {
+		c.MaxConns = def.MaxConns
+	}
+	if c.MinGap < 0 {
+		c.MinGap = 0
+	}
+	if c.MaxGap < c.MinGap {
+		c.MaxGap = c.MinGap
+	}
+	if c.SlowLatency < 0 {
+		c.SlowLatency = 0
+	}
+	return c
+}
+
+var current atomic.Pointer[Config]
+
+func init() {
+	cfg := DefaultConfig()
+	current.Store(&cfg)
+}
+
+// CurrentConfig returns the configuration in effect.
+func CurrentConfig() Config { return *current.Load() }
+
+// Configure applies cfg to the shared client. Requests in flight finish on
+// the settings they started with.
+func Configure(cfg Config) {
+	cfg = cfg.normalize()
+	current.Store(&cfg)
+	if t := sharedTransport(); t != nil {
+		t.apply(cfg)
+	}
+}
+
+// Timeout returns how long a request for path may take.
+func Timeout(path string) time.Duration {
+	cfg := current.Load()
+	if d, ok := cfg.EndpointTimeouts[Endpoint(path)]; ok && d > 0 {
+		return d
+	}
+	return cfg.Timeout
+}
+
+// Endpoint names the FPVTrackside file a path requests, as used for
+// per-endpoint timeouts: eventSource, event, pilots, rounds, results, race,
+// channels or other.
+func Endpoint(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	switch {
+	case path == "/" || path == "":
+		return "eventSource"
+	case strings.HasSuffix(path, "/Event.json"):
+		return "event"
+	case strings.HasSuffix(path, "/Pilots.json"):
+		return "pilots"
+	case strings.HasSuffix(path, "/Rounds.json"):
+		return "rounds"
+	case strings.HasSuffix(path, "/Results.json"):
+		return "results"
+	case strings.HasSuffix(path, "/Race.json"):
+		return "race"
+	case strings.HasSuffix(path, "/Channels.json"):
+		return "channels"
+	default:
+		return "other"
+	}
+}
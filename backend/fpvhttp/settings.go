@@ -0,0 +1,138 @@
+package fpvhttp
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Settings live in server_settings under fpvhttp.* and are applied whenever
+// one changes, like the scheduler.* keys. Per-endpoint timeouts are optional
+// keys named fpvhttp.timeoutMs.<endpoint>, e.g. fpvhttp.timeoutMs.race.
+const (
+	settingPrefix        = "fpvhttp."
+	settingTimeout       = "fpvhttp.timeoutMs"
+	settingMaxConns      = "fpvhttp.maxConns"
+	settingKeepAlive     = "fpvhttp.keepAlive"
+	settingMinGap        = "fpvhttp.minGapMs"
+	settingMaxGap        = "fpvhttp.maxGapMs"
+	settingSlowLatency   = "fpvhttp.slowMs"
+	settingTimeoutPrefix = settingTimeout + "."
+)
+
+// RegisterSettings seeds the fpvhttp.* defaults at startup, applies them and
+// re-applies them on every change.
+func RegisterSettings(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		ensureDefaultSettings(app)
+		reload(app, "startup")
+		return se.Next()
+	})
+	handle := func(op string) func(*core.RecordEvent) error {
+		return func(e *core.RecordEvent) error {
+			if key := strings.TrimSpace(e.Record.GetString("key")); strings.HasPrefix(key, settingPrefix) {
+				reload(e.App, op+":"+key)
+			}
+			return e.Next()
+		}
+	}
+	app.OnRecordAfterCreateSuccess("server_settings").BindFunc(handle("create"))
+	app.OnRecordAfterUpdateSuccess("server_settings").BindFunc(handle("update"))
+	app.OnRecordAfterDeleteSuccess("server_settings").BindFunc(handle("delete"))
+}
+
+func ensureDefaultSettings(app core.App) {
+	def := DefaultConfig()
+	defaults := map[string]string{
+		settingTimeout:     strconv.FormatInt(def.Timeout.Milliseconds(), 10),
+		settingMaxConns:    strconv.Itoa(def.MaxConns),
+		settingKeepAlive:   strconv.FormatBool(def.KeepAlive),
+		settingMinGap:      strconv.FormatInt(def.MinGap.Milliseconds(), 10),
+		settingMaxGap:      strconv.FormatInt(def.MaxGap.Milliseconds(), 10),
+		settingSlowLatency: strconv.FormatInt(def.SlowLatency.Milliseconds(), 10),
+	}
+	col, err := app.FindCollectionByNameOrId("server_settings")
+	if err != nil {
+		slog.Warn("fpvhttp.config.seed.collection.error", "err", err)
+		return
+	}
+	for k, v := range defaults {
+		rec, _ := app.FindFirstRecordByFilter("server_settings", "key = {:k}", dbx.Params{"k": k})
+		if rec != nil {
+			continue
+		}
+		rec = core.NewRecord(col)
+		rec.Set("key", k)
+		rec.Set("value", v)
+		if err := app.Save(rec); err != nil {
+			slog.Warn("fpvhttp.config.seed.save.error", "key", k, "err", err)
+		}
+	}
+}
+
+func reload(app core.App, reason string) {
+	cfg := LoadConfig(app)
+	Configure(cfg)
+	slog.Info("fpvhttp.reload",
+		"reason", reason,
+		"timeoutMs", cfg.Timeout.Milliseconds(),
+		"endpointTimeouts", len(cfg.EndpointTimeouts),
+		"maxConns", cfg.MaxConns,
+		"keepAlive", cfg.KeepAlive,
+		"minGapMs", cfg.MinGap.Milliseconds(),
+		"maxGapMs", cfg.MaxGap.Milliseconds(),
+		"slowMs", cfg.SlowLatency.Milliseconds(),
+	)
+}
+
+// LoadConfig reads the fpvhttp.* settings; missing or malformed values keep
+// their defaults.
+func LoadConfig(app core.App) Config {
+	cfg := DefaultConfig()
+	recs, err := app.FindRecordsByFilter("server_settings", "key ~ {:p}", "", 0, 0, dbx.Params{"p": settingPrefix + "%"})
+	if err != nil {
+		slog.Warn("fpvhttp.config.load.error", "err", err)
+		return cfg.normalize()
+	}
+	ms := func(v string, into *time.Duration) {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			*into = time.Duration(n) * time.Millisecond
+		}
+	}
+	for _, rec := range recs {
+		key := strings.TrimSpace(rec.GetString("key"))
+		value := strings.TrimSpace(rec.GetString("value"))
+		switch {
+		case key == settingTimeout:
+			ms(value, &cfg.Timeout)
+		case key == settingMaxConns:
+			if n, err := strconv.Atoi(value); err == nil {
+				cfg.MaxConns = n
+			}
+		case key == settingKeepAlive:
+			if b, err := strconv.ParseBool(value); err == nil {
+				cfg.KeepAlive = b
+			}
+		case key == settingMinGap:
+			ms(value, &cfg.MinGap)
+		case key == settingMaxGap:
+			ms(value, &cfg.MaxGap)
+		case key == settingSlowLatency:
+			ms(value, &cfg.SlowLatency)
+		case strings.HasPrefix(key, settingTimeoutPrefix):
+			var d time.Duration
+			ms(value, &d)
+			if d > 0 {
+				if cfg.EndpointTimeouts == nil {
+					cfg.EndpointTimeouts = make(map[string]time.Duration)
+				}
+				cfg.EndpointTimeouts[strings.TrimPrefix(key, settingTimeoutPrefix)] = d
+			}
+		}
+	}
+	return cfg.normalize()
+}
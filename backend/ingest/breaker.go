@@ -0,0 +1,304 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"drone-dashboard/control"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// The breaker stops ingest from hammering a timing system that is down. After
+// Threshold consecutive outage errors it opens and fetches fail at once with
+// a *CircuitOpenError. Once the open period passes, a single fetch is let
+// through as a probe: success closes the breaker, failure reopens it for twice
+// as long, up to MaxOpenFor.
+
+// BreakerState is the state of a Breaker.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "halfOpen"
+)
+
+const (
+	defaultBreakerThreshold  = 5
+	defaultBreakerOpenFor    = 2 * time.Second
+	defaultBreakerMaxOpenFor = 30 * time.Second
+)
+
+// ErrSourceUnavailable matches every *CircuitOpenError.
+var ErrSourceUnavailable = errors.New("timing system unreachable")
+
+// CircuitOpenError is returned instead of fetching while the breaker is open.
+type CircuitOpenError struct {
+	RetryAt time.Time
+	LastErr string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("timing system unreachable, next probe in %s (last error: %s)",
+		time.Until(e.RetryAt).Round(time.Millisecond), e.LastErr)
+}
+
+func (e *CircuitOpenError) Unwrap() error { return ErrSourceUnavailable }
+
+// BreakerStatus is what a Breaker publishes on every state change. The status
+// is public, so the last error, which may name internal hosts, stays in logs
+// and only its coarse Reason is published.
+type BreakerStatus struct {
+	State     BreakerState `json:"state"`
+	Failures  int          `json:"failures"`
+	LastError string       `json:"-"`
+	Reason    string       `json:"reason,omitempty"`
+	ChangedAt int64        `json:"changedAt"`
+	RetryAt   int64        `json:"retryAt,omitempty"`
+}
+
+// Breaker guards the fetches of one Source.
+type Breaker struct {
+	Threshold  int
+	OpenFor    time.Duration
+	MaxOpenFor time.Duration
+	// OnChange, when set, is called after every state change, outside the lock.
+	OnChange func(BreakerStatus)
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	lastErr   string
+	reason    string
+	changedAt time.Time
+	retryAt   time.Time
+	openFor   time.Duration
+	probing   bool
+}
+
+// NewBreaker returns a closed breaker with the default thresholds.
+func NewBreaker(onChange func(BreakerStatus)) *Breaker {
+	return &Breaker{
+		Threshold:  defaultBreakerThreshold,
+		OpenFor:    defaultBreakerOpenFor,
+		MaxOpenFor: defaultBreakerMaxOpenFor,
+		OnChange:   onChange,
+		state:      BreakerClosed,
+		changedAt:  time.Now(),
+	}
+}
+
+// Status returns the current state.
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.statusLocked()
+}
+
+func (b *Breaker) statusLocked() BreakerStatus {
+	st := BreakerStatus{State: b.state, Failures: b.failures, LastError: b.lastErr, Reason: b.reason, ChangedAt: b.changedAt.UnixMilli()}
+	if b.state != BreakerClosed {
+		st.RetryAt = b.retryAt.UnixMilli()
+	}
+	return st
+}
+
+// allow reports whether a fetch may go ahead; while half-open only the probe
+// may.
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	now := time.Now()
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.retryAt) {
+			err := &CircuitOpenError{RetryAt: b.retryAt, LastErr: b.lastErr}
+			b.mu.Unlock()
+			return err
+		}
+		b.probing = true
+		st := b.setStateLocked(BreakerHalfOpen, now)
+		b.mu.Unlock()
+		slog.Info("ingest.breaker.probe", "failures", st.Failures, "lastError", st.LastError)
+		b.notify(st)
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			err := &CircuitOpenError{RetryAt: now.Add(b.openFor), LastErr: b.lastErr}
+			b.mu.Unlock()
+			return err
+		}
+		b.probing = true
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// record feeds the outcome of an allowed fetch back into the breaker.
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	now := time.Now()
+	if errors.Is(err, context.Canceled) || control.IsLinkError(err) {
+		// says nothing about the source; let the next fetch probe instead
+		b.probing = false
+		b.mu.Unlock()
+		return
+	}
+	if !isSourceOutage(err) {
+		b.failures, b.probing = 0, false
+		if b.state == BreakerClosed {
+			b.mu.Unlock()
+			return
+		}
+		b.lastErr, b.reason = "", ""
+		st := b.setStateLocked(BreakerClosed, now)
+		b.mu.Unlock()
+		slog.Info("ingest.breaker.closed")
+		b.notify(st)
+		return
+	}
+
+	b.failures++
+	b.lastErr, b.reason = err.Error(), outageReason(err)
+	switch {
+	case b.state == BreakerHalfOpen:
+		b.openFor = min(2*b.openFor, b.MaxOpenFor)
+	case b.state == BreakerClosed && b.failures >= b.Threshold:
+		b.openFor = b.OpenFor
+	default:
+		b.mu.Unlock()
+		return
+	}
+	b.probing = false
+	b.retryAt = now.Add(b.openFor)
+	st := b.setStateLocked(BreakerOpen, now)
+	b.mu.Unlock()
+	slog.Warn("ingest.breaker.open", "failures", st.Failures, "lastError", st.LastError, "retryInMs", b.openFor.Milliseconds())
+	b.notify(st)
+}
+
+func (b *Breaker) setStateLocked(state BreakerState, now time.Time) BreakerStatus {
+	b.state, b.changedAt = state, now
+	return b.statusLocked()
+}
+
+func (b *Breaker) notify(st BreakerStatus) {
+	if b.OnChange != nil {
+		b.OnChange(st)
+	}
+}
+
+// isSourceOutage separates a timing system that cannot be reached or is
+// failing from one that answered with something ingest could not use.
+func isSourceOutage(err error) bool {
+	if err == nil {
+		return false
+	}
+	var status *HTTPStatusError
+	if errors.As(err, &status) {
+		return status.Status >= 500
+	}
+	var syntax *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return !errors.As(err, &syntax) && !errors.As(err, &typeErr)
+}
+
+// outageReason is the published cause of an outage, without its details.
+func outageReason(err error) string {
+	var status *HTTPStatusError
+	if errors.As(err, &status) {
+		return "server error"
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	return "unreachable"
+}
+
+func guard[T any](b *Breaker, fetch func() (T, error)) (T, error) {
+	if err := b.allow(); err != nil {
+		var zero T
+		return zero, err
+	}
+	v, err := fetch()
+	b.record(err)
+	return v, err
+}
+
+// breakerSource is a Source whose fetches pass through a Breaker.
+type breakerSource struct {
+	inner Source
+	b     *Breaker
+}
+
+func (s breakerSource) FetchEvent(eventSourceId string) (EventFile, error) {
+	return guard(s.b, func() (EventFile, error) { return s.inner.FetchEvent(eventSourceId) })
+}
+func (s breakerSource) FetchPilots(eventSourceId string) (PilotsFile, error) {
+	return guard(s.b, func() (PilotsFile, error) { return s.inner.FetchPilots(eventSourceId) })
+}
+func (s breakerSource) FetchChannels() (ChannelsFile, error) {
+	return guard(s.b, s.inner.FetchChannels)
+}
+func (s breakerSource) FetchRounds(eventSourceId string) (RoundsFile, error) {
+	return guard(s.b, func() (RoundsFile, error) { return s.inner.FetchRounds(eventSourceId) })
+}
+func (s breakerSource) FetchRace(eventSourceId, raceId string) (RaceFile, error) {
+	return guard(s.b, func() (RaceFile, error) { return s.inner.FetchRace(eventSourceId, raceId) })
+}
+func (s breakerSource) FetchResults(eventSourceId string) (ResultsFile, error) {
+	return guard(s.b, func() (ResultsFile, error) { return s.inner.FetchResults(eventSourceId) })
+}
+func (s breakerSource) FetchEventSourceId() (string, error) {
+	return guard(s.b, s.inner.FetchEventSourceId)
+}
+
+// SourceHealthKey is the server_settings key where the breaker of the
+// service for pitsID publishes its status; empty is the unscoped service.
+func SourceHealthKey(pitsID string) string {
+	if pitsID == "" {
+		return "ingest.sourceHealth"
+	}
+	return "ingest.sourceHealth." + pitsID
+}
+
+// NewSettingsBreaker returns a breaker that publishes its status as JSON in
+// server_settings under key, so the admin UI can show when the timing system
+// is unreachable. A closed status is written at startup to clear what a
+// previous run left behind.
+func NewSettingsBreaker(app core.App, key string) *Breaker {
+	b := NewBreaker(func(st BreakerStatus) { publishBreakerStatus(app, key, st) })
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		publishBreakerStatus(app, key, b.Status())
+		return se.Next()
+	})
+	return b
+}
+
+func publishBreakerStatus(app core.App, key string, st BreakerStatus) {
+	value, err := json.Marshal(st)
+	if err != nil {
+		return
+	}
+	rec, _ := app.FindFirstRecordByData("server_settings", "key", key)
+	if rec == nil {
+		col, err := app.FindCollectionByNameOrId("server_settings")
+		if err != nil {
+			slog.Warn("ingest.breaker.publish.error", "key", key, "err", err)
+			return
+		}
+		rec = core.NewRecord(col)
+		rec.Set("key", key)
+	}
+	rec.Set("value", string(value))
+	if err := app.Save(rec); err != nil {
+		slog.Warn("ingest.breaker.publish.error", "key", key, "err", err)
+	}
+}
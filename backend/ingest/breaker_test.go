@@ -0,0 +1,141 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"drone-dashboard/control"
+
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+// flakySource answers FetchEventSourceId with err.
+type flakySource struct {
+	fakeRaceSource
+	err   error
+	calls int
+}
+
+func (f *flakySource) FetchEventSourceId() (string, error) {
+	f.calls++
+	return "evt", f.err
+}
+
+func TestBreakerOpensProbesAndCloses(t *testing.T) {
+	var changes []BreakerState
+	b := NewBreaker(func(st BreakerStatus) { changes = append(changes, st.State) })
+	b.Threshold, b.OpenFor, b.MaxOpenFor = 2, 20*time.Millisecond, 30*time.Millisecond
+	src := &flakySource{err: errors.New("dial tcp: connection refused")}
+	svc := &Service{Source: src, Breaker: b}
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Origin().FetchEventSourceId(); errors.Is(err, ErrSourceUnavailable) {
+			t.Fatalf("attempt %d should reach the source, got %v", i, err)
+		}
+	}
+	_, err := svc.Origin().FetchEventSourceId()
+	var open *CircuitOpenError
+	if !errors.As(err, &open) || src.calls != 2 {
+		t.Fatalf("expected the open breaker to fail fast, got %v after %d calls", err, src.calls)
+	}
+
+	// the probe fails: open again, for longer (capped)
+	time.Sleep(25 * time.Millisecond)
+	_, _ = svc.Origin().FetchEventSourceId()
+	if st := b.Status(); st.State != BreakerOpen || src.calls != 3 || st.RetryAt-st.ChangedAt != 30 {
+		t.Fatalf("failed probe should reopen for 30ms, got %+v after %d calls", st, src.calls)
+	}
+
+	// FPVTrackside is back; a 404 still proves it answers
+	src.err = &HTTPStatusError{URL: "/", Status: 404}
+	time.Sleep(35 * time.Millisecond)
+	_, _ = svc.Origin().FetchEventSourceId()
+	if st := b.Status(); st.State != BreakerClosed || st.Failures != 0 {
+		t.Fatalf("successful probe should close, got %+v", st)
+	}
+	want := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(changes) != len(want) {
+		t.Fatalf("unexpected transitions %v", changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("unexpected transitions %v", changes)
+		}
+	}
+}
+
+func TestBreakerIgnoresUsableAnswers(t *testing.T) {
+	b := NewBreaker(nil)
+	b.Threshold = 1
+	for _, err := range []error{
+		&HTTPStatusError{Status: 404},
+		&json.SyntaxError{Offset: 3},
+		nil,
+	} {
+		if _, err := guard(b, func() (int, error) { return 0, err }); err != nil && errors.Is(err, ErrSourceUnavailable) {
+			t.Fatalf("breaker opened on %v", err)
+		}
+	}
+	if st := b.Status(); st.State != BreakerClosed {
+		t.Fatalf("expected closed, got %+v", st)
+	}
+	_, _ = guard(b, func() (int, error) { return 0, &HTTPStatusError{Status: 503} })
+	if st := b.Status(); st.State != BreakerOpen {
+		t.Fatalf("5xx should count as an outage, got %+v", st)
+	}
+}
+
+func TestBreakerIgnoresControlLinkErrors(t *testing.T) {
+	b := NewBreaker(nil)
+	b.Threshold = 1
+	for _, err := range []error{
+		fmt.Errorf("%w: north", control.ErrPitsNotConnected),
+		control.NewTraceError("t1", &control.RemoteError{Code: "BUSY", Message: "fetch queue full"}),
+		// a DoFetch that ran out of its link deadline
+		control.NewTraceError("t1", fmt.Errorf("%w: %w", control.ErrPitsTimeout, context.DeadlineExceeded)),
+	} {
+		_, _ = guard(b, func() (int, error) { return 0, err })
+		if st := b.Status(); st.State != BreakerClosed || st.Failures != 0 {
+			t.Fatalf("link error %v counted against the timing system: %+v", err, st)
+		}
+	}
+	// the pits reached the link but not FPVTrackside: that is an outage
+	_, _ = guard(b, func() (int, error) { return 0, &control.RemoteError{Code: "INTERNAL", Message: "connection refused"} })
+	if st := b.Status(); st.State != BreakerOpen {
+		t.Fatalf("pits-side fetch failure should open the breaker, got %+v", st)
+	}
+}
+
+func TestSettingsBreakerPublishesStatus(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	key := SourceHealthKey("north")
+	b := NewSettingsBreaker(app, key)
+	b.Threshold = 1
+	_, _ = guard(b, func() (int, error) { return 0, errors.New("dial tcp 10.0.0.7:8080: connection refused") })
+
+	rec, err := app.FindFirstRecordByData("server_settings", "key", key)
+	if err != nil {
+		t.Fatalf("status not published: %v", err)
+	}
+	value := rec.GetString("value")
+	var st BreakerStatus
+	if err := json.Unmarshal([]byte(value), &st); err != nil {
+		t.Fatalf("decode %q: %v", value, err)
+	}
+	if st.State != BreakerOpen || st.Reason != "unreachable" || st.RetryAt == 0 {
+		t.Fatalf("unexpected published status %+v", st)
+	}
+	if strings.Contains(value, "10.0.0.7") {
+		t.Fatalf("published status leaks the error text: %s", value)
+	}
+}
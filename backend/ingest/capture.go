@@ -0,0 +1,385 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"drone-dashboard/control"
+	"drone-dashboard/metrics"
+)
+
+// Recorder keeps every distinct FPVTrackside response on disk, so the raw
+// data behind an odd moment at an event can be looked at, or played back with
+// ReplaySource, afterwards.
+//
+// Captures go into archives, subdirectories of Dir named after the time they
+// were started. Each archive is a recording in the ReplaySource layout, with a
+// captures.jsonl index listing path, ETag and receive time of every file.
+// Once MaxBytes of new captures are in an archive a new one is started,
+// seeded with the latest capture of every path so it replays on its own, and
+// archives beyond the newest Keep are deleted.
+//
+// Record only queues the body: a background writer does the disk work, so a
+// slow disk never holds up a fetch. When the queue is full the capture is
+// dropped and counted instead.
+type Recorder struct {
+	Dir      string
+	MaxBytes int64
+	Keep     int
+	// Location, when set, gives the venue zone written to each archive's
+	// replay.json; the default is this server's zone.
+	Location func() *time.Location
+	// now is the receive clock; tests replace it.
+	now func() time.Time
+
+	queue   chan capture
+	done    chan struct{}
+	sendMu  sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+
+	// mu guards the archive state, which only the writer touches until Close.
+	mu      sync.Mutex
+	archive string
+	index   *os.File
+	size    int64
+	// seeded is the size of the captures carried into the archive.
+	seeded int64
+	last   map[string]capturedBody
+}
+
+// CaptureIndexName is the per-archive index of captures.
+const CaptureIndexName = "captures.jsonl"
+
+// archiveLayout names archive directories; it sorts by time.
+const archiveLayout = "20060102-150405.000"
+
+// CaptureEntry is one line of captures.jsonl.
+type CaptureEntry struct {
+	Path  string `json:"path"`
+	ETag  string `json:"etag"`
+	At    int64  `json:"at"`
+	File  string `json:"file"`
+	Bytes int    `json:"bytes"`
+}
+
+type capturedBody struct {
+	etag string
+	file string
+	at   time.Time
+}
+
+// capture is a queued body; a non-nil synced is closed once every capture
+// queued before it is written.
+type capture struct {
+	path   string
+	body   []byte
+	at     time.Time
+	synced chan struct{}
+}
+
+// captureQueueSize is how many bodies may wait for the writer.
+const captureQueueSize = 64
+
+// NewRecorder returns a recorder that starts a new archive under dir after
+// maxBytes of captures and keeps the newest keep archives; zero means no limit.
+func NewRecorder(dir string, maxBytes int64, keep int) *Recorder {
+	r := &Recorder{
+		Dir: dir, MaxBytes: maxBytes, Keep: keep, now: time.Now, last: make(map[string]capturedBody),
+		queue: make(chan capture, captureQueueSize), done: make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record queues body as the response to path. It is stored unless it equals
+// the last one stored for path; body must not be modified afterwards.
+// Failures are logged; capturing never fails or slows a fetch.
+func (r *Recorder) Record(path string, body []byte) {
+	r.send(capture{path: path, body: body, at: r.now()}, false)
+}
+
+// Dropped is how many captures were dropped because the writer fell behind.
+func (r *Recorder) Dropped() int64 { return r.dropped.Load() }
+
+// Collect reports dropped captures for /metrics.
+func (r *Recorder) Collect(w *metrics.Writer) {
+	w.Counter("ingest_capture_dropped_total", "Captures dropped because the capture writer fell behind.", float64(r.Dropped()), metrics.L("dir", r.Dir))
+}
+
+// Sync waits until everything recorded so far is on disk.
+func (r *Recorder) Sync() {
+	synced := make(chan struct{})
+	if r.send(capture{synced: synced}, true) {
+		<-synced
+	}
+}
+
+func (r *Recorder) send(c capture, wait bool) bool {
+	r.sendMu.RLock()
+	defer r.sendMu.RUnlock()
+	if r.closed {
+		return false
+	}
+	if wait {
+		r.queue <- c
+		return true
+	}
+	select {
+	case r.queue <- c:
+		return true
+	default:
+		if n := r.dropped.Add(1); n == 1 || n%100 == 0 {
+			slog.Warn("ingest.capture.dropped", "path", c.path, "dropped", n)
+		}
+		return false
+	}
+}
+
+// run is the writer.
+func (r *Recorder) run() {
+	defer close(r.done)
+	for c := range r.queue {
+		if c.synced != nil {
+			close(c.synced)
+			continue
+		}
+		r.write(c.path, c.body, c.at)
+	}
+}
+
+func (r *Recorder) write(path string, body []byte, at time.Time) {
+	path = replayPath(path)
+	if path == "" {
+		path = "/"
+	}
+	if !control.IsAllowedFetchPath(path) {
+		slog.Warn("ingest.capture.path.rejected", "path", path)
+		return
+	}
+	etag := control.ComputeETag(body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev, seen := r.last[path]
+	if seen && prev.etag == etag {
+		return
+	}
+	if seen && !at.After(prev.at) {
+		// file names carry millisecond times; keep versions of a path apart
+		at = prev.at.Add(time.Millisecond)
+	}
+	// carried-over captures do not count, or a large event would rotate on
+	// every change
+	added := r.size - r.seeded
+	full := r.MaxBytes > 0 && added > 0 && added+int64(len(body)) > r.MaxBytes
+	if r.archive == "" || full {
+		if err := r.rotateLocked(at); err != nil {
+			slog.Warn("ingest.capture.rotate.error", "dir", r.Dir, "err", err)
+			return
+		}
+	}
+	if err := r.writeLocked(path, etag, at, body); err != nil {
+		slog.Warn("ingest.capture.write.error", "path", path, "err", err)
+	}
+}
+
+// Close writes what is queued and closes the current archive's index. Later
+// captures are ignored.
+func (r *Recorder) Close() error {
+	r.sendMu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.sendMu.Unlock()
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.index == nil {
+		return nil
+	}
+	err := r.index.Close()
+	r.index = nil
+	return err
+}
+
+func (r *Recorder) writeLocked(path, etag string, at time.Time, body []byte) error {
+	rel := ReplayFileName(path, at)
+	file := filepath.Join(r.archive, rel)
+	if inside, err := filepath.Rel(r.archive, file); err != nil || !filepath.IsLocal(inside) {
+		return fmt.Errorf("capture of %s would leave the archive", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, body, 0o644); err != nil {
+		return err
+	}
+	line, _ := json.Marshal(CaptureEntry{Path: path, ETag: etag, At: at.UnixMilli(), File: filepath.ToSlash(rel), Bytes: len(body)})
+	if _, err := r.index.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	r.size += int64(len(body))
+	r.last[path] = capturedBody{etag: etag, file: file, at: at}
+	return nil
+}
+
+// rotateLocked starts a new archive, carries the latest capture of every path
+// into it and prunes old archives.
+func (r *Recorder) rotateLocked(at time.Time) error {
+	dir := filepath.Join(r.Dir, at.UTC().Format(archiveLayout))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	loc := time.Local
+	if r.Location != nil {
+		loc = r.Location()
+	}
+	_, offset := at.In(loc).Zone()
+	manifest, _ := json.Marshal(ReplayManifest{UTCOffsetSec: &offset})
+	if err := os.WriteFile(filepath.Join(dir, ReplayManifestName), manifest, 0o644); err != nil {
+		return err
+	}
+	index, err := os.OpenFile(filepath.Join(dir, CaptureIndexName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if r.index != nil {
+		r.index.Close()
+	}
+	prevArchive := r.archive
+	r.archive, r.index, r.size = dir, index, 0
+
+	carried := r.last
+	r.last = make(map[string]capturedBody, len(carried))
+	for path, c := range carried {
+		body, err := os.ReadFile(c.file)
+		if err != nil {
+			slog.Debug("ingest.capture.carry.skip", "path", path, "err", err)
+			continue
+		}
+		if err := r.writeLocked(path, c.etag, c.at, body); err != nil {
+			return err
+		}
+	}
+	r.seeded = r.size
+	slog.Info("ingest.capture.rotate", "archive", dir, "previous", prevArchive, "carriedPaths", len(r.last), "carriedBytes", r.seeded)
+	r.pruneLocked()
+	return nil
+}
+
+// pruneLocked deletes the oldest archives beyond Keep. Only directories that
+// hold a captures index are considered, so nothing else under Dir is touched.
+func (r *Recorder) pruneLocked() {
+	if r.Keep <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(r.Dir)
+	if err != nil {
+		return
+	}
+	var archives []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(archiveLayout, e.Name()); err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(r.Dir, e.Name(), CaptureIndexName)); err != nil {
+			continue
+		}
+		archives = append(archives, e.Name())
+	}
+	sort.Strings(archives)
+	for len(archives) > r.Keep {
+		old := filepath.Join(r.Dir, archives[0])
+		archives = archives[1:]
+		if old == r.archive {
+			continue
+		}
+		if err := os.RemoveAll(old); err != nil {
+			slog.Warn("ingest.capture.prune.error", "archive", old, "err", err)
+			continue
+		}
+		slog.Info("ingest.capture.prune", "archive", old)
+	}
+}
+
+// RecordSource makes every response src fetches reach record. FPVClient and
+// RemoteSource already see raw bodies, so those are tapped in place and src
+// is returned as is; any other Source is wrapped and its answers re-encoded.
+func RecordSource(src Source, record func(path string, body []byte)) Source {
+	switch s := src.(type) {
+	case DirectSource:
+		s.C.OnBody = chainOnBody(s.C.OnBody, record)
+		return s
+	case *RemoteSource:
+		s.OnBody = chainOnBody(s.OnBody, record)
+		return s
+	}
+	return recordingSource{inner: src, record: record}
+}
+
+func chainOnBody(prev, next func(string, []byte)) func(string, []byte) {
+	if prev == nil {
+		return next
+	}
+	return func(path string, body []byte) {
+		prev(path, body)
+		next(path, body)
+	}
+}
+
+// recordingSource records the re-encoded answers of a Source that does not
+// expose its bodies.
+type recordingSource struct {
+	inner  Source
+	record func(path string, body []byte)
+}
+
+func recordAnswer[T any](rs recordingSource, path string, v T, err error) (T, error) {
+	if err == nil {
+		if b, merr := json.Marshal(v); merr == nil {
+			rs.record(path, b)
+		}
+	}
+	return v, err
+}
+
+func (rs recordingSource) FetchEvent(eventSourceId string) (EventFile, error) {
+	v, err := rs.inner.FetchEvent(eventSourceId)
+	return recordAnswer(rs, "/events/"+eventSourceId+"/Event.json", v, err)
+}
+func (rs recordingSource) FetchPilots(eventSourceId string) (PilotsFile, error) {
+	v, err := rs.inner.FetchPilots(eventSourceId)
+	return recordAnswer(rs, "/events/"+eventSourceId+"/Pilots.json", v, err)
+}
+func (rs recordingSource) FetchChannels() (ChannelsFile, error) {
+	v, err := rs.inner.FetchChannels()
+	return recordAnswer(rs, "/httpfiles/Channels.json", v, err)
+}
+func (rs recordingSource) FetchRounds(eventSourceId string) (RoundsFile, error) {
+	v, err := rs.inner.FetchRounds(eventSourceId)
+	return recordAnswer(rs, "/events/"+eventSourceId+"/Rounds.json", v, err)
+}
+func (rs recordingSource) FetchRace(eventSourceId, raceId string) (RaceFile, error) {
+	v, err := rs.inner.FetchRace(eventSourceId, raceId)
+	return recordAnswer(rs, "/events/"+eventSourceId+"/"+raceId+"/Race.json", v, err)
+}
+func (rs recordingSource) FetchResults(eventSourceId string) (ResultsFile, error) {
+	v, err := rs.inner.FetchResults(eventSourceId)
+	return recordAnswer(rs, "/events/"+eventSourceId+"/Results.json", v, err)
+}
+func (rs recordingSource) FetchEventSourceId() (string, error) {
+	return rs.inner.FetchEventSourceId()
+}
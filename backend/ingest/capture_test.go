@@ -0,0 +1,186 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"drone-dashboard/control"
+)
+
+func readCaptureIndex(t *testing.T, archive string) []CaptureEntry {
+	t.Helper()
+	f, err := os.Open(filepath.Join(archive, CaptureIndexName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var out []CaptureEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e CaptureEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestRecorderDedupsAndReplays(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	rec := NewRecorder(dir, 0, 0)
+	rec.now = func() time.Time { return now }
+	rec.Location = func() *time.Location { return time.FixedZone("venue", 3600) }
+	t.Cleanup(func() { rec.Close() })
+
+	racePath := "/events/e1/r1/Race.json"
+	rec.Record(racePath, []byte(`[{"ID":"r1","Laps":[]}]`))
+	now = now.Add(time.Second)
+	rec.Record(racePath, []byte(`[{"ID":"r1","Laps":[]}]`))
+	rec.Record(racePath, []byte(`[{"ID":"r1","Laps":[{"ID":"l1"}]}]`))
+	// same millisecond as the previous version
+	rec.Record(racePath, []byte(`[{"ID":"r1","Laps":[{"ID":"l1"},{"ID":"l2"}]}]`))
+	rec.Sync()
+
+	archive := rec.archive
+	entries := readCaptureIndex(t, archive)
+	if len(entries) != 3 {
+		t.Fatalf("%d captures, want 3 (duplicate skipped): %+v", len(entries), entries)
+	}
+	if entries[2].At != entries[1].At+1 || entries[0].ETag == entries[1].ETag {
+		t.Fatalf("entries = %+v", entries)
+	}
+
+	r, err := NewReplaySource(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, off := time.Now().In(r.Location()).Zone(); off != 3600 {
+		t.Fatalf("venue offset %d, want 3600", off)
+	}
+	r.SetPaused(true)
+	r.Seek(time.UnixMilli(entries[2].At))
+	races, err := r.FetchRace("e1", "r1")
+	if err != nil || len(races[0].Laps) != 2 {
+		t.Fatalf("replayed race: %+v, %v", races, err)
+	}
+}
+
+func TestRecorderRotatesAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	// an unrelated directory that pruning must leave alone
+	if err := os.MkdirAll(filepath.Join(dir, "20000101-000000.000"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	rec := NewRecorder(dir, 40, 2)
+	rec.now = func() time.Time { return now }
+	t.Cleanup(func() { rec.Close() })
+
+	rec.Record("/httpfiles/Channels.json", []byte(`[{"ID":"c1"}]`)) // 13 bytes
+	rec.Sync()
+	var archives []string
+	for i := range 6 {
+		now = now.Add(time.Second)
+		rec.Record("/events/e1/Event.json", []byte(`[{"ID":"e1","Name":"round `+string(rune('a'+i))+`"}]`)) // 29 bytes
+		rec.Sync()
+		if len(archives) == 0 || archives[len(archives)-1] != rec.archive {
+			archives = append(archives, rec.archive)
+		}
+	}
+	if len(archives) < 3 {
+		t.Fatalf("expected several archives, got %v", archives)
+	}
+
+	// the newest archive replays on its own: Channels.json was carried over
+	last := archives[len(archives)-1]
+	r, err := NewReplaySource(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetPaused(true)
+	r.Seek(now)
+	if ch, err := r.FetchChannels(); err != nil || len(ch) != 1 {
+		t.Fatalf("carried channels: %+v, %v", ch, err)
+	}
+
+	left, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range left {
+		names = append(names, e.Name())
+	}
+	if len(names) != 3 || names[0] != "20000101-000000.000" || filepath.Join(dir, names[2]) != last {
+		t.Fatalf("after pruning: %v", names)
+	}
+}
+
+func TestRecordSourceTapsRemoteBodies(t *testing.T) {
+	var got []string
+	rs := NewRemoteSource(nil, "p1")
+	if RecordSource(rs, func(path string, _ []byte) { got = append(got, path) }) != Source(rs) {
+		t.Fatal("RemoteSource should be tapped in place")
+	}
+	svc := &Service{Source: rs}
+	push := control.Push{Path: "/events/e1/Rounds.json", BodyB64: base64.StdEncoding.EncodeToString([]byte(`[]`))}
+	if err := svc.HandlePush("p1", push); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "/events/e1/Rounds.json" {
+		t.Fatalf("recorded %v", got)
+	}
+}
+
+func TestRecorderDropsWhenWriterFallsBehind(t *testing.T) {
+	rec := NewRecorder(t.TempDir(), 0, 0)
+	t.Cleanup(func() { rec.Close() })
+	// a stalled disk: the writer blocks on the archive lock
+	rec.mu.Lock()
+	for i := range captureQueueSize + 10 {
+		rec.Record("/events/e1/Event.json", []byte(`[{"n":`+string(rune('0'+i%10))+`}]`))
+	}
+	rec.mu.Unlock()
+	if rec.Dropped() == 0 {
+		t.Fatal("no capture dropped with a full queue")
+	}
+}
+
+func TestRecorderRefusesPathsOutsideTheArchive(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "captures")
+	rec := NewRecorder(dir, 0, 0)
+	defer rec.Close()
+	rs := NewRemoteSource(nil, "p1")
+	RecordSource(rs, rec.Record)
+
+	svc := &Service{Source: rs}
+	for _, path := range []string{"/events/../../escaped.json", "/events/../../../escaped.json", "/events/e1/..\\..\\escaped.json", "/etc/escaped.json"} {
+		push := control.Push{Path: path, BodyB64: base64.StdEncoding.EncodeToString([]byte(`[]`))}
+		_ = svc.HandlePush("p1", push)
+	}
+	rec.Record("/events/e1/Event.json", []byte(`[]`))
+	rec.Sync()
+
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err == nil && strings.Contains(d.Name(), "escaped") {
+			t.Errorf("traversal capture written to %s", p)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archives, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(archives) != 1 {
+		t.Fatalf("archives = %v", archives)
+	}
+	if entries := readCaptureIndex(t, archives[0]); len(entries) != 1 || entries[0].Path != "/events/e1/Event.json" {
+		t.Fatalf("index = %+v", entries)
+	}
+}
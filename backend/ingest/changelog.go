@@ -0,0 +1,141 @@
+package ingest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"drone-dashboard/control"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// changeQuery finds changed rows of one collection grouped by the FPVTrackside
+// file they came from. Every query selects ev, race, n and lu.
+type changeQuery struct {
+	sql  string
+	path func(ev, race string) string
+}
+
+func eventFile(name string) func(ev, race string) string {
+	return func(ev, _ string) string { return "/events/" + ev + "/" + name }
+}
+
+func raceFile(ev, race string) string { return "/events/" + ev + "/" + race + "/Race.json" }
+
+// raceChildQuery covers collections that hang off a race.
+func raceChildQuery(table string) changeQuery {
+	return changeQuery{
+		sql: fmt.Sprintf(`SELECT e.sourceId AS ev, r.sourceId AS race, COUNT(*) AS n, MAX(x.lastUpdated) AS lu
+			FROM %s x JOIN races r ON r.id = x.race JOIN events e ON e.id = r.event
+			WHERE x.lastUpdated > {:since} GROUP BY e.sourceId, r.sourceId`, table),
+		path: raceFile,
+	}
+}
+
+// eventChildQuery covers collections that hang off an event and map to one file.
+func eventChildQuery(table, file string) changeQuery {
+	return changeQuery{
+		sql: fmt.Sprintf(`SELECT e.sourceId AS ev, '' AS race, COUNT(*) AS n, MAX(x.lastUpdated) AS lu
+			FROM %s x JOIN events e ON e.id = x.event
+			WHERE x.lastUpdated > {:since} GROUP BY e.sourceId`, table),
+		path: eventFile(file),
+	}
+}
+
+var changeQueries = []changeQuery{
+	{
+		sql:  `SELECT sourceId AS ev, '' AS race, COUNT(*) AS n, MAX(lastUpdated) AS lu FROM events WHERE lastUpdated > {:since} GROUP BY sourceId`,
+		path: eventFile("Event.json"),
+	},
+	{
+		sql:  `SELECT '' AS ev, '' AS race, COUNT(*) AS n, COALESCE(MAX(lastUpdated), '') AS lu FROM channels WHERE lastUpdated > {:since}`,
+		path: func(_, _ string) string { return "/httpfiles/Channels.json" },
+	},
+	{
+		sql: `SELECT e.sourceId AS ev, '' AS race, COUNT(*) AS n, MAX(MAX(p.lastUpdated, ep.lastUpdated)) AS lu
+			FROM event_pilots ep JOIN pilots p ON p.id = ep.pilot JOIN events e ON e.id = ep.event
+			WHERE p.lastUpdated > {:since} OR ep.lastUpdated > {:since} GROUP BY e.sourceId`,
+		path: eventFile("Pilots.json"),
+	},
+	eventChildQuery("rounds", "Rounds.json"),
+	{
+		sql: `SELECT e.sourceId AS ev, x.sourceId AS race, COUNT(*) AS n, MAX(x.lastUpdated) AS lu
+			FROM races x JOIN events e ON e.id = x.event
+			WHERE x.lastUpdated > {:since} GROUP BY e.sourceId, x.sourceId`,
+		path: raceFile,
+	},
+	raceChildQuery("pilotChannels"),
+	raceChildQuery("detections"),
+	raceChildQuery("laps"),
+	raceChildQuery("gamePoints"),
+	eventChildQuery("results", "Results.json"),
+}
+
+// ChangedSince implements control.ChangelogSource from the lastUpdated autodate
+// fields of the local copy. Entries come back in ingest dependency order.
+func (s *Service) ChangedSince(since time.Time) ([]control.ChangelogEntry, error) {
+	sinceStr := since.UTC().Format(types.DefaultDateLayout)
+	byPath := map[string]*control.ChangelogEntry{}
+	var order []string
+	for _, q := range changeQueries {
+		var rows []struct {
+			Ev   string `db:"ev"`
+			Race string `db:"race"`
+			N    int    `db:"n"`
+			LU   string `db:"lu"`
+		}
+		if err := s.Upserter.App.DB().NewQuery(q.sql).Bind(dbx.Params{"since": sinceStr}).All(&rows); err != nil {
+			return nil, fmt.Errorf("changelog query: %w", err)
+		}
+		for _, r := range rows {
+			path := q.path(r.Ev, r.Race)
+			if r.N == 0 || strings.Contains(path, "//") {
+				continue
+			}
+			lu := parseLastUpdated(r.LU)
+			entry, ok := byPath[path]
+			if !ok {
+				entry = &control.ChangelogEntry{Path: path}
+				byPath[path] = entry
+				order = append(order, path)
+			}
+			entry.Records += r.N
+			if lu > entry.LastUpdated {
+				entry.LastUpdated = lu
+			}
+		}
+	}
+	out := make([]control.ChangelogEntry, 0, len(order))
+	for _, path := range order {
+		out = append(out, *byPath[path])
+	}
+	// keep dependency order by file kind, races by time so the newest lands last
+	sort.SliceStable(out, func(i, j int) bool {
+		ri, rj := changelogRank(out[i].Path), changelogRank(out[j].Path)
+		if ri != rj {
+			return ri < rj
+		}
+		return out[i].LastUpdated < out[j].LastUpdated
+	})
+	return out, nil
+}
+
+func changelogRank(path string) int {
+	for i, suffix := range []string{"Event.json", "Channels.json", "Pilots.json", "Rounds.json", "Race.json", "Results.json"} {
+		if strings.HasSuffix(path, suffix) {
+			return i
+		}
+	}
+	return 99
+}
+
+func parseLastUpdated(s string) int64 {
+	dt, err := types.ParseDateTime(s)
+	if err != nil || dt.IsZero() {
+		return 0
+	}
+	return dt.Time().UnixMilli()
+}
@@ -0,0 +1,68 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	_ "drone-dashboard/migrations"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func TestChangedSinceMapsRecordsToPaths(t *testing.T) {
+	t.Helper()
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	save := func(collection string, fields map[string]any) *core.Record {
+		col, err := app.FindCollectionByNameOrId(collection)
+		if err != nil {
+			t.Fatalf("collection %s: %v", collection, err)
+		}
+		rec := core.NewRecord(col)
+		for k, v := range fields {
+			rec.Set(k, v)
+		}
+		if err := app.Save(rec); err != nil {
+			t.Fatalf("save %s: %v", collection, err)
+		}
+		return rec
+	}
+
+	event := save("events", map[string]any{"source": "fpv", "sourceId": "evt-1", "name": "Event"})
+	round := save("rounds", map[string]any{"source": "fpv", "sourceId": "round-1", "event": event.Id})
+	oldRace := save("races", map[string]any{"source": "fpv", "sourceId": "race-old", "event": event.Id, "round": round.Id})
+
+	since := time.Now()
+	time.Sleep(5 * time.Millisecond)
+
+	race := save("races", map[string]any{"source": "fpv", "sourceId": "race-1", "event": event.Id, "round": round.Id})
+	save("laps", map[string]any{"source": "fpv", "sourceId": "lap-1", "race": race.Id, "event": event.Id})
+	save("laps", map[string]any{"source": "fpv", "sourceId": "lap-2", "race": race.Id, "event": event.Id})
+	save("laps", map[string]any{"source": "fpv", "sourceId": "lap-3", "race": oldRace.Id, "event": event.Id})
+
+	svc := NewServiceWithSource(app, nil)
+	entries, err := svc.ChangedSince(since)
+	if err != nil {
+		t.Fatalf("changed since: %v", err)
+	}
+	want := map[string]int{
+		"/events/evt-1/race-1/Race.json":   3,
+		"/events/evt-1/race-old/Race.json": 1,
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), entries)
+	}
+	for _, e := range entries {
+		if want[e.Path] != e.Records {
+			t.Fatalf("entry %s: records=%d want %d", e.Path, e.Records, want[e.Path])
+		}
+		if e.LastUpdated < since.UnixMilli() {
+			t.Fatalf("entry %s lastUpdated %d before since", e.Path, e.LastUpdated)
+		}
+	}
+}
@@ -13,7 +13,7 @@ func (s *Service) IngestChannels(eventSourceId string) error {
 	if err != nil {
 		return err
 	}
-	events, err := s.Source.FetchEvent(eventSourceId)
+	events, err := s.source().FetchEvent(eventSourceId)
 	if err != nil {
 		return err
 	}
@@ -21,7 +21,7 @@ func (s *Service) IngestChannels(eventSourceId string) error {
 		return fmt.Errorf("event not found: %s", eventSourceId)
 	}
 	e := events[0]
-	channels, err := s.Source.FetchChannels()
+	channels, err := s.source().FetchChannels()
 	if err != nil {
 		return err
 	}
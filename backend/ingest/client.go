@@ -9,14 +9,31 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
-	"time"
 
 	"drone-dashboard/fpvhttp"
 )
 
+// HTTPStatusError is a non-2xx answer from FPVTrackside.
+type HTTPStatusError struct {
+	URL    string
+	Status int
+	Body   string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("GET %s: status %d: %s", e.URL, e.Status, e.Body)
+}
+
+// eventManagerPattern finds the current event in FPVTrackside's root page.
+var eventManagerPattern = regexp.MustCompile(`var eventManager = new EventManager\("events\/([a-z0-9-]+)"`)
+
 // FPVClient fetches FPVTrackside Browser API via the configured base URL.
 type FPVClient struct {
 	BaseURL *url.URL
+	// OnBody, when set, observes every successful response body by request path.
+	OnBody func(path string, body []byte)
+	// OnResponse is OnBody with the response's Content-Type.
+	OnResponse func(path, contentType string, body []byte)
 }
 
 func NewFPVClient(base string) (*FPVClient, error) {
@@ -30,7 +47,7 @@ func NewFPVClient(base string) (*FPVClient, error) {
 func (c *FPVClient) GetBytes(path string) ([]byte, error) {
 	u := *c.BaseURL
 	u.Path = path
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), fpvhttp.Timeout(path))
 	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
@@ -43,7 +60,7 @@ func (c *FPVClient) GetBytes(path string) ([]byte, error) {
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		b, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GET %s: status %d: %s", u.String(), resp.StatusCode, string(b))
+		return nil, &HTTPStatusError{URL: u.String(), Status: resp.StatusCode, Body: string(b)}
 	}
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -52,9 +69,19 @@ func (c *FPVClient) GetBytes(path string) ([]byte, error) {
 	if len(b) == 0 {
 		return nil, fmt.Errorf("GET %s: empty response body", u.String())
 	}
+	c.observe(path, resp.Header.Get("Content-Type"), b)
 	return b, nil
 }
 
+func (c *FPVClient) observe(path, contentType string, body []byte) {
+	if c.OnBody != nil {
+		c.OnBody(path, body)
+	}
+	if c.OnResponse != nil {
+		c.OnResponse(path, contentType, body)
+	}
+}
+
 func (c *FPVClient) getJSON(path string, v any) error {
 	b, err := c.GetBytes(path)
 	if err != nil {
@@ -65,7 +92,7 @@ func (c *FPVClient) getJSON(path string, v any) error {
 		if len(snippet) > 200 {
 			snippet = snippet[:200] + "..."
 		}
-		return fmt.Errorf("decode %s: %v; body: %s", c.BaseURL.String()+path, err, snippet)
+		return fmt.Errorf("decode %s: %w; body: %s", c.BaseURL.String()+path, err, snippet)
 	}
 	return nil
 }
@@ -116,7 +143,7 @@ func (c *FPVClient) FetchResults(eventSourceId string) (ResultsFile, error) {
 	// custom handling: empty body means no results yet
 	u := *c.BaseURL
 	u.Path = fmt.Sprintf("/events/%s/Results.json", eventSourceId)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), fpvhttp.Timeout(u.Path))
 	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
@@ -129,7 +156,7 @@ func (c *FPVClient) FetchResults(eventSourceId string) (ResultsFile, error) {
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		b, _ := io.ReadAll(resp.Body)
-		return out, fmt.Errorf("GET %s: status %d: %s", u.String(), resp.StatusCode, string(b))
+		return out, &HTTPStatusError{URL: u.String(), Status: resp.StatusCode, Body: string(b)}
 	}
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -139,12 +166,13 @@ func (c *FPVClient) FetchResults(eventSourceId string) (ResultsFile, error) {
 		// treat empty as no results
 		return ResultsFile{}, nil
 	}
+	c.observe(u.Path, resp.Header.Get("Content-Type"), b)
 	if err := json.Unmarshal(b, &out); err != nil {
 		snippet := string(b)
 		if len(snippet) > 200 {
 			snippet = snippet[:200] + "..."
 		}
-		return out, fmt.Errorf("decode %s: %v; body: %s", u.String(), err, snippet)
+		return out, fmt.Errorf("decode %s: %w; body: %s", u.String(), err, snippet)
 	}
 	return out, nil
 }
@@ -153,7 +181,7 @@ func (c *FPVClient) FetchResults(eventSourceId string) (ResultsFile, error) {
 func (c *FPVClient) FetchEventSourceId() (string, error) {
 	u := *c.BaseURL
 	u.Path = "/"
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), fpvhttp.Timeout(u.Path))
 	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
@@ -166,16 +194,17 @@ func (c *FPVClient) FetchEventSourceId() (string, error) {
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		b, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("GET %s: status %d: %s", u.String(), resp.StatusCode, string(b))
+		return "", &HTTPStatusError{URL: u.String(), Status: resp.StatusCode, Body: string(b)}
 	}
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
 	}
+	c.observe(u.Path, resp.Header.Get("Content-Type"), b)
 	text := string(b)
 
 	// Use the same regex as the frontend
-	match := regexp.MustCompile(`var eventManager = new EventManager\("events\/([a-z0-9-]+)"`).FindStringSubmatch(text)
+	match := eventManagerPattern.FindStringSubmatch(text)
 	if match != nil && len(match) > 1 {
 		return match[1], nil
 	}
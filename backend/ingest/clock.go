@@ -0,0 +1,80 @@
+package ingest
+
+import (
+	"strings"
+	"time"
+)
+
+// VenueClock places FPVTrackside's venue-local timestamps on this server's
+// clock. control.PitsClock implements it for a cloud fed by a pits.
+type VenueClock interface {
+	// Location is the time zone FPVTrackside writes timestamps in.
+	Location() *time.Location
+	// Offset is how far the venue clock runs ahead of ours.
+	Offset() time.Duration
+}
+
+// scaledClock is a VenueClock running at another rate than ours, like a
+// replay at a speed other than 1, where no single offset fits every time.
+type scaledClock interface {
+	VenueClock
+	// ToLocal places a venue time on our clock.
+	ToLocal(t time.Time) time.Time
+}
+
+// localClock is the standalone and pits case: FPVTrackside shares our zone and clock.
+type localClock struct{}
+
+func (localClock) Location() *time.Location { return time.Local }
+func (localClock) Offset() time.Duration    { return 0 }
+
+// ZoneClock is a venue that shares our clock but writes times in its own
+// zone, for a server whose zone is not the venue's (--venue-tz).
+type ZoneClock struct {
+	Loc *time.Location
+}
+
+func (z ZoneClock) Location() *time.Location { return z.Loc }
+func (ZoneClock) Offset() time.Duration      { return 0 }
+
+func (s *Service) venueClock() VenueClock {
+	if s.Clock != nil {
+		return s.Clock
+	}
+	return localClock{}
+}
+
+// venueTimeLayouts are tried in order; the first carries an explicit zone.
+var venueTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006/01/02 15:04:05",
+}
+
+// venueEpochMs converts an FPVTrackside timestamp to UTC epoch milliseconds on
+// our clock. Timestamps without a zone are read in the venue's. Empty,
+// unparseable and FPVTrackside's zero time ("0001-01-01...") give 0.
+func venueEpochMs(clock VenueClock, raw string) int64 {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.HasPrefix(raw, "0001-") {
+		return 0
+	}
+	for i, layout := range venueTimeLayouts {
+		var t time.Time
+		var err error
+		if i == 0 {
+			t, err = time.Parse(layout, raw)
+		} else {
+			t, err = time.ParseInLocation(layout, raw, clock.Location())
+		}
+		if err != nil {
+			continue
+		}
+		if sc, ok := clock.(scaledClock); ok {
+			return sc.ToLocal(t).UnixMilli()
+		}
+		return t.Add(-clock.Offset()).UnixMilli()
+	}
+	return 0
+}
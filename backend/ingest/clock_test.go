@@ -0,0 +1,36 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+)
+
+type fixedClock struct {
+	loc    *time.Location
+	offset time.Duration
+}
+
+func (c fixedClock) Location() *time.Location { return c.loc }
+func (c fixedClock) Offset() time.Duration    { return c.offset }
+
+func TestVenueEpochMs(t *testing.T) {
+	// venue at UTC+10 whose clock runs 3s fast
+	clock := fixedClock{loc: time.FixedZone("venue", 10*3600), offset: 3 * time.Second}
+	want := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+	cases := map[string]int64{
+		"2025-01-01T10:00:03":       want,
+		"2025-01-01T10:00:03.250":   want + 250,
+		"2025-01-01 10:00:03":       want,
+		"2025-01-01T00:00:03Z":      want,
+		"2025-01-01T02:00:03+02:00": want,
+		"0001-01-01T00:00:00":       0,
+		"":                          0,
+		"not a time":                0,
+	}
+	for raw, expected := range cases {
+		if got := venueEpochMs(clock, raw); got != expected {
+			t.Errorf("venueEpochMs(%q) = %d, want %d", raw, got, expected)
+		}
+	}
+}
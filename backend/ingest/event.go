@@ -11,7 +11,7 @@ import (
 // eventSourceId: The external system's event identifier (not PocketBase ID)
 func (s *Service) IngestEventMeta(eventSourceId string) error {
 	slog.Debug("ingest.event.start", "eventSourceId", eventSourceId)
-	events, err := s.Source.FetchEvent(eventSourceId)
+	events, err := s.source().FetchEvent(eventSourceId)
 	if err != nil {
 		return err
 	}
@@ -26,7 +26,7 @@ func (s *Service) IngestEventMeta(eventSourceId string) error {
 func (s *Service) IngestEventMetaFromData(e RaceEvent) error {
 	eventSourceId := string(e.ID)
 	slog.Debug("ingest.event.start", "eventSourceId", eventSourceId)
-	_, err := s.Upserter.Upsert("events", eventSourceId, map[string]any{
+	_, err := s.Upserter.Upsert("events", eventSourceId, s.scopeEventFields(map[string]any{
 		"name":                        e.Name,
 		"eventType":                   e.EventType,
 		"start":                       e.Start,
@@ -41,8 +41,8 @@ func (s *Service) IngestEventMetaFromData(e RaceEvent) error {
 		"raceStartIgnoreDetections":   e.RaceStartIgnoreDetections,
 		"minLapTime":                  e.MinLapTime,
 		"lastOpened":                  e.LastOpened,
-		"isCurrent":                   true,
-	})
+		"isCurrent":                   !s.manualEventCurrent(),
+	}))
 	if err != nil {
 		return err
 	}
@@ -50,20 +50,51 @@ func (s *Service) IngestEventMetaFromData(e RaceEvent) error {
 	return nil
 }
 
+// scopeEventFields tags event fields with the service's pits when scoped.
+func (s *Service) scopeEventFields(fields map[string]any) map[string]any {
+	if s.PitsID != "" {
+		fields["pitsId"] = s.PitsID
+	}
+	return fields
+}
+
+// manualEventCurrent reports whether an admin has made a manual event current
+// in the service's scope. Until it is released, timing-system ingest keeps
+// its events off isCurrent so it cannot take over the dashboard.
+func (s *Service) manualEventCurrent() bool {
+	if s.Upserter.source() == ManualSourceName {
+		return false
+	}
+	filter := "source = {:source} && isCurrent = true"
+	if s.PitsID != "" {
+		filter += " && pitsId = {:pits}"
+	}
+	rec, err := s.Upserter.App.FindFirstRecordByFilter("events", filter, dbx.Params{"source": ManualSourceName, "pits": s.PitsID})
+	return err == nil && rec != nil
+}
+
 // SetEventAsCurrent sets the specified event as current and flips others only if needed.
 // Uses a single SQL query to determine which records require updates and saves only those.
 // eventSourceId: The external system's event identifier (not PocketBase ID)
 func (s *Service) SetEventAsCurrent(eventSourceId string) error {
 	slog.Debug("ingest.setEventAsCurrent.start", "eventSourceId", eventSourceId)
+	if s.manualEventCurrent() {
+		slog.Debug("ingest.setEventAsCurrent.manualPinned", "eventSourceId", eventSourceId)
+		return nil
+	}
 
 	// Select only events that need their isCurrent flag changed, along with the new value
 	query := `
         SELECT id,
                CASE WHEN sourceId = {:sid} THEN 1 ELSE 0 END AS new_is_current
         FROM events
-        WHERE (isCurrent = 1 AND sourceId != {:sid})
-           OR (isCurrent = 0 AND sourceId = {:sid})
+        WHERE ((isCurrent = 1 AND sourceId != {:sid})
+           OR (isCurrent = 0 AND sourceId = {:sid}))
     `
+	if s.PitsID != "" {
+		// Other venues keep their own current event; unscoped legacy rows are released.
+		query += ` AND (pitsId = {:pits} OR pitsId = '' OR pitsId IS NULL)`
+	}
 
 	type row struct {
 		ID           string `db:"id"`
@@ -71,7 +102,7 @@ func (s *Service) SetEventAsCurrent(eventSourceId string) error {
 	}
 
 	var rows []row
-	if err := s.Upserter.App.DB().NewQuery(query).Bind(dbx.Params{"sid": eventSourceId}).All(&rows); err != nil {
+	if err := s.Upserter.App.DB().NewQuery(query).Bind(dbx.Params{"sid": eventSourceId, "pits": s.PitsID}).All(&rows); err != nil {
 		return fmt.Errorf("query events to flip isCurrent: %w", err)
 	}
 
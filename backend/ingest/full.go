@@ -1,6 +1,7 @@
 package ingest
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"time"
@@ -18,11 +19,12 @@ type FullSummary struct {
 
 // Full orchestrates a full ingestion for an event: snapshot -> all races -> results
 // eventSourceId: The external system's event identifier (not PocketBase ID)
-func (s *Service) Full(eventSourceId string) (FullSummary, error) {
+// Cancelling ctx stops it between races with the partial summary.
+func (s *Service) Full(ctx context.Context, eventSourceId string) (FullSummary, error) {
 	slog.Debug("ingest.full.start", "eventSourceId", eventSourceId)
 
 	// Fetch event to enumerate races
-	events, err := s.Source.FetchEvent(eventSourceId)
+	events, err := s.source().FetchEvent(eventSourceId)
 	if err != nil {
 		return FullSummary{EventId: eventSourceId}, fmt.Errorf("fetch event: %w", err)
 	}
@@ -40,14 +42,27 @@ func (s *Service) Full(eventSourceId string) (FullSummary, error) {
 	racesProcessed := 0
 	racesSucceeded := 0
 	racesFailed := 0
+	partial := func(err error) (FullSummary, error) {
+		return FullSummary{
+			EventId:        eventSourceId,
+			RacesProcessed: racesProcessed,
+			RacesSucceeded: racesSucceeded,
+			RacesFailed:    racesFailed,
+		}, err
+	}
 	for _, raceID := range e.Races {
+		if err := ctx.Err(); err != nil {
+			return partial(err)
+		}
 		racesProcessed++
 		var lastErr error
 		// retry policy: up to 3 attempts with exponential backoff
 		for attempt := 0; attempt < 3; attempt++ {
 			if attempt > 0 {
 				backoff := 200 * time.Millisecond << attempt // 200ms, 400ms, 800ms
-				time.Sleep(backoff)
+				if err := sleepCtx(ctx, backoff); err != nil {
+					return partial(err)
+				}
 			}
 			if err := s.IngestRace(string(e.ID), string(raceID)); err != nil {
 				lastErr = err
@@ -63,18 +78,15 @@ func (s *Service) Full(eventSourceId string) (FullSummary, error) {
 			racesFailed++
 		}
 		// soft rate limit between races
-		time.Sleep(50 * time.Millisecond)
+		if err := sleepCtx(ctx, 50*time.Millisecond); err != nil {
+			return partial(err)
+		}
 	}
 
 	// 3) Results
 	cnt, err := s.IngestResults(eventSourceId)
 	if err != nil {
-		return FullSummary{
-			EventId:        eventSourceId,
-			RacesProcessed: racesProcessed,
-			RacesSucceeded: racesSucceeded,
-			RacesFailed:    racesFailed,
-		}, fmt.Errorf("results: %w", err)
+		return partial(fmt.Errorf("results: %w", err))
 	}
 
 	summary := FullSummary{
@@ -90,11 +102,11 @@ func (s *Service) Full(eventSourceId string) (FullSummary, error) {
 }
 
 // FullAuto fetches the event sourceId automatically and then performs a full ingestion
-func (s *Service) FullAuto() (FullSummary, error) {
+func (s *Service) FullAuto(ctx context.Context) (FullSummary, error) {
 	slog.Debug("ingest.fullAuto.start")
 
 	// Fetch event sourceId using the same method as frontend
-	eventSourceId, err := s.Source.FetchEventSourceId()
+	eventSourceId, err := s.source().FetchEventSourceId()
 	if err != nil {
 		return FullSummary{}, fmt.Errorf("fetch event sourceId: %w", err)
 	}
@@ -102,7 +114,7 @@ func (s *Service) FullAuto() (FullSummary, error) {
 	slog.Info("ingest.fullAuto.eventSourceId", "eventSourceId", eventSourceId)
 
 	// Perform full ingestion with the fetched event sourceId
-	summary, err := s.Full(eventSourceId)
+	summary, err := s.Full(ctx, eventSourceId)
 	if err != nil {
 		return summary, err
 	}
@@ -115,3 +127,15 @@ func (s *Service) FullAuto() (FullSummary, error) {
 
 	return summary, nil
 }
+
+// sleepCtx waits for d unless ctx is done first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -1,7 +1,12 @@
 package ingest
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/pocketbase/pocketbase/core"
 )
@@ -62,7 +67,7 @@ func RegisterRoutes(app core.App, service *Service) {
 			}
 
 			eventId := c.Request.PathValue("eventId")
-			summary, ferr := service.Full(eventId)
+			summary, ferr := service.Full(c.Request.Context(), eventId)
 			if ferr != nil {
 				// Return a richer error payload including the detailed error message and partial summary
 				return c.JSON(http.StatusInternalServerError, map[string]any{
@@ -81,7 +86,7 @@ func RegisterRoutes(app core.App, service *Service) {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
 			}
 
-			summary, ferr := service.FullAuto()
+			summary, ferr := service.FullAuto(c.Request.Context())
 			if ferr != nil {
 				// Return a richer error payload including the detailed error message and partial summary
 				return c.JSON(http.StatusInternalServerError, map[string]any{
@@ -100,7 +105,7 @@ func RegisterRoutes(app core.App, service *Service) {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
 			}
 
-			summary, perr := service.Purge()
+			summary, perr := service.Purge(c.Request.Context())
 			if perr != nil {
 				return c.JSON(http.StatusInternalServerError, map[string]any{
 					"ok":      false,
@@ -114,3 +119,204 @@ func RegisterRoutes(app core.App, service *Service) {
 		return se.Next()
 	})
 }
+
+// RegisterReplayRoutes exposes the replay clock: GET /ingest/replay returns its
+// status; POST takes any of {"speed": 4, "paused": true, "seekMs": <epoch ms>}.
+func RegisterReplayRoutes(app core.App, replay *ReplaySource) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/ingest/replay", func(c *core.RequestEvent) error {
+			info, err := c.RequestInfo()
+			if err != nil || info.Auth == nil || !info.Auth.IsSuperuser() {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			return c.JSON(http.StatusOK, replay.Status())
+		})
+
+		se.Router.POST("/ingest/replay", func(c *core.RequestEvent) error {
+			info, err := c.RequestInfo()
+			if err != nil || info.Auth == nil || !info.Auth.IsSuperuser() {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+
+			var req struct {
+				Speed  *float64 `json:"speed"`
+				Paused *bool    `json:"paused"`
+				SeekMs *int64   `json:"seekMs"`
+			}
+			if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+				return c.BadRequestError("invalid body", err)
+			}
+			if req.Speed != nil {
+				if err := replay.SetSpeed(*req.Speed); err != nil {
+					return c.BadRequestError(err.Error(), err)
+				}
+			}
+			if req.SeekMs != nil {
+				replay.Seek(time.UnixMilli(*req.SeekMs))
+			}
+			if req.Paused != nil {
+				replay.SetPaused(*req.Paused)
+			}
+			status := replay.Status()
+			slog.Info("ingest.replay.control", "speed", status.Speed, "paused", status.Paused, "nowMs", status.NowMs)
+			return c.JSON(http.StatusOK, status)
+		})
+
+		return se.Next()
+	})
+}
+
+// RegisterManualRoutes wires admin-only authoring of events without a timing
+// system under /manual/*. IDs in paths and bodies are the sourceIds returned
+// on creation. A cloud fed by several pits needs ?pits=<pitsId> on every
+// request to say which venue the event belongs to.
+func RegisterManualRoutes(app core.App, manual *Manual) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		g := se.Router.Group("/manual")
+		g.BindFunc(func(c *core.RequestEvent) error {
+			info, err := c.RequestInfo()
+			if err != nil || info.Auth == nil || !info.Auth.IsSuperuser() {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			return c.Next()
+		})
+
+		g.POST("/events", func(c *core.RequestEvent) error {
+			m, err := manualScope(c, manual)
+			if err != nil {
+				return manualError(c, err)
+			}
+			var req ManualEvent
+			if err := c.BindBody(&req); err != nil {
+				return c.BadRequestError("invalid body", err)
+			}
+			id, err := m.CreateEvent(req)
+			if err != nil {
+				return manualError(c, err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"id": id})
+		})
+
+		g.POST("/events/{eventId}/pilots", func(c *core.RequestEvent) error {
+			m, err := manualScope(c, manual)
+			if err != nil {
+				return manualError(c, err)
+			}
+			var req struct {
+				Pilots []ManualPilot `json:"pilots"`
+			}
+			if err := c.BindBody(&req); err != nil {
+				return c.BadRequestError("invalid body", err)
+			}
+			ids, err := m.AddPilots(c.Request.PathValue("eventId"), req.Pilots)
+			if err != nil {
+				return manualError(c, err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"ids": ids})
+		})
+
+		g.POST("/events/{eventId}/rounds", func(c *core.RequestEvent) error {
+			m, err := manualScope(c, manual)
+			if err != nil {
+				return manualError(c, err)
+			}
+			var req ManualRound
+			if err := c.BindBody(&req); err != nil {
+				return c.BadRequestError("invalid body", err)
+			}
+			id, err := m.AddRound(c.Request.PathValue("eventId"), req)
+			if err != nil {
+				return manualError(c, err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"id": id})
+		})
+
+		g.POST("/events/{eventId}/races", func(c *core.RequestEvent) error {
+			m, err := manualScope(c, manual)
+			if err != nil {
+				return manualError(c, err)
+			}
+			var req ManualRace
+			if err := c.BindBody(&req); err != nil {
+				return c.BadRequestError("invalid body", err)
+			}
+			id, err := m.AddRace(c.Request.PathValue("eventId"), req)
+			if err != nil {
+				return manualError(c, err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"id": id})
+		})
+
+		g.PUT("/races/{raceId}/laps", func(c *core.RequestEvent) error {
+			m, err := manualScope(c, manual)
+			if err != nil {
+				return manualError(c, err)
+			}
+			var req ManualLaps
+			if err := c.BindBody(&req); err != nil {
+				return c.BadRequestError("invalid body", err)
+			}
+			if err := m.SetLaps(c.Request.PathValue("raceId"), req); err != nil {
+				return manualError(c, err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"ok": true})
+		})
+
+		g.PUT("/races/{raceId}/results", func(c *core.RequestEvent) error {
+			m, err := manualScope(c, manual)
+			if err != nil {
+				return manualError(c, err)
+			}
+			var req struct {
+				Results []ManualResult `json:"results"`
+			}
+			if err := c.BindBody(&req); err != nil {
+				return c.BadRequestError("invalid body", err)
+			}
+			if err := m.SetResults(c.Request.PathValue("raceId"), req.Results); err != nil {
+				return manualError(c, err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"ok": true})
+		})
+
+		g.POST("/events/{eventId}/current", func(c *core.RequestEvent) error {
+			m, err := manualScope(c, manual)
+			if err != nil {
+				return manualError(c, err)
+			}
+			if err := m.SetCurrent(c.Request.PathValue("eventId")); err != nil {
+				return manualError(c, err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"ok": true})
+		})
+
+		g.DELETE("/current", func(c *core.RequestEvent) error {
+			m, err := manualScope(c, manual)
+			if err != nil {
+				return manualError(c, err)
+			}
+			if err := m.ReleaseCurrent(); err != nil {
+				return manualError(c, err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"ok": true})
+		})
+
+		return se.Next()
+	})
+}
+
+// manualScope returns manual scoped to the request's ?pits.
+func manualScope(c *core.RequestEvent, manual *Manual) (*Manual, error) {
+	return manual.ForPits(c.Request.URL.Query().Get("pits"))
+}
+
+// manualError maps refused input to 400 and unknown IDs to 404.
+func manualError(c *core.RequestEvent, err error) error {
+	switch {
+	case IsManualInputError(err):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case IsEntityNotFound(err):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.InternalServerError("manual update failed", err)
+}
@@ -0,0 +1,122 @@
+// Package ingesttest authors events through the manual ingest API for the
+// tests of packages that read race data.
+package ingesttest
+
+import (
+	"testing"
+	"time"
+
+	"drone-dashboard/ingest"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Event is a manual event with its pilots, one round and the default channels.
+// Pilots and races are referred to by their sourceIds, as the manual API does.
+type Event struct {
+	App      core.App
+	Manual   *ingest.Manual
+	ID       string
+	Record   *core.Record
+	Pilots   []string
+	Round    string
+	Channels []string
+	// pilots of each race, by index into Pilots
+	races map[string][]int
+}
+
+// Flight is what one pilot flew in a race.
+type Flight struct {
+	Holeshot float64
+	Laps     []float64
+}
+
+// NewEvent creates an event with the named pilots and one round.
+func NewEvent(t testing.TB, app core.App, in ingest.ManualEvent, pilots ...string) *Event {
+	t.Helper()
+	m := ingest.NewManual(app)
+	id, err := m.CreateEvent(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Event{App: app, Manual: m, ID: id, races: map[string][]int{}}
+	if len(pilots) > 0 {
+		var ps []ingest.ManualPilot
+		for _, p := range pilots {
+			ps = append(ps, ingest.ManualPilot{Name: p})
+		}
+		if e.Pilots, err = m.AddPilots(id, ps); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if e.Round, err = m.AddRound(id, ingest.ManualRound{}); err != nil {
+		t.Fatal(err)
+	}
+	e.Record = Find(t, app, "events", id)
+	channels, err := app.FindRecordsByFilter("channels", "event = {:e}", "frequency", 0, 0, dbx.Params{"e": e.Record.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range channels {
+		e.Channels = append(e.Channels, ch.GetString("sourceId"))
+	}
+	return e
+}
+
+// Race adds a race between the pilots at the given indexes, each on the next
+// channel, and returns its sourceId.
+func (e *Event) Race(t testing.TB, targetLaps int, pilots ...int) string {
+	t.Helper()
+	race := ingest.ManualRace{Round: e.Round, TargetLaps: targetLaps}
+	for i, p := range pilots {
+		race.Pilots = append(race.Pilots, ingest.ManualRacePilot{Pilot: e.Pilots[p], Channel: e.Channels[i]})
+	}
+	id, err := e.Manual.AddRace(e.ID, race)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.races[id] = pilots
+	return id
+}
+
+// Fly records a race started at start; flights follow the race's pilots.
+func (e *Event) Fly(t testing.TB, raceId string, start time.Time, flights ...Flight) {
+	t.Helper()
+	startMs := start.UnixMilli()
+	in := ingest.ManualLaps{StartMs: &startMs}
+	for i, f := range flights {
+		in.Pilots = append(in.Pilots, ingest.ManualPilotLaps{Pilot: e.Pilots[e.races[raceId][i]], Holeshot: f.Holeshot, Laps: f.Laps})
+	}
+	if err := e.Manual.SetLaps(raceId, in); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Finish sets a race's results from the pilot indexes in finishing order.
+func (e *Event) Finish(t testing.TB, raceId string, order ...int) {
+	t.Helper()
+	var results []ingest.ManualResult
+	for i, p := range order {
+		results = append(results, ingest.ManualResult{Pilot: e.Pilots[p], Position: i + 1})
+	}
+	if err := e.Manual.SetResults(raceId, results); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Pilot returns the record of the pilot at index i.
+func (e *Event) Pilot(t testing.TB, i int) *core.Record {
+	t.Helper()
+	return Find(t, e.App, "pilots", e.Pilots[i])
+}
+
+// Find returns the record of collection with sourceId.
+func Find(t testing.TB, app core.App, collection, sourceId string) *core.Record {
+	t.Helper()
+	rec, err := app.FindFirstRecordByFilter(collection, "sourceId = {:id}", dbx.Params{"id": sourceId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
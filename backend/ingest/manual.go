@@ -0,0 +1,681 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// ManualSourceName stamps records typed in through the manual API rather
+// than ingested from a timing system.
+const ManualSourceName = "manual"
+
+const (
+	// manualTimeLayout is how race and lap times are written, as FPVTrackside
+	// does, in the venue's zone.
+	manualTimeLayout = "2006-01-02T15:04:05.000"
+	manualIDAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// ManualInputError reports a request the manual API refuses to store.
+type ManualInputError struct {
+	Msg string
+}
+
+func (e *ManualInputError) Error() string { return e.Msg }
+
+// IsManualInputError reports whether err is a rejected manual request.
+func IsManualInputError(err error) bool {
+	var target *ManualInputError
+	return errors.As(err, &target)
+}
+
+func invalidf(format string, args ...any) error {
+	return &ManualInputError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// Manual authors events, pilots, rounds, races, laps and results without a
+// timing system. Everything it writes has source "manual", so a timing
+// system's Purge and ingest never touch it, and it goes through the same
+// upsert paths so the dashboard cannot tell the two apart.
+type Manual struct {
+	svc *Service
+	// pits are the venues of a cloud fed by several pits; events are then
+	// authored for one of them, see ForPits.
+	pits map[string]*Service
+}
+
+// NewManual returns a Manual writing to app.
+func NewManual(app core.App) *Manual {
+	return &Manual{svc: &Service{Upserter: &Upserter{App: app, Source: ManualSourceName}}}
+}
+
+// SetClock places the times of unscoped manual events in clock's venue.
+func (m *Manual) SetClock(clock VenueClock) {
+	m.svc.Clock = clock
+}
+
+// AddPits lets manual events be authored for a pits, in its venue's clock.
+// Once any pits is added, every request must name one.
+func (m *Manual) AddPits(pitsID string, clock VenueClock) {
+	if m.pits == nil {
+		m.pits = map[string]*Service{}
+	}
+	m.pits[pitsID] = &Service{Upserter: m.svc.Upserter, PitsID: pitsID, Clock: clock}
+}
+
+// ForPits returns the Manual authoring events of pitsID. Without pits only
+// the empty scope exists.
+func (m *Manual) ForPits(pitsID string) (*Manual, error) {
+	if len(m.pits) == 0 {
+		if pitsID != "" {
+			return nil, invalidf("unknown pits %q", pitsID)
+		}
+		return m, nil
+	}
+	if pitsID == "" {
+		return nil, invalidf("pits is required when several pits feed this server")
+	}
+	svc, ok := m.pits[pitsID]
+	if !ok {
+		return nil, invalidf("unknown pits %q", pitsID)
+	}
+	return &Manual{svc: svc}, nil
+}
+
+func newManualID() string {
+	return ManualSourceName + "-" + security.RandomStringWithAlphabet(12, manualIDAlphabet)
+}
+
+// ManualChannel is a video channel of a manual event.
+type ManualChannel struct {
+	Band      string `json:"band"`
+	ShortBand string `json:"shortBand"`
+	Number    int    `json:"number"`
+	Frequency int    `json:"frequency"`
+	Color     string `json:"color"`
+}
+
+// defaultManualChannels are used when an event is created without channels.
+var defaultManualChannels = []ManualChannel{
+	{Band: "Raceband", ShortBand: "R", Number: 1, Frequency: 5658, Color: "#ff0000"},
+	{Band: "Raceband", ShortBand: "R", Number: 3, Frequency: 5732, Color: "#00ff00"},
+	{Band: "Raceband", ShortBand: "R", Number: 6, Frequency: 5843, Color: "#0000ff"},
+	{Band: "Raceband", ShortBand: "R", Number: 8, Frequency: 5917, Color: "#ffff00"},
+}
+
+// ManualEvent creates an event.
+type ManualEvent struct {
+	Name string `json:"name"`
+	// Laps is the default target lap count of its races.
+	Laps int `json:"laps"`
+	// Channels default to four Raceband channels.
+	Channels []ManualChannel `json:"channels"`
+}
+
+// ManualPilot adds a pilot to an event.
+type ManualPilot struct {
+	Name      string `json:"name"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+// ManualRound adds a round to an event.
+type ManualRound struct {
+	Name string `json:"name"`
+	// RoundNumber defaults to one after the event's last round.
+	RoundNumber int `json:"roundNumber"`
+}
+
+// ManualRace adds a race to a round, with the channel each pilot flies.
+type ManualRace struct {
+	Round string `json:"round"`
+	// RaceNumber defaults to one after the round's last race.
+	RaceNumber int    `json:"raceNumber"`
+	Bracket    string `json:"bracket"`
+	// TargetLaps defaults to the event's laps.
+	TargetLaps int               `json:"targetLaps"`
+	Pilots     []ManualRacePilot `json:"pilots"`
+}
+
+// ManualRacePilot puts a pilot on a channel in a race.
+type ManualRacePilot struct {
+	Pilot   string `json:"pilot"`
+	Channel string `json:"channel"`
+}
+
+// ManualLaps replaces the laps of a race. Times are in seconds: the holeshot
+// from the start to the first crossing, then each lap.
+type ManualLaps struct {
+	// StartMs is when the race started; it defaults to the race's current
+	// start, or now for a race that has not run.
+	StartMs *int64            `json:"startMs"`
+	Pilots  []ManualPilotLaps `json:"pilots"`
+}
+
+// ManualPilotLaps are one pilot's crossings in a race.
+type ManualPilotLaps struct {
+	Pilot    string    `json:"pilot"`
+	Holeshot float64   `json:"holeshot"`
+	Laps     []float64 `json:"laps"`
+}
+
+// ManualResult is one pilot's placing in a race.
+type ManualResult struct {
+	Pilot    string `json:"pilot"`
+	Position int    `json:"position"`
+	Points   int    `json:"points"`
+	DNF      bool   `json:"dnf"`
+}
+
+// manualRecord finds a manual record by its sourceId.
+func (m *Manual) manualRecord(collection, sourceId string) (*core.Record, error) {
+	rec, err := m.svc.Upserter.App.FindFirstRecordByFilter(collection, "source = {:source} && sourceId = {:sid}", dbx.Params{
+		"source": ManualSourceName,
+		"sid":    sourceId,
+	})
+	if err != nil || rec == nil {
+		return nil, &EntityNotFoundError{Collection: collection, SourceID: sourceId}
+	}
+	return rec, nil
+}
+
+// manualEvent finds a manual event of m's pits by its sourceId.
+func (m *Manual) manualEvent(eventId string) (*core.Record, error) {
+	rec, err := m.manualRecord("events", eventId)
+	if err != nil {
+		return nil, err
+	}
+	if m.svc.PitsID != "" && rec.GetString("pitsId") != m.svc.PitsID {
+		return nil, &EntityNotFoundError{Collection: "events", SourceID: eventId}
+	}
+	return rec, nil
+}
+
+// CreateEvent stores an event and its channels and returns the event's ID.
+// It does not become current until SetCurrent.
+func (m *Manual) CreateEvent(in ManualEvent) (string, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > 255 {
+		return "", invalidf("name must be 1-255 characters")
+	}
+	if in.Laps < 0 {
+		return "", invalidf("laps must not be negative")
+	}
+	channels := in.Channels
+	if len(channels) == 0 {
+		channels = defaultManualChannels
+	}
+	seen := map[int]bool{}
+	for i, ch := range channels {
+		if ch.Frequency < 1000 || ch.Frequency > 9999 {
+			return "", invalidf("channel %d: frequency must be in MHz", i+1)
+		}
+		if seen[ch.Frequency] {
+			return "", invalidf("channel %d: frequency %d is used twice", i+1, ch.Frequency)
+		}
+		seen[ch.Frequency] = true
+	}
+
+	eventId := newManualID()
+	err := m.svc.Upserter.App.RunInTransaction(func(txApp core.App) error {
+		u := m.svc.Upserter.withApp(txApp)
+		eventPBID, err := u.Upsert("events", eventId, m.svc.scopeEventFields(map[string]any{
+			"name":      in.Name,
+			"eventType": "Race",
+			"start":     time.Now().In(m.svc.venueClock().Location()).Format(manualTimeLayout),
+			"laps":      in.Laps,
+			"isCurrent": false,
+		}))
+		if err != nil {
+			return err
+		}
+		for _, ch := range channels {
+			display := ch.ShortBand + fmt.Sprint(ch.Number)
+			if ch.ShortBand == "" {
+				display = fmt.Sprint(ch.Frequency)
+			}
+			if _, err := u.Upsert("channels", newManualID(), map[string]any{
+				"number":             ch.Number,
+				"band":               ch.Band,
+				"shortBand":          ch.ShortBand,
+				"frequency":          ch.Frequency,
+				"displayName":        display,
+				"channelColor":       ch.Color,
+				"channelDisplayName": display,
+				"event":              eventPBID,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	slog.Info("ingest.manual.event.created", "eventId", eventId, "pitsId", m.svc.PitsID, "name", in.Name, "channels", len(channels))
+	return eventId, nil
+}
+
+// AddPilots adds pilots to an event and returns their IDs in order. A manual
+// pilot of the same name is reused, so a club's regulars keep one record
+// across practice nights.
+func (m *Manual) AddPilots(eventId string, pilots []ManualPilot) ([]string, error) {
+	event, err := m.manualEvent(eventId)
+	if err != nil {
+		return nil, err
+	}
+	if len(pilots) == 0 {
+		return nil, invalidf("no pilots given")
+	}
+	for i := range pilots {
+		pilots[i].Name = strings.TrimSpace(pilots[i].Name)
+		if pilots[i].Name == "" || len(pilots[i].Name) > 255 {
+			return nil, invalidf("pilot %d: name must be 1-255 characters", i+1)
+		}
+	}
+
+	ids := make([]string, 0, len(pilots))
+	err = m.svc.Upserter.App.RunInTransaction(func(txApp core.App) error {
+		u := m.svc.Upserter.withApp(txApp)
+		for _, p := range pilots {
+			pilotId := newManualID()
+			if existing, err := txApp.FindFirstRecordByFilter("pilots", "source = {:source} && name = {:name}", dbx.Params{
+				"source": ManualSourceName,
+				"name":   p.Name,
+			}); err == nil && existing != nil {
+				pilotId = existing.GetString("sourceId")
+			}
+			pilotPBID, err := u.Upsert("pilots", pilotId, map[string]any{
+				"name":      p.Name,
+				"firstName": p.FirstName,
+				"lastName":  p.LastName,
+			})
+			if err != nil {
+				return err
+			}
+			if existing, err := txApp.FindFirstRecordByFilter("event_pilots", "event = {:event} && pilot = {:pilot}", dbx.Params{
+				"event": event.Id,
+				"pilot": pilotPBID,
+			}); err != nil || existing == nil {
+				col, err := txApp.FindCollectionByNameOrId("event_pilots")
+				if err != nil {
+					return err
+				}
+				link := core.NewRecord(col)
+				link.Set("event", event.Id)
+				link.Set("pilot", pilotPBID)
+				if err := txApp.Save(link); err != nil {
+					return err
+				}
+			}
+			ids = append(ids, pilotId)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("ingest.manual.pilots.added", "eventId", eventId, "count", len(ids))
+	return ids, nil
+}
+
+// AddRound adds a round to an event and returns its ID.
+func (m *Manual) AddRound(eventId string, in ManualRound) (string, error) {
+	event, err := m.manualEvent(eventId)
+	if err != nil {
+		return "", err
+	}
+	if in.RoundNumber < 0 {
+		return "", invalidf("roundNumber must not be negative")
+	}
+	rounds, err := m.svc.Upserter.App.FindRecordsByFilter("rounds", "event = {:event}", "", 0, 0, dbx.Params{"event": event.Id})
+	if err != nil {
+		return "", err
+	}
+	last := 0
+	for _, r := range rounds {
+		n := r.GetInt("roundNumber")
+		if in.RoundNumber != 0 && n == in.RoundNumber {
+			return "", invalidf("round %d already exists", n)
+		}
+		last = max(last, n)
+	}
+	if in.RoundNumber == 0 {
+		in.RoundNumber = last + 1
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		in.Name = fmt.Sprintf("Round %d", in.RoundNumber)
+	}
+
+	roundId := newManualID()
+	if _, err := m.svc.Upserter.Upsert("rounds", roundId, map[string]any{
+		"name":        in.Name,
+		"roundNumber": in.RoundNumber,
+		"eventType":   "Race",
+		"roundType":   "Round",
+		"valid":       true,
+		"order":       in.RoundNumber,
+		"event":       event.Id,
+	}); err != nil {
+		return "", err
+	}
+	slog.Info("ingest.manual.round.added", "eventId", eventId, "roundId", roundId, "roundNumber", in.RoundNumber)
+	return roundId, nil
+}
+
+// AddRace adds a race to a round of an event and returns its ID. Every pilot
+// must be in the event and fly their own channel of it.
+func (m *Manual) AddRace(eventId string, in ManualRace) (string, error) {
+	event, err := m.manualEvent(eventId)
+	if err != nil {
+		return "", err
+	}
+	round, err := m.manualRecord("rounds", in.Round)
+	if err != nil {
+		return "", err
+	}
+	if round.GetString("event") != event.Id {
+		return "", invalidf("round %s is not in event %s", in.Round, eventId)
+	}
+	if len(in.Pilots) == 0 {
+		return "", invalidf("a race needs at least one pilot")
+	}
+	if in.RaceNumber < 0 || in.TargetLaps < 0 {
+		return "", invalidf("raceNumber and targetLaps must not be negative")
+	}
+
+	app := m.svc.Upserter.App
+	pilots := map[string]bool{}
+	channels := map[string]bool{}
+	race := Race{ID: newManualID(), Round: in.Round, Valid: true, Bracket: in.Bracket, TargetLaps: in.TargetLaps, RaceNumber: in.RaceNumber}
+	for i, pc := range in.Pilots {
+		pilot, err := m.manualRecord("pilots", pc.Pilot)
+		if err != nil {
+			return "", err
+		}
+		if linked, err := app.FindFirstRecordByFilter("event_pilots", "event = {:event} && pilot = {:pilot}", dbx.Params{
+			"event": event.Id,
+			"pilot": pilot.Id,
+		}); err != nil || linked == nil {
+			return "", invalidf("pilot %s is not in event %s", pc.Pilot, eventId)
+		}
+		channel, err := m.manualRecord("channels", pc.Channel)
+		if err != nil {
+			return "", err
+		}
+		if channel.GetString("event") != event.Id {
+			return "", invalidf("channel %s is not in event %s", pc.Channel, eventId)
+		}
+		if pilots[pc.Pilot] || channels[pc.Channel] {
+			return "", invalidf("pilot %d: pilot or channel is already in the race", i+1)
+		}
+		pilots[pc.Pilot], channels[pc.Channel] = true, true
+		race.PilotChannels = append(race.PilotChannels, struct {
+			ID      Guid
+			Pilot   Guid
+			Channel Guid
+		}{ID: race.ID + "-" + pc.Pilot, Pilot: pc.Pilot, Channel: pc.Channel})
+	}
+	if race.TargetLaps == 0 {
+		race.TargetLaps = event.GetInt("laps")
+	}
+	if race.RaceNumber == 0 {
+		races, err := app.FindRecordsByFilter("races", "round = {:round}", "", 0, 0, dbx.Params{"round": round.Id})
+		if err != nil {
+			return "", err
+		}
+		for _, r := range races {
+			race.RaceNumber = max(race.RaceNumber, r.GetInt("raceNumber"))
+		}
+		race.RaceNumber++
+	}
+
+	if err := m.svc.IngestRaceData(eventId, race.ID, race); err != nil {
+		return "", err
+	}
+	slog.Info("ingest.manual.race.added", "eventId", eventId, "raceId", race.ID, "pilots", len(race.PilotChannels))
+	return race.ID, nil
+}
+
+// manualRace rebuilds the stored race as ingest reads it, without laps, and
+// returns it with its event's ID.
+func (m *Manual) manualRace(raceId string) (Race, string, error) {
+	app := m.svc.Upserter.App
+	rec, err := m.manualRecord("races", raceId)
+	if err != nil {
+		return Race{}, "", err
+	}
+	event, err := app.FindRecordById("events", rec.GetString("event"))
+	if err != nil {
+		return Race{}, "", err
+	}
+	if m.svc.PitsID != "" && event.GetString("pitsId") != m.svc.PitsID {
+		return Race{}, "", &EntityNotFoundError{Collection: "races", SourceID: raceId}
+	}
+	round, err := app.FindRecordById("rounds", rec.GetString("round"))
+	if err != nil {
+		return Race{}, "", err
+	}
+	race := Race{
+		ID:         raceId,
+		Round:      round.GetString("sourceId"),
+		RaceNumber: rec.GetInt("raceNumber"),
+		Start:      rec.GetString("start"),
+		End:        rec.GetString("end"),
+		Valid:      rec.GetBool("valid"),
+		Bracket:    rec.GetString("bracket"),
+		TargetLaps: rec.GetInt("targetLaps"),
+	}
+	pcs, err := app.FindRecordsByFilter("pilotChannels", "race = {:race}", "sourceId", 0, 0, dbx.Params{"race": rec.Id})
+	if err != nil {
+		return Race{}, "", err
+	}
+	for _, pc := range pcs {
+		pilot, err := app.FindRecordById("pilots", pc.GetString("pilot"))
+		if err != nil {
+			return Race{}, "", err
+		}
+		channel, err := app.FindRecordById("channels", pc.GetString("channel"))
+		if err != nil {
+			return Race{}, "", err
+		}
+		race.PilotChannels = append(race.PilotChannels, struct {
+			ID      Guid
+			Pilot   Guid
+			Channel Guid
+		}{ID: pc.GetString("sourceId"), Pilot: pilot.GetString("sourceId"), Channel: channel.GetString("sourceId")})
+	}
+	return race, event.GetString("sourceId"), nil
+}
+
+// SetLaps replaces the laps of a race. Pilots left out have none, and the
+// race ends with the last crossing.
+func (m *Manual) SetLaps(raceId string, in ManualLaps) error {
+	race, eventId, err := m.manualRace(raceId)
+	if err != nil {
+		return err
+	}
+	channelOf := map[string]string{}
+	for _, pc := range race.PilotChannels {
+		channelOf[pc.Pilot] = pc.Channel
+	}
+
+	loc := m.svc.venueClock().Location()
+	start := time.Now()
+	if in.StartMs != nil {
+		start = time.UnixMilli(*in.StartMs)
+	} else if ms := venueEpochMs(m.svc.venueClock(), race.Start); ms != 0 {
+		start = time.UnixMilli(ms)
+	}
+	start = start.In(loc)
+	at := func(d time.Duration) string { return start.Add(d).Format(manualTimeLayout) }
+
+	race.Detections, race.Laps = nil, nil
+	var end time.Duration
+	seen := map[string]bool{}
+	for i, p := range in.Pilots {
+		channel, ok := channelOf[p.Pilot]
+		if !ok {
+			return invalidf("pilot %s is not in race %s", p.Pilot, raceId)
+		}
+		if seen[p.Pilot] {
+			return invalidf("pilot %d: laps given twice", i+1)
+		}
+		seen[p.Pilot] = true
+		if p.Holeshot < 0 {
+			return invalidf("pilot %d: holeshot must not be negative", i+1)
+		}
+		crossings := append([]float64{p.Holeshot}, p.Laps...)
+		var elapsed time.Duration
+		for n, seconds := range crossings {
+			if n > 0 && seconds <= 0 {
+				return invalidf("pilot %d: lap %d must be longer than zero", i+1, n)
+			}
+			lapStart := elapsed
+			elapsed += time.Duration(seconds * float64(time.Second))
+			detection := Detection{
+				ID:               fmt.Sprintf("%s-%s-d%d", raceId, p.Pilot, n),
+				Channel:          channel,
+				Pilot:            p.Pilot,
+				Time:             at(elapsed),
+				TimingSystemType: "Manual",
+				LapNumber:        n,
+				Valid:            true,
+				ValidityType:     "Auto",
+				IsLapEnd:         true,
+				IsHoleshot:       n == 0,
+			}
+			race.Detections = append(race.Detections, detection)
+			race.Laps = append(race.Laps, Lap{
+				ID:            fmt.Sprintf("%s-%s-l%d", raceId, p.Pilot, n),
+				Detection:     detection.ID,
+				LengthSeconds: seconds,
+				LapNumber:     n,
+				StartTime:     at(lapStart),
+				EndTime:       at(elapsed),
+			})
+		}
+		end = max(end, elapsed)
+	}
+	race.Start, race.End = at(0), at(end)
+
+	if err := m.svc.IngestRaceData(eventId, raceId, race); err != nil {
+		return err
+	}
+	slog.Info("ingest.manual.laps.set", "raceId", raceId, "pilots", len(in.Pilots), "laps", len(race.Laps))
+	return nil
+}
+
+// SetResults replaces the results of a race.
+func (m *Manual) SetResults(raceId string, results []ManualResult) error {
+	race, _, err := m.manualRace(raceId)
+	if err != nil {
+		return err
+	}
+	inRace := map[string]bool{}
+	for _, pc := range race.PilotChannels {
+		inRace[pc.Pilot] = true
+	}
+	seen := map[string]bool{}
+	positions := map[int]bool{}
+	for i, r := range results {
+		if !inRace[r.Pilot] {
+			return invalidf("pilot %s is not in race %s", r.Pilot, raceId)
+		}
+		if seen[r.Pilot] {
+			return invalidf("result %d: pilot has two results", i+1)
+		}
+		seen[r.Pilot] = true
+		if r.Position < 1 || positions[r.Position] {
+			return invalidf("result %d: position must be unique and at least 1", i+1)
+		}
+		positions[r.Position] = true
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Position < results[j].Position })
+	raceRec, err := m.manualRecord("races", raceId)
+	if err != nil {
+		return err
+	}
+
+	err = m.svc.Upserter.App.RunInTransaction(func(txApp core.App) error {
+		u := m.svc.Upserter.withApp(txApp)
+		valid := map[string]struct{}{}
+		for _, r := range results {
+			valid[raceId+"-"+r.Pilot+"-res"] = struct{}{}
+		}
+		if err := cleanupRaceCollection(u, "results", raceId, raceRec.Id, valid); err != nil {
+			return err
+		}
+		for _, r := range results {
+			pilotPBID, err := u.GetExistingId("pilots", r.Pilot)
+			if err != nil {
+				return err
+			}
+			if _, err := u.Upsert("results", raceId+"-"+r.Pilot+"-res", map[string]any{
+				"points":     r.Points,
+				"position":   r.Position,
+				"valid":      true,
+				"dnf":        r.DNF,
+				"resultType": "Race",
+				"event":      raceRec.GetString("event"),
+				"race":       raceRec.Id,
+				"pilot":      pilotPBID,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.svc.raceCommitted(&RaceCommitEvent{
+		EventID:      raceRec.GetString("event"),
+		RaceID:       raceRec.Id,
+		RaceSourceID: raceId,
+		Pilots:       racePilots(m.svc.Upserter.App, raceRec.Id, map[string]struct{}{}),
+	})
+	slog.Info("ingest.manual.results.set", "raceId", raceId, "results", len(results))
+	return nil
+}
+
+// SetCurrent makes a manual event the current one of its pits. It stays
+// current, and timing-system ingest leaves the flag alone, until
+// ReleaseCurrent.
+func (m *Manual) SetCurrent(eventId string) error {
+	if _, err := m.manualEvent(eventId); err != nil {
+		return err
+	}
+	return m.svc.SetEventAsCurrent(eventId)
+}
+
+// ReleaseCurrent clears the current manual event of m's pits so the timing
+// system's event becomes current again at the next discovery.
+func (m *Manual) ReleaseCurrent() error {
+	app := m.svc.Upserter.App
+	filter := "source = {:source} && isCurrent = true"
+	if m.svc.PitsID != "" {
+		filter += " && pitsId = {:pits}"
+	}
+	recs, err := app.FindRecordsByFilter("events", filter, "", 0, 0, dbx.Params{"source": ManualSourceName, "pits": m.svc.PitsID})
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		rec.Set("isCurrent", false)
+		if err := app.Save(rec); err != nil {
+			return err
+		}
+	}
+	slog.Info("ingest.manual.current.released", "pitsId", m.svc.PitsID, "events", len(recs))
+	return nil
+}
@@ -0,0 +1,207 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	_ "drone-dashboard/migrations"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func TestManualEventAuthoring(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	m := NewManual(app)
+	eventId, err := m.CreateEvent(ManualEvent{Name: "Tuesday practice", Laps: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pilots, err := m.AddPilots(eventId, []ManualPilot{{Name: "Zippy"}, {Name: "Bolt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundId, err := m.AddRound(eventId, ManualRound{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels, err := app.FindRecordsByFilter("channels", "source = 'manual'", "frequency", 0, 0, nil)
+	if err != nil || len(channels) != 4 {
+		t.Fatalf("default channels: %d, %v", len(channels), err)
+	}
+
+	raceId, err := m.AddRace(eventId, ManualRace{Round: roundId, Pilots: []ManualRacePilot{
+		{Pilot: pilots[0], Channel: channels[0].GetString("sourceId")},
+		{Pilot: pilots[1], Channel: channels[1].GetString("sourceId")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 6, 3, 19, 0, 0, 0, time.UTC).UnixMilli()
+	laps := ManualLaps{StartMs: &start, Pilots: []ManualPilotLaps{
+		{Pilot: pilots[0], Holeshot: 1.5, Laps: []float64{30, 28.5}},
+		{Pilot: pilots[1], Holeshot: 2, Laps: []float64{32}},
+	}}
+	if err := m.SetLaps(raceId, laps); err != nil {
+		t.Fatal(err)
+	}
+	raceRec, err := app.FindFirstRecordByFilter("races", "sourceId = {:id}", dbx.Params{"id": raceId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raceRec.GetInt("startEpoch") != int(start) || raceRec.GetInt("endEpoch") != int(start)+60000 || raceRec.GetInt("targetLaps") != 3 {
+		t.Fatalf("race: start=%d end=%d targetLaps=%d", raceRec.GetInt("startEpoch"), raceRec.GetInt("endEpoch"), raceRec.GetInt("targetLaps"))
+	}
+
+	// re-entering laps replaces them
+	laps.Pilots[0].Laps = []float64{30}
+	if err := m.SetLaps(raceId, laps); err != nil {
+		t.Fatal(err)
+	}
+	lapRecs, _ := app.FindRecordsByFilter("laps", "race = {:race}", "", 0, 0, dbx.Params{"race": raceRec.Id})
+	if len(lapRecs) != 4 {
+		t.Fatalf("%d laps after re-entry, want 4", len(lapRecs))
+	}
+
+	if err := m.SetResults(raceId, []ManualResult{{Pilot: pilots[0], Position: 1}, {Pilot: pilots[1], Position: 1}}); !IsManualInputError(err) {
+		t.Fatalf("duplicate position: %v", err)
+	}
+	if err := m.SetResults(raceId, []ManualResult{{Pilot: pilots[1], Position: 2}, {Pilot: pilots[0], Position: 1, Points: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddRace(eventId, ManualRace{Round: "manual-missing"}); !IsEntityNotFound(err) {
+		t.Fatalf("unknown round: %v", err)
+	}
+
+	// the same regulars come back next week
+	next, err := m.CreateEvent(ManualEvent{Name: "Wednesday practice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := m.AddPilots(next, []ManualPilot{{Name: "Zippy"}})
+	if err != nil || again[0] != pilots[0] {
+		t.Fatalf("returning pilot: %v, %v", again, err)
+	}
+}
+
+func TestManualEventCoexistsWithTimingSystem(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	m := NewManual(app)
+	eventId, err := m.CreateEvent(ManualEvent{Name: "Practice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddPilots(eventId, []ManualPilot{{Name: "Zippy"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetCurrent(eventId); err != nil {
+		t.Fatal(err)
+	}
+
+	// FPVTrackside comes up mid-session: its event is ingested but not made current
+	fpv := NewServiceWithSource(app, nil)
+	if err := fpv.IngestEventMetaFromData(RaceEvent{ID: "fpv-event", Name: "Club race"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fpv.SetEventAsCurrent("fpv-event"); err != nil {
+		t.Fatal(err)
+	}
+	current, err := app.FindRecordsByFilter("events", "isCurrent = true", "", 0, 0, nil)
+	if err != nil || len(current) != 1 || current[0].GetString("sourceId") != eventId {
+		t.Fatalf("current events while manual is pinned: %d, %v", len(current), err)
+	}
+
+	purged, err := fpv.Purge(context.Background())
+	if err != nil || purged.Events != 1 || purged.Pilots != 0 {
+		t.Fatalf("purge: %+v, %v", purged, err)
+	}
+	if recs, _ := app.FindRecordsByFilter("pilots", "source = 'manual'", "", 0, 0, nil); len(recs) != 1 {
+		t.Fatalf("manual pilots after purge: %d", len(recs))
+	}
+
+	// released, the timing system takes the dashboard back
+	if err := m.ReleaseCurrent(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fpv.IngestEventMetaFromData(RaceEvent{ID: "fpv-event", Name: "Club race"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fpv.SetEventAsCurrent("fpv-event"); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := app.FindFirstRecordByFilter("events", "isCurrent = true", nil)
+	if err != nil || rec.GetString("sourceId") != "fpv-event" {
+		t.Fatalf("current after release: %v, %v", rec, err)
+	}
+}
+
+func TestManualEventsAreScopedToTheirPits(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	manual := NewManual(app)
+	manual.AddPits("pits-a", nil)
+	manual.AddPits("pits-b", nil)
+	if _, err := manual.ForPits(""); !IsManualInputError(err) {
+		t.Fatalf("unscoped request with several pits: %v", err)
+	}
+	a, err := manual.ForPits("pits-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventId, err := a.CreateEvent(ManualEvent{Name: "Practice A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetCurrent(eventId); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := app.FindFirstRecordByFilter("events", "sourceId = {:id}", dbx.Params{"id": eventId})
+	if err != nil || rec.GetString("pitsId") != "pits-a" {
+		t.Fatalf("manual event pits: %v, %v", rec, err)
+	}
+
+	// the pin holds pits-a only; pits-b's timing system still takes over its venue
+	fpv := NewServiceWithSource(app, nil)
+	fpv.PitsID = "pits-b"
+	if err := fpv.IngestEventMetaFromData(RaceEvent{ID: "fpv-b", Name: "Club race B"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fpv.SetEventAsCurrent("fpv-b"); err != nil {
+		t.Fatal(err)
+	}
+	current, err := app.FindRecordsByFilter("events", "isCurrent = true", "sourceId", 0, 0, nil)
+	if err != nil || len(current) != 2 {
+		t.Fatalf("current events per venue: %d, %v", len(current), err)
+	}
+
+	// pits-b cannot see, pin or release pits-a's event
+	b, err := manual.ForPits("pits-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetCurrent(eventId); !IsEntityNotFound(err) {
+		t.Fatalf("pinning another venue's event: %v", err)
+	}
+	if err := b.ReleaseCurrent(); err != nil {
+		t.Fatal(err)
+	}
+	if rec, err := app.FindFirstRecordByFilter("events", "sourceId = {:id}", dbx.Params{"id": eventId}); err != nil || !rec.GetBool("isCurrent") {
+		t.Fatalf("pits-a pin after pits-b release: %v, %v", rec, err)
+	}
+}
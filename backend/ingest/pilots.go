@@ -14,7 +14,7 @@ func (s *Service) IngestPilots(eventSourceId string) error {
 	if err != nil {
 		return err
 	}
-	pilots, err := s.Source.FetchPilots(eventSourceId)
+	pilots, err := s.source().FetchPilots(eventSourceId)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,61 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"drone-dashboard/control"
+)
+
+// HandlePush applies a payload published by the pits. Every push primes the
+// RemoteSource ETag cache so the next scheduler fetch for the path is a 304;
+// Race.json pushes are also ingested immediately to cut active race latency.
+func (s *Service) HandlePush(pitsID string, push control.Push) error {
+	rs, ok := s.Source.(*RemoteSource)
+	if !ok || rs.PitsID != pitsID {
+		return fmt.Errorf("no remote source for pits %s", pitsID)
+	}
+	headers, body := control.DecodePush(push)
+	etag := headers["ETag"]
+
+	eventSourceId, raceId, isRace := parseRacePath(push.Path)
+	if !isRace {
+		rs.remember(push.Path, etag, body)
+		rs.observe(push.Path, body)
+		return nil
+	}
+
+	var rf RaceFile
+	if err := json.Unmarshal(body, &rf); err != nil {
+		return fmt.Errorf("decode pushed race %s: %w", raceId, err)
+	}
+	if len(rf) == 0 {
+		return fmt.Errorf("race not found: %s", raceId)
+	}
+	rs.remember(push.Path, etag, body)
+	rs.observe(push.Path, body)
+
+	if err := s.IngestRaceData(eventSourceId, raceId, rf[0]); err != nil {
+		if IsEntityNotFound(err) {
+			// The scheduler resolves dependencies; a later push or fetch will land it.
+			slog.Debug("ingest.push.race.deferred", "raceId", raceId, "err", err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// parseRacePath extracts identifiers from /events/{eventSourceId}/{raceId}/Race.json.
+func parseRacePath(path string) (string, string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 4 || parts[0] != "events" || parts[3] != "Race.json" {
+		return "", "", false
+	}
+	if parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
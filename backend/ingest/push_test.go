@@ -0,0 +1,46 @@
+package ingest
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"drone-dashboard/control"
+)
+
+func TestParseRacePath(t *testing.T) {
+	t.Helper()
+	eventID, raceID, ok := parseRacePath("/events/evt-1/race-1/Race.json")
+	if !ok || eventID != "evt-1" || raceID != "race-1" {
+		t.Fatalf("unexpected parse result: %q %q %v", eventID, raceID, ok)
+	}
+	for _, path := range []string{"/events/evt-1/Event.json", "/httpfiles/Channels.json", "/events//race-1/Race.json"} {
+		if _, _, ok := parseRacePath(path); ok {
+			t.Fatalf("expected %s not to parse as a race path", path)
+		}
+	}
+}
+
+func TestHandlePushPrimesRemoteCache(t *testing.T) {
+	t.Helper()
+	rs := NewRemoteSource(nil, "main")
+	service := &Service{Source: rs}
+
+	body := []byte(`[{"ID":"evt-1"}]`)
+	etag := control.ComputeETag(body)
+	push := control.Push{
+		Path:    "/events/evt-1/Event.json",
+		Headers: map[string]string{"ETag": etag},
+		BodyB64: base64.StdEncoding.EncodeToString(body),
+	}
+
+	if err := service.HandlePush("other", push); err == nil {
+		t.Fatalf("expected push from unknown pits to be rejected")
+	}
+	if err := service.HandlePush("main", push); err != nil {
+		t.Fatalf("handle push: %v", err)
+	}
+	got, ok := rs.cache[push.Path]
+	if !ok || got.etag != etag || string(got.body) != string(body) {
+		t.Fatalf("cache not primed: %+v", got)
+	}
+}
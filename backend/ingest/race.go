@@ -4,18 +4,21 @@ import (
 	"fmt"
 	"log/slog"
 
+	"drone-dashboard/tracing"
+
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
 )
 
-func cleanupStaleRaceRecords(app core.App, collectionName, racePBID string, validIDs map[string]struct{}) (int, error) {
+func cleanupStaleRaceRecords(app core.App, source, collectionName, racePBID string, validIDs map[string]struct{}) (int, error) {
 	col, err := app.FindCollectionByNameOrId(collectionName)
 	if err != nil {
 		return 0, fmt.Errorf("find %s collection: %w", collectionName, err)
 	}
 
 	records, err := app.FindRecordsByFilter(col.Name, "source = {:source} && race = {:raceId}", "", 0, 0, dbx.Params{
-		"source": sourceName,
+		"source": source,
 		"raceId": racePBID,
 	})
 	if err != nil {
@@ -40,8 +43,8 @@ func cleanupStaleRaceRecords(app core.App, collectionName, racePBID string, vali
 	return deleted, nil
 }
 
-func cleanupRaceCollection(app core.App, collection, raceId, racePBID string, validIDs map[string]struct{}) error {
-	deleted, err := cleanupStaleRaceRecords(app, collection, racePBID, validIDs)
+func cleanupRaceCollection(u *Upserter, collection, raceId, racePBID string, validIDs map[string]struct{}) error {
+	deleted, err := cleanupStaleRaceRecords(u.App, u.source(), collection, racePBID, validIDs)
 	if err != nil {
 		return err
 	}
@@ -150,76 +153,150 @@ func (s *Service) IngestPilotChannels(u *Upserter, eventSourceId, raceId, racePB
 // IngestRace fetches and upserts a race and its nested entities
 // eventSourceId: The external system's event identifier (not PocketBase ID)
 // raceId: The external system's race identifier (not PocketBase ID)
-func (s *Service) IngestRace(eventSourceId, raceId string) error {
+func (s *Service) IngestRace(eventSourceId, raceId string) (err error) {
 	slog.Debug("ingest.race.start", "raceId", raceId)
+	ctx, span := tracing.Start(s.context(), "ingest.race", tracing.KindInternal, tracing.String("raceId", raceId))
+	defer func() { span.End(err) }()
+	s = s.WithContext(ctx)
 
 	// Fetch race payload outside the transaction to avoid holding locks during network I/O
-	rf, err := s.Source.FetchRace(eventSourceId, raceId)
+	rf, err := s.source().FetchRace(eventSourceId, raceId)
 	if err != nil {
 		return err
 	}
 	if len(rf) == 0 {
 		return fmt.Errorf("race not found: %s", raceId)
 	}
-	r := rf[0]
+	return s.IngestRaceData(eventSourceId, raceId, rf[0])
+}
 
+// IngestRaceData upserts a race and its nested entities from an already fetched payload
+// eventSourceId: The external system's event identifier (not PocketBase ID)
+// raceId: The external system's race identifier (not PocketBase ID)
+func (s *Service) IngestRaceData(eventSourceId, raceId string, r Race) error {
+	_, span := tracing.Start(s.context(), "ingest.race.tx", tracing.KindInternal, tracing.String("raceId", raceId),
+		tracing.Int("detections", len(r.Detections)), tracing.Int("laps", len(r.Laps)), tracing.Int("gamePoints", len(r.GamePoints)))
 	// Execute all DB operations in a single transaction
+	var commit *RaceCommitEvent
 	if err := s.Upserter.App.RunInTransaction(func(txApp core.App) error {
-		return s.ingestRaceTransaction(txApp, eventSourceId, raceId, r)
+		var err error
+		commit, err = s.ingestRaceTransaction(txApp, eventSourceId, raceId, r)
+		return err
 	}); err != nil {
+		span.End(err)
 		return err
 	}
+	span.End(nil)
 
 	slog.Debug("ingest.race.done", "raceId", raceId, "detections", len(r.Detections), "laps", len(r.Laps), "gamePoints", len(r.GamePoints))
+	s.raceCommitted(commit)
 	return nil
 }
 
-func (s *Service) ingestRaceTransaction(txApp core.App, eventSourceId, raceId string, payload Race) error {
-	u := NewUpserter(txApp)
+// RaceCommitEvent describes a race whose ingest transaction has committed.
+type RaceCommitEvent struct {
+	hook.Event
+	App core.App
+	// EventID and RaceID are PocketBase IDs; RaceSourceID is the timing system's.
+	EventID      string
+	RaceID       string
+	RaceSourceID string
+	// Pilots are the PocketBase IDs of the pilots the commit may have changed
+	// data for: those in the race before and after it.
+	Pilots []string
+}
+
+const raceCommittedKey = "ingest.onRaceCommitted"
+
+// OnRaceCommitted is triggered after every race ingest of app commits, from a
+// timing system or the manual API, so derived data can be rebuilt from a
+// consistent race. Results written for a race trigger it too. Handler errors
+// are logged; they never fail the ingest.
+func OnRaceCommitted(app core.App) *hook.Hook[*RaceCommitEvent] {
+	return app.Store().GetOrSet(raceCommittedKey, func() any {
+		return &hook.Hook[*RaceCommitEvent]{}
+	}).(*hook.Hook[*RaceCommitEvent])
+}
+
+func (s *Service) raceCommitted(e *RaceCommitEvent) {
+	e.App = s.Upserter.App
+	if err := OnRaceCommitted(e.App).Trigger(e); err != nil {
+		slog.Warn("ingest.race.committedHook.error", "raceId", e.RaceSourceID, "err", err)
+	}
+}
+
+// racePilots lists the pilots with a channel in a race, adding them to seen.
+func racePilots(app core.App, racePBID string, seen map[string]struct{}) []string {
+	var rows []struct {
+		Pilot string `db:"pilot"`
+	}
+	if err := app.DB().NewQuery("SELECT DISTINCT pilot FROM pilotChannels WHERE race = {:r}").
+		Bind(dbx.Params{"r": racePBID}).All(&rows); err != nil {
+		slog.Warn("ingest.racePilots.error", "race", racePBID, "err", err)
+	}
+	var out []string
+	for _, r := range rows {
+		if _, ok := seen[r.Pilot]; ok || r.Pilot == "" {
+			continue
+		}
+		seen[r.Pilot] = struct{}{}
+		out = append(out, r.Pilot)
+	}
+	return out
+}
+
+func (s *Service) ingestRaceTransaction(txApp core.App, eventSourceId, raceId string, payload Race) (*RaceCommitEvent, error) {
+	u := s.Upserter.withApp(txApp)
 
 	eventPBID, err := u.GetExistingId("events", eventSourceId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	roundPBID, err := u.GetExistingId("rounds", string(payload.Round))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	racePBID, err := s.upsertRaceRecord(txApp, u, payload, eventPBID, roundPBID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	seen := map[string]struct{}{}
+	commit := &RaceCommitEvent{EventID: eventPBID, RaceID: racePBID, RaceSourceID: raceId, Pilots: racePilots(txApp, racePBID, seen)}
 
 	if err := s.IngestPilotChannels(u, eventSourceId, raceId, racePBID, eventPBID, payload.PilotChannels); err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := cleanupRaceCollection(txApp, "detections", raceId, racePBID, detectionIDSet(payload.Detections)); err != nil {
-		return err
+	if err := cleanupRaceCollection(u, "detections", raceId, racePBID, detectionIDSet(payload.Detections)); err != nil {
+		return nil, err
 	}
-	if err := cleanupRaceCollection(txApp, "laps", raceId, racePBID, lapIDSet(payload.Laps)); err != nil {
-		return err
+	if err := cleanupRaceCollection(u, "laps", raceId, racePBID, lapIDSet(payload.Laps)); err != nil {
+		return nil, err
 	}
-	if err := cleanupRaceCollection(txApp, "gamePoints", raceId, racePBID, gamePointIDSet(payload.GamePoints)); err != nil {
-		return err
+	if err := cleanupRaceCollection(u, "gamePoints", raceId, racePBID, gamePointIDSet(payload.GamePoints)); err != nil {
+		return nil, err
 	}
 
 	detectionPBIDMap, err := s.upsertDetections(u, payload, racePBID, eventPBID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := s.upsertLaps(u, payload, racePBID, eventPBID, detectionPBIDMap); err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := s.upsertGamePoints(u, payload, racePBID, eventPBID); err != nil {
-		return err
+		return nil, err
 	}
 
-	return RecalculateRaceOrder(txApp, eventPBID)
+	if err := RecalculateRaceOrder(txApp, eventPBID); err != nil {
+		return nil, err
+	}
+	commit.Pilots = append(commit.Pilots, racePilots(txApp, racePBID, seen)...)
+	return commit, nil
 }
 
 func (s *Service) upsertRaceRecord(txApp core.App, u *Upserter, race Race, eventPBID, roundPBID string) (string, error) {
@@ -232,6 +309,8 @@ func (s *Service) upsertRaceRecord(txApp core.App, u *Upserter, race Race, event
 		"raceNumber":                  race.RaceNumber,
 		"start":                       race.Start,
 		"end":                         race.End,
+		"startEpoch":                  venueEpochMs(s.venueClock(), race.Start),
+		"endEpoch":                    venueEpochMs(s.venueClock(), race.End),
 		"totalPausedTime":             race.TotalPausedTime,
 		"primaryTimingSystemLocation": race.PrimaryTimingSystemLocation,
 		"valid":                       race.Valid,
@@ -256,6 +335,7 @@ func (s *Service) upsertRaceRecord(txApp core.App, u *Upserter, race Race, event
 
 func (s *Service) upsertDetections(u *Upserter, race Race, racePBID, eventPBID string) (map[string]string, error) {
 	result := make(map[string]string, len(race.Detections))
+	clock := s.venueClock()
 	for _, d := range race.Detections {
 		pilotPBID, err := u.GetExistingId("pilots", string(d.Pilot))
 		if err != nil {
@@ -268,6 +348,7 @@ func (s *Service) upsertDetections(u *Upserter, race Race, racePBID, eventPBID s
 		detectionPBID, err := u.Upsert("detections", string(d.ID), map[string]any{
 			"timingSystemIndex": d.TimingSystemIndex,
 			"time":              d.Time,
+			"timeEpoch":         venueEpochMs(clock, d.Time),
 			"peak":              d.Peak,
 			"timingSystemType":  d.TimingSystemType,
 			"lapNumber":         d.LapNumber,
@@ -290,6 +371,7 @@ func (s *Service) upsertDetections(u *Upserter, race Race, racePBID, eventPBID s
 }
 
 func (s *Service) upsertLaps(u *Upserter, race Race, racePBID, eventPBID string, detectionPBIDMap map[string]string) error {
+	clock := s.venueClock()
 	for _, l := range race.Laps {
 		var detectionPBID string
 		if l.Detection != "" {
@@ -305,6 +387,8 @@ func (s *Service) upsertLaps(u *Upserter, race Race, racePBID, eventPBID string,
 			"lengthSeconds": l.LengthSeconds,
 			"startTime":     l.StartTime,
 			"endTime":       l.EndTime,
+			"startEpoch":    venueEpochMs(clock, l.StartTime),
+			"endEpoch":      venueEpochMs(clock, l.EndTime),
 			"detection":     detectionPBID,
 			"race":          racePBID,
 			"event":         eventPBID,
@@ -316,6 +400,7 @@ func (s *Service) upsertLaps(u *Upserter, race Race, racePBID, eventPBID string,
 }
 
 func (s *Service) upsertGamePoints(u *Upserter, race Race, racePBID, eventPBID string) error {
+	clock := s.venueClock()
 	for _, gp := range race.GamePoints {
 		pilotPBID, err := u.GetExistingId("pilots", string(gp.Pilot))
 		if err != nil {
@@ -326,12 +411,13 @@ func (s *Service) upsertGamePoints(u *Upserter, race Race, racePBID, eventPBID s
 			return err
 		}
 		if _, err := u.Upsert("gamePoints", string(gp.ID), map[string]any{
-			"valid":   gp.Valid,
-			"time":    gp.Time,
-			"pilot":   pilotPBID,
-			"race":    racePBID,
-			"channel": channelPBID,
-			"event":   eventPBID,
+			"valid":     gp.Valid,
+			"time":      gp.Time,
+			"timeEpoch": venueEpochMs(clock, gp.Time),
+			"pilot":     pilotPBID,
+			"race":      racePBID,
+			"channel":   channelPBID,
+			"event":     eventPBID,
 		}); err != nil {
 			return err
 		}
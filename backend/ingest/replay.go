@@ -0,0 +1,354 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replaySourceName stamps records ingested from a recording.
+const replaySourceName = "replay"
+
+// ReplaySource serves FPVTrackside responses captured to a directory, so an
+// event can be played back without a timing laptop. Every capture is a file
+// named after the request path, with the capture time in Unix milliseconds
+// before the extension:
+//
+//	<dir>/index.1718000000123.html                   GET /
+//	<dir>/httpfiles/Channels.1718000000123.json      GET /httpfiles/Channels.json
+//	<dir>/events/<id>/Event.1718000000456.json       GET /events/<id>/Event.json
+//	<dir>/events/<id>/<raceId>/Race.1718000012345.json
+//
+// A fetch returns the newest capture of its path taken at or before the
+// virtual clock, and a 404 while there is none yet. The clock starts at the
+// first capture and runs at Speed times real time; it can be paused and moved.
+// An optional replay.json gives the venue zone of the recording. Recorder
+// writes archives in this layout.
+type ReplaySource struct {
+	Dir string
+
+	captures map[string][]replayCapture
+	first    time.Time
+	last     time.Time
+	loc      *time.Location
+	// now is the real clock; tests replace it.
+	now func() time.Time
+
+	mu            sync.Mutex
+	speed         float64
+	paused        bool
+	anchorReal    time.Time
+	anchorVirtual time.Time
+}
+
+// ReplayManifestName is the optional file describing a recording.
+const ReplayManifestName = "replay.json"
+
+// ReplayManifest describes a recording.
+type ReplayManifest struct {
+	// UTCOffsetSec is the zone FPVTrackside wrote its timestamps in; without
+	// it they are read in this server's zone.
+	UTCOffsetSec *int `json:"utcOffsetSec,omitempty"`
+}
+
+// ReplayStatus is the state of the virtual clock.
+type ReplayStatus struct {
+	Dir      string  `json:"dir"`
+	FirstMs  int64   `json:"firstMs"`
+	LastMs   int64   `json:"lastMs"`
+	NowMs    int64   `json:"nowMs"`
+	Speed    float64 `json:"speed"`
+	Paused   bool    `json:"paused"`
+	Paths    int     `json:"paths"`
+	Captures int     `json:"captures"`
+}
+
+type replayCapture struct {
+	at   time.Time
+	file string
+}
+
+// ReplayFileName returns where, relative to a recording directory, the
+// response to path captured at at is stored.
+func ReplayFileName(requestPath string, at time.Time) string {
+	p := strings.TrimPrefix(replayPath(requestPath), "/")
+	if p == "" {
+		p = "index.html"
+	}
+	ext := path.Ext(p)
+	return filepath.FromSlash(strings.TrimSuffix(p, ext) + "." + strconv.FormatInt(at.UnixMilli(), 10) + ext)
+}
+
+// replayPath drops the query string, which captures do not record.
+func replayPath(requestPath string) string {
+	if i := strings.IndexByte(requestPath, '?'); i >= 0 {
+		requestPath = requestPath[:i]
+	}
+	return requestPath
+}
+
+// parseReplayFileName reverses ReplayFileName for a slash-separated name.
+func parseReplayFileName(rel string) (string, time.Time, bool) {
+	ext := path.Ext(rel)
+	stem := strings.TrimSuffix(rel, ext)
+	stamp := path.Ext(stem)
+	ms, err := strconv.ParseInt(strings.TrimPrefix(stamp, "."), 10, 64)
+	if stamp == "" || err != nil {
+		return "", time.Time{}, false
+	}
+	p := "/" + strings.TrimSuffix(stem, stamp) + ext
+	if p == "/index.html" {
+		p = "/"
+	}
+	return p, time.UnixMilli(ms), true
+}
+
+// NewReplaySource indexes the recording in dir and starts its clock at the
+// first capture, at real-time speed.
+func NewReplaySource(dir string) (*ReplaySource, error) {
+	r := &ReplaySource{Dir: dir, captures: make(map[string][]replayCapture), loc: time.Local, now: time.Now, speed: 1}
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == ReplayManifestName || rel == CaptureIndexName {
+			return nil
+		}
+		p, at, ok := parseReplayFileName(rel)
+		if !ok {
+			slog.Debug("ingest.replay.skip", "file", rel)
+			return nil
+		}
+		r.captures[p] = append(r.captures[p], replayCapture{at: at, file: file})
+		if r.first.IsZero() || at.Before(r.first) {
+			r.first = at
+		}
+		if at.After(r.last) {
+			r.last = at
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("index recording %s: %w", dir, err)
+	}
+	if len(r.captures) == 0 {
+		return nil, fmt.Errorf("no captures in %s", dir)
+	}
+	for _, cs := range r.captures {
+		sort.Slice(cs, func(i, j int) bool { return cs[i].at.Before(cs[j].at) })
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, ReplayManifestName)); err == nil {
+		var m ReplayManifest
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("read %s: %w", ReplayManifestName, err)
+		}
+		if m.UTCOffsetSec != nil {
+			r.loc = time.FixedZone("venue", *m.UTCOffsetSec)
+		}
+	}
+	r.anchorReal, r.anchorVirtual = r.now(), r.first
+	return r, nil
+}
+
+// Now returns the virtual time.
+func (r *ReplaySource) Now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.virtualLocked(r.now())
+}
+
+func (r *ReplaySource) virtualLocked(real time.Time) time.Time {
+	if r.paused {
+		return r.anchorVirtual
+	}
+	return r.anchorVirtual.Add(time.Duration(float64(real.Sub(r.anchorReal)) * r.speed))
+}
+
+// reanchorLocked restarts the clock from the current virtual time, so a
+// change of speed or pause only affects what comes after it.
+func (r *ReplaySource) reanchorLocked() {
+	real := r.now()
+	r.anchorVirtual, r.anchorReal = r.virtualLocked(real), real
+}
+
+// SetSpeed sets how many virtual seconds pass per real second.
+func (r *ReplaySource) SetSpeed(speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("speed must be positive, got %v", speed)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reanchorLocked()
+	r.speed = speed
+	return nil
+}
+
+// SetPaused stops or restarts the clock.
+func (r *ReplaySource) SetPaused(paused bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reanchorLocked()
+	r.paused = paused
+}
+
+// Seek moves the clock to t.
+func (r *ReplaySource) Seek(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.anchorReal, r.anchorVirtual = r.now(), t
+}
+
+// Status reports the recording and the clock.
+func (r *ReplaySource) Status() ReplayStatus {
+	r.mu.Lock()
+	now, speed, paused := r.virtualLocked(r.now()), r.speed, r.paused
+	r.mu.Unlock()
+	n := 0
+	for _, cs := range r.captures {
+		n += len(cs)
+	}
+	return ReplayStatus{
+		Dir:      r.Dir,
+		FirstMs:  r.first.UnixMilli(),
+		LastMs:   r.last.UnixMilli(),
+		NowMs:    now.UnixMilli(),
+		Speed:    speed,
+		Paused:   paused,
+		Paths:    len(r.captures),
+		Captures: n,
+	}
+}
+
+// Location implements VenueClock with the zone of the recording.
+func (r *ReplaySource) Location() *time.Location { return r.loc }
+
+// Offset implements VenueClock: how far the recording runs ahead of our clock
+// at the last change of it. Epochs are placed with ToLocal, which also
+// accounts for the speed.
+func (r *ReplaySource) Offset() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.anchorVirtual.Sub(r.anchorReal)
+}
+
+// ToLocal places a recorded time on today's clock, at the pace the replay
+// runs, so countdowns and lap times play out as the dashboard shows them
+// during a fast-forward too. It only moves when the clock is changed, so
+// re-ingesting unchanged captures does not rewrite epochs.
+func (r *ReplaySource) ToLocal(t time.Time) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.anchorReal.Add(time.Duration(float64(t.Sub(r.anchorVirtual)) / r.speed))
+}
+
+// SourceName implements NamedSource, so replayed records are stamped
+// "replay" and never pass for live FPVTrackside data.
+func (r *ReplaySource) SourceName() string { return replaySourceName }
+
+// body returns the capture of path current at the virtual time.
+func (r *ReplaySource) body(requestPath string) ([]byte, error) {
+	requestPath = replayPath(requestPath)
+	now := r.Now()
+	cs := r.captures[requestPath]
+	i := sort.Search(len(cs), func(i int) bool { return cs[i].at.After(now) })
+	if i == 0 {
+		return nil, &HTTPStatusError{URL: requestPath, Status: http.StatusNotFound, Body: "not captured by " + now.Format(time.RFC3339)}
+	}
+	return os.ReadFile(cs[i-1].file)
+}
+
+func (r *ReplaySource) getJSON(requestPath string, v any) error {
+	b, err := r.body(requestPath)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("decode %s: %w", requestPath, err)
+	}
+	return nil
+}
+
+func (r *ReplaySource) FetchEvent(eventSourceId string) (EventFile, error) {
+	var out EventFile
+	err := r.getJSON("/events/"+eventSourceId+"/Event.json", &out)
+	return out, err
+}
+func (r *ReplaySource) FetchPilots(eventSourceId string) (PilotsFile, error) {
+	var out PilotsFile
+	err := r.getJSON("/events/"+eventSourceId+"/Pilots.json", &out)
+	return out, err
+}
+func (r *ReplaySource) FetchChannels() (ChannelsFile, error) {
+	var out ChannelsFile
+	err := r.getJSON("/httpfiles/Channels.json", &out)
+	return out, err
+}
+func (r *ReplaySource) FetchRounds(eventSourceId string) (RoundsFile, error) {
+	var out RoundsFile
+	err := r.getJSON("/events/"+eventSourceId+"/Rounds.json", &out)
+	return out, err
+}
+func (r *ReplaySource) FetchRace(eventSourceId, raceId string) (RaceFile, error) {
+	var out RaceFile
+	err := r.getJSON("/events/"+eventSourceId+"/"+raceId+"/Race.json", &out)
+	return out, err
+}
+func (r *ReplaySource) FetchResults(eventSourceId string) (ResultsFile, error) {
+	b, err := r.body("/events/" + eventSourceId + "/Results.json")
+	if err != nil {
+		return ResultsFile{}, err
+	}
+	// Results.json is often 0 bytes; treat as empty results
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return ResultsFile{}, nil
+	}
+	var out ResultsFile
+	if err := json.Unmarshal(b, &out); err != nil {
+		return out, fmt.Errorf("decode Results.json: %w", err)
+	}
+	return out, nil
+}
+
+// FetchEventSourceId reads the captured root page like FPVClient does. A
+// recording without one falls back to the event whose Event.json was captured
+// last.
+func (r *ReplaySource) FetchEventSourceId() (string, error) {
+	if b, err := r.body("/"); err == nil {
+		if match := eventManagerPattern.FindStringSubmatch(string(b)); match != nil {
+			return match[1], nil
+		}
+	}
+	now := r.Now()
+	var id string
+	var latest time.Time
+	for p, cs := range r.captures {
+		rest, ok := strings.CutPrefix(p, "/events/")
+		if !ok || !strings.HasSuffix(rest, "/Event.json") || strings.Count(rest, "/") != 1 {
+			continue
+		}
+		for _, c := range cs {
+			if !c.at.After(now) && c.at.After(latest) {
+				id, latest = strings.TrimSuffix(rest, "/Event.json"), c.at
+			}
+		}
+	}
+	if id == "" {
+		return "", fmt.Errorf("event ID not found in recording")
+	}
+	return id, nil
+}
@@ -0,0 +1,146 @@
+package ingest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCapture stores body as the response to path captured at.
+func writeCapture(t *testing.T, dir, path string, at time.Time, body string) {
+	t.Helper()
+	file := filepath.Join(dir, ReplayFileName(path, at))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayServesCapturesOnVirtualClock(t *testing.T) {
+	dir := t.TempDir()
+	t0 := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	racePath := "/events/e1/r1/Race.json"
+	writeCapture(t, dir, racePath, t0, `[{"ID":"r1","Laps":[]}]`)
+	writeCapture(t, dir, racePath, t0.Add(10*time.Second), `[{"ID":"r1","Laps":[{"ID":"l1"}]}]`)
+	writeCapture(t, dir, "/events/e1/Results.json", t0.Add(20*time.Second), ``)
+	if err := os.WriteFile(filepath.Join(dir, ReplayManifestName), []byte(`{"utcOffsetSec":7200}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	real := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r, err := NewReplaySource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time { return real }
+	r.Seek(t0)
+
+	laps := func() int {
+		t.Helper()
+		races, err := r.FetchRace("e1", "r1")
+		if err != nil {
+			t.Fatalf("FetchRace: %v", err)
+		}
+		return len(races[0].Laps)
+	}
+	if got := laps(); got != 0 {
+		t.Fatalf("at start: %d laps, want 0", got)
+	}
+	if _, err := r.FetchResults("e1"); !isNotFound(err) {
+		t.Fatalf("results before capture: err = %v, want 404", err)
+	}
+
+	if err := r.SetSpeed(4); err != nil {
+		t.Fatal(err)
+	}
+	real = real.Add(3 * time.Second) // 12s of recording
+	if got := laps(); got != 1 {
+		t.Fatalf("after 12s virtual: %d laps, want 1", got)
+	}
+
+	r.SetPaused(true)
+	real = real.Add(time.Hour)
+	if got, want := r.Now(), t0.Add(12*time.Second); !got.Equal(want) {
+		t.Fatalf("paused clock at %v, want %v", got, want)
+	}
+	r.Seek(t0.Add(20 * time.Second))
+	if res, err := r.FetchResults("e1"); err != nil || len(res) != 0 {
+		t.Fatalf("empty Results.json: %v, %v", res, err)
+	}
+
+	st := r.Status()
+	if st.Paths != 2 || st.Captures != 3 || st.FirstMs != t0.UnixMilli() || !st.Paused || st.Speed != 4 {
+		t.Fatalf("status = %+v", st)
+	}
+	if _, off := time.Now().In(r.Location()).Zone(); off != 7200 {
+		t.Fatalf("venue offset %d, want 7200", off)
+	}
+	if got, want := r.Offset(), t0.Add(20*time.Second).Sub(real); got != want {
+		t.Fatalf("Offset() = %v, want %v", got, want)
+	}
+	// at 4x a lap 8s into the recording after the seek lands 2s from now
+	if got, want := r.ToLocal(t0.Add(28*time.Second)), real.Add(2*time.Second); !got.Equal(want) {
+		t.Fatalf("ToLocal() = %v, want %v", got, want)
+	}
+	if got := venueEpochMs(r, t0.Add(28*time.Second).Format(time.RFC3339Nano)); got != real.Add(2*time.Second).UnixMilli() {
+		t.Fatalf("venueEpochMs at 4x = %d, want %d", got, real.Add(2*time.Second).UnixMilli())
+	}
+	if name := NewServiceWithSource(nil, r).Upserter.source(); name != "replay" {
+		t.Fatalf("replayed records stamped %q, want replay", name)
+	}
+}
+
+func TestReplayEventSourceId(t *testing.T) {
+	dir := t.TempDir()
+	t0 := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	writeCapture(t, dir, "/events/old/Event.json", t0, `[]`)
+	writeCapture(t, dir, "/events/new/Event.json", t0.Add(time.Minute), `[]`)
+
+	r, err := NewReplaySource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := time.Now()
+	r.now = func() time.Time { return real }
+	r.Seek(t0)
+	if id, err := r.FetchEventSourceId(); err != nil || id != "old" {
+		t.Fatalf("without root page: %q, %v; want old", id, err)
+	}
+	r.Seek(t0.Add(time.Minute))
+	if id, _ := r.FetchEventSourceId(); id != "new" {
+		t.Fatalf("later event: %q, want new", id)
+	}
+
+	writeCapture(t, dir, "/", t0, `<script>var eventManager = new EventManager("events/root-1", 1);</script>`)
+	r, err = NewReplaySource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time { return real }
+	r.Seek(t0.Add(time.Minute))
+	if id, err := r.FetchEventSourceId(); err != nil || id != "root-1" {
+		t.Fatalf("with root page: %q, %v; want root-1", id, err)
+	}
+}
+
+func TestReplayFileNameRoundTrip(t *testing.T) {
+	at := time.UnixMilli(1718000000123)
+	for _, p := range []string{"/", "/httpfiles/Channels.json", "/events/e1/r1/Race.json"} {
+		got, gotAt, ok := parseReplayFileName(filepath.ToSlash(ReplayFileName(p, at)))
+		if !ok || got != p || !gotAt.Equal(at) {
+			t.Errorf("round trip of %s: %s %v %v", p, got, gotAt, ok)
+		}
+	}
+	if _, _, ok := parseReplayFileName("notes.txt"); ok {
+		t.Error("notes.txt parsed as a capture")
+	}
+}
+
+func isNotFound(err error) bool {
+	var status *HTTPStatusError
+	return errors.As(err, &status) && status.Status == 404
+}
@@ -15,11 +15,14 @@ func (s *Service) IngestResults(eventSourceId string) (int, error) {
 	}
 
 	// Fetch results
-	res, err := s.Source.FetchResults(eventSourceId)
+	res, err := s.source().FetchResults(eventSourceId)
 	if err != nil {
 		return 0, err
 	}
 
+	// races whose results changed, for OnRaceCommitted
+	changed := map[string]*RaceCommitEvent{}
+	var order []string
 	for _, r := range res {
 		// Resolve optional race id (may be empty GUID in some contexts)
 		var racePBID string
@@ -34,7 +37,7 @@ func (s *Service) IngestResults(eventSourceId string) (int, error) {
 			return 0, err
 		}
 
-		if _, err := s.Upserter.Upsert("results", string(r.ID), map[string]any{
+		_, wrote, err := s.Upserter.upsert("results", string(r.ID), map[string]any{
 			"points":     r.Points,
 			"position":   r.Position,
 			"valid":      r.Valid,
@@ -43,9 +46,22 @@ func (s *Service) IngestResults(eventSourceId string) (int, error) {
 			"event":      eventPBID,
 			"race":       racePBID,
 			"pilot":      pilotPBID,
-		}); err != nil {
+		})
+		if err != nil {
 			return 0, err
 		}
+		if wrote && racePBID != "" {
+			commit, ok := changed[racePBID]
+			if !ok {
+				commit = &RaceCommitEvent{EventID: eventPBID, RaceID: racePBID, RaceSourceID: string(r.Race)}
+				changed[racePBID] = commit
+				order = append(order, racePBID)
+			}
+			commit.Pilots = append(commit.Pilots, pilotPBID)
+		}
+	}
+	for _, race := range order {
+		s.raceCommitted(changed[race])
 	}
 	slog.Debug("ingest.results.done", "eventSourceId", eventSourceId, "results", len(res))
 	return len(res), nil
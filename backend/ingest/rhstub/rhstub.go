@@ -0,0 +1,178 @@
+// Package rhstub is a small stand-in for a RotorHazard timer's JSON API, for
+// tests and for trying the RotorHazard source without race hardware.
+package rhstub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+)
+
+// Pilot is a row of GET /api/pilot/all.
+type Pilot struct {
+	ID       int    `json:"id"`
+	Callsign string `json:"callsign"`
+	Name     string `json:"name"`
+	Team     string `json:"team"`
+}
+
+// Lap is one crossing of a node: lap 0 is the holeshot. Times are in
+// milliseconds; LapTimeStamp counts from the race start.
+type Lap struct {
+	ID           int     `json:"id"`
+	LapTimeStamp float64 `json:"lap_time_stamp"`
+	LapTime      float64 `json:"lap_time"`
+	Source       int     `json:"source"`
+	Deleted      bool    `json:"deleted"`
+}
+
+// Heat is a RotorHazard heat with its pilots by node index.
+type Heat struct {
+	ID     int
+	Note   string
+	Class  int
+	Pilots map[int]int
+}
+
+// SavedRace is a finished round of a heat.
+type SavedRace struct {
+	// Start is formatted as RotorHazard does, in the timer's local time.
+	Start string
+	// Laps by node index.
+	Laps map[int][]Lap
+}
+
+// Server serves the RotorHazard endpoints the ingest adapter reads. Tests
+// change its exported fields directly while holding Mu.
+type Server struct {
+	*httptest.Server
+
+	Mu          sync.Mutex
+	Pilots      []Pilot
+	Heats       []Heat
+	NumNodes    int
+	Frequencies []int
+	CurrentHeat int
+	// RaceStatus is 0 ready, 1 racing, 2 done, as RotorHazard reports it.
+	RaceStatus int
+	// CurrentLaps are the laps of the running race by node index.
+	CurrentLaps map[int][]Lap
+	// Saved races by heat ID, rounds in order.
+	Saved map[int][]SavedRace
+	// Requests counts the requests served by path.
+	Requests map[string]int
+}
+
+// New starts a stub with no pilots or heats.
+func New() *Server {
+	s := &Server{NumNodes: 4, Saved: make(map[int][]SavedRace), Requests: make(map[string]int)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", s.status)
+	mux.HandleFunc("GET /api/pilot/all", s.pilots)
+	mux.HandleFunc("GET /api/heat/all", s.heats)
+	mux.HandleFunc("GET /api/race/all", s.races)
+	mux.HandleFunc("GET /api/race/current", s.current)
+	mux.HandleFunc("GET /api/race/{heat}/{round}", s.race)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Mu.Lock()
+		s.Requests[r.URL.Path]++
+		s.Mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) status(w http.ResponseWriter, _ *http.Request) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	writeJSON(w, map[string]any{"status": map[string]any{
+		"server_info": map[string]any{"release_version": "stub"},
+		"state": map[string]any{
+			"current_heat": s.CurrentHeat,
+			"num_nodes":    s.NumNodes,
+			"race_status":  s.RaceStatus,
+			"frequencies":  s.Frequencies,
+		},
+	}})
+}
+
+func (s *Server) pilots(w http.ResponseWriter, _ *http.Request) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	writeJSON(w, map[string]any{"pilots": s.Pilots})
+}
+
+func (s *Server) heats(w http.ResponseWriter, _ *http.Request) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	heats := map[string]any{}
+	for _, h := range s.Heats {
+		nodes := map[string]int{}
+		for node, pilot := range h.Pilots {
+			nodes[strconv.Itoa(node)] = pilot
+		}
+		heats[strconv.Itoa(h.ID)] = map[string]any{
+			"heat_id":      h.ID,
+			"note":         h.Note,
+			"class_id":     h.Class,
+			"nodes_pilots": nodes,
+			"locked":       len(s.Saved[h.ID]) > 0,
+		}
+	}
+	writeJSON(w, map[string]any{"heats": heats})
+}
+
+func (s *Server) races(w http.ResponseWriter, _ *http.Request) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	heats := []map[string]int{}
+	for _, h := range s.Heats {
+		heats = append(heats, map[string]int{"id": h.ID, "rounds": len(s.Saved[h.ID])})
+	}
+	writeJSON(w, map[string]any{"heats": heats, "leaderboard": map[string]any{}})
+}
+
+func (s *Server) current(w http.ResponseWriter, _ *http.Request) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	raw := map[string][]Lap{}
+	for node := range s.NumNodes {
+		raw[strconv.Itoa(node)] = append([]Lap{}, s.CurrentLaps[node]...)
+	}
+	writeJSON(w, map[string]any{"raw_laps": raw, "leaderboard": map[string]any{}})
+}
+
+func (s *Server) race(w http.ResponseWriter, r *http.Request) {
+	heatID, err1 := strconv.Atoi(r.PathValue("heat"))
+	round, err2 := strconv.Atoi(r.PathValue("round"))
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	rounds := s.Saved[heatID]
+	if err1 != nil || err2 != nil || round < 1 || round > len(rounds) {
+		http.NotFound(w, r)
+		return
+	}
+	saved := rounds[round-1]
+	var heat Heat
+	for _, h := range s.Heats {
+		if h.ID == heatID {
+			heat = h
+		}
+	}
+	nodes := []map[string]any{}
+	for node, laps := range saved.Laps {
+		nodes = append(nodes, map[string]any{
+			"pilot_id":   heat.Pilots[node],
+			"node_index": node,
+			"laps":       laps,
+		})
+	}
+	writeJSON(w, map[string]any{"start_time_formatted": saved.Start, "nodes": nodes, "leaderboard": map[string]any{}})
+}
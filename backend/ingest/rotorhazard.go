@@ -0,0 +1,515 @@
+package ingest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotorHazardSource ingests from a RotorHazard timer through its JSON API
+// (/api/status, /api/pilot/all, /api/heat/all, /api/race/...). It translates
+// heats, pilots, nodes and laps into the FPVTrackside shapes the rest of
+// ingest works with, and records are stamped with source "rotorhazard".
+//
+// RotorHazard has no event or round objects of its own: the timer is one
+// event with ID EventID, round n holds the nth run of every heat, and each
+// node is a channel. All source IDs are prefixed with EventID so that
+// several RotorHazard events can share a database.
+type RotorHazardSource struct {
+	// C fetches through the shared FPVTrackside transport, so the fpvhttp
+	// throttle and timeouts apply to the timer too.
+	C         *FPVClient
+	EventID   string
+	EventName string
+	// Location is the timer's zone, which its race times are written in; nil
+	// means this process's zone. It must match the ingest service's Clock.
+	Location *time.Location
+	// ScheduleTTL is how long one read of the timer's heats and races is
+	// shared by the fetches of a scheduler pass.
+	ScheduleTTL time.Duration
+
+	// liveStart remembers when the running race was first seen, since
+	// /api/race/current does not say when it started.
+	mu        sync.Mutex
+	liveStart map[string]time.Time
+
+	scheduleMu sync.Mutex
+	cached     rhSchedule
+	cachedAt   time.Time
+}
+
+const (
+	rotorHazardSourceName = "rotorhazard"
+	// maxRotorHazardEventID keeps derived IDs within the 64-char sourceId.
+	maxRotorHazardEventID = 32
+	// rhTimeLayout is how race times are written, in the timer's zone.
+	rhTimeLayout = "2006-01-02T15:04:05.000"
+	// rhScheduleTTL spans one scheduler pass over every race of the event,
+	// which would otherwise read the schedule once per race.
+	rhScheduleTTL = time.Second
+)
+
+// RotorHazard race_status values.
+const (
+	rhRaceReady  = 0
+	rhRaceRacing = 1
+	rhRaceDone   = 2
+)
+
+// NewRotorHazardSource returns a source for the timer at baseURL. An empty
+// eventID means "rotorhazard".
+func NewRotorHazardSource(baseURL, eventID string) (*RotorHazardSource, error) {
+	if eventID == "" {
+		eventID = rotorHazardSourceName
+	}
+	if len(eventID) > maxRotorHazardEventID || strings.ContainsAny(eventID, "/?# ") {
+		return nil, fmt.Errorf("rotorhazard event id %q: at most %d characters, no spaces or slashes", eventID, maxRotorHazardEventID)
+	}
+	c, err := NewFPVClient(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RotorHazardSource{C: c, EventID: eventID, EventName: "RotorHazard", ScheduleTTL: rhScheduleTTL, liveStart: make(map[string]time.Time)}, nil
+}
+
+// SourceName implements NamedSource.
+func (r *RotorHazardSource) SourceName() string { return rotorHazardSourceName }
+
+// RotorHazard API payloads, reduced to the fields ingest reads.
+type rhStatus struct {
+	Status struct {
+		State struct {
+			CurrentHeat int `json:"current_heat"`
+			NumNodes    int `json:"num_nodes"`
+			RaceStatus  int `json:"race_status"`
+			// Frequencies of the nodes in MHz, when the timer reports them.
+			Frequencies []int `json:"frequencies"`
+		} `json:"state"`
+	} `json:"status"`
+}
+
+type rhPilotList struct {
+	Pilots []struct {
+		ID       int    `json:"id"`
+		Callsign string `json:"callsign"`
+		Name     string `json:"name"`
+	} `json:"pilots"`
+}
+
+type rhHeat struct {
+	ID          int            `json:"heat_id"`
+	Note        string         `json:"note"`
+	NodesPilots map[string]int `json:"nodes_pilots"`
+}
+
+type rhHeatList struct {
+	Heats map[string]rhHeat `json:"heats"`
+}
+
+type rhRaceList struct {
+	Heats []struct {
+		ID     int `json:"id"`
+		Rounds int `json:"rounds"`
+	} `json:"heats"`
+}
+
+type rhLap struct {
+	LapTimeStamp float64 `json:"lap_time_stamp"`
+	LapTime      float64 `json:"lap_time"`
+	Deleted      bool    `json:"deleted"`
+}
+
+type rhSavedRace struct {
+	Start string `json:"start_time_formatted"`
+	Nodes []struct {
+		PilotID   int     `json:"pilot_id"`
+		NodeIndex int     `json:"node_index"`
+		Laps      []rhLap `json:"laps"`
+	} `json:"nodes"`
+}
+
+type rhCurrentRace struct {
+	RawLaps map[string][]rhLap `json:"raw_laps"`
+}
+
+func (r *RotorHazardSource) roundID(n int) string  { return fmt.Sprintf("%s-r%d", r.EventID, n) }
+func (r *RotorHazardSource) pilotID(id int) string { return fmt.Sprintf("%s-p%d", r.EventID, id) }
+func (r *RotorHazardSource) nodeID(node int) string {
+	return fmt.Sprintf("%s-n%d", r.EventID, node)
+}
+func (r *RotorHazardSource) raceID(heat, round int) string {
+	return fmt.Sprintf("%s-h%d-r%d", r.EventID, heat, round)
+}
+
+// parseRaceID reverses raceID.
+func (r *RotorHazardSource) parseRaceID(raceId string) (heat, round int, ok bool) {
+	rest, found := strings.CutPrefix(raceId, r.EventID+"-h")
+	if !found {
+		return 0, 0, false
+	}
+	h, n, found := strings.Cut(rest, "-r")
+	heat, err1 := strconv.Atoi(h)
+	round, err2 := strconv.Atoi(n)
+	return heat, round, found && err1 == nil && err2 == nil && round > 0
+}
+
+func (r *RotorHazardSource) checkEvent(eventSourceId string) error {
+	if eventSourceId != r.EventID {
+		return &HTTPStatusError{URL: "/events/" + eventSourceId, Status: http.StatusNotFound, Body: "not the RotorHazard event"}
+	}
+	return nil
+}
+
+// rhSchedule is the races the timer knows of: every saved round of every
+// heat, plus the next round of the current heat and the first of heats that
+// have not run yet.
+type rhSchedule struct {
+	status rhStatus
+	heats  []rhHeat
+	saved  map[int]int
+}
+
+// schedule returns the timer's schedule, read at most once per ScheduleTTL.
+// Concurrent callers wait for the read in flight rather than start their own.
+func (r *RotorHazardSource) schedule() (rhSchedule, error) {
+	r.scheduleMu.Lock()
+	defer r.scheduleMu.Unlock()
+	if !r.cachedAt.IsZero() && time.Since(r.cachedAt) < r.ScheduleTTL {
+		return r.cached, nil
+	}
+	sc, err := r.readSchedule()
+	if err != nil {
+		return sc, err
+	}
+	r.cached, r.cachedAt = sc, time.Now()
+	return sc, nil
+}
+
+func (r *RotorHazardSource) readSchedule() (rhSchedule, error) {
+	var sc rhSchedule
+	var heats rhHeatList
+	var races rhRaceList
+	if err := r.C.getJSON("/api/status", &sc.status); err != nil {
+		return sc, err
+	}
+	if err := r.C.getJSON("/api/heat/all", &heats); err != nil {
+		return sc, err
+	}
+	if err := r.C.getJSON("/api/race/all", &races); err != nil {
+		return sc, err
+	}
+	for _, h := range heats.Heats {
+		sc.heats = append(sc.heats, h)
+	}
+	sort.Slice(sc.heats, func(i, j int) bool { return sc.heats[i].ID < sc.heats[j].ID })
+	sc.saved = make(map[int]int, len(races.Heats))
+	for _, h := range races.Heats {
+		sc.saved[h.ID] = h.Rounds
+	}
+	return sc, nil
+}
+
+// rounds returns how many rounds heat appears in, the pending one included.
+func (sc rhSchedule) rounds(heat int) int {
+	n := sc.saved[heat]
+	if n == 0 || heat == sc.status.Status.State.CurrentHeat {
+		n++
+	}
+	return n
+}
+
+func (sc rhSchedule) heat(id int) (rhHeat, bool) {
+	for _, h := range sc.heats {
+		if h.ID == id {
+			return h, true
+		}
+	}
+	return rhHeat{}, false
+}
+
+func (r *RotorHazardSource) FetchEvent(eventSourceId string) (EventFile, error) {
+	if err := r.checkEvent(eventSourceId); err != nil {
+		return nil, err
+	}
+	sc, err := r.schedule()
+	if err != nil {
+		return nil, err
+	}
+	ev := RaceEvent{ID: r.EventID, Name: r.EventName, EventType: "Race"}
+	maxRound := 0
+	for round := 1; ; round++ {
+		found := false
+		for _, h := range sc.heats {
+			if round <= sc.rounds(h.ID) {
+				ev.Races = append(ev.Races, r.raceID(h.ID, round))
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+		maxRound = round
+	}
+	for round := 1; round <= maxRound; round++ {
+		ev.Rounds = append(ev.Rounds, r.roundID(round))
+	}
+	for _, ch := range r.channels(sc.status) {
+		ev.Channels = append(ev.Channels, ch.ID)
+		ev.ChannelDisplayNames = append(ev.ChannelDisplayNames, ch.DisplayName)
+	}
+	return EventFile{ev}, nil
+}
+
+func (r *RotorHazardSource) FetchPilots(eventSourceId string) (PilotsFile, error) {
+	if err := r.checkEvent(eventSourceId); err != nil {
+		return nil, err
+	}
+	var list rhPilotList
+	if err := r.C.getJSON("/api/pilot/all", &list); err != nil {
+		return nil, err
+	}
+	out := make(PilotsFile, 0, len(list.Pilots))
+	for _, p := range list.Pilots {
+		first, last, _ := strings.Cut(strings.TrimSpace(p.Name), " ")
+		out = append(out, Pilot{ID: r.pilotID(p.ID), Name: p.Callsign, FirstName: first, LastName: last})
+	}
+	return out, nil
+}
+
+func (r *RotorHazardSource) FetchChannels() (ChannelsFile, error) {
+	sc, err := r.schedule()
+	if err != nil {
+		return nil, err
+	}
+	return r.channels(sc.status), nil
+}
+
+// channels maps the timer's nodes to channels.
+func (r *RotorHazardSource) channels(st rhStatus) ChannelsFile {
+	state := st.Status.State
+	out := make(ChannelsFile, 0, state.NumNodes)
+	for node := range state.NumNodes {
+		ch := Channel{ID: r.nodeID(node), Number: node + 1, DisplayName: fmt.Sprintf("Node %d", node+1)}
+		if node < len(state.Frequencies) && state.Frequencies[node] > 0 {
+			ch.Frequency = state.Frequencies[node]
+			ch.DisplayName = strconv.Itoa(ch.Frequency)
+		}
+		out = append(out, ch)
+	}
+	return out
+}
+
+func (r *RotorHazardSource) FetchRounds(eventSourceId string) (RoundsFile, error) {
+	events, err := r.FetchEvent(eventSourceId)
+	if err != nil {
+		return nil, err
+	}
+	out := make(RoundsFile, 0, len(events[0].Rounds))
+	for i, id := range events[0].Rounds {
+		n := i + 1
+		out = append(out, Round{ID: id, Name: fmt.Sprintf("Round %d", n), RoundNumber: n, EventType: "Race", Valid: true, Order: n})
+	}
+	return out, nil
+}
+
+func (r *RotorHazardSource) FetchRace(eventSourceId, raceId string) (RaceFile, error) {
+	if err := r.checkEvent(eventSourceId); err != nil {
+		return nil, err
+	}
+	heatID, round, ok := r.parseRaceID(raceId)
+	if !ok {
+		return nil, &HTTPStatusError{URL: raceId, Status: http.StatusNotFound, Body: "not a RotorHazard race"}
+	}
+	sc, err := r.schedule()
+	if err != nil {
+		return nil, err
+	}
+	heat, ok := sc.heat(heatID)
+	if !ok || round > sc.rounds(heatID) {
+		return nil, &HTTPStatusError{URL: raceId, Status: http.StatusNotFound, Body: "no such heat or round"}
+	}
+	race := Race{ID: raceId, Round: r.roundID(round), RaceNumber: heatID, Event: r.EventID, Bracket: heat.Note, Valid: true}
+
+	if round <= sc.saved[heatID] {
+		var saved rhSavedRace
+		if err := r.C.getJSON(fmt.Sprintf("/api/race/%d/%d", heatID, round), &saved); err != nil {
+			return nil, err
+		}
+		start, ok := parseRHTime(saved.Start, r.location())
+		if ok {
+			race.Start = start.Format(rhTimeLayout)
+		}
+		sort.Slice(saved.Nodes, func(i, j int) bool { return saved.Nodes[i].NodeIndex < saved.Nodes[j].NodeIndex })
+		var last float64
+		for _, n := range saved.Nodes {
+			r.addNode(&race, start, n.NodeIndex, n.PilotID, n.Laps)
+			last = max(last, lastStamp(n.Laps))
+		}
+		if ok {
+			race.End = start.Add(msDuration(last)).Format(rhTimeLayout)
+		}
+		r.forgetLive(raceId)
+		return RaceFile{race}, nil
+	}
+
+	pilots := make(map[int]int, len(heat.NodesPilots))
+	for node, pilot := range heat.NodesPilots {
+		if n, err := strconv.Atoi(node); err == nil && pilot > 0 {
+			pilots[n] = pilot
+		}
+	}
+	state := sc.status.Status.State
+	live := heatID == state.CurrentHeat && state.RaceStatus != rhRaceReady
+	var laps map[int][]rhLap
+	if live {
+		var cur rhCurrentRace
+		if err := r.C.getJSON("/api/race/current", &cur); err != nil {
+			return nil, err
+		}
+		laps = make(map[int][]rhLap, len(cur.RawLaps))
+		for node, l := range cur.RawLaps {
+			if n, err := strconv.Atoi(node); err == nil {
+				laps[n] = l
+			}
+		}
+	}
+	var start time.Time
+	var last float64
+	for _, l := range laps {
+		last = max(last, lastStamp(l))
+	}
+	if live {
+		start = r.seenLive(raceId, last)
+		race.Start = start.Format(rhTimeLayout)
+		if state.RaceStatus == rhRaceDone {
+			race.End = start.Add(msDuration(last)).Format(rhTimeLayout)
+		}
+	} else {
+		// a discarded race starts afresh
+		r.forgetLive(raceId)
+	}
+	nodes := make([]int, 0, len(pilots))
+	for node := range pilots {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+	for _, node := range nodes {
+		r.addNode(&race, start, node, pilots[node], laps[node])
+	}
+	return RaceFile{race}, nil
+}
+
+// addNode adds the pilot on node and their laps to race. Lap 0 is the
+// holeshot; deleted laps become invalid detections without a lap.
+func (r *RotorHazardSource) addNode(race *Race, start time.Time, node, pilotID int, laps []rhLap) {
+	if pilotID <= 0 {
+		return
+	}
+	pilot, channel := r.pilotID(pilotID), r.nodeID(node)
+	race.PilotChannels = append(race.PilotChannels, struct {
+		ID      Guid
+		Pilot   Guid
+		Channel Guid
+	}{ID: fmt.Sprintf("%s-n%d", race.ID, node), Pilot: pilot, Channel: channel})
+
+	stamp := func(ms float64) string {
+		if start.IsZero() {
+			return ""
+		}
+		return start.Add(msDuration(ms)).Format(rhTimeLayout)
+	}
+	lapNumber := 0
+	prevStamp := 0.0
+	for i, l := range laps {
+		det := Detection{
+			ID:                fmt.Sprintf("%s-n%d-d%d", race.ID, node, i),
+			TimingSystemIndex: node,
+			Channel:           channel,
+			Time:              stamp(l.LapTimeStamp),
+			TimingSystemType:  "RotorHazard",
+			Pilot:             pilot,
+			LapNumber:         lapNumber,
+			Valid:             !l.Deleted,
+			IsLapEnd:          true,
+			IsHoleshot:        lapNumber == 0,
+		}
+		if l.Deleted {
+			det.ValidityType = "Discarded"
+			race.Detections = append(race.Detections, det)
+			continue
+		}
+		det.ValidityType = "Auto"
+		race.Detections = append(race.Detections, det)
+		race.Laps = append(race.Laps, Lap{
+			ID:            fmt.Sprintf("%s-n%d-l%d", race.ID, node, lapNumber),
+			Detection:     det.ID,
+			LengthSeconds: l.LapTime / 1000,
+			LapNumber:     lapNumber,
+			StartTime:     stamp(prevStamp),
+			EndTime:       det.Time,
+		})
+		prevStamp = l.LapTimeStamp
+		lapNumber++
+	}
+}
+
+// seenLive returns the start of the running race raceId, estimated when it
+// is first seen from the newest lap timestamp.
+func (r *RotorHazardSource) seenLive(raceId string, lastMs float64) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start, ok := r.liveStart[raceId]
+	if !ok {
+		start = time.Now().Add(-msDuration(lastMs)).In(r.location())
+		r.liveStart[raceId] = start
+	}
+	return start
+}
+
+func (r *RotorHazardSource) forgetLive(raceId string) {
+	r.mu.Lock()
+	delete(r.liveStart, raceId)
+	r.mu.Unlock()
+}
+
+// FetchResults returns no results: RotorHazard ranks heats itself and the
+// dashboard computes standings from laps.
+func (r *RotorHazardSource) FetchResults(eventSourceId string) (ResultsFile, error) {
+	return ResultsFile{}, r.checkEvent(eventSourceId)
+}
+
+func (r *RotorHazardSource) FetchEventSourceId() (string, error) { return r.EventID, nil }
+
+func lastStamp(laps []rhLap) float64 {
+	var last float64
+	for _, l := range laps {
+		if !l.Deleted {
+			last = max(last, l.LapTimeStamp)
+		}
+	}
+	return last
+}
+
+func msDuration(ms float64) time.Duration { return time.Duration(ms * float64(time.Millisecond)) }
+
+func (r *RotorHazardSource) location() *time.Location {
+	if r.Location != nil {
+		return r.Location
+	}
+	return time.Local
+}
+
+// parseRHTime reads start_time_formatted in the timer's zone, which is the
+// venue zone ingest reads race times in.
+func parseRHTime(raw string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(raw), loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
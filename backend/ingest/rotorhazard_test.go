@@ -0,0 +1,219 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"drone-dashboard/ingest/rhstub"
+	_ "drone-dashboard/migrations"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func newRotorHazardStub() *rhstub.Server {
+	stub := rhstub.New()
+	stub.Pilots = []rhstub.Pilot{{ID: 1, Callsign: "Zippy", Name: "Zoe Park"}, {ID: 2, Callsign: "Bolt", Name: "Ben Ode"}}
+	stub.NumNodes = 2
+	stub.Frequencies = []int{5658, 5695}
+	stub.Heats = []rhstub.Heat{
+		{ID: 1, Note: "Heat 1", Pilots: map[int]int{0: 1, 1: 2}},
+		{ID: 2, Note: "Heat 2", Pilots: map[int]int{0: 2}},
+	}
+	stub.Saved[1] = []rhstub.SavedRace{{
+		Start: "2025-06-01 14:00:00.000",
+		Laps: map[int][]rhstub.Lap{
+			0: {{LapTimeStamp: 1500, LapTime: 1500}, {LapTimeStamp: 31500, LapTime: 30000}, {LapTimeStamp: 33000, LapTime: 1500, Deleted: true}, {LapTimeStamp: 60000, LapTime: 28500}},
+			1: {{LapTimeStamp: 2000, LapTime: 2000}, {LapTimeStamp: 34000, LapTime: 32000}},
+		},
+	}}
+	stub.CurrentHeat = 2
+	return stub
+}
+
+func TestRotorHazardSourceMapsHeatsAndLaps(t *testing.T) {
+	stub := newRotorHazardStub()
+	defer stub.Close()
+	src, err := NewRotorHazardSource(stub.URL, "club-0601")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := src.FetchEvent("club-0601")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// heat 1 ran once; heat 2 is current and waiting for its first round
+	ev := events[0]
+	if len(ev.Rounds) != 1 || len(ev.Races) != 2 || ev.Races[0] != "club-0601-h1-r1" || ev.Races[1] != "club-0601-h2-r1" {
+		t.Fatalf("event = %+v", ev)
+	}
+	if ev.ChannelDisplayNames[1] != "5695" {
+		t.Fatalf("channel names = %v", ev.ChannelDisplayNames)
+	}
+
+	races, err := src.FetchRace("club-0601", "club-0601-h1-r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	race := races[0]
+	if race.Start != "2025-06-01T14:00:00.000" || race.End != "2025-06-01T14:01:00.000" || len(race.PilotChannels) != 2 {
+		t.Fatalf("race = %+v", race)
+	}
+	// node 0: holeshot, two laps, one deleted crossing; node 1: holeshot and a lap
+	if len(race.Detections) != 6 || len(race.Laps) != 5 {
+		t.Fatalf("%d detections, %d laps", len(race.Detections), len(race.Laps))
+	}
+	lap2 := race.Laps[2]
+	if lap2.LapNumber != 2 || lap2.LengthSeconds != 28.5 || lap2.StartTime != "2025-06-01T14:00:31.500" {
+		t.Fatalf("second lap of node 0 = %+v", lap2)
+	}
+
+	// the current heat goes live; read the schedule afresh
+	src.ScheduleTTL = 0
+	stub.Mu.Lock()
+	stub.RaceStatus = 1
+	stub.CurrentLaps = map[int][]rhstub.Lap{0: {{LapTimeStamp: 1800, LapTime: 1800}}}
+	stub.Mu.Unlock()
+	races, err = src.FetchRace("club-0601", "club-0601-h2-r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if races[0].Start == "" || races[0].End != "" || len(races[0].Laps) != 1 || races[0].Laps[0].Detection == "" {
+		t.Fatalf("live race = %+v", races[0])
+	}
+}
+
+func TestRotorHazardIngestStampsSource(t *testing.T) {
+	stub := newRotorHazardStub()
+	defer stub.Close()
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	src, err := NewRotorHazardSource(stub.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewServiceWithSource(app, src)
+	summary, err := svc.FullAuto(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.RacesSucceeded != 2 || summary.RacesFailed != 0 {
+		t.Fatalf("summary = %+v", summary)
+	}
+
+	for collection, want := range map[string]int{"events": 1, "pilots": 2, "channels": 2, "races": 2, "laps": 5} {
+		recs, err := app.FindRecordsByFilter(collection, "source = 'rotorhazard'", "", 0, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recs) != want {
+			t.Errorf("%s from rotorhazard: %d, want %d", collection, len(recs), want)
+		}
+	}
+	race, err := app.FindFirstRecordByFilter("races", "sourceId = {:id}", dbx.Params{"id": "rotorhazard-h1-r1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if race.GetInt("startEpoch") == 0 || race.GetString("bracket") != "Heat 1" {
+		t.Fatalf("race record: start=%d bracket=%q", race.GetInt("startEpoch"), race.GetString("bracket"))
+	}
+
+	// a cancelled command stops before any race and rolls a purge back
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if summary, err := svc.FullAuto(cancelled); !errors.Is(err, context.Canceled) || summary.RacesProcessed != 0 {
+		t.Fatalf("cancelled full ingest: %+v, %v", summary, err)
+	}
+	if _, err := svc.Purge(cancelled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled purge: %v", err)
+	}
+
+	purged, err := svc.Purge(context.Background())
+	if err != nil || purged.Races != 2 {
+		t.Fatalf("purge: %+v, %v", purged, err)
+	}
+}
+
+func TestRotorHazardSharesScheduleAcrossAPass(t *testing.T) {
+	stub := newRotorHazardStub()
+	defer stub.Close()
+	src, err := NewRotorHazardSource(stub.URL, "club-0601")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.ScheduleTTL = time.Minute
+
+	if _, err := src.FetchEvent("club-0601"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.FetchRounds("club-0601"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"club-0601-h1-r1", "club-0601-h2-r1"} {
+		if _, err := src.FetchRace("club-0601", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stub.Mu.Lock()
+	defer stub.Mu.Unlock()
+	for _, path := range []string{"/api/status", "/api/heat/all", "/api/race/all"} {
+		if n := stub.Requests[path]; n != 1 {
+			t.Errorf("%s requested %d times, want 1", path, n)
+		}
+	}
+}
+
+func TestRotorHazardReadsTimesInVenueZone(t *testing.T) {
+	stub := newRotorHazardStub()
+	defer stub.Close()
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	// the server runs in UTC, the timer in Berlin summer time
+	berlin := time.FixedZone("CEST", 2*60*60)
+	src, err := NewRotorHazardSource(stub.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Location = berlin
+	svc := NewServiceWithSource(app, src)
+	svc.Clock = ZoneClock{Loc: berlin}
+	if _, err := svc.FullAuto(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	race, err := app.FindFirstRecordByFilter("races", "sourceId = 'rotorhazard-h1-r1'", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC).UnixMilli()
+	if got := int64(race.GetInt("startEpoch")); got != want {
+		t.Fatalf("startEpoch = %d, want %d", got, want)
+	}
+
+	// a live race started just now lands at about now
+	stub.Mu.Lock()
+	stub.RaceStatus = 1
+	stub.CurrentLaps = map[int][]rhstub.Lap{0: {{LapTimeStamp: 1800, LapTime: 1800}}}
+	stub.Mu.Unlock()
+	src.ScheduleTTL = 0
+	if _, err := svc.FullAuto(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	live, err := app.FindFirstRecordByFilter("races", "sourceId = 'rotorhazard-h2-r1'", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(time.UnixMilli(int64(live.GetInt("startEpoch")))); d < 0 || d > time.Minute {
+		t.Fatalf("live race started %v ago", d)
+	}
+}
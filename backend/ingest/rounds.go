@@ -12,7 +12,7 @@ func (s *Service) IngestRounds(eventSourceId string) error {
 	if err != nil {
 		return err
 	}
-	rounds, err := s.Source.FetchRounds(eventSourceId)
+	rounds, err := s.source().FetchRounds(eventSourceId)
 	if err != nil {
 		return err
 	}
@@ -1,17 +1,62 @@
 package ingest
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 )
 
 type Service struct {
 	Source   Source
 	Upserter *Upserter
+	// PitsID scopes events to one venue when several pits feed the same cloud.
+	// Empty keeps the single-venue behaviour where one event is current globally.
+	PitsID string
+	// Clock normalizes venue timestamps into the *Epoch fields; nil means
+	// FPVTrackside shares this server's zone and clock.
+	Clock VenueClock
+	// Breaker, when set, guards every fetch from Source.
+	Breaker *Breaker
+
+	// ctx is set on copies made by WithContext.
+	ctx context.Context
 }
 
+// WithContext returns a copy of s whose fetches and spans belong to ctx, for
+// callers such as the scheduler that trace a unit of work.
+func (s *Service) WithContext(ctx context.Context) *Service {
+	cp := *s
+	cp.ctx = ctx
+	return &cp
+}
+
+func (s *Service) context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+
+// source returns Source bound to the service context when it supports one,
+// behind the breaker when there is one.
+func (s *Service) source() Source {
+	src := s.Source
+	if cs, ok := src.(ContextSource); ok && s.ctx != nil {
+		src = cs.WithContext(s.ctx)
+	}
+	if s.Breaker != nil {
+		src = breakerSource{inner: src, b: s.Breaker}
+	}
+	return src
+}
+
+// Origin returns the Source as ingest uses it, for callers that fetch
+// without ingesting.
+func (s *Service) Origin() Source { return s.source() }
+
 func NewService(app core.App, baseURL string) (*Service, error) {
 	client, err := NewFPVClient(baseURL)
 	if err != nil {
@@ -21,7 +66,11 @@ func NewService(app core.App, baseURL string) (*Service, error) {
 }
 
 func NewServiceWithSource(app core.App, src Source) *Service {
-	return &Service{Source: src, Upserter: NewUpserter(app)}
+	u := NewUpserter(app)
+	if ns, ok := src.(NamedSource); ok {
+		u.Source = ns.SourceName()
+	}
+	return &Service{Source: src, Upserter: u}
 }
 
 // PurgeSummary captures the results of a purge operation
@@ -42,8 +91,9 @@ type PurgeSummary struct {
 	ControlStats  int `json:"controlStats"`
 }
 
-// Purge removes all FPVTrackside-derived data from the database, including current race order state
-func (s *Service) Purge() (*PurgeSummary, error) {
+// Purge removes all data ingested from the service's timing system from the database, including current race order state
+// Cancelling ctx before it commits rolls the whole purge back.
+func (s *Service) Purge(ctx context.Context) (*PurgeSummary, error) {
 	summary := &PurgeSummary{}
 
 	// Use a transaction to ensure atomicity
@@ -64,7 +114,10 @@ func (s *Service) Purge() (*PurgeSummary, error) {
 		}
 
 		for _, col := range collections {
-			records, err := txApp.FindRecordsByFilter(col, "source = 'fpvtrackside'", "", 0, 0, nil)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			records, err := txApp.FindRecordsByFilter(col, "source = {:source}", "", 0, 0, dbx.Params{"source": s.Upserter.source()})
 			if err != nil {
 				return fmt.Errorf("failed to find records in %s: %w", col, err)
 			}
@@ -141,6 +194,18 @@ func (s *Service) Purge() (*PurgeSummary, error) {
 		}
 		summary.ControlStats = len(csRecords)
 
+		// Clear control_stats_series
+		seriesRecords, err := txApp.FindRecordsByFilter("control_stats_series", "", "", 0, 0, nil)
+		if err != nil {
+			return fmt.Errorf("failed to find control_stats_series: %w", err)
+		}
+		for _, rec := range seriesRecords {
+			if err := txApp.Delete(rec); err != nil {
+				return fmt.Errorf("failed to delete control_stats_series: %w", err)
+			}
+		}
+		summary.ControlStats += len(seriesRecords)
+
 		return nil
 	})
 
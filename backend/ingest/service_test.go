@@ -1,6 +1,7 @@
 package ingest
 
 import (
+	"context"
 	"testing"
 
 	_ "drone-dashboard/migrations"
@@ -21,7 +22,7 @@ func TestPurge(t *testing.T) {
 	service := NewServiceWithSource(app, nil)
 
 	// Test purging on empty database (should succeed)
-	summary, err := service.Purge()
+	summary, err := service.Purge(context.Background())
 	if err != nil {
 		t.Fatalf("Purge failed: %v", err)
 	}
@@ -10,7 +10,7 @@ import (
 func (s *Service) Snapshot(eventSourceId string) error {
 	slog.Debug("ingest.snapshot.start", "eventSourceId", eventSourceId)
 	// Fetch
-	events, err := s.Source.FetchEvent(eventSourceId)
+	events, err := s.source().FetchEvent(eventSourceId)
 	if err != nil {
 		return err
 	}
@@ -18,22 +18,22 @@ func (s *Service) Snapshot(eventSourceId string) error {
 		return fmt.Errorf("event not found: %s", eventSourceId)
 	}
 
-	pilots, err := s.Source.FetchPilots(eventSourceId)
+	pilots, err := s.source().FetchPilots(eventSourceId)
 	if err != nil {
 		return err
 	}
-	channels, err := s.Source.FetchChannels()
+	channels, err := s.source().FetchChannels()
 	if err != nil {
 		return err
 	}
-	rounds, err := s.Source.FetchRounds(eventSourceId)
+	rounds, err := s.source().FetchRounds(eventSourceId)
 	if err != nil {
 		return err
 	}
 
 	// Upsert event
 	e := events[0]
-	eventPBID, err := s.Upserter.Upsert("events", string(e.ID), map[string]any{
+	eventPBID, err := s.Upserter.Upsert("events", string(e.ID), s.scopeEventFields(map[string]any{
 		"name":                        e.Name,
 		"eventType":                   e.EventType,
 		"start":                       e.Start,
@@ -48,8 +48,8 @@ func (s *Service) Snapshot(eventSourceId string) error {
 		"raceStartIgnoreDetections":   e.RaceStartIgnoreDetections,
 		"minLapTime":                  e.MinLapTime,
 		"lastOpened":                  e.LastOpened,
-		"isCurrent":                   true,
-	})
+		"isCurrent":                   !s.manualEventCurrent(),
+	}))
 	if err != nil {
 		return err
 	}
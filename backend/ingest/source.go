@@ -4,13 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"drone-dashboard/control"
+	"drone-dashboard/fpvhttp"
 )
 
 // Source abstracts where we fetch FPVTrackside-like data from.
@@ -24,6 +25,20 @@ type Source interface {
 	FetchEventSourceId() (string, error)
 }
 
+// NamedSource is a Source for a timing system other than FPVTrackside. Its
+// name is stored in the source field of every record ingested from it.
+type NamedSource interface {
+	Source
+	SourceName() string
+}
+
+// ContextSource is a Source that can bind its fetches to a context, so they
+// carry its trace and cancellation.
+type ContextSource interface {
+	Source
+	WithContext(ctx context.Context) Source
+}
+
 // DirectSource wraps the existing FPVClient.
 type DirectSource struct{ C *FPVClient }
 
@@ -52,6 +67,8 @@ type RemoteSource struct {
 	// simple per-path cache of last ETag/body to leverage 304s
 	cacheMu sync.RWMutex
 	cache   map[string]cached
+	// OnBody, when set, observes every full body fetched or pushed, by path.
+	OnBody func(path string, body []byte)
 }
 type cached struct {
 	etag string
@@ -62,133 +79,190 @@ func NewRemoteSource(h *control.Hub, pitsID string) *RemoteSource {
 	return &RemoteSource{Hub: h, PitsID: pitsID, cache: make(map[string]cached)}
 }
 
-const (
-	cloudFetchTimeout = 3 * time.Second
-	pitsHTTPTimeoutMs = 1000
-)
+// cloudFetchMargin is what a fetch over the control link may take on top of
+// the pits' FPVTrackside request: queueing on the pits and the round trip.
+const cloudFetchMargin = 2 * time.Second
+
+// fetchTimeouts returns how long the cloud waits for path and how long the
+// pits may spend on FPVTrackside, from this server's fpvhttp settings.
+func fetchTimeouts(path string) (cloud time.Duration, pitsMs int) {
+	pits := fpvhttp.Timeout(path)
+	return pits + cloudFetchMargin, int(pits.Milliseconds())
+}
+
+// WithContext binds fetches to ctx so they carry its trace and deadline.
+func (r *RemoteSource) WithContext(ctx context.Context) Source {
+	return boundRemoteSource{r: r, ctx: ctx}
+}
 
-func (r *RemoteSource) fetchJSON(path string, out any) error {
-	ctx, cancel := context.WithTimeout(context.Background(), cloudFetchTimeout)
+func (r *RemoteSource) FetchEvent(eventSourceId string) (EventFile, error) {
+	return r.WithContext(context.Background()).FetchEvent(eventSourceId)
+}
+func (r *RemoteSource) FetchPilots(eventSourceId string) (PilotsFile, error) {
+	return r.WithContext(context.Background()).FetchPilots(eventSourceId)
+}
+func (r *RemoteSource) FetchChannels() (ChannelsFile, error) {
+	return r.WithContext(context.Background()).FetchChannels()
+}
+func (r *RemoteSource) FetchRounds(eventSourceId string) (RoundsFile, error) {
+	return r.WithContext(context.Background()).FetchRounds(eventSourceId)
+}
+func (r *RemoteSource) FetchRace(eventSourceId, raceId string) (RaceFile, error) {
+	return r.WithContext(context.Background()).FetchRace(eventSourceId, raceId)
+}
+func (r *RemoteSource) FetchResults(eventSourceId string) (ResultsFile, error) {
+	return r.WithContext(context.Background()).FetchResults(eventSourceId)
+}
+func (r *RemoteSource) FetchEventSourceId() (string, error) {
+	return r.WithContext(context.Background()).FetchEventSourceId()
+}
+
+func (r *RemoteSource) observe(path string, body []byte) {
+	if r.OnBody != nil {
+		r.OnBody(path, body)
+	}
+}
+
+// remember stores a body as the latest known payload for path.
+func (r *RemoteSource) remember(path, etag string, body []byte) {
+	if etag == "" {
+		return
+	}
+	r.cacheMu.Lock()
+	r.cache[path] = cached{etag: etag, body: body}
+	r.cacheMu.Unlock()
+}
+
+// boundRemoteSource is a RemoteSource whose fetches derive from ctx.
+type boundRemoteSource struct {
+	r   *RemoteSource
+	ctx context.Context
+}
+
+// fetchBody fetches path via the pits, resolving 304s and JSON Patch deltas
+// against the cached body. It returns the full body and its ETag.
+func (b boundRemoteSource) fetchBody(path string) ([]byte, string, error) {
+	r := b.r
+	cloudTimeout, pitsTimeoutMs := fetchTimeouts(path)
+	ctx, cancel := context.WithTimeout(b.ctx, cloudTimeout)
 	defer cancel()
 	ctx, traceID := control.EnsureTraceID(ctx)
-	var ifNone string
 	r.cacheMu.RLock()
-	if c, ok := r.cache[path]; ok {
-		ifNone = c.etag
-	}
+	prev, hasPrev := r.cache[path]
 	r.cacheMu.RUnlock()
-	resp, err := r.Hub.DoFetch(ctx, r.PitsID, control.Fetch{Method: http.MethodGet, Path: path, IfNoneMatch: ifNone, TimeoutMs: pitsHTTPTimeoutMs, TraceID: traceID})
+	resp, err := r.Hub.DoFetch(ctx, r.PitsID, control.Fetch{Method: http.MethodGet, Path: path, IfNoneMatch: prev.etag, TimeoutMs: pitsTimeoutMs, TraceID: traceID})
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 	status, hdrs, body := control.DecodeResponse(resp)
+	if status >= http.StatusInternalServerError {
+		return nil, "", &HTTPStatusError{URL: path, Status: status, Body: string(body)}
+	}
+	etag := hdrs["ETag"]
 	if status == http.StatusNotModified {
-		r.cacheMu.RLock()
-		c, ok := r.cache[path]
-		r.cacheMu.RUnlock()
-		if ok {
-			body = c.body
+		if !hasPrev {
+			return nil, "", fmt.Errorf("304 but no cache for %s", path)
+		}
+		return prev.body, prev.etag, nil
+	}
+	if resp.Encoding == control.EncodingJSONPatch {
+		var full []byte
+		if hasPrev && resp.BaseETag == prev.etag {
+			full, err = control.ApplyDelta(prev.body, body, etag)
 		} else {
-			return fmt.Errorf("304 but no cache for %s", path)
+			err = fmt.Errorf("delta base %s not cached", resp.BaseETag)
 		}
+		if err != nil {
+			slog.Warn("ingest.remote.delta.error", "path", path, "traceId", traceID, "err", err)
+			if !hasPrev {
+				return nil, "", err
+			}
+			// Drop the base so the retry asks for a full body.
+			r.cacheMu.Lock()
+			delete(r.cache, path)
+			r.cacheMu.Unlock()
+			return b.fetchBody(path)
+		}
+		body = full
+	}
+	return body, etag, nil
+}
+
+func (b boundRemoteSource) fetchJSON(path string, out any) error {
+	body, etag, err := b.fetchBody(path)
+	if err != nil {
+		return err
 	}
-	etag, _ := hdrs["ETag"]
 	if err := json.Unmarshal(body, out); err != nil {
 		// Do not cache invalid payloads
 		return err
 	}
-	if etag != "" {
-		r.cacheMu.Lock()
-		r.cache[path] = cached{etag: etag, body: body}
-		r.cacheMu.Unlock()
-	}
+	b.r.remember(path, etag, body)
+	b.r.observe(path, body)
 	return nil
 }
 
-func (r *RemoteSource) FetchEvent(eventSourceId string) (EventFile, error) {
+func (b boundRemoteSource) FetchEvent(eventSourceId string) (EventFile, error) {
 	var o EventFile
-	err := r.fetchJSON("/events/"+eventSourceId+"/Event.json", &o)
+	err := b.fetchJSON("/events/"+eventSourceId+"/Event.json", &o)
 	return o, err
 }
-func (r *RemoteSource) FetchPilots(eventSourceId string) (PilotsFile, error) {
+func (b boundRemoteSource) FetchPilots(eventSourceId string) (PilotsFile, error) {
 	var o PilotsFile
-	err := r.fetchJSON("/events/"+eventSourceId+"/Pilots.json", &o)
+	err := b.fetchJSON("/events/"+eventSourceId+"/Pilots.json", &o)
 	return o, err
 }
-func (r *RemoteSource) FetchChannels() (ChannelsFile, error) {
+func (b boundRemoteSource) FetchChannels() (ChannelsFile, error) {
 	var o ChannelsFile
-	err := r.fetchJSON("/httpfiles/Channels.json", &o)
+	err := b.fetchJSON("/httpfiles/Channels.json", &o)
 	return o, err
 }
-func (r *RemoteSource) FetchRounds(eventSourceId string) (RoundsFile, error) {
+func (b boundRemoteSource) FetchRounds(eventSourceId string) (RoundsFile, error) {
 	var o RoundsFile
-	err := r.fetchJSON("/events/"+eventSourceId+"/Rounds.json", &o)
+	err := b.fetchJSON("/events/"+eventSourceId+"/Rounds.json", &o)
 	return o, err
 }
-func (r *RemoteSource) FetchRace(eventSourceId, raceId string) (RaceFile, error) {
+func (b boundRemoteSource) FetchRace(eventSourceId, raceId string) (RaceFile, error) {
 	var o RaceFile
-	err := r.fetchJSON("/events/"+eventSourceId+"/"+raceId+"/Race.json", &o)
+	err := b.fetchJSON("/events/"+eventSourceId+"/"+raceId+"/Race.json", &o)
 	return o, err
 }
-func (r *RemoteSource) FetchResults(eventSourceId string) (ResultsFile, error) {
+func (b boundRemoteSource) FetchResults(eventSourceId string) (ResultsFile, error) {
 	var out ResultsFile
 	path := "/events/" + eventSourceId + "/Results.json"
-	ctx, cancel := context.WithTimeout(context.Background(), cloudFetchTimeout)
-	defer cancel()
-	ctx, traceID := control.EnsureTraceID(ctx)
-	var ifNone string
-	r.cacheMu.RLock()
-	if c, ok := r.cache[path]; ok {
-		ifNone = c.etag
-	}
-	r.cacheMu.RUnlock()
-	resp, err := r.Hub.DoFetch(ctx, r.PitsID, control.Fetch{Method: http.MethodGet, Path: path, IfNoneMatch: ifNone, TimeoutMs: pitsHTTPTimeoutMs, TraceID: traceID})
+	body, etag, err := b.fetchBody(path)
 	if err != nil {
 		return out, err
 	}
-	status, hdrs, body := control.DecodeResponse(resp)
-	if status == http.StatusNotModified {
-		r.cacheMu.RLock()
-		c, ok := r.cache[path]
-		r.cacheMu.RUnlock()
-		if ok {
-			body = c.body
-		} else {
-			return out, fmt.Errorf("304 but no cache for %s", path)
-		}
-	}
-	etag := hdrs["ETag"]
 	// Special-case: Results.json is often 0 bytes; treat as empty results
 	if len(strings.TrimSpace(string(body))) == 0 {
-		if etag != "" {
-			r.cacheMu.Lock()
-			r.cache[path] = cached{etag: etag, body: body}
-			r.cacheMu.Unlock()
-		}
+		b.r.remember(path, etag, body)
+		b.r.observe(path, body)
 		return ResultsFile{}, nil
 	}
 	if err := json.Unmarshal(body, &out); err != nil {
 		return out, err
 	}
-	if etag != "" {
-		r.cacheMu.Lock()
-		r.cache[path] = cached{etag: etag, body: body}
-		r.cacheMu.Unlock()
-	}
+	b.r.remember(path, etag, body)
+	b.r.observe(path, body)
 	return out, nil
 }
-func (r *RemoteSource) FetchEventSourceId() (string, error) {
+func (b boundRemoteSource) FetchEventSourceId() (string, error) {
 	// Fetch root page and scrape event id, mirroring FPVClient behavior
-	ctx, cancel := context.WithTimeout(context.Background(), cloudFetchTimeout)
+	cloudTimeout, pitsTimeoutMs := fetchTimeouts("/")
+	ctx, cancel := context.WithTimeout(b.ctx, cloudTimeout)
 	defer cancel()
 	ctx, traceID := control.EnsureTraceID(ctx)
-	resp, err := r.Hub.DoFetch(ctx, r.PitsID, control.Fetch{Method: http.MethodGet, Path: "/", TimeoutMs: pitsHTTPTimeoutMs, TraceID: traceID})
+	resp, err := b.r.Hub.DoFetch(ctx, b.r.PitsID, control.Fetch{Method: http.MethodGet, Path: "/", TimeoutMs: pitsTimeoutMs, TraceID: traceID})
 	if err != nil {
 		return "", err
 	}
-	_, _, body := control.DecodeResponse(resp)
+	status, _, body := control.DecodeResponse(resp)
+	if status >= 200 && status < 300 {
+		b.r.observe("/", body)
+	}
 	text := string(body)
-	match := regexp.MustCompile(`var eventManager = new EventManager\("events\/([a-z0-9-]+)"`).FindStringSubmatch(text)
+	match := eventManagerPattern.FindStringSubmatch(text)
 	if match != nil && len(match) > 1 {
 		return match[1], nil
 	}
@@ -9,6 +9,7 @@ import (
 	"github.com/pocketbase/pocketbase/core"
 )
 
+// sourceName is the source of records when Upserter.Source is empty.
 const sourceName = "fpvtrackside"
 
 // EntityNotFoundError indicates that an expected record was not present in PocketBase.
@@ -31,22 +32,44 @@ func IsEntityNotFound(err error) bool {
 // Note: This is a minimal skeleton; concrete calls will be filled in Phase 3.
 type Upserter struct {
 	App core.App
+	// Source is the timing system records are stamped with; empty means
+	// FPVTrackside.
+	Source string
 }
 
 func NewUpserter(app core.App) *Upserter { return &Upserter{App: app} }
 
+func (u *Upserter) source() string {
+	if u.Source == "" {
+		return sourceName
+	}
+	return u.Source
+}
+
+// withApp returns an Upserter for the same source writing through app, e.g.
+// a transaction.
+func (u *Upserter) withApp(app core.App) *Upserter {
+	return &Upserter{App: app, Source: u.Source}
+}
+
 // findExistingId returns the PB id for a given (source, sourceId) if it exists
 func (u *Upserter) findExistingId(collection string, sourceId string) (string, error) {
 	rec, err := u.App.FindFirstRecordByFilter(collection, "source = {:source} && sourceId = {:sourceId}", dbx.Params{
-		"source":   sourceName,
+		"source":   u.source(),
 		"sourceId": sourceId,
 	})
 	if err == nil && rec != nil {
 		return rec.Id, nil
 	}
-	// Fallback: try by sourceId only to recover older rows
-	rec2, err2 := u.App.FindFirstRecordByFilter(collection, "sourceId = {:sourceId}", dbx.Params{
+	// Fallback: try by sourceId only to recover older rows; manual rows are
+	// never adopted by a timing system or the other way around
+	filter := "sourceId = {:sourceId} && source != {:manual}"
+	if u.source() == ManualSourceName {
+		filter = "sourceId = {:sourceId} && source = ''"
+	}
+	rec2, err2 := u.App.FindFirstRecordByFilter(collection, filter, dbx.Params{
 		"sourceId": sourceId,
+		"manual":   ManualSourceName,
 	})
 	if err2 == nil && rec2 != nil {
 		return rec2.Id, nil
@@ -68,14 +91,20 @@ func (u *Upserter) GetExistingId(collection string, sourceId string) (string, er
 
 // Upsert creates or updates a record by (source, sourceId)
 func (u *Upserter) Upsert(collection string, sourceId string, fields map[string]any) (string, error) {
+	id, _, err := u.upsert(collection, sourceId, fields)
+	return id, err
+}
+
+// upsert is Upsert that also reports whether the record was written.
+func (u *Upserter) upsert(collection string, sourceId string, fields map[string]any) (string, bool, error) {
 	col, err := u.App.FindCollectionByNameOrId(collection)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	existingId, err := u.findExistingId(collection, sourceId)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	var record *core.Record
@@ -83,7 +112,7 @@ func (u *Upserter) Upsert(collection string, sourceId string, fields map[string]
 	if existingId != "" {
 		record, err = u.App.FindRecordById(col, existingId)
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
 		isNewRecord = false
 	} else {
@@ -95,7 +124,7 @@ func (u *Upserter) Upsert(collection string, sourceId string, fields map[string]
 	hasChanges := false
 	if !isNewRecord {
 		// Always check source and sourceId fields
-		if record.GetString("source") != sourceName {
+		if record.GetString("source") != u.source() {
 			hasChanges = true
 		}
 		if record.GetString("sourceId") != sourceId {
@@ -118,18 +147,18 @@ func (u *Upserter) Upsert(collection string, sourceId string, fields map[string]
 	// Only save if there are changes or it's a new record
 	if hasChanges {
 		// Set source + sourceId to align with the composite unique index
-		record.Set("source", sourceName)
+		record.Set("source", u.source())
 		record.Set("sourceId", sourceId)
 		for k, v := range fields {
 			record.Set(k, v)
 		}
 
 		if err := u.App.Save(record); err != nil {
-			return "", err
+			return "", false, err
 		}
 	}
 
-	return record.Id, nil
+	return record.Id, hasChanges, nil
 }
 
 // valuesEqual compares two values, handling type conversions for common numeric types
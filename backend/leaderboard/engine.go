@@ -0,0 +1,103 @@
+package leaderboard
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"drone-dashboard/derived"
+	"drone-dashboard/ingest"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DefaultDelay coalesces the commits of a live race, which the scheduler
+// re-ingests every few hundred milliseconds.
+const DefaultDelay = 500 * time.Millisecond
+
+// Engine republishes leaderboards after race ingests commit. Each commit
+// queues its event; Delay after the first every queued event is published.
+type Engine struct {
+	*derived.Queue
+	App core.App
+}
+
+// Register binds an Engine to ingest's race commits. Edits to an event (e.g.
+// its pbLaps) and to the leaderboard.* settings republish too, and a database
+// without leaderboard rows yet is backfilled at startup.
+func Register(app core.App) *Engine {
+	eng := &Engine{App: app}
+	eng.Queue = derived.NewQueue(app, DefaultDelay, eng.publishQueued)
+	eng.Backfill(app, "leaderboard", collectionName, func() (int, error) { return eng.PublishAll(), nil })
+	ingest.OnRaceCommitted(app).BindFunc(func(e *ingest.RaceCommitEvent) error {
+		eng.Mark(e.EventID)
+		return e.Next()
+	})
+	app.OnRecordAfterUpdateSuccess("events").BindFunc(func(e *core.RecordEvent) error {
+		eng.Mark(e.Record.Id)
+		return e.Next()
+	})
+	settingChanged := func(e *core.RecordEvent) error {
+		if strings.HasPrefix(e.Record.GetString("key"), "leaderboard.") {
+			eng.markPublished()
+		}
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("server_settings").BindFunc(settingChanged)
+	app.OnRecordAfterUpdateSuccess("server_settings").BindFunc(settingChanged)
+	app.OnRecordAfterDeleteSuccess("server_settings").BindFunc(settingChanged)
+	return eng
+}
+
+// markPublished queues every event that has a leaderboard or is current.
+func (eng *Engine) markPublished() {
+	var rows []struct {
+		Event string `db:"event"`
+	}
+	if err := eng.App.DB().NewQuery(`
+        SELECT DISTINCT event FROM leaderboard_entries
+        UNION SELECT id AS event FROM events WHERE isCurrent = 1
+    `).All(&rows); err != nil {
+		slog.Warn("leaderboard.markPublished.error", "err", err)
+		return
+	}
+	for _, r := range rows {
+		eng.Mark(r.Event)
+	}
+}
+
+func (eng *Engine) publishQueued(events map[string]struct{}) {
+	for ev := range events {
+		eng.publish(ev)
+	}
+}
+
+// PublishAll publishes every stored event and returns how many succeeded.
+func (eng *Engine) PublishAll() int {
+	events, err := eng.App.FindAllRecords("events")
+	if err != nil {
+		slog.Warn("leaderboard.publishAll.error", "err", err)
+		return 0
+	}
+	n := 0
+	for _, ev := range events {
+		if eng.publish(ev.Id) {
+			n++
+		}
+	}
+	return n
+}
+
+func (eng *Engine) publish(eventID string) bool {
+	if _, err := eng.App.FindRecordById("events", eventID); err != nil {
+		// deleted event: the cascade took its rows
+		return false
+	}
+	started := time.Now()
+	if _, err := Publish(eng.App, eventID); err != nil {
+		slog.Warn("leaderboard.publish.error", "event", eventID, "err", err)
+		return false
+	}
+	slog.Debug("leaderboard.publish.done", "event", eventID, "ms", time.Since(started).Milliseconds())
+	return true
+}
@@ -0,0 +1,48 @@
+package leaderboard
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterRoutes wires GET /leaderboard/{eventId}, public like the
+// leaderboard_entries collection, which clients can also subscribe to for
+// live updates, and the admin-only POST /leaderboard/{eventId}/refresh that
+// republishes an event right away.
+func RegisterRoutes(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/leaderboard/{eventId}", func(c *core.RequestEvent) error {
+			eventID := c.Request.PathValue("eventId")
+			event, err := c.App.FindRecordById("events", eventID)
+			if err != nil {
+				return c.NotFoundError("event not found", err)
+			}
+			entries, err := Stored(c.App, eventID)
+			if err != nil {
+				return c.InternalServerError("read leaderboard failed", err)
+			}
+			return c.JSON(http.StatusOK, map[string]any{"event": eventID, "config": LoadConfig(c.App, event), "entries": entries})
+		})
+
+		se.Router.POST("/leaderboard/{eventId}/refresh", func(c *core.RequestEvent) error {
+			info, err := c.RequestInfo()
+			if err != nil || info.Auth == nil || !info.Auth.IsSuperuser() {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			eventID := c.Request.PathValue("eventId")
+			if _, err := c.App.FindRecordById("events", eventID); err != nil {
+				return c.NotFoundError("event not found", err)
+			}
+			cfg, err := Publish(c.App, eventID)
+			if err != nil {
+				return c.InternalServerError("publish failed", err)
+			}
+			slog.Info("leaderboard.refresh", "event", eventID)
+			return c.JSON(http.StatusOK, map[string]any{"ok": true, "config": cfg})
+		})
+
+		return se.Next()
+	})
+}
@@ -0,0 +1,462 @@
+// Package leaderboard ranks the pilots of an event server-side and publishes
+// the rows in the leaderboard_entries collection, so overlays and viewers get
+// the same standings without running the dashboard's lap maths themselves.
+package leaderboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"drone-dashboard/derived"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const collectionName = "leaderboard_entries"
+
+// Metric names, as used in MetricsKey and in an entry's ranks.
+const (
+	BestLap     = "bestLap"
+	Consecutive = "consecutive"
+	Holeshot    = "holeshot"
+	TotalRace   = "totalRace"
+	Laps        = "laps"
+	Races       = "races"
+)
+
+// AllMetrics are the metrics computed for every entry.
+var AllMetrics = []string{BestLap, Consecutive, Holeshot, TotalRace, Laps, Races}
+
+// DefaultRankBy ranks like the dashboard: best consecutive laps first, then
+// best lap for pilots who never flew enough laps in one race.
+var DefaultRankBy = []string{Consecutive, BestLap}
+
+// server_settings keys read on every computation.
+const (
+	// MetricsKey is a comma-separated list of metrics ranking the event,
+	// most significant first. Unknown names are ignored.
+	MetricsKey = "leaderboard.metrics"
+	// ConsecutiveLapsKey sets the consecutive window: "pbLaps" (default)
+	// takes the event's pbLaps, "laps" its laps, a number fixes it.
+	ConsecutiveLapsKey = "leaderboard.consecutiveLaps"
+)
+
+// defaultConsecutiveLaps is used when the event does not say.
+const defaultConsecutiveLaps = 3
+
+// Config is what an event is ranked by.
+type Config struct {
+	RankBy          []string `json:"rankBy"`
+	ConsecutiveLaps int      `json:"consecutiveLaps"`
+}
+
+// Entry is one pilot's row. Times are in seconds and 0 when the pilot has
+// none; the race fields name the race each time was set in.
+type Entry struct {
+	Pilot           string  `json:"pilot"`
+	Name            string  `json:"name"`
+	Position        int     `json:"position"`
+	BestLap         float64 `json:"bestLap"`
+	BestLapRace     string  `json:"bestLapRace"`
+	Consecutive     float64 `json:"consecutive"`
+	ConsecutiveLaps int     `json:"consecutiveLaps"`
+	ConsecutiveRace string  `json:"consecutiveRace"`
+	Holeshot        float64 `json:"holeshot"`
+	HoleshotRace    string  `json:"holeshotRace"`
+	TotalRace       float64 `json:"totalRace"`
+	TotalRaceRace   string  `json:"totalRaceRace"`
+	Laps            int     `json:"laps"`
+	Races           int     `json:"races"`
+	// Ranks holds the pilot's standing per metric, ties sharing a rank.
+	// Metrics the pilot has no value for are left out.
+	Ranks map[string]int `json:"ranks"`
+}
+
+func setting(app core.App, key string) string {
+	rec, err := app.FindFirstRecordByData("server_settings", "key", key)
+	if err != nil || rec == nil {
+		return ""
+	}
+	return strings.TrimSpace(rec.GetString("value"))
+}
+
+// LoadConfig reads the ranking settings for an event.
+func LoadConfig(app core.App, event *core.Record) Config {
+	cfg := Config{}
+	for _, name := range strings.Split(setting(app, MetricsKey), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known(name) {
+			slog.Warn("leaderboard.config.unknownMetric", "metric", name)
+			continue
+		}
+		cfg.RankBy = append(cfg.RankBy, name)
+	}
+	if len(cfg.RankBy) == 0 {
+		cfg.RankBy = DefaultRankBy
+	}
+
+	pbLaps, laps := event.GetInt("pbLaps"), event.GetInt("laps")
+	switch v := setting(app, ConsecutiveLapsKey); v {
+	case "", "pbLaps":
+		cfg.ConsecutiveLaps = firstPositive(pbLaps, laps, defaultConsecutiveLaps)
+	case "laps":
+		cfg.ConsecutiveLaps = firstPositive(laps, defaultConsecutiveLaps)
+	default:
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			slog.Warn("leaderboard.config.invalidConsecutiveLaps", "value", v)
+			n = firstPositive(pbLaps, laps, defaultConsecutiveLaps)
+		}
+		cfg.ConsecutiveLaps = n
+	}
+	return cfg
+}
+
+func known(metric string) bool {
+	for _, m := range AllMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+func firstPositive(vs ...int) int {
+	for _, v := range vs {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+type pilotRow struct {
+	ID   string `db:"id"`
+	Name string `db:"name"`
+}
+
+type lapRow struct {
+	Pilot      string  `db:"pilot"`
+	Race       string  `db:"race"`
+	TargetLaps int     `db:"targetLaps"`
+	IsHoleshot bool    `db:"isHoleshot"`
+	Seconds    float64 `db:"seconds"`
+}
+
+// raceLaps are a pilot's laps in one race.
+type raceLaps struct {
+	race       string
+	targetLaps int
+	holeshot   float64
+	laps       []float64
+}
+
+// Compute ranks the pilots of an event, by its PocketBase ID. Laps count as
+// on the dashboard: valid detections in valid races, holeshots kept apart.
+// Pilots of the event without a lap yet are listed last, by name.
+func Compute(app core.App, eventID string) (Config, []Entry, error) {
+	event, err := app.FindRecordById("events", eventID)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	cfg := LoadConfig(app, event)
+	params := dbx.Params{"e": eventID}
+
+	var pilots []pilotRow
+	if err := app.DB().NewQuery(`
+        SELECT p.id AS id, p.name AS name FROM pilots p
+        WHERE p.id IN (
+            SELECT pilot FROM event_pilots WHERE event = {:e} AND (removed IS NULL OR removed = 0)
+            UNION SELECT pc.pilot FROM pilotChannels pc JOIN races r ON r.id = pc.race
+            WHERE pc.event = {:e} AND r.valid = 1
+        )
+    `).Bind(params).All(&pilots); err != nil {
+		return cfg, nil, fmt.Errorf("pilots of %s: %w", eventID, err)
+	}
+
+	var flown []struct {
+		Pilot string `db:"pilot"`
+		Races int    `db:"races"`
+	}
+	if err := app.DB().NewQuery(`
+        SELECT pc.pilot AS pilot, COUNT(DISTINCT r.id) AS races
+        FROM pilotChannels pc JOIN races r ON r.id = pc.race
+        WHERE pc.event = {:e} AND r.valid = 1 AND r.startEpoch > 0
+        GROUP BY pc.pilot
+    `).Bind(params).All(&flown); err != nil {
+		return cfg, nil, fmt.Errorf("races of %s: %w", eventID, err)
+	}
+
+	var laps []lapRow
+	if err := app.DB().NewQuery(`
+        SELECT d.pilot AS pilot, l.race AS race, r.targetLaps AS targetLaps,
+               d.isHoleshot AS isHoleshot, l.lengthSeconds AS seconds
+        FROM laps l
+        JOIN detections d ON d.id = l.detection
+        JOIN races r ON r.id = l.race
+        WHERE l.event = {:e} AND d.valid = 1 AND r.valid = 1 AND l.lengthSeconds > 0
+        ORDER BY r.raceOrder, l.race, d.pilot, l.lapNumber
+    `).Bind(params).All(&laps); err != nil {
+		return cfg, nil, fmt.Errorf("laps of %s: %w", eventID, err)
+	}
+
+	byPilot := map[string][]*raceLaps{}
+	for _, l := range laps {
+		races := byPilot[l.Pilot]
+		if len(races) == 0 || races[len(races)-1].race != l.Race {
+			races = append(races, &raceLaps{race: l.Race, targetLaps: l.TargetLaps})
+			byPilot[l.Pilot] = races
+		}
+		rl := races[len(races)-1]
+		if l.IsHoleshot {
+			if rl.holeshot == 0 {
+				rl.holeshot = l.Seconds
+			}
+			continue
+		}
+		rl.laps = append(rl.laps, l.Seconds)
+	}
+	racesOf := map[string]int{}
+	for _, f := range flown {
+		racesOf[f.Pilot] = f.Races
+	}
+
+	entries := make([]Entry, 0, len(pilots))
+	for _, p := range pilots {
+		e := Entry{Pilot: p.ID, Name: p.Name, ConsecutiveLaps: cfg.ConsecutiveLaps, Races: racesOf[p.ID]}
+		for _, rl := range byPilot[p.ID] {
+			e.fold(rl, cfg.ConsecutiveLaps)
+		}
+		e.BestLap, e.Consecutive = derived.Round3(e.BestLap), derived.Round3(e.Consecutive)
+		e.Holeshot, e.TotalRace = derived.Round3(e.Holeshot), derived.Round3(e.TotalRace)
+		entries = append(entries, e)
+	}
+	rank(entries, cfg.RankBy)
+	return cfg, entries, nil
+}
+
+// fold takes one race's laps into the entry, keeping the earliest race on ties.
+func (e *Entry) fold(rl *raceLaps, n int) {
+	for _, s := range rl.laps {
+		if e.BestLap == 0 || s < e.BestLap {
+			e.BestLap, e.BestLapRace = s, rl.race
+		}
+	}
+	e.Laps += len(rl.laps)
+	if c := derived.BestConsecutive(rl.laps, n); c > 0 && (e.Consecutive == 0 || c < e.Consecutive) {
+		e.Consecutive, e.ConsecutiveRace = c, rl.race
+	}
+	if rl.holeshot == 0 {
+		return
+	}
+	if e.Holeshot == 0 || rl.holeshot < e.Holeshot {
+		e.Holeshot, e.HoleshotRace = rl.holeshot, rl.race
+	}
+	if n := rl.targetLaps; n > 0 && len(rl.laps) >= n {
+		total := rl.holeshot
+		for _, s := range rl.laps[:n] {
+			total += s
+		}
+		if e.TotalRace == 0 || total < e.TotalRace {
+			e.TotalRace, e.TotalRaceRace = total, rl.race
+		}
+	}
+}
+
+// value returns an entry's metric and whether the pilot has one.
+func (e *Entry) value(metric string) (float64, bool) {
+	var v float64
+	switch metric {
+	case BestLap:
+		v = e.BestLap
+	case Consecutive:
+		v = e.Consecutive
+	case Holeshot:
+		v = e.Holeshot
+	case TotalRace:
+		v = e.TotalRace
+	case Laps:
+		v = float64(e.Laps)
+	case Races:
+		v = float64(e.Races)
+	}
+	return v, v > 0
+}
+
+// better reports whether a beats b on metric; counts go up, times go down.
+func better(metric string, a, b float64) bool {
+	if metric == Laps || metric == Races {
+		return a > b
+	}
+	return a < b
+}
+
+// rank fills Ranks and sorts entries by position. Pilots are grouped by the
+// first rankBy metric they have a value for, as the dashboard groups pilots
+// with and without a consecutive time; within a group that metric decides,
+// then the following ones, then the name.
+func rank(entries []Entry, rankBy []string) {
+	for _, metric := range AllMetrics {
+		idx := make([]int, 0, len(entries))
+		for i := range entries {
+			if _, ok := entries[i].value(metric); ok {
+				idx = append(idx, i)
+			}
+		}
+		sort.SliceStable(idx, func(a, b int) bool {
+			va, _ := entries[idx[a]].value(metric)
+			vb, _ := entries[idx[b]].value(metric)
+			return better(metric, va, vb)
+		})
+		for n, i := range idx {
+			if entries[i].Ranks == nil {
+				entries[i].Ranks = map[string]int{}
+			}
+			r := n + 1
+			if n > 0 {
+				prev := idx[n-1]
+				va, _ := entries[i].value(metric)
+				vp, _ := entries[prev].value(metric)
+				if va == vp {
+					r = entries[prev].Ranks[metric]
+				}
+			}
+			entries[i].Ranks[metric] = r
+		}
+	}
+
+	group := func(e *Entry) int {
+		for g, metric := range rankBy {
+			if _, ok := e.value(metric); ok {
+				return g
+			}
+		}
+		return len(rankBy)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := &entries[i], &entries[j]
+		ga, gb := group(a), group(b)
+		if ga != gb {
+			return ga < gb
+		}
+		for _, metric := range rankBy[ga:] {
+			va, oka := a.value(metric)
+			vb, okb := b.value(metric)
+			if oka != okb {
+				return oka
+			}
+			if va != vb {
+				return better(metric, va, vb)
+			}
+		}
+		return a.Name < b.Name
+	})
+	for i := range entries {
+		entries[i].Position = i + 1
+	}
+}
+
+// Publish recomputes an event's leaderboard and stores it in one transaction.
+// Rows are written only when they changed so subscribers are not woken for
+// nothing, and pilots no longer in the event lose theirs.
+func Publish(app core.App, eventID string) (Config, error) {
+	cfg, entries, err := Compute(app, eventID)
+	if err != nil {
+		return cfg, err
+	}
+	col, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, app.RunInTransaction(func(txApp core.App) error {
+		existing, err := txApp.FindAllRecords(collectionName, dbx.HashExp{"event": eventID})
+		if err != nil {
+			return err
+		}
+		byPilot := make(map[string]*core.Record, len(existing))
+		for _, rec := range existing {
+			byPilot[rec.GetString("pilot")] = rec
+		}
+		for _, e := range entries {
+			ranks, err := json.Marshal(e.Ranks)
+			if err != nil {
+				return err
+			}
+			fields := map[string]any{
+				"name":            e.Name,
+				"position":        e.Position,
+				"bestLap":         e.BestLap,
+				"bestLapRace":     e.BestLapRace,
+				"consecutive":     e.Consecutive,
+				"consecutiveLaps": e.ConsecutiveLaps,
+				"consecutiveRace": e.ConsecutiveRace,
+				"holeshot":        e.Holeshot,
+				"holeshotRace":    e.HoleshotRace,
+				"totalRace":       e.TotalRace,
+				"totalRaceRace":   e.TotalRaceRace,
+				"laps":            e.Laps,
+				"races":           e.Races,
+			}
+			rec := byPilot[e.Pilot]
+			delete(byPilot, e.Pilot)
+			if rec == nil {
+				rec = core.NewRecord(col)
+				rec.Set("event", eventID)
+				rec.Set("pilot", e.Pilot)
+			} else if derived.Unchanged(rec, fields, "ranks", ranks) {
+				continue
+			}
+			for k, v := range fields {
+				rec.Set(k, v)
+			}
+			rec.Set("ranks", string(ranks))
+			if err := txApp.Save(rec); err != nil {
+				return fmt.Errorf("save entry of %s: %w", e.Pilot, err)
+			}
+		}
+		for _, rec := range byPilot {
+			if err := txApp.Delete(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Stored returns an event's published leaderboard in position order.
+func Stored(app core.App, eventID string) ([]Entry, error) {
+	recs, err := app.FindRecordsByFilter(collectionName, "event = {:e}", "position", 0, 0, dbx.Params{"e": eventID})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Entry, 0, len(recs))
+	for _, rec := range recs {
+		e := Entry{
+			Pilot:           rec.GetString("pilot"),
+			Name:            rec.GetString("name"),
+			Position:        rec.GetInt("position"),
+			BestLap:         rec.GetFloat("bestLap"),
+			BestLapRace:     rec.GetString("bestLapRace"),
+			Consecutive:     rec.GetFloat("consecutive"),
+			ConsecutiveLaps: rec.GetInt("consecutiveLaps"),
+			ConsecutiveRace: rec.GetString("consecutiveRace"),
+			Holeshot:        rec.GetFloat("holeshot"),
+			HoleshotRace:    rec.GetString("holeshotRace"),
+			TotalRace:       rec.GetFloat("totalRace"),
+			TotalRaceRace:   rec.GetString("totalRaceRace"),
+			Laps:            rec.GetInt("laps"),
+			Races:           rec.GetInt("races"),
+		}
+		_ = rec.UnmarshalJSONField("ranks", &e.Ranks)
+		out = append(out, e)
+	}
+	return out, nil
+}
@@ -0,0 +1,107 @@
+package leaderboard
+
+import (
+	"testing"
+	"time"
+
+	"drone-dashboard/ingest"
+	"drone-dashboard/ingest/ingesttest"
+	_ "drone-dashboard/migrations"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func setSetting(t *testing.T, app core.App, key, value string) {
+	t.Helper()
+	col, err := app.FindCollectionByNameOrId("server_settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, _ := app.FindFirstRecordByData("server_settings", "key", key)
+	if rec == nil {
+		rec = core.NewRecord(col)
+		rec.Set("key", key)
+	}
+	rec.Set("value", value)
+	if err := app.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func names(entries []Entry) []string {
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.Name)
+	}
+	return out
+}
+
+func TestLeaderboardFollowsIngest(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+	eng := Register(app)
+	eng.Delay = time.Hour // flushed by hand below
+
+	fixture := ingesttest.NewEvent(t, app, ingest.ManualEvent{Name: "Club night", Laps: 3}, "Zippy", "Bolt", "Ace")
+	ev := fixture.Record
+	ev.Set("pbLaps", 2)
+	if err := app.Save(ev); err != nil {
+		t.Fatal(err)
+	}
+	raceId := fixture.Race(t, 3, 0, 1)
+	fixture.Fly(t, raceId, time.Date(2025, 6, 3, 19, 0, 0, 0, time.UTC),
+		ingesttest.Flight{Holeshot: 1, Laps: []float64{30, 29, 31}},
+		ingesttest.Flight{Holeshot: 2, Laps: []float64{28, 35}})
+	eng.Flush()
+
+	// pbLaps=2: Zippy's 29+30 beats Bolt's 28+35; Ace has not flown
+	entries, err := Stored(app, ev.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(entries); len(got) != 3 || got[0] != "Zippy" || got[1] != "Bolt" || got[2] != "Ace" {
+		t.Fatalf("order = %v", got)
+	}
+	zippy, bolt := entries[0], entries[1]
+	if zippy.Consecutive != 59 || zippy.ConsecutiveLaps != 2 || zippy.TotalRace != 91 || zippy.Holeshot != 1 || zippy.Laps != 3 || zippy.Races != 1 {
+		t.Fatalf("zippy = %+v", zippy)
+	}
+	if bolt.BestLap != 28 || bolt.TotalRace != 0 || bolt.Ranks[BestLap] != 1 || zippy.Ranks[BestLap] != 2 || bolt.Ranks[TotalRace] != 0 {
+		t.Fatalf("bolt = %+v, zippy ranks = %v", bolt, zippy.Ranks)
+	}
+	race := ingesttest.Find(t, app, "races", raceId)
+	if zippy.ConsecutiveRace != race.Id || bolt.BestLapRace != race.Id {
+		t.Fatalf("race links = %q, %q", zippy.ConsecutiveRace, bolt.BestLapRace)
+	}
+
+	// an unchanged publish does not rewrite the rows
+	before, _ := app.FindFirstRecordByData(collectionName, "name", "Zippy")
+	if _, err := Publish(app, ev.Id); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := app.FindFirstRecordByData(collectionName, "name", "Zippy")
+	if before.GetString("lastUpdated") != after.GetString("lastUpdated") {
+		t.Fatal("unchanged entry was saved again")
+	}
+
+	// ranking by best lap alone puts Bolt ahead
+	setSetting(t, app, MetricsKey, "bestLap, nonsense")
+	eng.Flush()
+	entries, _ = Stored(app, ev.Id)
+	if got := names(entries); got[0] != "Bolt" || got[1] != "Zippy" {
+		t.Fatalf("bestLap order = %v", got)
+	}
+
+	// a 3-lap window leaves Bolt without a consecutive time
+	setSetting(t, app, MetricsKey, "consecutive")
+	setSetting(t, app, ConsecutiveLapsKey, "laps")
+	eng.Flush()
+	entries, _ = Stored(app, ev.Id)
+	if got := names(entries); got[0] != "Zippy" || entries[0].Consecutive != 90 || entries[1].Consecutive != 0 {
+		t.Fatalf("3-lap entries = %+v", entries)
+	}
+}
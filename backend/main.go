@@ -5,12 +5,17 @@ import (
 	"log"
 	"log/slog"
 
+	"drone-dashboard/archive"
 	"drone-dashboard/bootstrap/config"
 	"drone-dashboard/bootstrap/mode"
 	"drone-dashboard/bootstrap/server"
+	"drone-dashboard/careers"
+	"drone-dashboard/fpvhttp"
 	"drone-dashboard/ingest"
+	"drone-dashboard/leaderboard"
 	"drone-dashboard/logger"
 	_ "drone-dashboard/migrations"
+	"drone-dashboard/tracing"
 )
 
 //go:embed static/*
@@ -30,6 +35,13 @@ func main() {
 
 	ingestService, manager := mode.Build(app, flags)
 	ingest.RegisterRoutes(app, ingestService)
+	archive.RegisterRoutes(app)
+	careers.Register(app)
+	careers.RegisterRoutes(app)
+	leaderboard.Register(app)
+	leaderboard.RegisterRoutes(app)
+	tracing.RegisterRoutes(app, tracing.Default)
+	fpvhttp.RegisterSettings(app)
 	manager.RegisterHooks()
 
 	server.RegisterServe(app, staticContent, ingestService, manager, flags)
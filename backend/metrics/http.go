@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Handler serves r in the text exposition format. A non-empty token must be
+// presented as "Authorization: Bearer <token>" or ?token=<token>.
+func Handler(r *Registry, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if token != "" && !authorized(req, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		if _, err := r.WriteTo(w); err != nil {
+			slog.Debug("metrics.write.error", "err", err)
+		}
+	})
+}
+
+func authorized(req *http.Request, token string) bool {
+	presented := req.URL.Query().Get("token")
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		presented = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
@@ -0,0 +1,281 @@
+// Package metrics renders the Prometheus text exposition format (0.0.4) for
+// the /metrics endpoint. It has no dependencies so any package can keep its own
+// counters and histograms and report them through a Collector.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the exposition format served by Handler.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Label is one name/value pair on a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// L builds a label.
+func L(name, value string) Label { return Label{Name: name, Value: value} }
+
+// Collector writes current values when /metrics is scraped.
+type Collector func(w *Writer)
+
+// Registry holds the collectors exposed on /metrics.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+// Default is the registry served by the backend's /metrics route.
+var Default = &Registry{}
+
+// Register adds a collector.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo runs every collector and writes the families in first-seen order.
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	r.mu.RLock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.RUnlock()
+	w := &Writer{families: map[string]*family{}}
+	for _, c := range collectors {
+		c(w)
+	}
+	var sb strings.Builder
+	for _, name := range w.order {
+		f := w.families[name]
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(f.help), name, f.kind)
+		for _, line := range f.lines {
+			sb.WriteString(line)
+		}
+	}
+	n, err := io.WriteString(out, sb.String())
+	return int64(n), err
+}
+
+type family struct {
+	kind  string
+	help  string
+	lines []string
+}
+
+// Writer buffers samples per metric family so collectors that report the same
+// family (one per pits, for instance) still produce contiguous output.
+type Writer struct {
+	families map[string]*family
+	order    []string
+}
+
+func (w *Writer) family(name, kind, help string) *family {
+	f, ok := w.families[name]
+	if !ok {
+		f = &family{kind: kind, help: help}
+		w.families[name] = f
+		w.order = append(w.order, name)
+	}
+	return f
+}
+
+// Gauge writes one gauge sample.
+func (w *Writer) Gauge(name, help string, v float64, labels ...Label) {
+	f := w.family(name, "gauge", help)
+	f.lines = append(f.lines, sample(name, labels, v))
+}
+
+// Counter writes one counter sample; name should end in _total.
+func (w *Writer) Counter(name, help string, v float64, labels ...Label) {
+	f := w.family(name, "counter", help)
+	f.lines = append(f.lines, sample(name, labels, v))
+}
+
+// Histogram writes the cumulative buckets, sum and count of s.
+func (w *Writer) Histogram(name, help string, s HistogramSnapshot, labels ...Label) {
+	f := w.family(name, "histogram", help)
+	var cum uint64
+	for i, bound := range s.Bounds {
+		cum += s.Counts[i]
+		f.lines = append(f.lines, sample(name+"_bucket", append(labels[:len(labels):len(labels)], L("le", formatFloat(bound))), float64(cum)))
+	}
+	f.lines = append(f.lines,
+		sample(name+"_bucket", append(labels[:len(labels):len(labels)], L("le", "+Inf")), float64(s.Count)),
+		sample(name+"_sum", labels, s.Sum),
+		sample(name+"_count", labels, float64(s.Count)),
+	)
+}
+
+func sample(name string, labels []Label, v float64) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	if len(labels) > 0 {
+		sb.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(l.Name)
+			sb.WriteString(`="`)
+			sb.WriteString(escapeLabel(l.Value))
+			sb.WriteByte('"')
+		}
+		sb.WriteByte('}')
+	}
+	sb.WriteByte(' ')
+	sb.WriteString(formatFloat(v))
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+// Histogram counts observations against fixed upper bounds.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// HistogramSnapshot is a point-in-time copy of a Histogram. Counts are per
+// bucket, not cumulative.
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64
+	Sum    float64
+	Count  uint64
+}
+
+// NewHistogram returns a histogram with the given sorted upper bounds.
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+// Snapshot copies the current state.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return HistogramSnapshot{Bounds: h.bounds, Counts: append([]uint64(nil), h.counts...), Sum: h.sum, Count: h.count}
+}
+
+// HistogramVec keeps one histogram per value of a single label.
+type HistogramVec struct {
+	mu     sync.Mutex
+	bounds []float64
+	byKey  map[string]*Histogram
+}
+
+// NewHistogramVec returns an empty vector using bounds for every child.
+func NewHistogramVec(bounds []float64) *HistogramVec {
+	return &HistogramVec{bounds: bounds, byKey: map[string]*Histogram{}}
+}
+
+// With returns the histogram for key, creating it on first use.
+func (v *HistogramVec) With(key string) *Histogram {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, ok := v.byKey[key]
+	if !ok {
+		h = NewHistogram(v.bounds)
+		v.byKey[key] = h
+	}
+	return h
+}
+
+// Each calls fn for every child in key order.
+func (v *HistogramVec) Each(fn func(key string, s HistogramSnapshot)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.byKey))
+	for k := range v.byKey {
+		keys = append(keys, k)
+	}
+	children := make(map[string]*Histogram, len(v.byKey))
+	for k, h := range v.byKey {
+		children[k] = h
+	}
+	v.mu.Unlock()
+	sort.Strings(keys)
+	for _, k := range keys {
+		fn(k, children[k].Snapshot())
+	}
+}
+
+// CounterVec keeps one monotonic counter per value of a single label.
+type CounterVec struct {
+	mu    sync.Mutex
+	byKey map[string]float64
+}
+
+// NewCounterVec returns an empty vector.
+func NewCounterVec() *CounterVec {
+	return &CounterVec{byKey: map[string]float64{}}
+}
+
+// Add increments the counter for key.
+func (v *CounterVec) Add(key string, delta float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.byKey[key] += delta
+}
+
+// Each calls fn for every counter in key order.
+func (v *CounterVec) Each(fn func(key string, value float64)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.byKey))
+	for k := range v.byKey {
+		keys = append(keys, k)
+	}
+	values := make(map[string]float64, len(v.byKey))
+	for k, val := range v.byKey {
+		values[k] = val
+	}
+	v.mu.Unlock()
+	sort.Strings(keys)
+	for _, k := range keys {
+		fn(k, values[k])
+	}
+}
+
+// DurationBounds are the default latency bounds in seconds.
+var DurationBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
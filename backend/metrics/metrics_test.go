@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryGroupsFamilies(t *testing.T) {
+	reg := &Registry{}
+	h := NewHistogram([]float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+	reg.Register(func(w *Writer) {
+		w.Gauge("queue_depth", "Queue depth.", 2, L("pits", "north"))
+		w.Histogram("wait_seconds", "Wait.", h.Snapshot())
+	})
+	reg.Register(func(w *Writer) {
+		w.Gauge("queue_depth", "Queue depth.", 1, L("pits", `so"uth`))
+	})
+
+	var out strings.Builder
+	if _, err := reg.WriteTo(&out); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	want := `# HELP queue_depth Queue depth.
+# TYPE queue_depth gauge
+queue_depth{pits="north"} 2
+queue_depth{pits="so\"uth"} 1
+# HELP wait_seconds Wait.
+# TYPE wait_seconds histogram
+wait_seconds_bucket{le="0.1"} 1
+wait_seconds_bucket{le="1"} 2
+wait_seconds_bucket{le="+Inf"} 3
+wait_seconds_sum 5.55
+wait_seconds_count 3
+`
+	if out.String() != want {
+		t.Fatalf("unexpected exposition:\n%s", out.String())
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	reg := &Registry{}
+	reg.Register(func(w *Writer) { w.Counter("hits_total", "Hits.", 1) })
+	handler := Handler(reg, "s3cret")
+
+	for _, tc := range []struct {
+		name   string
+		target string
+		auth   string
+		status int
+	}{
+		{"missing", "/metrics", "", http.StatusUnauthorized},
+		{"wrong", "/metrics", "Bearer nope", http.StatusUnauthorized},
+		{"header", "/metrics", "Bearer s3cret", http.StatusOK},
+		{"query", "/metrics?token=s3cret", "", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: status %d want %d", tc.name, rec.Code, tc.status)
+		}
+		if tc.status == http.StatusOK && !strings.Contains(rec.Body.String(), "hits_total 1") {
+			t.Fatalf("%s: body %q", tc.name, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Adds events.pitsId and ingest_targets.pitsId so a cloud fed by several pits
+// can keep a current event and scheduler queue per venue.
+func init() {
+	m.Register(func(app core.App) error {
+		for _, name := range []string{"events", "ingest_targets"} {
+			col, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			if col.Fields.GetByName("pitsId") == nil {
+				col.Fields.Add(&core.TextField{Name: "pitsId", Max: 64})
+			}
+			if err := app.Save(col); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		for _, name := range []string{"ingest_targets", "events"} {
+			col, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			col.Fields.RemoveByName("pitsId")
+			if err := app.Save(col); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
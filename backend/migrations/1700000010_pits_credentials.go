@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Per-pits control link credentials. Only a SHA-256 hash of each token is
+// stored; rules stay nil so the collection is superuser-only.
+func init() {
+	m.Register(func(app core.App) error {
+		col := core.NewBaseCollection("pits_credentials")
+		col.Fields.Add(
+			&core.TextField{Name: "pitsId", Required: true, Max: 64, Presentable: true},
+			&core.TextField{Name: "tokenHash", Required: true, Max: 128, Hidden: true},
+			&core.TextField{Name: "tokenPrefix", Max: 16},
+			&core.TextField{Name: "label", Max: 255},
+			&core.DateField{Name: "expiresAt"},
+			&core.BoolField{Name: "revoked"},
+			&core.DateField{Name: "lastUsedAt"},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		col.AddIndex("ux_pits_credentials_tokenHash", true, "tokenHash", "")
+		col.AddIndex("idx_pits_credentials_pitsId", false, "pitsId", "")
+		return app.Save(col)
+	}, func(app core.App) error {
+		_ = app.DeleteTable("pits_credentials")
+		return nil
+	})
+}
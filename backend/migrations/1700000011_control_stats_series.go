@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Windowed control fetch telemetry: one row per bucket, window size (1m, 5m,
+// 1h) and window start, rewritten while the window is open.
+func init() {
+	m.Register(func(app core.App) error {
+		col := core.NewBaseCollection("control_stats_series")
+		col.Fields.Add(
+			&core.TextField{Name: "bucket", Required: true, Max: 64, Presentable: true},
+			&core.TextField{Name: "window", Required: true, Max: 8},
+			&core.NumberField{Name: "windowStart", Required: true},
+			&core.NumberField{Name: "count"},
+			&core.NumberField{Name: "fullResponses"},
+			&core.NumberField{Name: "etagHits"},
+			&core.NumberField{Name: "errors"},
+			&core.NumberField{Name: "latencyP50"},
+			&core.NumberField{Name: "latencyP95"},
+			&core.NumberField{Name: "latencyP99"},
+			&core.NumberField{Name: "latencyMax"},
+			&core.NumberField{Name: "bytesTotal"},
+			&core.NumberField{Name: "bytesP50"},
+			&core.NumberField{Name: "bytesP95"},
+			&core.NumberField{Name: "bytesP99"},
+			&core.JSONField{Name: "latencyHist"},
+			&core.JSONField{Name: "bytesHist"},
+		)
+		col.AddIndex("ux_control_stats_series_key", true, "bucket, window, windowStart", "")
+		col.AddIndex("idx_control_stats_series_window", false, "window, windowStart", "")
+		col.ListRule = types.Pointer("")
+		col.ViewRule = types.Pointer("")
+		return app.Save(col)
+	}, func(app core.App) error {
+		_ = app.DeleteTable("control_stats_series")
+		return nil
+	})
+}
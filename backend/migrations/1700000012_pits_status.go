@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Control link presence per pits, written by the cloud hub. Viewers subscribe
+// to it for the "venue link down" banner; remoteAddr stays hidden.
+func init() {
+	m.Register(func(app core.App) error {
+		col := core.NewBaseCollection("pits_status")
+		col.Fields.Add(
+			&core.TextField{Name: "pitsId", Required: true, Max: 64, Presentable: true},
+			&core.BoolField{Name: "connected"},
+			&core.DateField{Name: "connectedAt"},
+			&core.DateField{Name: "disconnectedAt"},
+			&core.DateField{Name: "lastFrameAt"},
+			&core.NumberField{Name: "rttMs"},
+			&core.TextField{Name: "swVersion", Max: 64},
+			&core.TextField{Name: "protocol", Max: 16},
+			&core.TextField{Name: "remoteAddr", Max: 128, Hidden: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		col.AddIndex("ux_pits_status_pitsId", true, "pitsId", "")
+		col.ListRule = types.Pointer("")
+		col.ViewRule = types.Pointer("")
+		return app.Save(col)
+	}, func(app core.App) error {
+		_ = app.DeleteTable("pits_status")
+		return nil
+	})
+}
@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// venueEpochFields lists the UTC epoch (ms) companions ingest writes next to
+// FPVTrackside's venue-local timestamp strings, corrected for the venue clock.
+var venueEpochFields = map[string][]string{
+	"races":      {"startEpoch", "endEpoch"},
+	"laps":       {"startEpoch", "endEpoch"},
+	"detections": {"timeEpoch"},
+	"gamePoints": {"timeEpoch"},
+}
+
+// venueClockFields publish the venue clock correction itself.
+var venueClockFields = map[string][]string{
+	"pits_status": {"clockOffsetMs"},
+}
+
+// venueClockMigrationFields are all number fields this migration adds.
+func venueClockMigrationFields() map[string][]string {
+	all := make(map[string][]string, len(venueEpochFields)+len(venueClockFields))
+	for name, fields := range venueEpochFields {
+		all[name] = fields
+	}
+	for name, fields := range venueClockFields {
+		all[name] = append(all[name], fields...)
+	}
+	return all
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		for name, fields := range venueClockMigrationFields() {
+			col, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			for _, field := range fields {
+				if col.Fields.GetByName(field) == nil {
+					col.Fields.Add(&core.NumberField{Name: field})
+				}
+			}
+			if err := app.Save(col); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		for name, fields := range venueClockMigrationFields() {
+			col, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			for _, field := range fields {
+				col.Fields.RemoveByName(field)
+			}
+			if err := app.Save(col); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
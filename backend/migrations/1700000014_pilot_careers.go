@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Career stats per pilot across every stored event, rebuilt by the careers
+// package after races are ingested. Viewers read and subscribe to it.
+func init() {
+	m.Register(func(app core.App) error {
+		pilots, err := app.FindCollectionByNameOrId("pilots")
+		if err != nil {
+			return err
+		}
+		events, err := app.FindCollectionByNameOrId("events")
+		if err != nil {
+			return err
+		}
+		races, err := app.FindCollectionByNameOrId("races")
+		if err != nil {
+			return err
+		}
+		col := core.NewBaseCollection("pilot_careers")
+		col.Fields.Add(
+			&core.RelationField{Name: "pilot", CollectionId: pilots.Id, MaxSelect: 1, Required: true, CascadeDelete: true},
+			&core.TextField{Name: "name", Max: 255, Presentable: true},
+			&core.NumberField{Name: "bestLapSeconds"},
+			&core.RelationField{Name: "bestLapRace", CollectionId: races.Id, MaxSelect: 1},
+			&core.NumberField{Name: "bestConsecutiveSeconds"},
+			&core.NumberField{Name: "consecutiveLaps"},
+			&core.NumberField{Name: "racesFlown"},
+			&core.NumberField{Name: "eventsFlown"},
+			&core.NumberField{Name: "laps"},
+			&core.NumberField{Name: "wins"},
+			&core.NumberField{Name: "podiums"},
+			&core.NumberField{Name: "averagePosition"},
+			&core.RelationField{Name: "lastEvent", CollectionId: events.Id, MaxSelect: 1},
+			&core.JSONField{Name: "trend"},
+			&core.AutodateField{Name: "lastUpdated", OnCreate: true, OnUpdate: true},
+		)
+		col.AddIndex("ux_pilot_careers_pilot", true, "pilot", "")
+		col.ListRule = types.Pointer("")
+		col.ViewRule = types.Pointer("")
+		return app.Save(col)
+	}, func(app core.App) error {
+		_ = app.DeleteTable("pilot_careers")
+		return nil
+	})
+}
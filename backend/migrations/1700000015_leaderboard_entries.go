@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Ranked leaderboard rows per event, rebuilt by the leaderboard package after
+// each race ingest commits. Viewers and overlays read and subscribe to it.
+func init() {
+	m.Register(func(app core.App) error {
+		pilots, err := app.FindCollectionByNameOrId("pilots")
+		if err != nil {
+			return err
+		}
+		events, err := app.FindCollectionByNameOrId("events")
+		if err != nil {
+			return err
+		}
+		races, err := app.FindCollectionByNameOrId("races")
+		if err != nil {
+			return err
+		}
+		col := core.NewBaseCollection("leaderboard_entries")
+		col.Fields.Add(
+			&core.RelationField{Name: "event", CollectionId: events.Id, MaxSelect: 1, Required: true, CascadeDelete: true},
+			&core.RelationField{Name: "pilot", CollectionId: pilots.Id, MaxSelect: 1, Required: true, CascadeDelete: true},
+			&core.TextField{Name: "name", Max: 255, Presentable: true},
+			&core.NumberField{Name: "position"},
+			&core.NumberField{Name: "bestLap"},
+			&core.RelationField{Name: "bestLapRace", CollectionId: races.Id, MaxSelect: 1},
+			&core.NumberField{Name: "consecutive"},
+			&core.NumberField{Name: "consecutiveLaps"},
+			&core.RelationField{Name: "consecutiveRace", CollectionId: races.Id, MaxSelect: 1},
+			&core.NumberField{Name: "holeshot"},
+			&core.RelationField{Name: "holeshotRace", CollectionId: races.Id, MaxSelect: 1},
+			&core.NumberField{Name: "totalRace"},
+			&core.RelationField{Name: "totalRaceRace", CollectionId: races.Id, MaxSelect: 1},
+			&core.NumberField{Name: "laps"},
+			&core.NumberField{Name: "races"},
+			&core.JSONField{Name: "ranks"},
+			&core.AutodateField{Name: "lastUpdated", OnCreate: true, OnUpdate: true},
+		)
+		col.AddIndex("ux_leaderboard_entries_event_pilot", true, "event, pilot", "")
+		col.ListRule = types.Pointer("")
+		col.ViewRule = types.Pointer("")
+		return app.Save(col)
+	}, func(app core.App) error {
+		_ = app.DeleteTable("leaderboard_entries")
+		return nil
+	})
+}
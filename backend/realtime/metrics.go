@@ -0,0 +1,39 @@
+package realtime
+
+import (
+	"sort"
+	"strings"
+
+	"drone-dashboard/metrics"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Collector reports realtime (SSE) clients and their subscriptions per topic.
+// Record topics such as "races/abc?options=..." are counted under "races".
+func Collector(app core.App) metrics.Collector {
+	return func(w *metrics.Writer) {
+		broker := app.SubscriptionsBroker()
+		w.Gauge("realtime_clients", "Connected realtime clients.", float64(broker.TotalClients()))
+		byTopic := map[string]int{}
+		for _, client := range broker.Clients() {
+			for sub := range client.Subscriptions() {
+				byTopic[subscriptionTopic(sub)]++
+			}
+		}
+		topics := make([]string, 0, len(byTopic))
+		for topic := range byTopic {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		for _, topic := range topics {
+			w.Gauge("realtime_subscriptions", "Realtime subscriptions by topic.", float64(byTopic[topic]), metrics.L("topic", topic))
+		}
+	}
+}
+
+func subscriptionTopic(sub string) string {
+	sub, _, _ = strings.Cut(sub, "?")
+	sub, _, _ = strings.Cut(sub, "/")
+	return sub
+}
@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"drone-dashboard/control"
+
+	"github.com/pocketbase/dbx"
+)
+
+// HandleChangelog makes the targets behind a pits changelog due immediately so
+// an outage is caught up in one worker pass instead of at each target's interval.
+func (m *Manager) HandleChangelog(pitsID string, cl control.Changelog) error {
+	nowMs := time.Now().UnixMilli()
+	touched := 0
+	for _, e := range cl.Entries {
+		typ, sourceID, ok := targetForPath(e.Path)
+		if !ok {
+			continue
+		}
+		filter := "type = {:t} && nextDueAt > {:now}"
+		if sourceID != "" {
+			filter += " && sourceId = {:sid}"
+		}
+		recs, err := m.App.FindRecordsByFilter("ingest_targets", filter, "", 0, 0, dbx.Params{"t": typ, "sid": sourceID, "now": nowMs})
+		if err != nil {
+			slog.Warn("scheduler.changelog.find.error", "pitsId", pitsID, "path", e.Path, "err", err)
+			continue
+		}
+		for _, rec := range recs {
+			if !m.ownsTarget(rec) {
+				continue
+			}
+			rec.Set("nextDueAt", nowMs)
+			if err := m.App.Save(rec); err != nil {
+				slog.Warn("scheduler.changelog.save.error", "id", rec.Id, "err", err)
+				continue
+			}
+			touched++
+		}
+	}
+	slog.Info("scheduler.changelog.applied", "pitsId", pitsID, "entries", len(cl.Entries), "targetsDue", touched)
+	return nil
+}
+
+// targetForPath maps an FPVTrackside path to the ingest_targets type and sourceId
+// that fetch it. Channels targets are matched by type only.
+func targetForPath(path string) (string, string, bool) {
+	if path == "/httpfiles/Channels.json" {
+		return "channels", "", true
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 3 || parts[0] != "events" || parts[1] == "" {
+		return "", "", false
+	}
+	if len(parts) == 4 && parts[3] == "Race.json" && parts[2] != "" {
+		return "race", parts[2], true
+	}
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	switch parts[2] {
+	case "Event.json":
+		return "event", parts[1], true
+	case "Pilots.json":
+		return "pilots", parts[1], true
+	case "Rounds.json":
+		return "rounds", parts[1], true
+	case "Results.json":
+		return "results", parts[1], true
+	}
+	return "", "", false
+}
@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"errors"
 	"log/slog"
 	"time"
 
@@ -22,14 +23,18 @@ func (m *Manager) runDiscovery() {
 	cfg := m.currentConfig()
 
 	// 1. Get event source ID from external system
-	eventSourceId, err := m.Service.Source.FetchEventSourceId()
+	eventSourceId, err := m.Service.Origin().FetchEventSourceId()
+	if errors.Is(err, ingest.ErrSourceUnavailable) {
+		slog.Debug("scheduler.discovery.sourceUnavailable", "err", err)
+		return
+	}
 	if err != nil {
 		slog.Warn("scheduler.discovery.fetchEventSourceId.error", "err", err)
 		return
 	}
 
 	// 2. Fetch event data to validate it exists and get race information
-	events, err := m.Service.Source.FetchEvent(eventSourceId)
+	events, err := m.Service.Origin().FetchEvent(eventSourceId)
 	if err != nil || len(events) == 0 {
 		slog.Warn("scheduler.discovery.fetchEvent.error", "eventSourceId", eventSourceId, "err", err)
 		return
@@ -96,13 +101,17 @@ func (m *Manager) pruneTargetsNotForEvent(currentEventPBID string) {
 	// Select targets whose event is null/empty or different than the current event
 	query := `
         SELECT id FROM ingest_targets
-        WHERE event IS NULL OR event = '' OR event != {:e}
+        WHERE (event IS NULL OR event = '' OR event != {:e})
     `
+	if m.PitsID != "" {
+		// leave other venues' queues alone
+		query += ` AND pitsId = {:pits}`
+	}
 	type row struct {
 		ID string `db:"id"`
 	}
 	var rows []row
-	if err := m.App.DB().NewQuery(query).Bind(dbx.Params{"e": currentEventPBID}).All(&rows); err != nil {
+	if err := m.App.DB().NewQuery(query).Bind(dbx.Params{"e": currentEventPBID, "pits": m.PitsID}).All(&rows); err != nil {
 		slog.Warn("scheduler.pruneTargetsNotForEvent.query.error", "eventPBID", currentEventPBID, "err", err)
 		return
 	}
@@ -126,7 +135,11 @@ func (m *Manager) pruneTargetsNotForEvent(currentEventPBID string) {
 
 func (m *Manager) upsertTarget(t string, sourceId string, eventPBID string, interval time.Duration, now time.Time, priority ...int) {
 	colName := "ingest_targets"
-	rec, _ := m.App.FindFirstRecordByFilter(colName, "type = {:t} && sourceId = {:sid}", dbx.Params{"t": t, "sid": sourceId})
+	filter := "type = {:t} && sourceId = {:sid}"
+	if m.PitsID != "" {
+		filter += " && pitsId = {:pits}"
+	}
+	rec, _ := m.App.FindFirstRecordByFilter(colName, filter, dbx.Params{"t": t, "sid": sourceId, "pits": m.PitsID})
 
 	// Treat non-positive interval as disabled: remove existing target if present and exit.
 	if interval <= 0 {
@@ -172,6 +185,9 @@ func (m *Manager) upsertTarget(t string, sourceId string, eventPBID string, inte
 	if eventPBID != "" {
 		rec.Set("event", eventPBID)
 	}
+	if m.PitsID != "" {
+		rec.Set("pitsId", m.PitsID)
+	}
 
 	if err := m.App.Save(rec); err != nil {
 		slog.Warn("scheduler.upsertTarget.save.error", "type", t, "sourceId", sourceId, "err", err)
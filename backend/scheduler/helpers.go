@@ -2,13 +2,21 @@ package scheduler
 
 import (
 	"log/slog"
+	"strconv"
 	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
 )
 
 // -------------------- Helpers --------------------
 
 func (m *Manager) findCurrentEventPBID() string {
-	rec, err := m.App.FindFirstRecordByFilter("events", "isCurrent = true", nil)
+	filter := "isCurrent = true"
+	if m.PitsID != "" {
+		filter += " && pitsId = {:pits}"
+	}
+	rec, err := m.App.FindFirstRecordByFilter("events", filter, dbx.Params{"pits": m.PitsID})
 	if err != nil {
 		// Debug level: not critical; empty means none set
 		slog.Debug("scheduler.findCurrentEventPBID.error", "err", err)
@@ -20,6 +28,38 @@ func (m *Manager) findCurrentEventPBID() string {
 	return ""
 }
 
+// ownsTarget reports whether an ingest_targets record belongs to this manager.
+// Unscoped managers own everything; scoped ones only rows tagged with their
+// pits (dropLegacyTargets clears untagged ones at startup).
+func (m *Manager) ownsTarget(rec *core.Record) bool {
+	return m.PitsID == "" || rec.GetString("pitsId") == m.PitsID
+}
+
+// dropLegacyTargets deletes ingest_targets left untagged by a single-pits run.
+// Which venue they belonged to is unknown, and discovery recreates every
+// target a scoped manager needs, so no pits adopts them.
+func (m *Manager) dropLegacyTargets() {
+	if m.PitsID == "" {
+		return
+	}
+	recs, err := m.App.FindRecordsByFilter("ingest_targets", "pitsId = ''", "", 0, 0)
+	if err != nil {
+		slog.Warn("scheduler.dropLegacyTargets.query.error", "pitsId", m.PitsID, "err", err)
+		return
+	}
+	removed := 0
+	for _, rec := range recs {
+		if err := m.App.Delete(rec); err != nil {
+			slog.Warn("scheduler.dropLegacyTargets.delete.error", "id", rec.Id, "err", err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		slog.Info("scheduler.dropLegacyTargets.done", "pitsId", m.PitsID, "removed", removed)
+	}
+}
+
 // resolveEventSourceIdByPBID resolves the upstream sourceId from an event PB id.
 func (m *Manager) resolveEventSourceIdByPBID(pbid string) string {
 	if pbid == "" {
@@ -51,3 +91,53 @@ func (m *Manager) isEnabled() bool {
 	val := strings.ToLower(strings.TrimSpace(rec.GetString("value")))
 	return !(val == "false" || val == "0" || val == "off")
 }
+
+// SetEnabled writes `scheduler.enabled`, which the loops check on every tick.
+func (m *Manager) SetEnabled(enabled bool) error {
+	rec, _ := m.App.FindFirstRecordByFilter("server_settings", "key = 'scheduler.enabled'", nil)
+	if rec == nil {
+		col, err := m.App.FindCollectionByNameOrId("server_settings")
+		if err != nil {
+			return err
+		}
+		rec = core.NewRecord(col)
+		rec.Set("key", "scheduler.enabled")
+	}
+	rec.Set("value", strconv.FormatBool(enabled))
+	return m.App.Save(rec)
+}
+
+// TargetStatus is a read-only view of one ingest_targets row.
+type TargetStatus struct {
+	ID            string `json:"id" db:"id"`
+	Type          string `json:"type" db:"type"`
+	SourceID      string `json:"sourceId" db:"sourceId"`
+	Event         string `json:"event" db:"event"`
+	Enabled       bool   `json:"enabled" db:"enabled"`
+	IntervalMs    int    `json:"intervalMs" db:"intervalMs"`
+	Priority      int    `json:"priority" db:"priority"`
+	NextDueAt     int64  `json:"nextDueAt" db:"nextDueAt"`
+	LastFetchedAt int64  `json:"lastFetchedAt" db:"lastFetchedAt"`
+	LastStatus    string `json:"lastStatus" db:"lastStatus"`
+}
+
+// TargetsStatus lists the ingest targets this manager owns, most urgent first.
+func (m *Manager) TargetsStatus() ([]TargetStatus, error) {
+	scope := ""
+	if m.PitsID != "" {
+		scope = " WHERE pitsId = {:pits}"
+	}
+	var rows []TargetStatus
+	q := `SELECT id, type, sourceId, event, enabled, intervalMs, priority, nextDueAt, lastFetchedAt, lastStatus
+		FROM ingest_targets` + scope + `
+		ORDER BY priority DESC, nextDueAt ASC`
+	if err := m.App.DB().NewQuery(q).Bind(dbx.Params{"pits": m.PitsID}).All(&rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// IsEnabled reports the current `scheduler.enabled` setting.
+func (m *Manager) IsEnabled() bool {
+	return m.isEnabled()
+}
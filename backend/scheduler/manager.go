@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"drone-dashboard/ingest"
+	"drone-dashboard/metrics"
 
 	"github.com/pocketbase/pocketbase/core"
 )
@@ -18,6 +19,9 @@ import (
 type Manager struct {
 	App     core.App
 	Service *ingest.Service
+	// PitsID limits this manager to one venue's events and ingest targets.
+	// Empty manages every target, which is the single-venue default.
+	PitsID string
 
 	cfgMu sync.RWMutex
 	cfg   Config
@@ -32,10 +36,20 @@ type Manager struct {
 	workerSlots   chan struct{}
 
 	reloadMu sync.Mutex
+	// reloads tracks hook-triggered reloads still running
+	reloads sync.WaitGroup
+
+	ingestDurations *metrics.HistogramVec
+	ingestErrors    *metrics.CounterVec
 }
 
 func NewManager(app core.App, service *ingest.Service, cfg Config) *Manager {
-	m := &Manager{App: app, Service: service}
+	m := &Manager{
+		App:             app,
+		Service:         service,
+		ingestDurations: metrics.NewHistogramVec(metrics.DurationBounds),
+		ingestErrors:    metrics.NewCounterVec(),
+	}
 	m.setConfig(cfg)
 	return m
 }
@@ -44,6 +58,7 @@ func NewManager(app core.App, service *ingest.Service, cfg Config) *Manager {
 func (m *Manager) StartLoops(ctx context.Context) {
 	// seed defaults if missing
 	m.ensureDefaultSettings()
+	m.dropLegacyTargets()
 	// load settings-derived config
 	loaded := m.loadConfigFromDB()
 	m.setConfig(loaded)
@@ -168,7 +183,11 @@ func (m *Manager) registerSchedulerSettingHooks() {
 			}
 			if m.shouldReloadForSetting(key) {
 				reason := fmt.Sprintf("%s:%s", op, key)
-				go m.reloadSchedulerConfig(reason)
+				m.reloads.Add(1)
+				go func() {
+					defer m.reloads.Done()
+					m.reloadSchedulerConfig(reason)
+				}()
 			}
 			return e.Next()
 		}
@@ -235,6 +254,9 @@ func (m *Manager) reapplyTargetIntervals(cfg Config) (map[string]int, error) {
 	now := time.Now()
 	counts := make(map[string]int)
 	for _, rec := range records {
+		if !m.ownsTarget(rec) {
+			continue
+		}
 		typeName := rec.GetString("type")
 		sourceID := rec.GetString("sourceId")
 		eventID := rec.GetString("event")
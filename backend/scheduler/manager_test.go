@@ -4,9 +4,13 @@ import (
 	"database/sql"
 	"errors"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"drone-dashboard/control"
+	"drone-dashboard/metrics"
+
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tests"
@@ -25,6 +29,8 @@ func TestManagerReloadSchedulerConfigAppliesSettings(t *testing.T) {
 
 	manager := NewManager(app, nil, Config{})
 	manager.RegisterHooks()
+	// hook reloads run in the background; let them finish before the app is torn down
+	t.Cleanup(manager.reloads.Wait)
 
 	initialCfg := Config{
 		FullInterval:     2 * time.Second,
@@ -177,6 +183,99 @@ func TestManagerReloadSchedulerConfigAppliesSettings(t *testing.T) {
 	}
 }
 
+func TestManagerPitsScoping(t *testing.T) {
+	t.Helper()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	north := createRecord(t, app, "events", map[string]any{
+		"source": "fpv", "sourceId": "evt-north", "name": "North", "isCurrent": true, "pitsId": "north",
+	})
+	south := createRecord(t, app, "events", map[string]any{
+		"source": "fpv", "sourceId": "evt-south", "name": "South", "isCurrent": true, "pitsId": "south",
+	})
+	past := time.Now().Add(-time.Second).UnixMilli()
+	for _, ev := range []*core.Record{north, south} {
+		createRecord(t, app, "ingest_targets", map[string]any{
+			"type": "event", "sourceId": ev.GetString("sourceId"), "event": ev.Id,
+			"intervalMs": 1000, "nextDueAt": past, "enabled": true, "pitsId": ev.GetString("pitsId"),
+		})
+	}
+
+	// left behind by a single-pits run: no venue may adopt it
+	legacy := createRecord(t, app, "ingest_targets", map[string]any{
+		"type": "race", "sourceId": "race-legacy", "event": north.Id,
+		"intervalMs": 1000, "nextDueAt": past, "enabled": true,
+	})
+
+	manager := NewManager(app, nil, Config{})
+	manager.PitsID = "north"
+	if manager.ownsTarget(legacy) {
+		t.Fatalf("scoped manager adopted an untagged target")
+	}
+	manager.dropLegacyTargets()
+	if _, err := app.FindRecordById("ingest_targets", legacy.Id); err == nil {
+		t.Fatalf("untagged target survived startup")
+	}
+	if got := manager.findCurrentEventPBID(); got != north.Id {
+		t.Fatalf("current event: got %s want %s", got, north.Id)
+	}
+	rows, err := manager.fetchDueRows(10)
+	if err != nil {
+		t.Fatalf("fetch due rows: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Event != north.Id {
+		t.Fatalf("expected only north target, got %+v", rows)
+	}
+
+	manager.pruneTargetsNotForEvent(north.Id)
+	if rec := getIngestTarget(t, app, "event", "evt-south"); rec.GetString("pitsId") != "south" {
+		t.Fatalf("south target should survive north prune")
+	}
+}
+
+func TestHandleChangelogMakesTargetsDue(t *testing.T) {
+	t.Helper()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	event := createRecord(t, app, "events", map[string]any{"source": "fpv", "sourceId": "evt-1", "name": "Event", "isCurrent": true})
+	later := time.Now().Add(time.Hour).UnixMilli()
+	for _, target := range []struct{ typ, sid string }{{"race", "race-1"}, {"race", "race-2"}, {"channels", "evt-1"}} {
+		createRecord(t, app, "ingest_targets", map[string]any{
+			"type": target.typ, "sourceId": target.sid, "event": event.Id,
+			"intervalMs": 1000, "nextDueAt": later, "enabled": true,
+		})
+	}
+
+	manager := NewManager(app, nil, Config{})
+	err = manager.HandleChangelog("default", control.Changelog{Entries: []control.ChangelogEntry{
+		{Path: "/events/evt-1/race-1/Race.json", Records: 2},
+		{Path: "/httpfiles/Channels.json", Records: 1},
+		{Path: "/events/evt-1/unknown.json", Records: 1},
+	}})
+	if err != nil {
+		t.Fatalf("handle changelog: %v", err)
+	}
+	now := time.Now().UnixMilli()
+	for sid, due := range map[string]bool{"race-1": true, "race-2": false} {
+		if got := getIngestTarget(t, app, "race", sid).GetInt("nextDueAt") <= int(now); got != due {
+			t.Fatalf("race %s due=%v want %v", sid, got, due)
+		}
+	}
+	if getIngestTarget(t, app, "channels", "evt-1").GetInt("nextDueAt") > int(now) {
+		t.Fatalf("channels target not made due")
+	}
+}
+
 func seedSchedulerSettings(t testing.TB, app core.App, cfg Config) {
 	t.Helper()
 	setSetting(t, app, "scheduler.fullIntervalMs", intToString(cfg.FullInterval.Milliseconds()))
@@ -252,3 +351,48 @@ func waitFor(t testing.TB, timeout time.Duration, cond func() bool) {
 	}
 	t.Fatalf("condition not met within %v", timeout)
 }
+
+func TestManagerCollectReportsQueue(t *testing.T) {
+	t.Helper()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("new test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	event := createRecord(t, app, "events", map[string]any{"source": "fpv", "sourceId": "evt-1", "name": "Event", "isCurrent": true})
+	now := time.Now()
+	for i, due := range []time.Time{now.Add(-30 * time.Second), now.Add(-5 * time.Second), now.Add(time.Hour)} {
+		createRecord(t, app, "ingest_targets", map[string]any{
+			"type": "race", "sourceId": "race-" + strconv.Itoa(i), "event": event.Id,
+			"intervalMs": 1000, "nextDueAt": due.UnixMilli(), "enabled": true,
+		})
+	}
+
+	manager := NewManager(app, nil, Config{Concurrency: 3})
+	manager.ingestDurations.With("race").Observe(0.2)
+	manager.ingestErrors.Add("race", 1)
+
+	reg := &metrics.Registry{}
+	reg.Register(manager.Collect)
+	var out strings.Builder
+	if _, err := reg.WriteTo(&out); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	text := out.String()
+	for _, want := range []string{
+		"scheduler_queue_depth 2\n",
+		"scheduler_worker_slots 3\n",
+		`scheduler_ingest_duration_seconds_bucket{type="race",le="0.25"} 1`,
+		`scheduler_ingest_errors_total{type="race"} 1`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+	depth, lag, err := manager.queueStats(now)
+	if err != nil || depth != 2 || lag < 29*time.Second {
+		t.Fatalf("queue stats: depth=%d lag=%v err=%v", depth, lag, err)
+	}
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"log/slog"
+	"time"
+
+	"drone-dashboard/metrics"
+
+	"github.com/pocketbase/dbx"
+)
+
+// queueStats counts enabled targets that are past due and how late the oldest is.
+func (m *Manager) queueStats(now time.Time) (int, time.Duration, error) {
+	scope := ""
+	if m.PitsID != "" {
+		scope = " AND pitsId = {:pits}"
+	}
+	var row struct {
+		Depth  int   `db:"depth"`
+		Oldest int64 `db:"oldest"`
+	}
+	q := `SELECT COUNT(*) AS depth, COALESCE(MIN(nextDueAt), 0) AS oldest
+		FROM ingest_targets WHERE enabled = 1 AND nextDueAt < {:now}` + scope
+	nowMs := now.UnixMilli()
+	if err := m.App.DB().NewQuery(q).Bind(dbx.Params{"now": nowMs, "pits": m.PitsID}).One(&row); err != nil {
+		return 0, 0, err
+	}
+	if row.Depth == 0 {
+		return 0, 0, nil
+	}
+	return row.Depth, time.Duration(nowMs-row.Oldest) * time.Millisecond, nil
+}
+
+// Collect reports queue, worker and ingest metrics for /metrics.
+func (m *Manager) Collect(w *metrics.Writer) {
+	var labels []metrics.Label
+	if m.PitsID != "" {
+		labels = append(labels, metrics.L("pits", m.PitsID))
+	}
+	depth, lag, err := m.queueStats(time.Now())
+	if err != nil {
+		slog.Debug("scheduler.metrics.queue.error", "err", err)
+	} else {
+		w.Gauge("scheduler_queue_depth", "Enabled ingest targets past their nextDueAt.", float64(depth), labels...)
+		w.Gauge("scheduler_queue_lag_seconds", "How long the oldest due ingest target has been waiting.", lag.Seconds(), labels...)
+	}
+	slots := m.workerLimiter()
+	w.Gauge("scheduler_worker_slots", "Scheduler worker concurrency.", float64(cap(slots)), labels...)
+	w.Gauge("scheduler_worker_slots_busy", "Scheduler worker slots currently running a target.", float64(len(slots)), labels...)
+	enabled := 0.0
+	if m.isEnabled() {
+		enabled = 1
+	}
+	w.Gauge("scheduler_enabled", "Whether the scheduler.enabled setting is on.", enabled, labels...)
+	m.ingestDurations.Each(func(typ string, s metrics.HistogramSnapshot) {
+		w.Histogram("scheduler_ingest_duration_seconds", "Time to ingest one target, by target type.", s, append(labels, metrics.L("type", typ))...)
+	})
+	m.ingestErrors.Each(func(typ string, v float64) {
+		w.Counter("scheduler_ingest_errors_total", "Failed target ingests, by target type.", v, append(labels, metrics.L("type", typ))...)
+	})
+}
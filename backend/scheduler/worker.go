@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -10,6 +11,7 @@ import (
 
 	"drone-dashboard/control"
 	"drone-dashboard/ingest"
+	"drone-dashboard/tracing"
 
 	"github.com/pocketbase/dbx"
 )
@@ -110,12 +112,16 @@ func (m *Manager) fetchDueRows(limit int) ([]dueRow, error) {
 	}
 	nowMs := time.Now().UnixMilli()
 	var rows []dueRow
+	scope := ""
+	if m.PitsID != "" {
+		scope = " AND pitsId = {:pits}"
+	}
 	q := `SELECT id, type, sourceId, event, nextDueAt, intervalMs, priority
 		      FROM ingest_targets
-		      WHERE enabled = 1 AND nextDueAt <= {:now}
+		      WHERE enabled = 1 AND nextDueAt <= {:now}` + scope + `
 		      ORDER BY nextDueAt ASC, priority DESC
 		      LIMIT {:lim}`
-	if err := m.App.DB().NewQuery(q).Bind(dbx.Params{"now": nowMs, "lim": limit}).All(&rows); err != nil {
+	if err := m.App.DB().NewQuery(q).Bind(dbx.Params{"now": nowMs, "lim": limit, "pits": m.PitsID}).All(&rows); err != nil {
 		return nil, err
 	}
 	return rows, nil
@@ -132,32 +138,45 @@ func (m *Manager) processDueRow(rw dueRow) {
 	// Resolve event sourceId from event relation id
 	eventSourceId := m.resolveEventSourceIdByPBID(rw.Event)
 	var runErr error
+	start := time.Now()
+	ctx, span := tracing.Start(context.Background(), "scheduler.ingest", tracing.KindInternal,
+		tracing.String("type", t), tracing.String("sourceId", sid), tracing.String("event", rw.Event), tracing.String("pitsId", m.PitsID))
+	svc := m.Service.WithContext(ctx)
 	switch t {
 	case "event":
-		runErr = m.Service.IngestEventMeta(eventSourceId)
+		runErr = svc.IngestEventMeta(eventSourceId)
 	case "pilots":
-		runErr = m.Service.IngestPilots(eventSourceId)
+		runErr = svc.IngestPilots(eventSourceId)
 	case "channels":
-		runErr = m.Service.IngestChannels(eventSourceId)
+		runErr = svc.IngestChannels(eventSourceId)
 	case "rounds":
-		runErr = m.Service.IngestRounds(eventSourceId)
+		runErr = svc.IngestRounds(eventSourceId)
 	case "race":
-		runErr = m.Service.IngestRace(eventSourceId, sid)
+		runErr = svc.IngestRace(eventSourceId, sid)
 	case "results":
-		_, runErr = m.Service.IngestResults(eventSourceId)
+		_, runErr = svc.IngestResults(eventSourceId)
 	default:
 		slog.Warn("scheduler.worker.unknownType", "type", t)
 	}
+	span.End(runErr)
+	m.ingestDurations.With(t).Observe(time.Since(start).Seconds())
 	if runErr != nil {
+		m.ingestErrors.Add(t, 1)
 		var missing *ingest.EntityNotFoundError
-		if errors.As(runErr, &missing) {
+		var open *ingest.CircuitOpenError
+		if errors.As(runErr, &open) {
+			// the breaker already logged the outage; one line per target would flood
+			slog.Debug("scheduler.worker.sourceUnavailable", "type", t, "sourceId", sid, "retryAt", open.RetryAt.UnixMilli())
+		} else if errors.As(runErr, &missing) {
 			slog.Info("scheduler.worker.dependencyMissing", "type", t, "sourceId", sid, "event", rw.Event, "collection", missing.Collection, "missingSourceId", missing.SourceID, "error", runErr)
 		} else {
 			fields := []any{"type", t, "sourceId", sid, "event", rw.Event, "error", runErr}
+			traceID := span.TraceID
 			var traced control.TraceCarrier
 			if errors.As(runErr, &traced) && traced != nil && traced.TraceID() != "" {
-				fields = append(fields, "traceId", traced.TraceID())
+				traceID = traced.TraceID()
 			}
+			fields = append(fields, "traceId", traceID)
 			slog.Warn("scheduler.worker.drainOnce.ingestError", fields...)
 		}
 	}
@@ -180,7 +199,12 @@ func (m *Manager) rescheduleRow(id string, intervalMs int, runErr error) {
 		return
 	}
 
-	if runErr != nil {
+	var open *ingest.CircuitOpenError
+	if errors.As(runErr, &open) {
+		// wait for the breaker's probe rather than retrying against a dead source
+		record.Set("lastStatus", "waiting: timing system unreachable")
+		record.Set("nextDueAt", max64(open.RetryAt.UnixMilli(), m.nextDueAt(now, interval, true)))
+	} else if runErr != nil {
 		// Update fields for error case while distinguishing dependency gaps
 		var missing *ingest.EntityNotFoundError
 		if errors.As(runErr, &missing) {
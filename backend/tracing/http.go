@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const defaultTraceLimit = 50
+
+// RegisterRoutes serves recent spans as OTLP-JSON on GET /traces (superuser
+// only). ?traceId= selects one trace, ?limit= the number of recent traces and
+// ?download=1 makes it a file to import or POST to a collector.
+func RegisterRoutes(app core.App, rec *Recorder) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/traces", func(c *core.RequestEvent) error {
+			info, err := c.RequestInfo()
+			if err != nil || info.Auth == nil || !info.Auth.IsSuperuser() {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin only"})
+			}
+			q := c.Request.URL.Query()
+			limit := defaultTraceLimit
+			if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+				limit = v
+			}
+			spans := rec.Recent(q.Get("traceId"), limit)
+			c.Response.Header().Set("Content-Type", "application/json")
+			if q.Get("download") != "" {
+				name := fmt.Sprintf("traces-%s.json", time.Now().UTC().Format("20060102T150405Z"))
+				c.Response.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+			}
+			c.Response.WriteHeader(http.StatusOK)
+			return rec.WriteOTLP(c.Response, spans)
+		})
+		return se.Next()
+	})
+}
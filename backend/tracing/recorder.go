@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultCapacity = 4096
+
+// SpanData is a finished span.
+type SpanData struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Name     string
+	Kind     Kind
+	Start    time.Time
+	End      time.Time
+	Attrs    []Attr
+	Error    string
+}
+
+// Recorder keeps the most recent finished spans in a ring buffer.
+type Recorder struct {
+	mu       sync.Mutex
+	spans    []SpanData
+	next     int
+	full     bool
+	resource []Attr
+}
+
+// Default records the spans of this process.
+var Default = NewRecorder(defaultCapacity)
+
+// NewRecorder returns a recorder holding up to capacity spans.
+func NewRecorder(capacity int) *Recorder {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	return &Recorder{spans: make([]SpanData, capacity)}
+}
+
+// SetResource sets the attributes describing this process (service.name and
+// so on) for export.
+func (r *Recorder) SetResource(attrs ...Attr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resource = append([]Attr(nil), attrs...)
+}
+
+// Start begins a span as a child of ctx's span, of the remote parent set by
+// WithRemoteParent, or as the root of ctx's trace ID or a new trace.
+func (r *Recorder) Start(ctx context.Context, name string, kind Kind, attrs ...Attr) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s := &Span{SpanID: newSpanID(), Name: name, Kind: kind, Start: time.Now(), attrs: attrs, rec: r}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.TraceID, s.ParentID = parent.TraceID, parent.SpanID
+	} else {
+		s.TraceID = TraceIDFromContext(ctx)
+		if s.TraceID == "" {
+			s.TraceID = NewTraceID()
+		}
+		s.ParentID, _ = ctx.Value(remoteKey).(string)
+	}
+	return context.WithValue(ctx, spanKey, s), s
+}
+
+func (r *Recorder) add(d SpanData) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans[r.next] = d
+	r.next++
+	if r.next == len(r.spans) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// snapshot returns the buffered spans, oldest first.
+func (r *Recorder) snapshot() []SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]SpanData(nil), r.spans[:r.next]...)
+	}
+	out := make([]SpanData, 0, len(r.spans))
+	out = append(out, r.spans[r.next:]...)
+	return append(out, r.spans[:r.next]...)
+}
+
+// Recent returns the spans of one trace, or of the limit most recently
+// finished traces when traceID is empty, ordered by start time.
+func (r *Recorder) Recent(traceID string, limit int) []SpanData {
+	all := r.snapshot()
+	traceID = normalizeTraceID(traceID)
+	var out []SpanData
+	if traceID != "" {
+		for _, s := range all {
+			if s.TraceID == traceID {
+				out = append(out, s)
+			}
+		}
+	} else {
+		keep := make(map[string]bool)
+		for i := len(all) - 1; i >= 0 && (limit <= 0 || len(keep) < limit); i-- {
+			keep[all[i].TraceID] = true
+		}
+		for _, s := range all {
+			if keep[s.TraceID] {
+				out = append(out, s)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
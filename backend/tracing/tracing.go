@@ -0,0 +1,190 @@
+// Package tracing records spans in process and exports them as OTLP-JSON. It
+// owns the trace ID carried in contexts (control.WithTraceID delegates here),
+// so scheduler runs, ingest transactions and control link fetches on both
+// sides of the link end up in one trace.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is the OTLP span kind.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Attr is one span attribute; Value is a string, bool, int64 or float64.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(key, v string) Attr      { return Attr{Key: key, Value: v} }
+func Int(key string, v int) Attr     { return Attr{Key: key, Value: int64(v)} }
+func Int64(key string, v int64) Attr { return Attr{Key: key, Value: v} }
+func Bool(key string, v bool) Attr   { return Attr{Key: key, Value: v} }
+
+// Span is one timed operation. A nil *Span is valid and does nothing, so
+// callers never check whether tracing is on.
+type Span struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Name     string
+	Kind     Kind
+	Start    time.Time
+
+	mu    sync.Mutex
+	attrs []Attr
+	ended bool
+	rec   *Recorder
+}
+
+// SetAttrs adds attributes to a running span.
+func (s *Span) SetAttrs(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// End finishes the span and hands it to the recorder; err marks it failed.
+// Only the first call counts.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		TraceID:  s.TraceID,
+		SpanID:   s.SpanID,
+		ParentID: s.ParentID,
+		Name:     s.Name,
+		Kind:     s.Kind,
+		Start:    s.Start,
+		End:      end,
+		Attrs:    append([]Attr(nil), s.attrs...),
+	}
+	s.mu.Unlock()
+	if err != nil {
+		data.Error = err.Error()
+	}
+	s.rec.add(data)
+}
+
+type spanKeyType struct{}
+type traceKeyType struct{}
+type remoteKeyType struct{}
+
+var (
+	spanKey   spanKeyType
+	traceKey  traceKeyType
+	remoteKey remoteKeyType
+)
+
+// WithTraceID returns ctx carrying traceID for spans started from it. Shorter
+// IDs from older peers are widened, see normalizeTraceID.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, traceKey, normalizeTraceID(traceID))
+}
+
+// TraceIDFromContext returns the trace ID of ctx, from its span if it has one.
+func TraceIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if s, ok := ctx.Value(spanKey).(*Span); ok && s != nil {
+		return s.TraceID
+	}
+	if v, ok := ctx.Value(traceKey).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// SpanFromContext returns the current span of ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanKey).(*Span)
+	return s
+}
+
+// SpanIDFromContext returns the ID to send as parent to a remote peer.
+func SpanIDFromContext(ctx context.Context) string {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.SpanID
+	}
+	return ""
+}
+
+// WithRemoteParent continues a trace started by a peer: spans started from the
+// returned context become children of the peer's span.
+func WithRemoteParent(ctx context.Context, traceID, spanID string) context.Context {
+	if traceID == "" {
+		return ctx
+	}
+	ctx = WithTraceID(ctx, traceID)
+	if spanID != "" {
+		ctx = context.WithValue(ctx, remoteKey, spanID)
+	}
+	return ctx
+}
+
+// Start begins a span on the Default recorder as a child of ctx's span.
+func Start(ctx context.Context, name string, kind Kind, attrs ...Attr) (context.Context, *Span) {
+	return Default.Start(ctx, name, kind, attrs...)
+}
+
+// NewTraceID returns a random 16 byte trace ID in hex, the OTLP size.
+func NewTraceID() string { return randomHex(16) }
+
+// traceIDHexLen is the length of a trace ID in hex.
+const traceIDHexLen = 32
+
+// normalizeTraceID left-pads a hex trace ID shorter than 16 bytes, such as the
+// 12 byte IDs of older peers, with zeros, so its spans export as a valid OTLP
+// trace and can be looked up by the exported ID. Anything else is kept.
+func normalizeTraceID(id string) string {
+	if id == "" || len(id) >= traceIDHexLen {
+		return id
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return id
+	}
+	return strings.Repeat("0", traceIDHexLen-len(id)) + id
+}
+
+func newSpanID() string { return randomHex(8) }
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// entropy failures are not worth failing a request over
+		for i := range b {
+			b[i] = byte(time.Now().UnixNano() >> (i % 8 * 8))
+		}
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSpansNestAndContinueRemoteParents(t *testing.T) {
+	rec := NewRecorder(16)
+	ctx, root := rec.Start(context.Background(), "scheduler.ingest", KindInternal)
+	_, child := rec.Start(ctx, "control.fetch", KindClient, String("path", "/events/e1/Race.json"))
+	child.End(nil)
+	root.End(errors.New("boom"))
+
+	// the peer continues the trace from the IDs sent over the link
+	remote := WithRemoteParent(context.Background(), child.TraceID, child.SpanID)
+	_, pits := rec.Start(remote, "pits.fetch", KindServer)
+	pits.End(nil)
+
+	spans := rec.Recent(root.TraceID, 0)
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans in trace, got %d", len(spans))
+	}
+	byName := map[string]SpanData{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	if byName["control.fetch"].ParentID != root.SpanID || byName["pits.fetch"].ParentID != child.SpanID {
+		t.Fatalf("wrong parents: %+v", byName)
+	}
+	if byName["scheduler.ingest"].ParentID != "" || byName["scheduler.ingest"].Error != "boom" {
+		t.Fatalf("unexpected root: %+v", byName["scheduler.ingest"])
+	}
+	if len(root.TraceID) != 32 || len(root.SpanID) != 16 {
+		t.Fatalf("ids not OTLP sized: %q %q", root.TraceID, root.SpanID)
+	}
+}
+
+func TestShortTraceIDsFromOlderPeersAreWidened(t *testing.T) {
+	rec := NewRecorder(4)
+	legacy := "0123456789abcdef01234567"
+	_, s := rec.Start(WithRemoteParent(context.Background(), legacy, "89abcdef01234567"), "pits.fetch", KindServer)
+	s.End(nil)
+
+	want := "00000000" + legacy
+	if s.TraceID != want {
+		t.Fatalf("trace id = %q, want %q", s.TraceID, want)
+	}
+	if got := rec.Recent(legacy, 0); len(got) != 1 {
+		t.Fatalf("lookup by the peer's id found %d spans", len(got))
+	}
+	if got := normalizeTraceID("not-hex"); got != "not-hex" {
+		t.Fatalf("non-hex id rewritten to %q", got)
+	}
+}
+
+func TestRecentKeepsLatestTraces(t *testing.T) {
+	rec := NewRecorder(4)
+	var last string
+	for i := 0; i < 6; i++ {
+		_, s := rec.Start(context.Background(), "op", KindInternal)
+		s.End(nil)
+		s.End(nil) // a second End is ignored
+		last = s.TraceID
+	}
+	if got := rec.Recent("", 0); len(got) != 4 {
+		t.Fatalf("ring should hold 4 spans, got %d", len(got))
+	}
+	got := rec.Recent("", 1)
+	if len(got) != 1 || got[0].TraceID != last {
+		t.Fatalf("expected only the newest trace, got %+v", got)
+	}
+}
+
+func TestWriteOTLP(t *testing.T) {
+	rec := NewRecorder(4)
+	rec.SetResource(String("service.name", "drone-dashboard"))
+	ctx := WithTraceID(context.Background(), "0123456789abcdef01234567") // 12 byte ID from an older pits
+	_, s := rec.Start(ctx, "ingest.race.tx", KindInternal, Int("laps", 3))
+	s.End(errors.New("locked"))
+
+	var buf bytes.Buffer
+	if err := rec.WriteOTLP(&buf, rec.Recent("", 0)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var out struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []otlpKeyValue `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID    string         `json:"traceId"`
+					Kind       int            `json:"kind"`
+					Attributes []otlpKeyValue `json:"attributes"`
+					Status     otlpStatus     `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v\n%s", err, buf.String())
+	}
+	rs := out.ResourceSpans[0]
+	span := rs.ScopeSpans[0].Spans[0]
+	if rs.Resource.Attributes[0].Value["stringValue"] != "drone-dashboard" {
+		t.Fatalf("resource not exported: %s", buf.String())
+	}
+	if span.TraceID != "000000000123456789abcdef01234567" || span.Kind != int(KindInternal) {
+		t.Fatalf("unexpected span: %s", buf.String())
+	}
+	if span.Attributes[0].Value["intValue"] != "3" || span.Status.Code != 2 || span.Status.Message != "locked" {
+		t.Fatalf("unexpected attributes or status: %s", buf.String())
+	}
+}
@@ -11,6 +11,7 @@ import { useAtom, useAtomValue } from 'jotai';
 import useBreakpoint from './responsive/useBreakpoint.ts';
 import { activePaneAtom, rightPaneViewAtom } from './state/viewAtoms.ts';
 import { SubscriptionStatusIndicator } from './common/SubscriptionStatusIndicator.tsx';
+import { VenueLinkIndicator } from './common/VenueLinkIndicator.tsx';
 import { pbInvalidateAll } from './api/pb.ts';
 // @ts-ignore - TanStack Router type issue, see https://github.com/denoland/deno/issues/30444
 import { Link } from '@tanstack/react-router';
@@ -128,6 +129,7 @@ function App() {
 				? (
 					<div className='app-header'>
 						<SubscriptionStatusIndicator />
+						<VenueLinkIndicator />
 						<div className='app-header-time'>
 							<GenericSuspense id='time-display'>
 								<TimeDisplay
@@ -242,6 +244,7 @@ function App() {
 			{isMobile && (
 				<div className='app-mobile-header-status'>
 					<SubscriptionStatusIndicator />
+					<VenueLinkIndicator />
 				</div>
 			)}
 			{isMobile && activePane === 'leaderboard' && (
@@ -1,6 +1,7 @@
 import { Link, Outlet } from '@tanstack/react-router';
 import './admin.css';
 import { authenticatedKind, logout } from '../api/pb.ts';
+import { SourceHealthBanner } from './SourceHealthBanner.tsx';
 import React from 'react';
 
 export function AdminLayout() {
@@ -30,6 +31,7 @@ export function AdminLayout() {
 			</aside>
 
 			<section className='admin-content'>
+				<SourceHealthBanner />
 				<Outlet />
 			</section>
 		</div>
@@ -0,0 +1,124 @@
+import { useEffect, useMemo, useState } from 'react';
+import type { EChartsOption } from 'echarts';
+import type { BarSeriesOption } from 'echarts/charts';
+import { pb } from '../api/pb.ts';
+import type { FetchSeriesPoint } from '../api/pbTypes.ts';
+import { EChart } from '../pilot/EChart.tsx';
+
+const TREND_WINDOWS = ['1m', '5m', '1h'] as const;
+type TrendWindow = (typeof TREND_WINDOWS)[number];
+
+const TREND_WINDOW_LABELS: Record<TrendWindow, string> = {
+	'1m': 'Last 3 hours by minute',
+	'5m': 'Last 2 days by 5 minutes',
+	'1h': 'Last 2 weeks by hour',
+};
+
+const TREND_REFRESH_MS = 30_000;
+
+// Request volume, errors and p95 latency per window for one bucket, from the
+// cloud's stored fetch history.
+export function FetchTrends({ bucketLabel }: { bucketLabel: (bucket: string) => string }) {
+	const [trendWindow, setTrendWindow] = useState<TrendWindow>('5m');
+	const [bucket, setBucket] = useState('overall');
+	const [points, setPoints] = useState<FetchSeriesPoint[]>([]);
+	const [error, setError] = useState<string | null>(null);
+	const [loading, setLoading] = useState(true);
+
+	useEffect(() => {
+		let cancelled = false;
+		const load = async () => {
+			try {
+				const res = await pb.send<FetchSeriesPoint[]>('/control/fetch-series', { query: { window: trendWindow }, requestKey: null });
+				if (cancelled) return;
+				setPoints(res ?? []);
+				setError(null);
+			} catch (err) {
+				if (cancelled) return;
+				setError(err instanceof Error ? err.message : 'Unknown error');
+			} finally {
+				if (!cancelled) setLoading(false);
+			}
+		};
+		setLoading(true);
+		load();
+		const timer = setInterval(load, TREND_REFRESH_MS);
+		return () => {
+			cancelled = true;
+			clearInterval(timer);
+		};
+	}, [trendWindow]);
+
+	const buckets = useMemo(() => {
+		const seen = new Set(points.map((p) => p.bucket));
+		seen.add('overall');
+		return [...seen].sort((a, b) => (a === 'overall' ? -1 : b === 'overall' ? 1 : a.localeCompare(b)));
+	}, [points]);
+
+	const option = useMemo(() => buildTrendOption(points.filter((p) => p.bucket === bucket)), [points, bucket]);
+
+	return (
+		<section className='section-card control-section'>
+			<div className='section-heading'>
+				<div>
+					<h2>Trends</h2>
+					<p className='muted'>{TREND_WINDOW_LABELS[trendWindow]}</p>
+				</div>
+				<div className='control-totals'>
+					<select value={bucket} onChange={(e) => setBucket(e.target.value)}>
+						{buckets.map((b) => <option key={b} value={b}>{b === 'overall' ? 'All requests' : bucketLabel(b)}</option>)}
+					</select>
+					{TREND_WINDOWS.map((w) => (
+						<button key={w} type='button' className={w === trendWindow ? 'active' : undefined} onClick={() => setTrendWindow(w)}>
+							{w}
+						</button>
+					))}
+				</div>
+			</div>
+			{error && <div className='muted empty-hint'>Could not load trends: {error}</div>}
+			{!error && !loading && points.length === 0 && <div className='muted empty-hint'>No fetch history yet.</div>}
+			{!error && points.length > 0 && (
+				<div className='control-trend-chart'>
+					<EChart option={option} loading={loading} />
+				</div>
+			)}
+		</section>
+	);
+}
+
+function buildTrendOption(points: FetchSeriesPoint[]): EChartsOption {
+	const at = (p: FetchSeriesPoint) => p.windowStart;
+	return {
+		backgroundColor: 'transparent',
+		tooltip: { trigger: 'axis' },
+		legend: { data: ['Full', '304', 'Errors', 'p95 latency'], textStyle: { color: '#cbd5e1' } },
+		grid: { left: 48, right: 56, top: 36, bottom: 32 },
+		xAxis: { type: 'time', axisLabel: { color: '#94a3b8' } },
+		yAxis: [
+			{
+				type: 'value',
+				name: 'requests',
+				axisLabel: { color: '#94a3b8' },
+				splitLine: { lineStyle: { color: 'rgba(148, 163, 184, 0.12)' } },
+			},
+			{ type: 'value', name: 'ms', axisLabel: { color: '#94a3b8' }, splitLine: { show: false } },
+		],
+		series: [
+			requestBars('Full', 'rgba(59, 130, 246, 0.7)', points.map((p) => [at(p), p.fullResponses ?? 0])),
+			requestBars('304', 'rgba(52, 211, 153, 0.8)', points.map((p) => [at(p), p.etagHits ?? 0])),
+			requestBars('Errors', 'rgba(248, 113, 113, 0.7)', points.map((p) => [at(p), p.errors ?? 0])),
+			{
+				name: 'p95 latency',
+				type: 'line',
+				yAxisIndex: 1,
+				showSymbol: false,
+				color: '#fde68a',
+				data: points.map((p) => [at(p), Math.round(p.latencyP95 ?? 0)]),
+			},
+		],
+	};
+}
+
+function requestBars(name: string, color: string, data: BarSeriesOption['data']): BarSeriesOption {
+	return { name, type: 'bar', stack: 'requests', color, data };
+}
@@ -0,0 +1,29 @@
+import { useAtomValue } from 'jotai';
+import { unreachableSourcesAtom } from '../state/pbAtoms.ts';
+
+function formatTime(ms?: number): string {
+	if (!ms) return '';
+	return new Date(ms).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit', second: '2-digit' });
+}
+
+// Shown while ingest's circuit breaker holds off a timing system that stopped
+// answering; ingest targets resume on their own once a probe succeeds.
+export function SourceHealthBanner() {
+	const sources = useAtomValue(unreachableSourcesAtom);
+	if (sources.length === 0) return null;
+
+	return (
+		<div className='source-health-banner' role='alert'>
+			{sources.map((s) => (
+				<div key={s.source || 'default'}>
+					<strong>Timing system unreachable{s.source ? ` (${s.source})` : ''}.</strong>{' '}
+					<span className='muted'>
+						{s.failures} failed request{s.failures === 1 ? '' : 's'} since {formatTime(s.changedAt)}
+						{s.state === 'halfOpen' ? ', probing now' : s.retryAt ? `, next probe ${formatTime(s.retryAt)}` : ''}
+						{s.reason ? ` (${s.reason})` : ''}
+					</span>
+				</div>
+			))}
+		</div>
+	);
+}
@@ -23,6 +23,8 @@ export interface PBEventRecord extends PBBaseRecord {
 	minLapTime?: string;
 	lastOpened?: string;
 	isCurrent?: boolean;
+	// set on a cloud fed by several pits: the venue the event runs at
+	pitsId?: string;
 	lastUpdated?: string;
 }
 
@@ -99,6 +101,8 @@ export interface PBRaceRecord extends PBBaseRecord {
 	raceNumber: number;
 	start?: string;
 	end?: string;
+	startEpoch?: number; // UTC ms corrected for the venue clock, 0 when unknown
+	endEpoch?: number;
 	totalPausedTime?: string;
 	primaryTimingSystemLocation?: string;
 	valid: boolean;
@@ -133,6 +137,7 @@ export interface PBPilotChannelRecord extends PBBaseRecord {
 export interface PBDetectionRecord extends PBBaseRecord {
 	timingSystemIndex?: number;
 	time?: string;
+	timeEpoch?: number; // UTC ms corrected for the venue clock
 	peak?: number;
 	timingSystemType?: string;
 	lapNumber?: number;
@@ -154,6 +159,8 @@ export interface PBLapRecord extends PBBaseRecord {
 	lengthSeconds?: number;
 	startTime?: string;
 	endTime?: string;
+	startEpoch?: number; // UTC ms corrected for the venue clock
+	endEpoch?: number;
 	detection: string; // relation → detections.id
 	race?: string; // relation → races.id
 	event?: string; // relation → events.id
@@ -164,6 +171,7 @@ export interface PBLapRecord extends PBBaseRecord {
 export interface PBGamePointRecord extends PBBaseRecord {
 	valid?: boolean;
 	time?: string;
+	timeEpoch?: number; // UTC ms corrected for the venue clock
 	pilot?: string; // relation → pilots.id
 	race?: string; // relation → races.id
 	channel?: string; // relation → channels.id
@@ -201,6 +209,44 @@ export interface PBControlStatsRecord extends PBBaseRecord {
 	lastUpdated?: string;
 }
 
+// control_stats_series (windowed control channel telemetry, one row per bucket/window/windowStart)
+export interface PBControlStatsSeriesRecord extends PBBaseRecord {
+	bucket: string;
+	window: '1m' | '5m' | '1h';
+	windowStart: number; // epoch ms
+	count?: number;
+	fullResponses?: number;
+	etagHits?: number;
+	errors?: number;
+	latencyP50?: number;
+	latencyP95?: number;
+	latencyP99?: number;
+	latencyMax?: number;
+	bytesTotal?: number;
+	bytesP50?: number;
+	bytesP95?: number;
+	bytesP99?: number;
+	latencyHist?: number[];
+	bytesHist?: number[];
+}
+
+// A control_stats_series point as /control/fetch-series returns it: stored
+// history overlaid with windows the cloud has not flushed yet.
+export type FetchSeriesPoint = Omit<PBControlStatsSeriesRecord, keyof PBBaseRecord>;
+
+// pits_status (control link presence per pits, written by the cloud hub)
+export interface PBPitsStatusRecord extends PBBaseRecord {
+	pitsId: string;
+	connected: boolean;
+	connectedAt?: string;
+	disconnectedAt?: string;
+	lastFrameAt?: string;
+	rttMs?: number;
+	clockOffsetMs?: number; // pits clock minus cloud clock
+	swVersion?: string;
+	protocol?: string;
+}
+
 // Convenience union for any PB record our API deals with
 export type AnyPBRecord =
 	| PBEventRecord
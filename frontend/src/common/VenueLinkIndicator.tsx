@@ -0,0 +1,35 @@
+import { useMemo } from 'react';
+import { useAtomValue } from 'jotai';
+import { pitsLinkDownAtom } from '../state/pbAtoms.ts';
+
+function formatSince(iso?: string): string {
+	if (!iso) return '';
+	const d = new Date(iso);
+	if (Number.isNaN(d.getTime())) return '';
+	return d.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+}
+
+// Shown when the venue's pits has lost its control link: results stop updating
+// until it reconnects, even though the realtime connection to us is fine.
+export function VenueLinkIndicator() {
+	const down = useAtomValue(pitsLinkDownAtom);
+
+	const { names, since } = useMemo(() => {
+		const latest = down.reduce<string | undefined>(
+			(acc, r) => (r.disconnectedAt && (!acc || r.disconnectedAt > acc) ? r.disconnectedAt : acc),
+			undefined,
+		);
+		return { names: down.map((r) => r.pitsId).join(', '), since: formatSince(latest) };
+	}, [down]);
+
+	if (down.length === 0) return null;
+
+	return (
+		<div className='venue-link-indicator' role='status' title={names}>
+			<span>Venue link down. Live results paused.</span>
+			{since && <span className='venue-link-indicator__since'>since {since}</span>}
+		</div>
+	);
+}
+
+export default VenueLinkIndicator;
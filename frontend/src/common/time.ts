@@ -23,6 +23,19 @@ export const parseTimestampMs = (value: unknown): number | null => {
 	return Number.isNaN(parsedWithT) ? null : parsedWithT;
 };
 
+/**
+ * Prefers the backend's normalized epoch (UTC, corrected for the venue clock)
+ * over parsing FPVTrackside's venue-local string, which is only right when the
+ * viewer shares the venue's time zone and the venue clock is accurate.
+ * @param epoch - The *Epoch field from the record (0 or missing when unknown)
+ * @param raw - The original timestamp string
+ * @returns Timestamp in milliseconds, or null if neither is usable
+ */
+export const venueTimestampMs = (epoch: number | null | undefined, raw: unknown): number | null => {
+	if (typeof epoch === 'number' && Number.isFinite(epoch) && epoch > 0) return epoch;
+	return parseTimestampMs(raw);
+};
+
 /**
  * Parses a timestamp value with a fallback default.
  * Useful when you need a non-null result for sorting/comparison.
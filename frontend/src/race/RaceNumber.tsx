@@ -3,7 +3,7 @@ import { useAtomValue } from 'jotai';
 import { roundsDataAtom, streamVideoRangesAtom } from '../state/index.ts';
 import { raceDataAtom } from './race-atoms.ts';
 import { buildStreamLinkForTimestamp } from '../stream/stream-utils.ts';
-import { parseTimestampMs } from '../common/time.ts';
+import { venueTimestampMs } from '../common/time.ts';
 
 interface RaceNumberProps {
 	raceId: string;
@@ -14,7 +14,7 @@ export function RaceNumber({ raceId }: RaceNumberProps) {
 	const race = useAtomValue(raceDataAtom(raceId));
 	const streamRanges = useAtomValue(streamVideoRangesAtom);
 
-	const raceStartMs = useMemo(() => parseTimestampMs(race?.start ?? null), [race?.start]);
+	const raceStartMs = useMemo(() => venueTimestampMs(race?.startEpoch, race?.start ?? null), [race?.startEpoch, race?.start]);
 	const raceStreamLink = useMemo(
 		() => buildStreamLinkForTimestamp(streamRanges, raceStartMs),
 		[streamRanges, raceStartMs],
@@ -3,7 +3,7 @@ import { useAtomValue } from 'jotai';
 import { currentEventAtom } from '../state/index.ts'; // PB current event
 import { currentRaceAtom } from './race-atoms.ts';
 import { secondsFromString } from '../common/index.ts'; // Adjusted path
-import { parseTimestampMs } from '../common/time.ts';
+import { venueTimestampMs } from '../common/time.ts';
 
 function RaceTime() {
 	const currentEvent = useAtomValue(currentEventAtom);
@@ -15,7 +15,7 @@ function RaceTime() {
 	useEffect(() => {
 		// Only start countdown if race has started
 		if (currentRace?.start && !currentRace.start.startsWith('0')) {
-			const parsedTimestamp = parseTimestampMs(currentRace.start);
+			const parsedTimestamp = venueTimestampMs(currentRace.startEpoch, currentRace.start);
 
 			if (parsedTimestamp === null) {
 				console.error('[RaceTime] Failed to parse timestamp:', {
@@ -42,7 +42,7 @@ function RaceTime() {
 		} else {
 			setTimeRemaining(raceLength);
 		}
-	}, [currentRace?.start, raceLength]);
+	}, [currentRace?.start, currentRace?.startEpoch, raceLength]);
 
 	return <div className='race-time'>{timeRemaining.toFixed(1)}</div>;
 }
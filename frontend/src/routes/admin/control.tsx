@@ -1,6 +1,7 @@
 import { createFileRoute } from '@tanstack/react-router';
 import { useAtomValue } from 'jotai';
 import { useMemo, useState } from 'react';
+import { FetchTrends } from '../../admin/FetchTrends.tsx';
 import type { PBControlStatsRecord } from '../../api/pbTypes.ts';
 import { controlStatsRecordsAtom } from '../../state/pbAtoms.ts';
 
@@ -115,6 +116,8 @@ function ControlPage() {
 					})}
 				</div>
 			</section>
+
+			<FetchTrends bucketLabel={formatBucketLabel} />
 		</div>
 	);
 }
@@ -2,7 +2,17 @@ import { createFileRoute, Link } from '@tanstack/react-router';
 import { useAtom, useAtomValue } from 'jotai';
 import { useEffect, useMemo } from 'react';
 import { GenericSuspense } from '../common/GenericSuspense.tsx';
-import { currentEventAtom, EVENT_SELECTION_CURRENT, eventsAtom, pbCurrentEventAtom, selectedEventIdAtom } from '../state/pbAtoms.ts';
+import {
+	currentEventAtom,
+	EVENT_SELECTION_CURRENT,
+	eventsAtom,
+	pbCurrentEventAtom,
+	pinnedEventAtom,
+	selectedEventIdAtom,
+	selectedVenueAtom,
+	VENUE_SELECTION_ANY,
+	venuesAtom,
+} from '../state/pbAtoms.ts';
 import type { PBEventRecord } from '../api/pbTypes.ts';
 import '../settings/SettingsPage.css';
 
@@ -22,6 +32,10 @@ function SettingsPage() {
 				</Link>
 			</header>
 
+			<GenericSuspense id='venue-selector'>
+				<VenueSelectionSection />
+			</GenericSuspense>
+
 			<GenericSuspense id='event-selector'>
 				<EventSelectionSection />
 			</GenericSuspense>
@@ -29,11 +43,51 @@ function SettingsPage() {
 	);
 }
 
+function VenueSelectionSection() {
+	const [selectedVenue, setSelectedVenue] = useAtom(selectedVenueAtom);
+	const venues = useAtomValue(venuesAtom);
+
+	useEffect(() => {
+		if (selectedVenue !== VENUE_SELECTION_ANY && venues.length > 0 && !venues.includes(selectedVenue)) {
+			setSelectedVenue(VENUE_SELECTION_ANY);
+		}
+	}, [selectedVenue, venues, setSelectedVenue]);
+
+	// A single venue has nothing to choose between.
+	if (venues.length < 2) return null;
+
+	return (
+		<section className='settings-card' aria-labelledby='settings-venue-heading'>
+			<h2 id='settings-venue-heading'>Venue</h2>
+			<p className='settings-help-text'>
+				This server follows several venues. Choose the one whose current event <strong>Current (auto)</strong> should show.
+			</p>
+			<label htmlFor='settings-venue-select' className='settings-label'>
+				Venue
+			</label>
+			<select
+				id='settings-venue-select'
+				className='settings-select'
+				value={selectedVenue}
+				onChange={(event) => setSelectedVenue(event.target.value)}
+			>
+				<option value={VENUE_SELECTION_ANY}>Any venue</option>
+				{venues.map((venue) => (
+					<option key={venue} value={venue}>
+						{venue}
+					</option>
+				))}
+			</select>
+		</section>
+	);
+}
+
 function EventSelectionSection() {
 	const [selectedEventId, setSelectedEventId] = useAtom(selectedEventIdAtom);
 	const events = useAtomValue(eventsAtom);
 	const activeEvent = useAtomValue(currentEventAtom);
 	const pbCurrentEvent = useAtomValue(pbCurrentEventAtom);
+	const pinnedEvent = useAtomValue(pinnedEventAtom);
 
 	const sortedEvents = useMemo(() => {
 		return [...events].sort((a, b) => {
@@ -91,6 +145,7 @@ function EventSelectionSection() {
 							{formatDateRange(activeEvent.start, activeEvent.end)}
 							{activeEvent.isCurrent && selectedEventId !== EVENT_SELECTION_CURRENT && <span className='settings-chip'>Auto-detected</span>}
 							{selectedEventId === EVENT_SELECTION_CURRENT && <span className='settings-chip'>Auto</span>}
+							{pinnedEvent?.id === activeEvent.id && <span className='settings-chip'>Archive (pinned)</span>}
 						</div>
 					</div>
 				)
@@ -1,15 +1,48 @@
 import { atom } from 'jotai';
 import { atomWithStorage } from 'jotai/utils';
 import { pbSubscribeCollection } from '../../api/pb.ts';
-import type { PBEventRecord } from '../../api/pbTypes.ts';
+import type { PBEventRecord, PBServerSettingRecord } from '../../api/pbTypes.ts';
 
 // Live events collection; we filter locally for the current event
 export const eventsAtom = pbSubscribeCollection<PBEventRecord>('events');
 
+// With several pits each venue has its own isCurrent event; viewers pick the
+// venue they follow (empty means whichever venue is current first).
+const VENUE_SELECTION_STORAGE_KEY = 'selected-venue';
+export const VENUE_SELECTION_ANY = '';
+
+export const selectedVenueAtom = atomWithStorage<string>(
+	VENUE_SELECTION_STORAGE_KEY,
+	VENUE_SELECTION_ANY,
+);
+
+export const venuesAtom = atom((get) => {
+	const ids = new Set<string>();
+	for (const event of get(eventsAtom)) {
+		if (event.pitsId) ids.add(event.pitsId);
+	}
+	return [...ids].sort();
+});
+
 export const pbCurrentEventAtom = atom((get) => {
-	const events = get(eventsAtom);
-	const currentEvent = events.find((event) => event.isCurrent);
-	return currentEvent || null;
+	const current = get(eventsAtom).filter((event) => event.isCurrent);
+	const venue = get(selectedVenueAtom);
+	if (venue !== VENUE_SELECTION_ANY) {
+		const match = current.find((event) => event.pitsId === venue);
+		if (match) return match;
+	}
+	return current[0] || null;
+});
+
+// An admin can pin the dashboard to an archived event (server_settings key
+// dashboard.pinnedEvent); isCurrent keeps following the live one.
+const PINNED_EVENT_KEY = 'dashboard.pinnedEvent';
+export const serverSettingsRecordsAtom = pbSubscribeCollection<PBServerSettingRecord>('server_settings');
+
+export const pinnedEventAtom = atom((get) => {
+	const pinnedId = get(serverSettingsRecordsAtom).find((r) => r.key === PINNED_EVENT_KEY)?.value;
+	if (!pinnedId) return null;
+	return get(eventsAtom).find((event) => event.id === pinnedId) ?? null;
 });
 
 const EVENT_SELECTION_STORAGE_KEY = 'selected-event-id';
@@ -22,11 +55,12 @@ export const selectedEventIdAtom = atomWithStorage<string>(
 
 export const currentEventAtom = atom((get) => {
 	const selection = get(selectedEventIdAtom);
-	if (selection === EVENT_SELECTION_CURRENT) return get(pbCurrentEventAtom);
+	const fallback = get(pinnedEventAtom) ?? get(pbCurrentEventAtom);
+	if (selection === EVENT_SELECTION_CURRENT) return fallback;
 	const events = get(eventsAtom);
 	const match = events.find((event) => event.id === selection);
 	if (match) return match;
-	return get(pbCurrentEventAtom);
+	return fallback;
 });
 
 export const consecutiveLapsAtom = atom((get) => {
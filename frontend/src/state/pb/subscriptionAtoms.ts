@@ -12,11 +12,11 @@ import {
 	PBLapRecord,
 	PBPilotChannelRecord,
 	PBPilotRecord,
+	PBPitsStatusRecord,
 	PBRaceRecord,
 	PBRoundRecord,
-	PBServerSettingRecord,
 } from '../../api/pbTypes.ts';
-import { currentEventAtom } from './eventAtoms.ts';
+import { currentEventAtom, serverSettingsRecordsAtom } from './eventAtoms.ts';
 
 // Pilots as PB records (no longer filtered by event directly)
 export const pilotsRecordsAtom = pbSubscribeCollection<PBPilotRecord>('pilots');
@@ -163,8 +163,43 @@ export const gamePointRecordsAtom = atom((get) => {
 
 // Ingest targets (live subscription)
 export const ingestTargetRecordsAtom = pbSubscribeCollection<PBIngestTargetRecord>('ingest_targets');
-export const serverSettingsRecordsAtom = pbSubscribeCollection<PBServerSettingRecord>('server_settings');
 export const controlStatsRecordsAtom = pbSubscribeCollection<PBControlStatsRecord>('control_stats');
+export const pitsStatusRecordsAtom = pbSubscribeCollection<PBPitsStatusRecord>('pits_status');
+
+// Pits whose control link to the cloud is down, limited to the venue of the shown
+// event when the cloud serves several; empty in standalone and pits mode.
+export const pitsLinkDownAtom = atom((get) => {
+	const pitsId = get(currentEventAtom)?.pitsId;
+	return get(pitsStatusRecordsAtom).filter((r) => !r.connected && (!pitsId || r.pitsId === pitsId));
+});
+
+// Circuit breaker state the backend publishes per ingest source under
+// server_settings keys ingest.sourceHealth[.<pitsId>].
+export interface SourceHealth {
+	source: string;
+	state: 'closed' | 'open' | 'halfOpen';
+	failures: number;
+	// coarse cause: 'unreachable' | 'timeout' | 'server error'
+	reason?: string;
+	changedAt: number;
+	retryAt?: number;
+}
+
+const SOURCE_HEALTH_KEY = 'ingest.sourceHealth';
+
+// Ingest sources whose timing system is not answering (breaker open or probing).
+export const unreachableSourcesAtom = atom((get) =>
+	get(serverSettingsRecordsAtom).flatMap((r): SourceHealth[] => {
+		if (r.key !== SOURCE_HEALTH_KEY && !r.key.startsWith(`${SOURCE_HEALTH_KEY}.`)) return [];
+		try {
+			const health = JSON.parse(r.value ?? '') as Omit<SourceHealth, 'source'>;
+			if (health.state === 'closed') return [];
+			return [{ ...health, source: r.key.slice(SOURCE_HEALTH_KEY.length + 1) }];
+		} catch {
+			return [];
+		}
+	})
+);
 
 export const DEFAULT_APP_TITLE = 'Drone Dashboard';
 
@@ -16,6 +16,7 @@ const MONITORED_COLLECTIONS = [
 	'ingest_targets',
 	'server_settings',
 	'control_stats',
+	'pits_status',
 ] as const;
 
 const statusAtoms = MONITORED_COLLECTIONS.map((collection) => ({
@@ -67,7 +67,12 @@ build_project() {
 	mkdir -p "${PROJECT_ROOT}/backend/build"
 	rm -f "${BINARY_PATH}"
 
-	if ! ( cd "$PROJECT_ROOT/backend" && GOOS=linux GOARCH=arm64 go build -ldflags="-s -w" -trimpath -o "${BINARY_PATH}" ); then
+	VERSION="${VERSION:-$(git -C "$PROJECT_ROOT" describe --tags --always --dirty 2>/dev/null || echo dev)}"
+	COMMIT="$(git -C "$PROJECT_ROOT" rev-parse --short HEAD 2>/dev/null)"
+	LDFLAGS="-s -w -X drone-dashboard/buildinfo.Version=${VERSION} -X drone-dashboard/buildinfo.Commit=${COMMIT}"
+	log "Version ${VERSION}"
+
+	if ! ( cd "$PROJECT_ROOT/backend" && GOOS=linux GOARCH=arm64 go build -ldflags="${LDFLAGS}" -trimpath -o "${BINARY_PATH}" ); then
 		error "Backend build failed."
 		exit 1
 	fi